@@ -0,0 +1,351 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// identifies the dataset file format, followed by the records
+	magic = "HIBPSHA1"
+	// SHA-1 hash followed by a big endian uint32 breach count
+	recordSize = sha1.Size + 4
+	// hex characters of the hash that name a range file
+	prefixLen = 5
+)
+
+var (
+	// ErrNoDataset is returned when no dataset is configured.
+	ErrNoDataset = errors.New("no breach dataset configured")
+	// ErrInvalidDataset is returned when a dataset file or import source is malformed.
+	ErrInvalidDataset = errors.New("invalid breach dataset")
+)
+
+// Dataset is an open dataset file of sorted SHA-1 hashes and breach counts, searched with
+// binary search so it never has to fit in memory.
+type Dataset struct {
+	file    *os.File
+	records int64
+}
+
+// Open opens a dataset file written by Import.
+func Open(path string) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != magic {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s is not a dataset file", ErrInvalidDataset, path)
+	}
+
+	size := info.Size() - int64(len(magic))
+	if size%recordSize != 0 {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s is truncated", ErrInvalidDataset, path)
+	}
+
+	return &Dataset{file: f, records: size / recordSize}, nil
+}
+
+// Len returns the number of hashes in the dataset.
+func (d *Dataset) Len() int64 {
+	return d.records
+}
+
+// Count returns how many times the value appears in breaches, 0 if it doesn't.
+func (d *Dataset) Count(value string) (int, error) {
+	return d.CountHash(sha1.Sum([]byte(value)))
+}
+
+// CountHash returns how many times the value with the SHA-1 hash appears in breaches.
+func (d *Dataset) CountHash(hash [sha1.Size]byte) (int, error) {
+	record := make([]byte, recordSize)
+	low, high := int64(0), d.records
+
+	for low < high {
+		mid := low + (high-low)/2
+
+		if _, err := d.file.ReadAt(record, int64(len(magic))+mid*recordSize); err != nil {
+			return 0, err
+		}
+
+		switch bytes.Compare(record[:sha1.Size], hash[:]) {
+		case 0:
+			return int(binary.BigEndian.Uint32(record[sha1.Size:])), nil
+		case -1:
+			low = mid + 1
+		default:
+			high = mid
+		}
+	}
+
+	return 0, nil
+}
+
+func (d *Dataset) Close() error {
+	return d.file.Close()
+}
+
+// Checker looks values up in the dataset at a path, reopening it when the file is replaced so
+// an updated dataset is used without a restart.
+type Checker struct {
+	path string
+
+	mu      sync.Mutex
+	dataset *Dataset
+	modTime time.Time
+	size    int64
+}
+
+// NewChecker returns a checker for the dataset at path. The file is opened on first use. An
+// empty path disables checks, they return ErrNoDataset.
+func NewChecker(path string) *Checker {
+	return &Checker{path: path}
+}
+
+// Enabled reports whether a dataset is configured.
+func (c *Checker) Enabled() bool {
+	return c.path != ""
+}
+
+// Count returns how many times the value appears in breaches, 0 if it doesn't.
+func (c *Checker) Count(value string) (int, error) {
+	dataset, err := c.open()
+	if err != nil {
+		return 0, err
+	}
+
+	return dataset.Count(value)
+}
+
+func (c *Checker) open() (*Dataset, error) {
+	if c.path == "" {
+		return nil, ErrNoDataset
+	}
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dataset != nil && info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.dataset, nil
+	}
+
+	dataset, err := Open(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	// lookups in flight on the replaced dataset fail once and are retried on the next check
+	if c.dataset != nil {
+		c.dataset.Close()
+	}
+
+	c.dataset, c.modTime, c.size = dataset, info.ModTime(), info.Size()
+
+	return dataset, nil
+}
+
+func (c *Checker) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dataset == nil {
+		return nil
+	}
+
+	err := c.dataset.Close()
+	c.dataset = nil
+
+	return err
+}
+
+// Import writes a dataset file to dst from a Have I Been Pwned SHA-1 source: either a text file
+// of HASH:COUNT lines ordered by hash, or a directory of range files named by their 5 character
+// hash prefix (with or without an extension) holding SUFFIX:COUNT lines. The file is written
+// next to dst and renamed over it, so a running Checker switches to it atomically.
+// Returns the number of hashes written.
+func Import(dst string, src string) (int64, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := &writer{w: bufio.NewWriterSize(tmp, 1<<20)}
+	if _, err := w.w.WriteString(magic); err != nil {
+		return 0, err
+	}
+
+	if info.IsDir() {
+		err = importRanges(w, src)
+	} else {
+		err = importFile(w, src, "")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.w.Flush(); err != nil {
+		return 0, err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return 0, err
+	}
+
+	return w.records, nil
+}
+
+// importRanges imports the range files of a directory in hash order.
+func importRanges(w *writer, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		prefix := strings.ToUpper(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		if entry.IsDir() || len(prefix) != prefixLen {
+			continue
+		}
+
+		if _, err := hex.DecodeString(prefix + "0"); err != nil {
+			continue
+		}
+
+		if err := importFile(w, filepath.Join(dir, entry.Name()), prefix); err != nil {
+			return err
+		}
+	}
+
+	if w.records == 0 {
+		return fmt.Errorf("%w: no range files found in %s", ErrInvalidDataset, dir)
+	}
+
+	return nil
+}
+
+// importFile imports the HASH:COUNT lines of a file, with the hash prefix prepended to each.
+func importFile(w *writer, path string, prefix string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		hash, count, err := parseLine(prefix, text)
+		if err != nil {
+			return fmt.Errorf("%w: %s line %d: %s", ErrInvalidDataset, path, line, err)
+		}
+
+		if err := w.write(hash, count); err != nil {
+			return fmt.Errorf("%w: %s line %d: %s", ErrInvalidDataset, path, line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func parseLine(prefix string, line string) ([sha1.Size]byte, uint32, error) {
+	var hash [sha1.Size]byte
+
+	rawHash, rawCount, _ := strings.Cut(line, ":")
+	rawHash = prefix + rawHash
+
+	if len(rawHash) != hex.EncodedLen(sha1.Size) {
+		return hash, 0, errors.New("expected a SHA-1 hash")
+	}
+
+	if _, err := hex.Decode(hash[:], []byte(rawHash)); err != nil {
+		return hash, 0, errors.New("expected a SHA-1 hash")
+	}
+
+	count := uint64(1)
+	if rawCount != "" {
+		val, err := strconv.ParseUint(rawCount, 10, 64)
+		if err != nil {
+			return hash, 0, errors.New("expected a count")
+		}
+		count = val
+	}
+
+	return hash, uint32(min(count, math.MaxUint32)), nil
+}
+
+// writer writes records and checks they are strictly ascending, which binary search relies on.
+type writer struct {
+	w       *bufio.Writer
+	last    [sha1.Size]byte
+	records int64
+}
+
+func (w *writer) write(hash [sha1.Size]byte, count uint32) error {
+	if w.records > 0 && bytes.Compare(hash[:], w.last[:]) <= 0 {
+		return errors.New("hashes must be sorted and unique")
+	}
+
+	if _, err := w.w.Write(hash[:]); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w.w, binary.BigEndian, count); err != nil {
+		return err
+	}
+
+	w.last = hash
+	w.records++
+
+	return nil
+}
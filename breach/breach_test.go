@@ -0,0 +1,160 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeHashFile writes the values as an ordered HASH:COUNT file with counts of 1, 2, 3...
+func writeHashFile(t *testing.T, path string, values ...string) map[string]int {
+	t.Helper()
+
+	counts := make(map[string]int)
+	var lines []string
+	for i, value := range values {
+		counts[value] = i + 1
+		lines = append(lines, sha1Hex(value)+":"+strconv.Itoa(i+1))
+	}
+	sort.Strings(lines)
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return counts
+}
+
+func TestImportFileAndCount(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pwned.txt")
+	dst := filepath.Join(dir, "pwned.bin")
+	counts := writeHashFile(t, src, "password", "123456", "hunter2", "letmein")
+
+	n, err := Import(dst, src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if n != int64(len(counts)) {
+		t.Fatalf("Expected %d hashes, got %d", len(counts), n)
+	}
+
+	dataset, err := Open(dst)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer dataset.Close()
+
+	for value, want := range counts {
+		if got, err := dataset.Count(value); err != nil || got != want {
+			t.Errorf("%q: expected count %d, got %d (%v)", value, want, got, err)
+		}
+	}
+
+	if got, err := dataset.Count("correct horse battery staple"); err != nil || got != 0 {
+		t.Fatalf("Expected an unknown value to have count 0, got %d (%v)", got, err)
+	}
+}
+
+func TestImportRanges(t *testing.T) {
+	dir := t.TempDir()
+	ranges := filepath.Join(dir, "ranges")
+	if err := os.Mkdir(ranges, 0700); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	byPrefix := make(map[string][]string)
+	for _, value := range []string{"password", "123456", "hunter2"} {
+		hash := sha1Hex(value)
+		byPrefix[hash[:prefixLen]] = append(byPrefix[hash[:prefixLen]], hash[prefixLen:]+":7")
+	}
+
+	for prefix, lines := range byPrefix {
+		sort.Strings(lines)
+		if err := os.WriteFile(filepath.Join(ranges, prefix+".txt"), []byte(strings.Join(lines, "\n")), 0600); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	dst := filepath.Join(dir, "pwned.bin")
+	if _, err := Import(dst, ranges); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	checker := NewChecker(dst)
+	defer checker.Close()
+
+	if got, err := checker.Count("hunter2"); err != nil || got != 7 {
+		t.Fatalf("Expected count 7, got %d (%v)", got, err)
+	}
+}
+
+func TestImportRejectsUnsortedInput(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pwned.txt")
+	dst := filepath.Join(dir, "pwned.bin")
+
+	lines := []string{sha1Hex("password"), sha1Hex("123456")}
+	sort.Sort(sort.Reverse(sort.StringSlice(lines)))
+	if err := os.WriteFile(src, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := Import(dst, src); !errors.Is(err, ErrInvalidDataset) {
+		t.Fatalf("Expected ErrInvalidDataset, got %v", err)
+	}
+
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("Expected no dataset to be written, got %v", err)
+	}
+}
+
+func TestCheckerReloadsReplacedDataset(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pwned.txt")
+	dst := filepath.Join(dir, "pwned.bin")
+
+	writeHashFile(t, src, "password")
+	if _, err := Import(dst, src); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	checker := NewChecker(dst)
+	defer checker.Close()
+
+	if got, _ := checker.Count("hunter2"); got != 0 {
+		t.Fatalf("Expected count 0 before the update, got %d", got)
+	}
+
+	writeHashFile(t, src, "password", "hunter2")
+	if _, err := Import(dst, src); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if got, err := checker.Count("hunter2"); err != nil || got != 2 {
+		t.Fatalf("Expected count 2 after the update, got %d (%v)", got, err)
+	}
+}
+
+func TestCheckerWithoutDataset(t *testing.T) {
+	checker := NewChecker("")
+	if checker.Enabled() {
+		t.Fatal("Expected a checker without a path to be disabled")
+	}
+
+	if _, err := checker.Count("password"); !errors.Is(err, ErrNoDataset) {
+		t.Fatalf("Expected ErrNoDataset, got %v", err)
+	}
+}
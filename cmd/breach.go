@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/oalexander6/web-app-template/breach"
+)
+
+var breachCommand = &command{
+	Name:    "breach",
+	Usage:   "breach <import|scan>",
+	Summary: "Manage the offline breached password dataset.",
+	Subcommands: []*command{
+		{
+			Name:    "import",
+			Usage:   "breach import -i PATH [-o FILE]",
+			Summary: "Convert Have I Been Pwned SHA-1 hashes, ordered by hash or as a directory of range files, into a dataset file.",
+			Run:     runBreachImport,
+		},
+		{
+			Name:    "scan",
+			Usage:   "breach scan",
+			Summary: "Check every note against the dataset and update which notes are marked compromised.",
+			Run:     runBreachScan,
+		},
+	},
+}
+
+func runBreachImport(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	input := fs.String("i", "", "HASH:COUNT file ordered by hash, or a directory of range files")
+	output := fs.String("o", "", "dataset file to write, replaced atomically (default $BREACH_DATASET_PATH)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *input == "" {
+		fs.Usage()
+		return fmt.Errorf("%w: an input file or directory is required", errUsage)
+	}
+
+	if *output == "" {
+		c, err := loadConfig(os.Stderr)
+		if err != nil {
+			return err
+		}
+		*output = c.Breach.DatasetPath
+	}
+
+	if *output == "" {
+		fs.Usage()
+		return fmt.Errorf("%w: an output file is required when BREACH_DATASET_PATH is not set", errUsage)
+	}
+
+	count, err := breach.Import(*output, *input)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d hashes into %s. Run breach scan to re-check existing notes.\n", count, *output)
+
+	return nil
+}
+
+func runBreachScan(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	report, err := m.NoteScanBreaches(cliContext())
+	fmt.Fprintf(os.Stderr, "Checked %d notes, %d compromised, %d skipped.\n", report.Checked, report.Compromised, report.Skipped)
+
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oalexander6/web-app-template/models"
+)
+
+var doctorCommand = &command{
+	Name:    "doctor",
+	Usage:   "doctor [-sample N]",
+	Summary: "Validate the deployment: config, database, schema version, encryption key and note decryptability.",
+	Run:     runDoctor,
+}
+
+// errDoctorFailed is returned when any check fails so the command exits non-zero.
+var errDoctorFailed = errors.New("one or more checks failed")
+
+func runDoctor(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	sample := fs.Int("sample", 20, "number of random notes to test decrypt")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c, err := loadConfig(os.Stderr)
+	if err != nil {
+		printFinding(models.DoctorFinding{Check: "config", Status: models.DOCTOR_STATUS_FAIL, Message: err.Error(), Action: "Fix the listed settings in the environment or .env file."})
+		return errDoctorFailed
+	}
+
+	s, err := openStore(c, false)
+	if err != nil {
+		printFinding(models.DoctorFinding{Check: "store", Status: models.DOCTOR_STATUS_FAIL, Message: err.Error(), Action: "Check DB_URI and that the database is reachable from this host."})
+		return errDoctorFailed
+	}
+	defer s.Close()
+
+	report := models.New(s, c).Doctor(cliContext(), models.DoctorOptions{SampleSize: *sample})
+	for _, finding := range report.Findings {
+		printFinding(finding)
+	}
+
+	if !report.Healthy {
+		return errDoctorFailed
+	}
+
+	return nil
+}
+
+func printFinding(finding models.DoctorFinding) {
+	fmt.Printf("%-5s %s: %s\n", strings.ToUpper(finding.Status), finding.Check, finding.Message)
+	if finding.Action != "" {
+		fmt.Printf("      -> %s\n", finding.Action)
+	}
+}
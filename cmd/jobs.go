@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/models"
+	"github.com/oalexander6/web-app-template/queue"
+	"github.com/oalexander6/web-app-template/scheduler"
+)
+
+// registerJobs adds the periodic maintenance jobs run by the server.
+func registerJobs(jobs *scheduler.Scheduler, c *config.Config, m *models.Models) error {
+	err := jobs.Register(scheduler.Job{
+		Name:     "trash-purge",
+		Schedule: c.Notes.TrashPurgeSchedule,
+		Jitter:   time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := m.NotePurgeExpired(jobContext(ctx, "trash-purge"))
+			return err
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = jobs.Register(scheduler.Job{
+		Name:     "queue-cleanup",
+		Schedule: "@daily",
+		Jitter:   10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := m.JobDeleteFinished(jobContext(ctx, "queue-cleanup"), c.Queue.Retention)
+			return err
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = jobs.Register(scheduler.Job{
+		Name:     "expiry-reminders",
+		Schedule: c.Notes.ExpiryReminderSchedule,
+		Jitter:   5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := m.NoteSendExpiryReminders(jobContext(ctx, "expiry-reminders"))
+			return err
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = jobs.Register(scheduler.Job{
+		Name:     "note-rotation",
+		Schedule: c.Notes.RotationSchedule,
+		Jitter:   time.Minute,
+		Timeout:  15 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := m.NoteRotateDue(jobContext(ctx, "note-rotation"), c.Notes.RotationDryRun)
+			return err
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// repairing only helps when there are previous keys to try
+	if len(c.Encryption.Previous) > 0 {
+		err := jobs.Register(scheduler.Job{
+			Name:     "note-repair",
+			Schedule: c.Notes.RepairSchedule,
+			Jitter:   time.Minute,
+			Timeout:  30 * time.Minute,
+			Run: func(ctx context.Context) error {
+				_, err := m.NoteRepairQuarantined(jobContext(ctx, "note-repair"))
+				return err
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registerQueueHandlers adds the handlers for queued background jobs.
+func registerQueueHandlers(pool *queue.Pool, m *models.Models) {
+	pool.Handle(models.JOB_KIND_NOTE_IMPORT, asJobCreator(m.NoteImportRun))
+	pool.Handle(models.JOB_KIND_AUDIT_VERIFY, asJobCreator(m.AuditVerifyRun))
+}
+
+// asJobCreator runs a queued job as the user who queued it, so it has their permissions and its
+// changes are attributed to them in the audit log. Jobs queued by a system actor run as one.
+func asJobCreator(handler queue.Handler) queue.Handler {
+	return func(ctx context.Context, job models.Job, progress func(int, string)) (string, error) {
+		ctx = models.WithActor(ctx, models.Actor{UserID: job.CreatedBy, Username: "system:queue", System: job.CreatedBy == 0})
+		return handler(ctx, job, progress)
+	}
+}
+
+// jobContext runs a job as a system actor, attributing its changes in the audit log.
+func jobContext(ctx context.Context, job string) context.Context {
+	return models.WithActor(ctx, models.SystemActor("system:"+job))
+}
+
+// schedulerCoordinator returns the store's coordinator when it can lock across instances,
+// otherwise jobs are only coordinated within this process.
+func schedulerCoordinator(s models.Store) scheduler.Coordinator {
+	if coordinator, ok := s.(scheduler.Coordinator); ok {
+		return coordinator
+	}
+
+	return nil
+}
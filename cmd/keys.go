@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+var keysCommand = &command{
+	Name:    "keys",
+	Usage:   "keys <rotate>",
+	Summary: "Manage note encryption keys.",
+	Subcommands: []*command{
+		{
+			Name:    "rotate",
+			Usage:   "keys rotate [-new-secret VALUE] [-new-iv VALUE]",
+			Summary: "Re-encrypt every note with a new key. Update ENCRYPTION_SECRET and ENCRYPTION_IV afterwards.",
+			Run:     runKeysRotate,
+		},
+	},
+}
+
+func runKeysRotate(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	newSecret := fs.String("new-secret", os.Getenv("NEW_ENCRYPTION_SECRET"), "new 32 byte key or secret:// reference (default $NEW_ENCRYPTION_SECRET)")
+	newIV := fs.String("new-iv", os.Getenv("NEW_ENCRYPTION_IV"), "new 16 byte IV or secret:// reference (default $NEW_ENCRYPTION_IV)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *newSecret == "" || *newIV == "" {
+		fs.Usage()
+		return fmt.Errorf("%w: a new secret and IV are required", errUsage)
+	}
+
+	ctx := cliContext()
+
+	resolvedSecret, err := config.DefaultSecretResolver().Resolve(ctx, *newSecret)
+	if err != nil {
+		return err
+	}
+
+	resolvedIV, err := config.DefaultSecretResolver().Resolve(ctx, *newIV)
+	if err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	count, err := m.NoteRotateKeys(ctx, config.EncryptionConfig{EncSecret: resolvedSecret, EncIV: resolvedIV})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-encrypted %d notes. Update ENCRYPTION_SECRET and ENCRYPTION_IV before restarting the server.\n", count)
+
+	return nil
+}
@@ -1,38 +1,208 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"os/user"
 
 	"github.com/oalexander6/web-app-template/config"
-	"github.com/oalexander6/web-app-template/httpserver"
 	"github.com/oalexander6/web-app-template/logger"
 	"github.com/oalexander6/web-app-template/models"
 	"github.com/oalexander6/web-app-template/store/postgres"
 	"github.com/rs/zerolog"
 )
 
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errUsage is returned by commands when they are invoked with invalid arguments.
+var errUsage = errors.New("invalid usage")
+
+// command is a CLI subcommand. Commands with subcommands dispatch on their first argument.
+type command struct {
+	Name        string
+	Usage       string
+	Summary     string
+	Run         func(cmd *command, args []string) error
+	Subcommands []*command
+}
+
+var commands = []*command{
+	serveCommand,
+	migrateCommand,
+	keysCommand,
+	notesCommand,
+	userCommand,
+	doctorCommand,
+	auditCommand,
+	breachCommand,
+}
+
 func main() {
-	logger.Init(zerolog.DebugLevel, os.Stdout)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to the requested command and maps its result to an exit code. With no
+// arguments the server is started, matching the behavior before subcommands existed.
+func run(args []string) int {
+	if len(args) == 0 {
+		args = []string{serveCommand.Name}
+	}
+
+	err := dispatch(commands, args, "")
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitError
+	}
+}
+
+func dispatch(cmds []*command, args []string, parent string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage(os.Stderr, cmds, parent)
+		if len(args) == 0 {
+			return errUsage
+		}
+		return flag.ErrHelp
+	}
+
+	for _, cmd := range cmds {
+		if cmd.Name != args[0] {
+			continue
+		}
+
+		if len(cmd.Subcommands) > 0 {
+			return dispatch(cmd.Subcommands, args[1:], parent+cmd.Name+" ")
+		}
+
+		return cmd.Run(cmd, args[1:])
+	}
+
+	printUsage(os.Stderr, cmds, parent)
+
+	return fmt.Errorf("%w: unknown command %q", errUsage, parent+args[0])
+}
+
+func printUsage(w io.Writer, cmds []*command, parent string) {
+	fmt.Fprintf(w, "Usage: webapp %s<command> [flags]\n\nCommands:\n", parent)
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(w, "\nRun 'webapp %s<command> --help' for details.\n", parent)
+}
+
+// newFlagSet creates a flag set for a command that prints the command's usage on --help and
+// reports parse errors as usage errors.
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: webapp %s\n\n%s\n", cmd.Usage, cmd.Summary)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseFlags parses args and rejects unexpected positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %s", errUsage, err)
+	}
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, fs.Args())
+	}
+
+	return nil
+}
+
+// loadConfig loads and validates the config and initializes the logger from it. Logs are
+// written to logWriter so commands can keep stdout for their own output.
+func loadConfig(logWriter io.Writer) (c *config.Config, err error) {
+	logger.Init(logger.Options{Level: zerolog.InfoLevel, Writer: logWriter})
+
+	// config.New panics when secrets can't be loaded, report that as a normal error
+	defer func() {
+		if r := recover(); r != nil {
+			c, err = nil, fmt.Errorf("failed to load configuration: %v", r)
+		}
+	}()
 
-	c := config.New()
+	c = config.New()
 	if err := c.Validate(); err != nil {
-		logger.Log.Fatal().Msgf("Invalid configuration: %s", err.Error())
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	logger.Log.Info().Interface("config", c).Msg("Config initialized")
+	logOpts, err := c.LoggerOptions()
+	if err != nil {
+		return nil, fmt.Errorf("invalid log configuration: %w", err)
+	}
+	logOpts.Writer = logWriter
+
+	if err := logger.Init(logOpts); err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
 
-	var s models.Store
+	logger.Log.Debug().Interface("config", c).Msg("Config initialized")
 
+	return c, nil
+}
+
+// openStore connects to the configured store. Pending migrations are applied when migrate
+// is true.
+func openStore(c *config.Config, migrate bool) (models.Store, error) {
 	switch c.StoreType {
 	case config.STORE_TYPE_POSTGRES:
-		s = postgres.New(c.PostgresOpts)
+		if migrate {
+			return postgres.New(c.PostgresOpts), nil
+		}
+		return postgres.Open(c.PostgresOpts)
 	default:
-		logger.Log.Fatal().Msgf("Invalid store type: %s", c.StoreType)
+		return nil, fmt.Errorf("invalid store type: %s", c.StoreType)
+	}
+}
+
+// setup loads the config and connects to the store, applying pending migrations. The returned
+// store must be closed by the caller.
+func setup(logWriter io.Writer) (*config.Config, models.Store, *models.Models, error) {
+	c, err := loadConfig(logWriter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	s, err := openStore(c, true)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	defer s.Close()
+	return c, s, models.New(s, c), nil
+}
 
-	app := httpserver.New(c, *models.New(s, c))
+// cliContext returns a context carrying a system actor for the local OS user. Whoever can run
+// the CLI has access to the store's credentials, so it may do anything, and its changes are
+// attributed in the audit log.
+func cliContext() context.Context {
+	name := "cli"
+	if u, err := user.Current(); err == nil {
+		name = "cli:" + u.Username
+	}
 
-	app.Run()
+	return models.WithActor(context.Background(), models.SystemActor(name))
 }
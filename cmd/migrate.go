@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/oalexander6/web-app-template/models"
+)
+
+var migrateCommand = &command{
+	Name:    "migrate",
+	Usage:   "migrate <up|down|status>",
+	Summary: "Manage the database schema.",
+	Subcommands: []*command{
+		{Name: "up", Usage: "migrate up", Summary: "Apply all pending migrations.", Run: runMigrateUp},
+		{Name: "down", Usage: "migrate down [-steps N]", Summary: "Revert the most recent migrations.", Run: runMigrateDown},
+		{Name: "status", Usage: "migrate status", Summary: "List migrations and whether they are applied.", Run: runMigrateStatus},
+	},
+}
+
+// migrateSetup loads the config and opens the store without applying migrations.
+func migrateSetup() (*models.Models, func(), error) {
+	c, err := loadConfig(os.Stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := openStore(c, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return models.New(s, c), s.Close, nil
+}
+
+func runMigrateUp(cmd *command, args []string) error {
+	if err := parseFlags(newFlagSet(cmd), args); err != nil {
+		return err
+	}
+
+	m, closeStore, err := migrateSetup()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	applied, err := m.MigrateUp(cliContext())
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("No pending migrations.")
+		return nil
+	}
+
+	printMigrations(applied)
+
+	return nil
+}
+
+func runMigrateDown(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	steps := fs.Int("steps", 1, "number of migrations to revert")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *steps < 1 {
+		return fmt.Errorf("%w: -steps must be at least 1", errUsage)
+	}
+
+	m, closeStore, err := migrateSetup()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	reverted, err := m.MigrateDown(cliContext(), *steps)
+	if err != nil {
+		return err
+	}
+
+	if len(reverted) == 0 {
+		fmt.Println("No applied migrations to revert.")
+		return nil
+	}
+
+	printMigrations(reverted)
+
+	return nil
+}
+
+func runMigrateStatus(cmd *command, args []string) error {
+	if err := parseFlags(newFlagSet(cmd), args); err != nil {
+		return err
+	}
+
+	m, closeStore, err := migrateSetup()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	statuses, err := m.MigrationStatus(cliContext())
+	if err != nil {
+		return err
+	}
+
+	printMigrations(statuses)
+
+	return nil
+}
+
+func printMigrations(statuses []models.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED\tAPPLIED AT")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%d\t%s\t%t\t%s\n", s.Version, s.Name, s.Applied, s.AppliedAt)
+	}
+	w.Flush()
+}
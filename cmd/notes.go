@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/oalexander6/web-app-template/models"
+)
+
+var notesCommand = &command{
+	Name:    "notes",
+	Usage:   "notes <export|import|repair|rotate|encrypt-names|encrypt-fields>",
+	Summary: "Export, import, repair, rotate notes and encrypt their names and fields.",
+	Subcommands: []*command{
+		{
+			Name:    "export",
+			Usage:   "notes export [-o FILE]",
+			Summary: "Write every note as JSON lines with plaintext values.",
+			Run:     runNotesExport,
+		},
+		{
+			Name:    "import",
+			Usage:   "notes import [-i FILE] [-on-conflict rename|replace]",
+			Summary: "Create notes from JSON lines produced by notes export.",
+			Run:     runNotesImport,
+		},
+		{
+			Name:    "repair",
+			Usage:   "notes repair",
+			Summary: "Retry quarantined notes with every key in the keyring and re-encrypt them with the current key.",
+			Run:     runNotesRepair,
+		},
+		{
+			Name:    "rotate",
+			Usage:   "notes rotate [-dry-run]",
+			Summary: "Regenerate every note whose rotation policy is due.",
+			Run:     runNotesRotate,
+		},
+		{
+			Name:    "encrypt-names",
+			Usage:   "notes encrypt-names",
+			Summary: "Encrypt the names of notes and versions saved before NOTE_ENCRYPT_NAMES was enabled.",
+			Run:     runNotesEncryptNames,
+		},
+		{
+			Name:    "encrypt-fields",
+			Usage:   "notes encrypt-fields",
+			Summary: "Encrypt the fields of notes and versions saved as plaintext before they were encrypted.",
+			Run:     runNotesEncryptFields,
+		},
+	},
+}
+
+func runNotesExport(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	output := fs.String("o", "", "file to write to, created with mode 0600 (default stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	count, err := m.NoteExport(cliContext(), w)
+	fmt.Fprintf(os.Stderr, "Exported %d notes.\n", count)
+
+	return err
+}
+
+func runNotesImport(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	input := fs.String("i", "", "file to read from (default stdin)")
+	vault := fs.Int64("vault", 0, "ID of the vault to import into (required)")
+	onConflict := fs.String("on-conflict", "", "rename records whose name is taken or replace the existing note (default fail)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	result, err := m.NoteImport(cliContext(), r, *vault, *onConflict)
+	fmt.Fprintf(os.Stderr, "Imported %d notes (%d renamed, %d replaced).\n", result.Imported, result.Renamed, result.Replaced)
+
+	return err
+}
+
+func runNotesRepair(cmd *command, args []string) error {
+	if err := parseFlags(newFlagSet(cmd), args); err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	report, err := m.NoteRepairQuarantined(cliContext())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Repaired %d notes %v.\n", len(report.Repaired), report.Repaired)
+
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d notes could not be decrypted with any keyring key: %v", len(report.Failed), report.Failed)
+	}
+
+	return nil
+}
+
+func runNotesRotate(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	dryRun := fs.Bool("dry-run", false, "report and audit due rotations without changing values")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	results, err := m.NoteRotateDue(cliContext(), *dryRun)
+	for _, result := range results {
+		fmt.Printf("%d\t%s\tnext %s\n", result.NoteID, result.Name, result.NextRotationAt)
+	}
+
+	verb := "Rotated"
+	if *dryRun {
+		verb = "Would rotate"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d notes.\n", verb, len(results))
+
+	return err
+}
+
+func runNotesEncryptNames(cmd *command, args []string) error {
+	if err := parseFlags(newFlagSet(cmd), args); err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	count, err := m.NoteEncryptNames(cliContext())
+	if err != nil {
+		if errors.Is(err, models.ErrAlreadyExists) {
+			return fmt.Errorf("%w: rename one of the notes sharing a name in the same folder and retry", err)
+		}
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Encrypted the names of %d notes.\n", count)
+
+	return nil
+}
+
+func runNotesEncryptFields(cmd *command, args []string) error {
+	if err := parseFlags(newFlagSet(cmd), args); err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	count, err := m.NoteEncryptFields(cliContext())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Encrypted the fields of %d notes.\n", count)
+
+	return nil
+}
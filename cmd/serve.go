@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/httpserver"
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/queue"
+	"github.com/oalexander6/web-app-template/scheduler"
+	"github.com/rs/zerolog"
+)
+
+var serveCommand = &command{
+	Name:    "serve",
+	Usage:   "serve",
+	Summary: "Apply pending migrations and start the HTTP server.",
+	Run:     runServe,
+}
+
+func runServe(cmd *command, args []string) error {
+	if err := parseFlags(newFlagSet(cmd), args); err != nil {
+		return err
+	}
+
+	c, s, m, err := setup(os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := m.EnsureCanary(jobContext(context.Background(), "startup")); err != nil {
+		logger.Log.Error().Err(err).Msg("Encryption canary check failed, run 'webapp doctor'")
+	}
+
+	runtime, err := config.NewReloader(c.Runtime, config.ENV_FILE)
+	if err != nil {
+		return err
+	}
+
+	runtime.OnReload(func(rt *config.Runtime) {
+		level, err := zerolog.ParseLevel(rt.LogLevel)
+		if err != nil {
+			logger.Log.Error().Msgf("Invalid log level: %s", rt.LogLevel)
+			return
+		}
+		logger.SetLevel(level)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go runtime.Watch(ctx)
+
+	jobs := scheduler.New(schedulerCoordinator(s))
+	if err := registerJobs(jobs, c, m); err != nil {
+		return err
+	}
+	jobs.Start(ctx)
+
+	workers := queue.NewPool(m, queue.Options{Workers: c.Queue.Workers})
+	registerQueueHandlers(workers, m)
+	if c.Queue.Workers > 0 {
+		workers.Start(jobContext(ctx, "queue"))
+	}
+
+	app := httpserver.New(c, runtime, *m)
+
+	app.Run()
+
+	// stop scheduled jobs and queue workers once the server has drained
+	cancel()
+	jobs.Wait()
+	workers.Wait()
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+var userCommand = &command{
+	Name:    "user",
+	Usage:   "user <create>",
+	Summary: "Manage users.",
+	Subcommands: []*command{
+		{
+			Name:    "create",
+			Usage:   "user create -username NAME [-password-file FILE]",
+			Summary: "Create a user. The password is read from -password-file, $USER_PASSWORD, or the first line of stdin.",
+			Run:     runUserCreate,
+		},
+	},
+}
+
+func runUserCreate(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	username := fs.String("username", "", "username for the new user")
+	passwordFile := fs.String("password-file", "", "file containing the password")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *username == "" {
+		fs.Usage()
+		return fmt.Errorf("%w: -username is required", errUsage)
+	}
+
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return err
+	}
+
+	_, s, m, err := setup(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	user, err := m.UserCreate(cliContext(), models.UserCreateParams{Username: *username, Password: password})
+	if err != nil {
+		if errors.Is(err, models.ErrAlreadyExists) {
+			return fmt.Errorf("user %q already exists", *username)
+		}
+		return err
+	}
+
+	fmt.Printf("Created user %s with id %d.\n", user.Username, user.ID)
+
+	return nil
+}
+
+// readPassword reads the password from a file, the USER_PASSWORD variable, or stdin.
+func readPassword(path string) (string, error) {
+	if path != "" {
+		return config.FileSecretProvider{}.GetSecret(context.Background(), path)
+	}
+
+	if password := os.Getenv("USER_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
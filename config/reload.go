@@ -0,0 +1,261 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+	"github.com/oalexander6/web-app-template/logger"
+)
+
+const defaultReloadPollInterval = 5 * time.Second
+
+// Runtime is a validated, immutable snapshot of the reloadable configuration.
+type Runtime struct {
+	RuntimeConfig
+	// loaded TLS certificate, nil when TLS is disabled
+	Certificate *tls.Certificate
+	// incremented on every successful reload
+	Generation int64
+}
+
+// ReloadStatus describes the outcome of the most recent reload attempt.
+type ReloadStatus struct {
+	At         time.Time `json:"at"`
+	Trigger    string    `json:"trigger"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Generation int64     `json:"generation"`
+}
+
+// Reloader holds the current Runtime configuration and swaps it atomically when the
+// configuration is reloaded. Reloads are triggered by SIGHUP, changes to the env file, or
+// explicit calls to Reload.
+type Reloader struct {
+	envFile string
+	load    func() (RuntimeConfig, error)
+	// keys set from envFile by the last load, unset again when they are removed from the file
+	envKeys map[string]bool
+
+	current atomic.Pointer[Runtime]
+	status  atomic.Pointer[ReloadStatus]
+
+	mu    sync.Mutex
+	hooks []func(*Runtime)
+}
+
+// NewReloader creates a Reloader seeded with the provided initial configuration. envFile is
+// re-read on every reload and watched for changes; values in it take precedence over the
+// process environment during a reload, and keys removed from it are unset.
+func NewReloader(initial RuntimeConfig, envFile string) (*Reloader, error) {
+	rt, err := buildRuntime(initial, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reloader{
+		envFile: envFile,
+		load:    loadRuntimeConfig,
+	}
+	r.current.Store(rt)
+
+	if envFile != "" {
+		vals, err := readEnvFile(envFile)
+		if err != nil {
+			return nil, err
+		}
+		r.envKeys = envKeys(vals)
+	}
+
+	return r, nil
+}
+
+// Current returns the active runtime configuration. The returned value must not be modified.
+func (r *Reloader) Current() *Runtime {
+	return r.current.Load()
+}
+
+// Status returns the result of the most recent reload, or nil if no reload has happened.
+func (r *Reloader) Status() *ReloadStatus {
+	return r.status.Load()
+}
+
+// OnReload registers a hook that is called with the new configuration after every successful
+// reload, and immediately with the current configuration.
+func (r *Reloader) OnReload(hook func(*Runtime)) {
+	r.mu.Lock()
+	r.hooks = append(r.hooks, hook)
+	r.mu.Unlock()
+
+	hook(r.Current())
+}
+
+// Reload re-reads the runtime configuration, validates it, and swaps it in if valid. On
+// failure the current configuration is kept. trigger describes what caused the reload.
+func (r *Reloader) Reload(trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := r.Current()
+
+	err := r.reloadLocked(prev)
+
+	status := &ReloadStatus{
+		At:         time.Now().UTC(),
+		Trigger:    trigger,
+		Success:    err == nil,
+		Generation: r.Current().Generation,
+	}
+
+	if err != nil {
+		status.Error = err.Error()
+		logger.Log.Error().Str("trigger", trigger).Err(err).Msg("Configuration reload failed, keeping previous configuration")
+	} else {
+		logger.Log.Info().Str("trigger", trigger).Int64("generation", status.Generation).Msg("Configuration reloaded")
+	}
+
+	r.status.Store(status)
+
+	return err
+}
+
+func (r *Reloader) reloadLocked(prev *Runtime) error {
+	if r.envFile != "" {
+		vals, err := readEnvFile(r.envFile)
+		if err != nil {
+			return err
+		}
+
+		for key := range r.envKeys {
+			if _, ok := vals[key]; !ok {
+				os.Unsetenv(key)
+			}
+		}
+		for key, val := range vals {
+			os.Setenv(key, val)
+		}
+		r.envKeys = envKeys(vals)
+	}
+
+	if _, err := DefaultSecretResolver().Refresh(context.Background()); err != nil {
+		return fmt.Errorf("failed to refresh secrets: %w", err)
+	}
+
+	next, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	rt, err := buildRuntime(next, prev.Generation+1)
+	if err != nil {
+		return err
+	}
+
+	if prev.Certificate != nil && rt.Certificate == nil {
+		return errors.New("TLS cannot be disabled without a restart")
+	}
+
+	r.current.Store(rt)
+
+	for _, hook := range r.hooks {
+		hook(rt)
+	}
+
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the env file's modification time
+// changes. It blocks until ctx is cancelled.
+func (r *Reloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(defaultReloadPollInterval)
+	defer ticker.Stop()
+
+	lastMod := r.envFileModTime()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload("SIGHUP")
+			lastMod = r.envFileModTime()
+		case <-ticker.C:
+			if mod := r.envFileModTime(); !mod.Equal(lastMod) {
+				lastMod = mod
+				r.Reload("file change")
+			}
+		}
+	}
+}
+
+// readEnvFile parses the env file, a missing file has no values.
+func readEnvFile(path string) (map[string]string, error) {
+	vals, err := godotenv.Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return vals, nil
+}
+
+func envKeys(vals map[string]string) map[string]bool {
+	keys := make(map[string]bool, len(vals))
+	for key := range vals {
+		keys[key] = true
+	}
+
+	return keys
+}
+
+func (r *Reloader) envFileModTime() time.Time {
+	if r.envFile == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(r.envFile)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// buildRuntime validates the configuration and loads any referenced certificates.
+func buildRuntime(rc RuntimeConfig, generation int64) (*Runtime, error) {
+	var Validate *validator.Validate = validator.New(validator.WithRequiredStructEnabled())
+
+	if err := Validate.Struct(rc); err != nil {
+		return nil, err
+	}
+
+	rt := &Runtime{
+		RuntimeConfig: rc,
+		Generation:    generation,
+	}
+
+	if rc.TLS.Enabled() {
+		cert, err := tls.LoadX509KeyPair(rc.TLS.CertFile, rc.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		rt.Certificate = &cert
+	}
+
+	return rt, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadKeepsPreviousConfigOnValidationFailure(t *testing.T) {
+	r, err := NewReloader(RuntimeConfig{LogLevel: "info"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var applied []string
+	r.OnReload(func(rt *Runtime) { applied = append(applied, rt.LogLevel) })
+
+	r.load = func() (RuntimeConfig, error) { return RuntimeConfig{LogLevel: "verbose"}, nil }
+	if err := r.Reload("test"); err == nil {
+		t.Fatal("Expected invalid log level to fail validation")
+	}
+
+	if r.Current().LogLevel != "info" || r.Status().Success {
+		t.Fatalf("Expected previous config to be kept, got %s", r.Current().LogLevel)
+	}
+
+	r.load = func() (RuntimeConfig, error) { return RuntimeConfig{}, errors.New("boom") }
+	if err := r.Reload("test"); err == nil {
+		t.Fatal("Expected load error to be returned")
+	}
+
+	r.load = func() (RuntimeConfig, error) { return RuntimeConfig{LogLevel: "warn"}, nil }
+	if err := r.Reload("test"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if r.Current().LogLevel != "warn" || r.Current().Generation != 1 || !r.Status().Success {
+		t.Fatalf("Expected new config to be applied, got %+v", r.Current())
+	}
+
+	if len(applied) != 2 || applied[1] != "warn" {
+		t.Fatalf("Expected hooks to run for initial and reloaded config, got %v", applied)
+	}
+}
+
+func TestReloadUnsetsKeysRemovedFromEnvFile(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	os.Unsetenv("LOG_LEVEL")
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("LOG_LEVEL=warn\n"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	r, err := NewReloader(RuntimeConfig{LogLevel: "info"}, envFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	r.load = func() (RuntimeConfig, error) {
+		level, ok := os.LookupEnv("LOG_LEVEL")
+		if !ok {
+			level = "info"
+		}
+		return RuntimeConfig{LogLevel: level}, nil
+	}
+
+	if err := r.Reload("test"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if r.Current().LogLevel != "warn" {
+		t.Fatalf("Expected the env file value to be applied, got %s", r.Current().LogLevel)
+	}
+
+	if err := os.WriteFile(envFile, []byte("# LOG_LEVEL removed\n"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := r.Reload("test"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := os.LookupEnv("LOG_LEVEL"); ok {
+		t.Fatal("Expected LOG_LEVEL to be unset after it was removed from the env file")
+	}
+	if r.Current().LogLevel != "info" {
+		t.Fatalf("Expected the default to be applied, got %s", r.Current().LogLevel)
+	}
+}
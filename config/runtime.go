@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RuntimeConfig holds the subset of configuration that can be reloaded without restarting
+// the server.
+type RuntimeConfig struct {
+	// trace, debug, info, warn, error
+	LogLevel string `json:"LOG_LEVEL" validate:"required,oneof=trace debug info warn error"`
+	// per-client request rate limiting
+	RateLimit RateLimitConfig `json:"RATE_LIMIT"`
+	// origins allowed to make cross-origin requests with credentials, * lets any other origin
+	// read responses without them
+	CORSOrigins []string `json:"CORS_ORIGINS" validate:"dive,required"`
+	// named feature toggles
+	FeatureFlags map[string]bool `json:"FEATURE_FLAGS"`
+	// TLS certificate configuration, TLS is disabled when empty
+	TLS TLSConfig `json:"TLS"`
+}
+
+type RateLimitConfig struct {
+	// sustained requests per second allowed per client IP, 0 disables rate limiting
+	RequestsPerSecond float64 `json:"REQUESTS_PER_SECOND" validate:"gte=0"`
+	// maximum burst of requests per client IP
+	Burst int `json:"BURST" validate:"gte=0"`
+}
+
+type TLSConfig struct {
+	// path to the PEM encoded certificate chain
+	CertFile string `json:"CERT_FILE" validate:"required_with=KeyFile"`
+	// path to the PEM encoded private key
+	KeyFile string `json:"KEY_FILE" validate:"required_with=CertFile"`
+}
+
+// Enabled reports whether a certificate and key are configured.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// FeatureEnabled reports whether the named feature flag is turned on.
+func (r RuntimeConfig) FeatureEnabled(name string) bool {
+	return r.FeatureFlags[strings.ToLower(name)]
+}
+
+// loadRuntimeConfig reads the reloadable configuration from the environment.
+func loadRuntimeConfig() (RuntimeConfig, error) {
+	r := RuntimeConfig{
+		LogLevel:     strings.ToLower(os.Getenv("LOG_LEVEL")),
+		CORSOrigins:  splitList(os.Getenv("CORS_ORIGINS")),
+		FeatureFlags: map[string]bool{},
+		TLS: TLSConfig{
+			CertFile: os.Getenv("TLS_CERT_FILE"),
+			KeyFile:  os.Getenv("TLS_KEY_FILE"),
+		},
+	}
+
+	if r.LogLevel == "" {
+		r.LogLevel = "info"
+	}
+
+	if rawRPS := os.Getenv("RATE_LIMIT_RPS"); rawRPS != "" {
+		rps, err := strconv.ParseFloat(rawRPS, 64)
+		if err != nil {
+			return RuntimeConfig{}, fmt.Errorf("invalid RATE_LIMIT_RPS: %w", err)
+		}
+		r.RateLimit.RequestsPerSecond = rps
+	}
+
+	if rawBurst := os.Getenv("RATE_LIMIT_BURST"); rawBurst != "" {
+		burst, err := strconv.Atoi(rawBurst)
+		if err != nil {
+			return RuntimeConfig{}, fmt.Errorf("invalid RATE_LIMIT_BURST: %w", err)
+		}
+		r.RateLimit.Burst = burst
+	}
+
+	for _, flag := range splitList(os.Getenv("FEATURE_FLAGS")) {
+		name, rawVal, hasVal := strings.Cut(flag, "=")
+		enabled := true
+		if hasVal {
+			parsed, err := strconv.ParseBool(rawVal)
+			if err != nil {
+				return RuntimeConfig{}, fmt.Errorf("invalid value for feature flag %s: %w", name, err)
+			}
+			enabled = parsed
+		}
+		r.FeatureFlags[strings.ToLower(strings.TrimSpace(name))] = enabled
+	}
+
+	if err := resolveSecretRefs(context.Background(), DefaultSecretResolver(), &r); err != nil {
+		return RuntimeConfig{}, err
+	}
+
+	return r, nil
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(raw string) []string {
+	var results []string
+
+	for _, item := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			results = append(results, trimmed)
+		}
+	}
+
+	return results
+}
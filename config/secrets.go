@@ -0,0 +1,365 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SecretRefPrefix marks a config value as a reference to be resolved by a SecretProvider,
+	// e.g. secret://vault/webapp/db#uri or secret://file//run/secrets/db_uri.
+	SecretRefPrefix = "secret://"
+
+	SECRET_PROVIDER_ENV   = "env"
+	SECRET_PROVIDER_FILE  = "file"
+	SECRET_PROVIDER_DIR   = "dir"
+	SECRET_PROVIDER_VAULT = "vault"
+
+	defaultSecretCacheTTL = 5 * time.Minute
+	defaultVaultKey       = "value"
+)
+
+var (
+	ErrSecretNotFound        = errors.New("secret not found")
+	ErrInvalidSecretRef      = errors.New("invalid secret reference")
+	ErrUnknownSecretProvider = errors.New("unknown secret provider")
+)
+
+// SecretProvider resolves secret values from a backing source. The path format is
+// specific to each provider.
+type SecretProvider interface {
+	GetSecret(ctx context.Context, path string) (string, error)
+}
+
+// EnvSecretProvider reads secrets from environment variables. The path is the variable name.
+type EnvSecretProvider struct{}
+
+// GetSecret implements SecretProvider.
+func (EnvSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	val, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("%w: env %s", ErrSecretNotFound, path)
+	}
+
+	return val, nil
+}
+
+// FileSecretProvider reads secrets from files, such as Docker or Kubernetes secret mounts.
+// The path is the path to the file. Trailing newlines are trimmed.
+type FileSecretProvider struct{}
+
+// GetSecret implements SecretProvider.
+func (FileSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	return readSecretFile(path)
+}
+
+// DirSecretProvider reads secrets from a directory containing one file per secret. The path
+// is the file name within Dir and may not escape it.
+type DirSecretProvider struct {
+	Dir string
+}
+
+// GetSecret implements SecretProvider.
+func (p DirSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	if p.Dir == "" {
+		return "", fmt.Errorf("%w: no secrets directory configured", ErrSecretNotFound)
+	}
+
+	cleaned := filepath.Clean("/" + path)
+	if cleaned == "/" {
+		return "", fmt.Errorf("%w: empty path", ErrInvalidSecretRef)
+	}
+
+	return readSecretFile(filepath.Join(p.Dir, cleaned))
+}
+
+// HTTPSecretProvider reads secrets from a Vault-style KV v2 HTTP API. The path has the
+// form <secret path>#<key>; the key defaults to "value" when omitted.
+type HTTPSecretProvider struct {
+	// Base address of the server, e.g. https://vault.internal:8200
+	Addr string
+	// Token sent in the X-Vault-Token header
+	Token string
+	// KV mount name, defaults to "secret"
+	Mount string
+	// HTTP client to use, defaults to a client with a 10 second timeout
+	Client *http.Client
+}
+
+// GetSecret implements SecretProvider.
+func (p HTTPSecretProvider) GetSecret(ctx context.Context, path string) (string, error) {
+	if p.Addr == "" {
+		return "", fmt.Errorf("%w: no secret server address configured", ErrSecretNotFound)
+	}
+
+	secretPath, key, _ := strings.Cut(path, "#")
+	if key == "" {
+		key = defaultVaultKey
+	}
+
+	mount := p.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(p.Addr, "/"), mount, strings.TrimLeft(secretPath, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, secretPath)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("secret server returned status %d for %s", res.StatusCode, secretPath)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode secret response: %w", err)
+	}
+
+	val, ok := body.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s#%s", ErrSecretNotFound, secretPath, key)
+	}
+
+	strVal, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s#%s is not a string", secretPath, key)
+	}
+
+	return strVal, nil
+}
+
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// SecretResolver resolves secret:// references using a set of named providers. Resolved
+// values are cached for the configured TTL so rotated values are picked up on the next
+// resolve after expiry, or immediately after Refresh.
+type SecretResolver struct {
+	providers map[string]SecretProvider
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+// NewSecretResolver creates a resolver with the provided providers keyed by name. A
+// non-positive ttl disables caching.
+func NewSecretResolver(ttl time.Duration, providers map[string]SecretProvider) *SecretResolver {
+	return &SecretResolver{
+		providers: providers,
+		ttl:       ttl,
+		cache:     make(map[string]cachedSecret),
+	}
+}
+
+// IsSecretRef reports whether the value is a secret:// reference.
+func IsSecretRef(val string) bool {
+	return strings.HasPrefix(val, SecretRefPrefix)
+}
+
+// Resolve returns the secret referenced by ref. Values that are not secret references are
+// returned unchanged.
+func (r *SecretResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	if !IsSecretRef(ref) {
+		return ref, nil
+	}
+
+	r.mu.Lock()
+	cached, ok := r.cache[ref]
+	r.mu.Unlock()
+
+	if ok && r.ttl > 0 && time.Since(cached.fetchedAt) < r.ttl {
+		return cached.value, nil
+	}
+
+	return r.fetch(ctx, ref)
+}
+
+// Refresh re-fetches every cached reference and returns the references whose values changed.
+func (r *SecretResolver) Refresh(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	previous := make(map[string]string, len(r.cache))
+	for ref, cached := range r.cache {
+		previous[ref] = cached.value
+	}
+	r.mu.Unlock()
+
+	var changed []string
+	var errs []error
+
+	for ref, oldVal := range previous {
+		newVal, err := r.fetch(ctx, ref)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if newVal != oldVal {
+			changed = append(changed, ref)
+		}
+	}
+
+	return changed, errors.Join(errs...)
+}
+
+func (r *SecretResolver) fetch(ctx context.Context, ref string) (string, error) {
+	providerName, path, ok := strings.Cut(strings.TrimPrefix(ref, SecretRefPrefix), "/")
+	if !ok || providerName == "" || path == "" {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSecretRef, ref)
+	}
+
+	provider, ok := r.providers[providerName]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownSecretProvider, providerName)
+	}
+
+	val, err := provider.GetSecret(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[ref] = cachedSecret{value: val, fetchedAt: time.Now()}
+		r.mu.Unlock()
+	}
+
+	return val, nil
+}
+
+// resolveSecretRefs replaces every string field of the struct pointed to by target that
+// holds a secret:// reference with its resolved value. Nested structs and slices are walked.
+func resolveSecretRefs(ctx context.Context, r *SecretResolver, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("secret resolution target must be a pointer to a struct")
+	}
+
+	return resolveStructSecretRefs(ctx, r, v.Elem())
+}
+
+func resolveStructSecretRefs(ctx context.Context, r *SecretResolver, v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !t.Field(i).IsExported() {
+			continue
+		}
+
+		if err := resolveValueSecretRefs(ctx, r, t.Field(i).Name, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveValueSecretRefs resolves a string holding a secret:// reference, or the references
+// in a struct or the elements of a slice. name identifies the value in errors.
+func resolveValueSecretRefs(ctx context.Context, r *SecretResolver, name string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		if !IsSecretRef(v.String()) {
+			return nil
+		}
+		val, err := r.Resolve(ctx, v.String())
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		v.SetString(val)
+	case reflect.Struct:
+		return resolveStructSecretRefs(ctx, r, v)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveValueSecretRefs(ctx, r, fmt.Sprintf("%s[%d]", name, i), v.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readSecretFile reads a secret from a file, trimming trailing newlines added by editors and
+// secret mounts.
+func readSecretFile(path string) (string, error) {
+	val, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+		}
+		return "", err
+	}
+
+	return strings.TrimRight(string(val), "\r\n"), nil
+}
+
+var (
+	secretResolverOnce sync.Once
+	secretResolver     *SecretResolver
+)
+
+// DefaultSecretResolver returns the process-wide resolver configured from the environment.
+// SECRETS_DIR configures the dir provider; VAULT_ADDR, VAULT_TOKEN (or VAULT_TOKEN_FILE) and
+// VAULT_MOUNT configure the vault provider; SECRETS_CACHE_TTL sets the cache lifetime.
+func DefaultSecretResolver() *SecretResolver {
+	secretResolverOnce.Do(func() {
+		ttl := defaultSecretCacheTTL
+		if rawTTL := os.Getenv("SECRETS_CACHE_TTL"); rawTTL != "" {
+			if parsed, err := time.ParseDuration(rawTTL); err == nil {
+				ttl = parsed
+			}
+		}
+
+		vaultToken := os.Getenv("VAULT_TOKEN")
+		if tokenFile := os.Getenv("VAULT_TOKEN_FILE"); vaultToken == "" && tokenFile != "" {
+			vaultToken, _ = readSecretFile(tokenFile)
+		}
+
+		secretResolver = NewSecretResolver(ttl, map[string]SecretProvider{
+			SECRET_PROVIDER_ENV:  EnvSecretProvider{},
+			SECRET_PROVIDER_FILE: FileSecretProvider{},
+			SECRET_PROVIDER_DIR:  DirSecretProvider{Dir: os.Getenv("SECRETS_DIR")},
+			SECRET_PROVIDER_VAULT: HTTPSecretProvider{
+				Addr:  os.Getenv("VAULT_ADDR"),
+				Token: vaultToken,
+				Mount: os.Getenv("VAULT_MOUNT"),
+			},
+		})
+	})
+
+	return secretResolver
+}
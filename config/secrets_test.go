@@ -0,0 +1,176 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSecretProviderTrimsNewlines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("abcdefghijklmnopqrstuvwxyz012345\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := FileSecretProvider{}.GetSecret(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(val) != 32 {
+		t.Fatalf("Expected trimmed secret of length 32, got %d", len(val))
+	}
+}
+
+func TestDirSecretProviderRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db_uri"), []byte("postgres://x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := DirSecretProvider{Dir: dir}
+
+	val, err := p.GetSecret(context.Background(), "db_uri")
+	if err != nil || val != "postgres://x" {
+		t.Fatalf("Got unexpected value %q, err %v", val, err)
+	}
+
+	if _, err := p.GetSecret(context.Background(), "../../etc/passwd"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("Expected not found for path outside the directory, got %v", err)
+	}
+}
+
+func TestHTTPSecretProvider(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/webapp/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"uri":"postgres://vault","value":"default"}}}`))
+	}))
+	defer stub.Close()
+
+	p := HTTPSecretProvider{Addr: stub.URL, Token: "token"}
+
+	val, err := p.GetSecret(context.Background(), "webapp/db#uri")
+	if err != nil || val != "postgres://vault" {
+		t.Fatalf("Got unexpected value %q, err %v", val, err)
+	}
+
+	val, err = p.GetSecret(context.Background(), "webapp/db")
+	if err != nil || val != "default" {
+		t.Fatalf("Got unexpected value %q, err %v", val, err)
+	}
+
+	if _, err := p.GetSecret(context.Background(), "webapp/missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("Expected not found, got %v", err)
+	}
+}
+
+func TestSecretResolverCachesAndRefreshes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	if err := os.WriteFile(path, []byte("first"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewSecretResolver(time.Hour, map[string]SecretProvider{SECRET_PROVIDER_DIR: DirSecretProvider{Dir: dir}})
+
+	val, err := r.Resolve(context.Background(), "secret://dir/key")
+	if err != nil || val != "first" {
+		t.Fatalf("Got unexpected value %q, err %v", val, err)
+	}
+
+	if err := os.WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if val, _ := r.Resolve(context.Background(), "secret://dir/key"); val != "first" {
+		t.Fatalf("Expected cached value, got %q", val)
+	}
+
+	changed, err := r.Refresh(context.Background())
+	if err != nil || len(changed) != 1 {
+		t.Fatalf("Expected one changed reference, got %v, err %v", changed, err)
+	}
+
+	if val, _ := r.Resolve(context.Background(), "secret://dir/key"); val != "second" {
+		t.Fatalf("Expected refreshed value, got %q", val)
+	}
+
+	if val, _ := r.Resolve(context.Background(), "plain"); val != "plain" {
+		t.Fatalf("Expected non-reference value to be returned unchanged, got %q", val)
+	}
+
+	if _, err := r.Resolve(context.Background(), "secret://unknown/key"); !errors.Is(err, ErrUnknownSecretProvider) {
+		t.Fatalf("Expected unknown provider error, got %v", err)
+	}
+}
+
+func TestResolveSecretRefsWalksConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "enc_secret"), []byte("abcdefghijklmnopqrstuvwxyz012345\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewSecretResolver(0, map[string]SecretProvider{SECRET_PROVIDER_DIR: DirSecretProvider{Dir: dir}})
+
+	c := &Config{
+		Port: "8000",
+		Encryption: EncryptionConfig{
+			EncSecret: "secret://dir/enc_secret",
+			Previous:  []EncryptionConfig{{EncIV: "0123456789abcdef", EncSecret: "secret://dir/enc_secret"}},
+		},
+	}
+
+	if err := resolveSecretRefs(context.Background(), r, c); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if c.Encryption.EncSecret != "abcdefghijklmnopqrstuvwxyz012345" || c.Port != "8000" {
+		t.Fatalf("Config was not resolved as expected: %+v", c.Encryption)
+	}
+
+	if c.Encryption.Previous[0].EncSecret != "abcdefghijklmnopqrstuvwxyz012345" {
+		t.Fatalf("Expected the previous keys to be resolved, got %+v", c.Encryption.Previous)
+	}
+}
+
+func TestLoadSecretsResolvesPreviousKeysRef(t *testing.T) {
+	dir := t.TempDir()
+	keys := "0123456789abcdef:abcdefghijklmnopqrstuvwxyz012345,fedcba9876543210:543210zyxwvutsrqponmlkjihgfedcba\n"
+	if err := os.WriteFile(filepath.Join(dir, "old-keys"), []byte(keys), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewSecretResolver(0, map[string]SecretProvider{SECRET_PROVIDER_DIR: DirSecretProvider{Dir: dir}})
+	t.Setenv("ENCRYPTION_PREVIOUS_KEYS", "secret://dir/old-keys")
+
+	secretVals, err := loadSecrets(context.Background(), r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	previous, err := parsePreviousKeys(secretVals["ENCRYPTION_PREVIOUS_KEYS"])
+	if err != nil {
+		t.Fatalf("Expected the resolved keys to parse, got %s", err)
+	}
+
+	if len(previous) != 2 || previous[1].EncIV != "fedcba9876543210" || previous[1].EncSecret != "543210zyxwvutsrqponmlkjihgfedcba" {
+		t.Fatalf("Expected both previous keys, got %+v", previous)
+	}
+
+	t.Setenv("ENCRYPTION_PREVIOUS_KEYS", "secret://dir/missing")
+	if _, err := loadSecrets(context.Background(), r); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("Expected a missing secret to fail, got %v", err)
+	}
+}
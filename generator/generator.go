@@ -0,0 +1,351 @@
+package generator
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	MODE_PASSWORD   = "password"
+	MODE_PASSPHRASE = "passphrase"
+
+	CAPITALIZE_NONE   = "none"
+	CAPITALIZE_FIRST  = "first"
+	CAPITALIZE_ALL    = "all"
+	CAPITALIZE_RANDOM = "random"
+
+	// DefaultSymbols keeps the default alphabet the same as the original random note alphabet.
+	DefaultSymbols = "!?.#$"
+
+	lowerChars = "abcdefghijklmnopqrstuvwxyz"
+	upperChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars = "0123456789"
+	// characters that are easily confused with each other when read or typed
+	ambiguousChars = "Il1O0o|`'\""
+
+	// DefaultWords and DefaultSeparator are used for passphrases that don't set their own.
+	DefaultWords     = 6
+	DefaultSeparator = "-"
+	MaxWords         = 64
+
+	maxSeparatorLen = 8
+	maxLength       = 2048
+)
+
+var ErrInvalidOptions = errors.New("invalid generator options")
+
+// eff_large_wordlist.txt is the EFF large diceware wordlist, licensed CC BY 3.0:
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed eff_large_wordlist.txt
+var effWordlist string
+
+// Words returns the embedded diceware wordlist.
+var Words = sync.OnceValue(func() []string {
+	lines := strings.Split(strings.TrimSpace(effWordlist), "\n")
+	words := make([]string, len(lines))
+
+	for i, line := range lines {
+		_, word, _ := strings.Cut(line, "\t")
+		words[i] = strings.TrimSpace(word)
+	}
+
+	return words
+})
+
+// Options configures a generated password or passphrase. The zero value of every field except
+// Length is a sensible default.
+type Options struct {
+	// password (default) or passphrase
+	Mode string `json:"mode" form:"mode" binding:"omitempty,oneof=password passphrase"`
+
+	// number of characters, required for passwords
+	Length int `json:"length" form:"length" binding:"gte=0,lte=2048"`
+	// character classes to leave out, all four are used by default
+	ExcludeLower   bool `json:"exclude_lower" form:"exclude_lower"`
+	ExcludeUpper   bool `json:"exclude_upper" form:"exclude_upper"`
+	ExcludeDigits  bool `json:"exclude_digits" form:"exclude_digits"`
+	ExcludeSymbols bool `json:"exclude_symbols" form:"exclude_symbols"`
+	// leave out characters such as l, 1, O and 0
+	ExcludeAmbiguous bool `json:"exclude_ambiguous" form:"exclude_ambiguous"`
+	// include at least one character from every used class
+	RequireEachClass bool `json:"require_each_class" form:"require_each_class"`
+	// symbol class, defaults to DefaultSymbols
+	Symbols string `json:"symbols" form:"symbols"`
+
+	// number of words in a passphrase, defaults to 6
+	Words int `json:"words" form:"words" binding:"gte=0,lte=64"`
+	// placed between words, defaults to "-"; use a single space for spaces
+	Separator string `json:"separator" form:"separator"`
+	// none (default), first letter of each word, all letters of each word, or random words
+	Capitalize string `json:"capitalize" form:"capitalize" binding:"omitempty,oneof=none first all random"`
+	// add a random digit after one randomly chosen word
+	IncludeDigit bool `json:"include_digit" form:"include_digit"`
+}
+
+// Result is a generated value and its estimated entropy. The estimate assumes the attacker
+// knows the options used, so it only counts the random choices.
+type Result struct {
+	Value       string
+	EntropyBits float64
+}
+
+// Generate creates a password or passphrase from cryptographically secure randomness.
+// Returns an ErrInvalidOptions error if the options can't produce a value.
+func Generate(opts Options) (Result, error) {
+	switch opts.Mode {
+	case "", MODE_PASSWORD:
+		return generatePassword(opts)
+	case MODE_PASSPHRASE:
+		return generatePassphrase(opts)
+	default:
+		return Result{}, fmt.Errorf("%w: unknown mode %q", ErrInvalidOptions, opts.Mode)
+	}
+}
+
+// Alphabet returns the character classes the options select, after exclusions.
+func Alphabet(opts Options) ([]string, error) {
+	symbols := DefaultSymbols
+	if opts.Symbols != "" {
+		symbols = opts.Symbols
+		for _, r := range symbols {
+			if r > unicode.MaxASCII || !unicode.IsPrint(r) || unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return nil, fmt.Errorf("%w: symbols must be printable ASCII punctuation", ErrInvalidOptions)
+			}
+		}
+	}
+
+	var classes []string
+	seen := make(map[rune]bool)
+
+	for _, class := range []struct {
+		chars    string
+		excluded bool
+	}{
+		{lowerChars, opts.ExcludeLower},
+		{upperChars, opts.ExcludeUpper},
+		{digitChars, opts.ExcludeDigits},
+		{symbols, opts.ExcludeSymbols},
+	} {
+		if class.excluded {
+			continue
+		}
+
+		var b strings.Builder
+		for _, r := range class.chars {
+			if seen[r] || (opts.ExcludeAmbiguous && strings.ContainsRune(ambiguousChars, r)) {
+				continue
+			}
+			seen[r] = true
+			b.WriteRune(r)
+		}
+
+		if b.Len() > 0 {
+			classes = append(classes, b.String())
+		}
+	}
+
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("%w: at least one character class must be included", ErrInvalidOptions)
+	}
+
+	return classes, nil
+}
+
+func generatePassword(opts Options) (Result, error) {
+	if opts.Length <= 0 || opts.Length > maxLength {
+		return Result{}, fmt.Errorf("%w: length must be between 1 and %d", ErrInvalidOptions, maxLength)
+	}
+
+	classes, err := Alphabet(opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	required := []string{}
+	if opts.RequireEachClass {
+		required = classes
+	}
+
+	value, err := Compose(opts.Length, strings.Join(classes, ""), required)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Value: value, EntropyBits: passwordEntropy(opts.Length, classes, opts.RequireEachClass)}, nil
+}
+
+// Compose returns a random string of the length drawn from the alphabet that contains a
+// character of each required class. One character is drawn from each class and the rest from
+// the alphabet, then the characters are shuffled, so no retries are needed however short the
+// length. Returns an ErrInvalidOptions error if the length can't fit every class or a class is
+// empty.
+func Compose(length int, alphabet string, required []string) (string, error) {
+	if alphabet == "" {
+		return "", fmt.Errorf("%w: at least one character class must be included", ErrInvalidOptions)
+	}
+
+	if length < len(required) {
+		return "", fmt.Errorf("%w: length must be at least %d to include every class", ErrInvalidOptions, len(required))
+	}
+
+	result := make([]byte, 0, length)
+
+	for _, class := range required {
+		if class == "" {
+			return "", fmt.Errorf("%w: every required class needs at least one character", ErrInvalidOptions)
+		}
+
+		c, err := randomString(1, class)
+		if err != nil {
+			return "", err
+		}
+		result = append(result, c...)
+	}
+
+	rest, err := randomString(length-len(result), alphabet)
+	if err != nil {
+		return "", err
+	}
+	result = append(result, rest...)
+
+	// Fisher-Yates, so the required characters can be anywhere
+	for i := len(result) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return string(result), nil
+}
+
+func generatePassphrase(opts Options) (Result, error) {
+	count := opts.Words
+	if count == 0 {
+		count = DefaultWords
+	}
+
+	if count < 1 || count > MaxWords {
+		return Result{}, fmt.Errorf("%w: words must be between 1 and %d", ErrInvalidOptions, MaxWords)
+	}
+
+	separator := opts.Separator
+	if separator == "" {
+		separator = DefaultSeparator
+	}
+
+	if len(separator) > maxSeparatorLen {
+		return Result{}, fmt.Errorf("%w: separator must be at most %d characters", ErrInvalidOptions, maxSeparatorLen)
+	}
+
+	wordlist := Words()
+	words := make([]string, count)
+	bitsPerWord := math.Log2(float64(len(wordlist)))
+
+	for i := range words {
+		n, err := randomInt(len(wordlist))
+		if err != nil {
+			return Result{}, err
+		}
+
+		word := wordlist[n]
+
+		switch opts.Capitalize {
+		case CAPITALIZE_FIRST:
+			word = strings.ToUpper(word[:1]) + word[1:]
+		case CAPITALIZE_ALL:
+			word = strings.ToUpper(word)
+		case CAPITALIZE_RANDOM:
+			upper, err := randomInt(2)
+			if err != nil {
+				return Result{}, err
+			}
+			if upper == 1 {
+				word = strings.ToUpper(word)
+			}
+		}
+
+		words[i] = word
+	}
+
+	if opts.Capitalize == CAPITALIZE_RANDOM {
+		bitsPerWord++
+	}
+
+	bits := float64(count) * bitsPerWord
+
+	if opts.IncludeDigit {
+		i, err := randomInt(count)
+		if err != nil {
+			return Result{}, err
+		}
+
+		digit, err := randomString(1, digitChars)
+		if err != nil {
+			return Result{}, err
+		}
+
+		words[i] += digit
+		bits += math.Log2(float64(count * len(digitChars)))
+	}
+
+	return Result{Value: strings.Join(words, separator), EntropyBits: roundBits(bits)}, nil
+}
+
+// passwordEntropy returns log2 of the number of possible passwords. When every class is
+// required, one character is drawn from each class and the rest from the whole alphabet, and
+// the estimate counts those draws but not the shuffle, so it is a lower bound.
+func passwordEntropy(length int, classes []string, requireEach bool) float64 {
+	total := 0
+	for _, class := range classes {
+		total += len(class)
+	}
+
+	if !requireEach {
+		return roundBits(float64(length) * math.Log2(float64(total)))
+	}
+
+	bits := float64(length-len(classes)) * math.Log2(float64(total))
+	for _, class := range classes {
+		bits += math.Log2(float64(len(class)))
+	}
+
+	return roundBits(bits)
+}
+
+func roundBits(bits float64) float64 {
+	return math.Round(bits*10) / 10
+}
+
+// randomString returns a cryptographically secure random string of the provided length.
+func randomString(length int, alphabet string) (string, error) {
+	result := make([]byte, length)
+
+	for i := range result {
+		n, err := randomInt(len(alphabet))
+		if err != nil {
+			return "", err
+		}
+		result[i] = alphabet[n]
+	}
+
+	return string(result), nil
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n.Int64()), nil
+}
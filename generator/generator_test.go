@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePasswordDefaultsMatchOriginalAlphabet(t *testing.T) {
+	result, err := Generate(Options{Length: 32})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(result.Value) != 32 {
+		t.Fatalf("Expected 32 characters, got %q", result.Value)
+	}
+
+	if want := roundBits(32 * math.Log2(67)); result.EntropyBits != want {
+		t.Fatalf("Expected %.1f bits, got %.1f", want, result.EntropyBits)
+	}
+}
+
+func TestGeneratePasswordRequiresEachClass(t *testing.T) {
+	opts := Options{Length: 4, RequireEachClass: true, ExcludeAmbiguous: true, Symbols: "@"}
+
+	for i := 0; i < 50; i++ {
+		result, err := Generate(opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		for _, class := range []string{lowerChars, upperChars, digitChars, "@"} {
+			if !strings.ContainsAny(result.Value, class) {
+				t.Fatalf("Expected %q to contain one of %q", result.Value, class)
+			}
+		}
+
+		if strings.ContainsAny(result.Value, ambiguousChars) {
+			t.Fatalf("Expected %q to have no ambiguous characters", result.Value)
+		}
+	}
+
+	if _, err := Generate(Options{Length: 3, RequireEachClass: true}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("Expected a length shorter than the class count to fail, got %v", err)
+	}
+}
+
+func TestPasswordEntropyRequiredClasses(t *testing.T) {
+	// one of two characters from each class and one of four from the alphabet
+	if got := passwordEntropy(3, []string{"ab", "cd"}, true); got != 4 {
+		t.Fatalf("Expected 4 bits, got %.1f", got)
+	}
+
+	if got := passwordEntropy(3, []string{"ab", "cd"}, false); got != 6 {
+		t.Fatalf("Expected 6 bits, got %.1f", got)
+	}
+}
+
+func TestComposeIncludesEveryRequiredClass(t *testing.T) {
+	// one character per class leaves nothing to chance, so a retry loop would fail often
+	for i := 0; i < 50; i++ {
+		value, err := Compose(3, "abcdefABCDEF0123", []string{"abcdef", "ABCDEF", "0123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		for _, class := range []string{"abcdef", "ABCDEF", "0123"} {
+			if !strings.ContainsAny(value, class) {
+				t.Fatalf("Expected %q to contain one of %q", value, class)
+			}
+		}
+	}
+
+	if _, err := Compose(4, "abc", []string{"abc", ""}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("Expected an empty class to fail, got %v", err)
+	}
+}
+
+func TestGenerateRejectsInvalidOptions(t *testing.T) {
+	tests := []Options{
+		{},
+		{Length: 8, ExcludeLower: true, ExcludeUpper: true, ExcludeDigits: true, ExcludeSymbols: true},
+		{Length: 8, Symbols: "a!"},
+		{Mode: MODE_PASSPHRASE, Words: 65},
+		{Mode: "pin"},
+	}
+
+	for _, opts := range tests {
+		if _, err := Generate(opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Expected ErrInvalidOptions for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	if n := len(Words()); n != 7776 {
+		t.Fatalf("Expected the EFF large wordlist, got %d words", n)
+	}
+
+	result, err := Generate(Options{Mode: MODE_PASSPHRASE, Words: 5, Separator: " ", Capitalize: CAPITALIZE_FIRST})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	words := strings.Split(result.Value, " ")
+	if len(words) != 5 {
+		t.Fatalf("Expected 5 words, got %q", result.Value)
+	}
+
+	for _, word := range words {
+		if word[:1] != strings.ToUpper(word[:1]) {
+			t.Fatalf("Expected %q to be capitalized", word)
+		}
+	}
+
+	if result.EntropyBits != 64.6 {
+		t.Fatalf("Expected 64.6 bits, got %.1f", result.EntropyBits)
+	}
+}
+
+func TestGeneratePassphraseIncludeDigit(t *testing.T) {
+	result, err := Generate(Options{Mode: MODE_PASSPHRASE, Words: 4, IncludeDigit: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !strings.ContainsAny(result.Value, digitChars) {
+		t.Fatalf("Expected %q to contain a digit", result.Value)
+	}
+
+	// the digit and the word it follows add log2(10 * 4) bits
+	if result.EntropyBits != 57.0 {
+		t.Fatalf("Expected 57.0 bits, got %.1f", result.EntropyBits)
+	}
+}
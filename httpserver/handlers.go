@@ -1,8 +1,11 @@
 package httpserver
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oalexander6/web-app-template/models"
@@ -14,10 +17,48 @@ func HandleHello() gin.HandlerFunc {
 	}
 }
 
+// HandleGetAllNotes lists the notes of the vaults the caller can read, optionally filtered by the
+// vault_id, folder_id (0 for notes outside any folder), tag, favorite and exact name query
+// parameters.
 func HandleGetAllNotes(m models.Models) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		notes, err := m.NoteGetAll(ctx)
+		var filter models.NoteFilter
+
+		if folder, ok := ctx.GetQuery("folder_id"); ok {
+			folderID, err := strconv.ParseInt(folder, 10, 64)
+			if err != nil || folderID < 0 {
+				json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid folder_id."})
+				return
+			}
+			filter.FolderID = &folderID
+		}
+
+		if favorite, ok := ctx.GetQuery("favorite"); ok {
+			isFavorite, err := strconv.ParseBool(favorite)
+			if err != nil {
+				json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid favorite."})
+				return
+			}
+			filter.Favorite = isFavorite
+		}
+
+		if vault, ok := ctx.GetQuery("vault_id"); ok {
+			vaultID, err := strconv.ParseInt(vault, 10, 64)
+			if err != nil || vaultID <= 0 {
+				json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid vault_id."})
+				return
+			}
+			filter.VaultID = vaultID
+		}
+
+		filter.Tag = strings.TrimSpace(ctx.Query("tag"))
+		filter.Name = ctx.Query("name")
+
+		notes, err := m.NoteGetAll(ctx, filter)
 		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
 			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting notes."})
 			return
 		}
@@ -26,6 +67,38 @@ func HandleGetAllNotes(m models.Models) gin.HandlerFunc {
 	}
 }
 
+// HandleSearchNotes searches the names, tags, plaintext fields and non-hidden custom fields of
+// notes for the q query parameter, returning up to limit ranked results with highlights.
+func HandleSearchNotes(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		limit := 0
+
+		if value, ok := ctx.GetQuery("limit"); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid limit."})
+				return
+			}
+			limit = parsed
+		}
+
+		results, err := m.NoteSearch(ctx, ctx.Query("q"), limit)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrInvalidInput) {
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while searching notes."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"results": results})
+	}
+}
+
 func HandleCreateNote(m models.Models) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var createNoteParams models.NoteCreateParams
@@ -37,6 +110,16 @@ func HandleCreateNote(m models.Models) gin.HandlerFunc {
 
 		note, err := m.NoteCreate(ctx, createNoteParams)
 		if err != nil {
+			if respondAccessError(ctx, err) || respondPolicyError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrInvalidInput) {
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+				return
+			}
+			if respondNameConflict(ctx, err, noteNameConflict) {
+				return
+			}
 			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while saving note."})
 			return
 		}
@@ -44,3 +127,239 @@ func HandleCreateNote(m models.Models) gin.HandlerFunc {
 		json(ctx, http.StatusCreated, gin.H{"note": note})
 	}
 }
+
+func HandleRevealNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		note, err := m.NoteReveal(ctx, noteID)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			switch {
+			case errors.Is(err, models.ErrNotFound):
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found."})
+			case errors.Is(err, models.ErrReauthRequired):
+				json(ctx, http.StatusUnauthorized, gin.H{"error": "Re-authentication required.", "reauth_required": true})
+			case errors.Is(err, models.ErrDecryptFailed):
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Note could not be decrypted."})
+			default:
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while revealing note."})
+			}
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"note": note})
+	}
+}
+
+func HandleUpdateNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var updateNoteParams models.NoteUpdateParams
+
+		if err := ctx.ShouldBindJSON(&updateNoteParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		note, err := m.NoteUpdate(ctx, noteID, updateNoteParams)
+		if err != nil {
+			if respondAccessError(ctx, err) || respondPolicyError(ctx, err) || respondNameConflict(ctx, err, noteNameConflict) {
+				return
+			}
+			switch {
+			case errors.Is(err, models.ErrNotFound):
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found."})
+			case errors.Is(err, models.ErrInvalidInput):
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			default:
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while updating note."})
+			}
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"note": note})
+	}
+}
+
+func HandleGetNoteVersions(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		versions, err := m.NoteGetVersions(ctx, noteID)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting note versions."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"versions": versions})
+	}
+}
+
+func HandleRevealNoteVersion(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		version, ok := parseVersionParam(ctx)
+		if !ok {
+			return
+		}
+
+		noteVersion, err := m.NoteRevealVersion(ctx, noteID, version)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			switch {
+			case errors.Is(err, models.ErrNotFound):
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note version not found."})
+			case errors.Is(err, models.ErrReauthRequired):
+				json(ctx, http.StatusUnauthorized, gin.H{"error": "Re-authentication required.", "reauth_required": true})
+			case errors.Is(err, models.ErrDecryptFailed):
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Note version could not be decrypted."})
+			default:
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while revealing note version."})
+			}
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"version": noteVersion})
+	}
+}
+
+func HandleRestoreNoteVersion(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		version, ok := parseVersionParam(ctx)
+		if !ok {
+			return
+		}
+
+		note, err := m.NoteRestoreVersion(ctx, noteID, version)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note version not found."})
+				return
+			}
+			if respondNameConflict(ctx, err, noteNameConflict) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while restoring note version."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"note": note})
+	}
+}
+
+// noteNameConflict is the error for a note name already used by another note in the folder.
+const noteNameConflict = "A note with this name already exists in the folder."
+
+// parseIDParam reads the :id path parameter, responding with 400 if it is not a valid ID.
+func parseIDParam(ctx *gin.Context) (int64, bool) {
+	return parseNamedIDParam(ctx, "id")
+}
+
+// parseNamedIDParam reads an ID path parameter, responding with 400 if it is not a valid ID.
+func parseNamedIDParam(ctx *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s.", name)})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// parseVersionParam reads the :version path parameter, responding with 400 if it is not a
+// valid version number.
+func parseVersionParam(ctx *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil || version <= 0 {
+		json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid version."})
+		return 0, false
+	}
+
+	return version, true
+}
+
+// respondNameConflict responds with 409, the message and the ID of the conflicting note as
+// conflict_id if err is a name conflict. Returns false for any other error.
+func respondNameConflict(ctx *gin.Context, err error, message string) bool {
+	if !errors.Is(err, models.ErrAlreadyExists) {
+		return false
+	}
+
+	body := gin.H{"error": message}
+
+	var conflict *models.NameConflictError
+	if errors.As(err, &conflict) {
+		body["conflict_id"] = conflict.NoteID
+	}
+
+	json(ctx, http.StatusConflict, body)
+
+	return true
+}
+
+// respondAccessError responds with 401 if err says the caller must sign in, or 403 if they lack
+// the permission. Returns false for any other error.
+func respondAccessError(ctx *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, models.ErrUnauthenticated):
+		json(ctx, http.StatusUnauthorized, gin.H{"error": "Authentication required."})
+	case errors.Is(err, models.ErrForbidden):
+		json(ctx, http.StatusForbidden, gin.H{"error": "Permission denied."})
+	default:
+		return false
+	}
+
+	return true
+}
+
+// respondPolicyError responds with 422 and the broken rules if err is a password policy
+// violation, or 503 if the policy needs the breach dataset and it is unavailable. Returns false
+// for any other error.
+func respondPolicyError(ctx *gin.Context, err error) bool {
+	var policyErr *models.PolicyError
+
+	switch {
+	case errors.As(err, &policyErr):
+		json(ctx, http.StatusUnprocessableEntity, gin.H{"error": "The value does not meet the password policy.", "violations": policyErr.Violations})
+	case errors.Is(err, models.ErrBreachCheckUnavailable):
+		json(ctx, http.StatusServiceUnavailable, gin.H{"error": "The password policy can't be checked right now."})
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,153 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func HandleGetReloadStatus(runtime *config.Reloader) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		current := runtime.Current()
+
+		json(ctx, http.StatusOK, gin.H{
+			"status":     runtime.Status(),
+			"runtime":    current.RuntimeConfig,
+			"generation": current.Generation,
+		})
+	}
+}
+
+func HandleReload(runtime *config.Reloader) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := runtime.Reload("admin endpoint"); err != nil {
+			json(ctx, http.StatusUnprocessableEntity, gin.H{"error": "Configuration reload failed.", "status": runtime.Status()})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"status": runtime.Status()})
+	}
+}
+
+func HandleDoctor(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report := m.Doctor(ctx, models.DoctorOptions{})
+
+		status := http.StatusOK
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+
+		json(ctx, status, gin.H{"report": report})
+	}
+}
+
+func HandleGetQuarantinedNotes(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		notes, err := m.NoteGetQuarantined(ctx)
+		if err != nil {
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting quarantined notes."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"notes": notes})
+	}
+}
+
+func HandleRepairQuarantinedNotes(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report, err := m.NoteRepairQuarantined(ctx)
+		if err != nil {
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while repairing notes.", "report": report})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"report": report})
+	}
+}
+
+func HandleGetAuditEvents(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var filter models.AuditFilter
+
+		if err := ctx.ShouldBindQuery(&filter); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		events, err := m.AuditQuery(ctx, filter)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidInput) {
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting audit events."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"events": events})
+	}
+}
+
+// HandleExportAuditEvents streams the events matching the query filters as JSON lines. Errors
+// after the first event is written can only be logged since the status is already sent.
+func HandleExportAuditEvents(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var filter models.AuditFilter
+
+		if err := ctx.ShouldBindQuery(&filter); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		ctx.Header("Content-Type", "application/x-ndjson")
+		ctx.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+
+		count, err := m.AuditExport(ctx, filter, ctx.Writer)
+		if err != nil {
+			if count == 0 && !ctx.Writer.Written() {
+				ctx.Writer.Header().Del("Content-Type")
+				ctx.Writer.Header().Del("Content-Disposition")
+				if errors.Is(err, models.ErrInvalidInput) {
+					json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+					return
+				}
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while exporting audit events."})
+				return
+			}
+			logger.Log.Error().Err(err).Int("exported", count).Msg("Audit export interrupted")
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+func HandleVerifyAuditLog(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var expected models.AuditHead
+
+		if err := ctx.ShouldBindQuery(&expected); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		report, err := m.AuditVerify(ctx, expected)
+		if err != nil {
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while verifying the audit log."})
+			return
+		}
+
+		status := http.StatusOK
+		if !report.Valid {
+			status = http.StatusConflict
+		}
+
+		json(ctx, status, gin.H{"report": report})
+	}
+}
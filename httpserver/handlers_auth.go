@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func HandleLogin(m models.Models, sm *sessionManager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var loginParams models.LoginParams
+
+		if err := ctx.ShouldBindJSON(&loginParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		if loginParams.Username == "" {
+			json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid request: username is required."})
+			return
+		}
+
+		user, err := m.UserAuthenticate(ctx, loginParams)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				json(ctx, http.StatusUnauthorized, gin.H{"error": "Invalid username or password."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while signing in."})
+			return
+		}
+
+		sess := sm.issue(ctx, user)
+
+		json(ctx, http.StatusOK, gin.H{"user": user, "expires_at": time.Unix(sess.ExpiresAt, 0).UTC().Format(time.RFC3339)})
+	}
+}
+
+func HandleReauth(m models.Models, sm *sessionManager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var loginParams models.LoginParams
+
+		if err := ctx.ShouldBindJSON(&loginParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		loginParams.Username = ctx.MustGet(sessionKey).(session).Username
+
+		user, err := m.UserReauthenticate(ctx, loginParams)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				json(ctx, http.StatusUnauthorized, gin.H{"error": "Invalid password."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while re-authenticating."})
+			return
+		}
+
+		sess := sm.issue(ctx, user)
+
+		json(ctx, http.StatusOK, gin.H{"user": user, "authenticated_at": time.Unix(sess.AuthenticatedAt, 0).UTC().Format(time.RFC3339)})
+	}
+}
+
+func HandleLogout(m models.Models, sm *sessionManager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := ctx.Get(sessionKey); ok {
+			m.UserLogout(ctx)
+		}
+
+		sm.clear(ctx)
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
+
+func HandleGetCurrentUser(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		sess := ctx.MustGet(sessionKey).(session)
+
+		user, err := m.UserGetByID(ctx, sess.UserID)
+		if err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting the current user."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"user": user, "authenticated_at": time.Unix(sess.AuthenticatedAt, 0).UTC().Format(time.RFC3339)})
+	}
+}
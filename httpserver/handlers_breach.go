@@ -0,0 +1,34 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// HandleCheckPassword looks a value up in the local breach dataset without saving it.
+func HandleCheckPassword(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var params models.PasswordCheckParams
+
+		if err := ctx.ShouldBindJSON(&params); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		result, err := m.CheckPassword(params)
+		if err != nil {
+			if errors.Is(err, models.ErrBreachCheckUnavailable) {
+				json(ctx, http.StatusServiceUnavailable, gin.H{"error": "Breach checks are not available."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while checking the password."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"result": result})
+	}
+}
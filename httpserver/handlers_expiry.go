@@ -0,0 +1,76 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// HandleGetExpiringNotes lists notes that are expired or due within the window given by the
+// optional within query parameter, defaulting to the configured expiry warning.
+func HandleGetExpiringNotes(m models.Models, defaultWindow time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		within := defaultWindow
+
+		if raw := ctx.Query("within"); raw != "" {
+			val, err := time.ParseDuration(raw)
+			if err != nil || val < 0 {
+				json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid request: within must be a non-negative duration such as 168h."})
+				return
+			}
+			within = val
+		}
+
+		notes, err := m.NoteGetExpiring(ctx, within)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting expiring notes."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"notes": notes})
+	}
+}
+
+func HandleReviewNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var reviewParams models.NoteReviewParams
+
+		// the body is optional, an empty review only marks the note as reviewed
+		if ctx.Request.ContentLength != 0 {
+			if err := ctx.ShouldBindJSON(&reviewParams); err != nil {
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+				return
+			}
+		}
+
+		note, err := m.NoteReview(ctx, noteID, reviewParams)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			switch {
+			case errors.Is(err, models.ErrNotFound):
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found."})
+			case errors.Is(err, models.ErrInvalidInput):
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			default:
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while reviewing note."})
+			}
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"note": note})
+	}
+}
@@ -0,0 +1,208 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func HandleGetFolders(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		folders, err := m.FolderGetAll(ctx)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting folders."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"folders": folders})
+	}
+}
+
+func HandleCreateFolder(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var folderParams models.FolderParams
+
+		if err := ctx.ShouldBindJSON(&folderParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		folder, err := m.FolderCreate(ctx, folderParams)
+		if err != nil {
+			respondFolderError(ctx, err, "saving folder")
+			return
+		}
+
+		json(ctx, http.StatusCreated, gin.H{"folder": folder})
+	}
+}
+
+func HandleUpdateFolder(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		folderID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var folderParams models.FolderParams
+
+		if err := ctx.ShouldBindJSON(&folderParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		folder, err := m.FolderUpdate(ctx, folderID, folderParams)
+		if err != nil {
+			respondFolderError(ctx, err, "updating folder")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"folder": folder})
+	}
+}
+
+// HandleDeleteFolder deletes a folder and its subfolders. The notes query parameter decides
+// whether their notes are moved out of any folder (root, the default) or to the trash (trash).
+func HandleDeleteFolder(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		folderID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		result, err := m.FolderDelete(ctx, folderID, ctx.Query("notes"))
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if respondNameConflict(ctx, err, "A note in the folder would have the same name as another note outside any folder.") {
+				return
+			}
+			respondFolderError(ctx, err, "deleting folder")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"deleted": result})
+	}
+}
+
+func HandleGetTags(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tags, err := m.TagGetAll(ctx)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting tags."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"tags": tags})
+	}
+}
+
+func HandleMoveNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var folderParams models.NoteFolderParams
+
+		if err := ctx.ShouldBindJSON(&folderParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		if err := m.NoteMove(ctx, noteID, folderParams); err != nil {
+			respondNoteError(ctx, err, "moving note")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"folder_id": folderParams.FolderID})
+	}
+}
+
+func HandleSetNoteTags(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var tagsParams models.NoteTagsParams
+
+		if err := ctx.ShouldBindJSON(&tagsParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		tags, err := m.NoteSetTags(ctx, noteID, tagsParams)
+		if err != nil {
+			respondNoteError(ctx, err, "tagging note")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"tags": tags})
+	}
+}
+
+// HandleSetNoteFavorite marks a note as a favorite, or unmarks it when favorite is false.
+func HandleSetNoteFavorite(m models.Models, favorite bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := m.NoteSetFavorite(ctx, noteID, favorite); err != nil {
+			respondNoteError(ctx, err, "updating note")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"favorite": favorite})
+	}
+}
+
+// respondFolderError responds to a failed folder operation with 401 or 403 if access was denied,
+// 404 for missing folders, 409 for duplicate names and 400 for invalid input.
+func respondFolderError(ctx *gin.Context, err error, action string) {
+	if respondAccessError(ctx, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		json(ctx, http.StatusNotFound, gin.H{"error": "Folder not found."})
+	case errors.Is(err, models.ErrAlreadyExists):
+		json(ctx, http.StatusConflict, gin.H{"error": "A folder with this name already exists here."})
+	case errors.Is(err, models.ErrInvalidInput):
+		json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+	default:
+		json(ctx, http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Something went wrong while %s.", action)})
+	}
+}
+
+// respondNoteError responds to a failed note operation with 401 or 403 if access was denied, 404
+// for missing notes, 409 for duplicate names and 400 for invalid input.
+func respondNoteError(ctx *gin.Context, err error, action string) {
+	if respondAccessError(ctx, err) || respondNameConflict(ctx, err, noteNameConflict) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		json(ctx, http.StatusNotFound, gin.H{"error": "Note not found."})
+	case errors.Is(err, models.ErrInvalidInput):
+		json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+	default:
+		json(ctx, http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Something went wrong while %s.", action)})
+	}
+}
@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/generator"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func HandleCreateRandomNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var createRandomParams models.NoteCreateRandomParams
+
+		if err := ctx.ShouldBindJSON(&createRandomParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		note, err := m.NoteCreateRandom(ctx, createRandomParams)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if respondPolicyError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrInvalidInput) {
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while saving note."})
+			return
+		}
+
+		json(ctx, http.StatusCreated, gin.H{"note": note})
+	}
+}
+
+// HandleGenerate previews a generated password or passphrase without saving anything.
+func HandleGenerate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var opts generator.Options
+
+		if err := ctx.ShouldBindJSON(&opts); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		generated, err := models.Generate(opts)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidInput) {
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while generating a value."})
+			return
+		}
+
+		// generated values must not be cached by the browser or proxies
+		ctx.Header("Cache-Control", "no-store")
+		json(ctx, http.StatusOK, gin.H{"generated": generated})
+	}
+}
@@ -0,0 +1,101 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// maxImportSize bounds the request body of queued note imports.
+const maxImportSize = 10 << 20
+
+func HandleGetJob(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		jobID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		job, err := m.JobGetByID(ctx, jobID)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Job not found."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting job."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"job": job})
+	}
+}
+
+// HandleImportNotes queues an import of the JSON lines request body into the vault given by the
+// vault_id query parameter. The on_conflict query parameter decides whether records whose name is
+// taken fail the import (the default), are renamed (rename) or replace the existing note (replace).
+// Poll the returned job for progress.
+func HandleImportNotes(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		vaultID, err := strconv.ParseInt(ctx.Query("vault_id"), 10, 64)
+		if err != nil || vaultID <= 0 {
+			json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid vault_id."})
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize))
+		if err != nil {
+			json(ctx, http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Import must be at most %d MB.", maxImportSize>>20)})
+			return
+		}
+
+		if len(data) == 0 {
+			json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid request: body must contain JSON lines."})
+			return
+		}
+
+		job, err := m.NoteImportEnqueue(ctx, data, vaultID, ctx.Query("on_conflict"))
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrInvalidInput) {
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while queueing import."})
+			return
+		}
+
+		json(ctx, http.StatusAccepted, gin.H{"job": job})
+	}
+}
+
+func HandleQueueAuditVerify(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var expected models.AuditHead
+
+		if err := ctx.ShouldBindQuery(&expected); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		job, err := m.AuditVerifyEnqueue(ctx, expected)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while queueing audit verification."})
+			return
+		}
+
+		json(ctx, http.StatusAccepted, gin.H{"job": job})
+	}
+}
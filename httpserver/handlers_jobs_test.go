@@ -0,0 +1,94 @@
+package httpserver
+
+import (
+	"context"
+	encjson "encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/models"
+	"github.com/oalexander6/web-app-template/queue"
+)
+
+// jobTestStore keeps jobs in memory and accepts audit events. Other store methods are not
+// implemented.
+type jobTestStore struct {
+	models.Store
+	jobs *queue.MemoryStore
+}
+
+func (s jobTestStore) JobEnqueue(ctx context.Context, params models.JobEnqueueParams) (models.Job, error) {
+	return s.jobs.JobEnqueue(ctx, params)
+}
+
+func (s jobTestStore) JobGetByID(ctx context.Context, id int64) (models.Job, error) {
+	return s.jobs.JobGetByID(ctx, id)
+}
+
+func (s jobTestStore) AuditAppend(ctx context.Context, event models.AuditEvent, hash func(prevHash string, event models.AuditEvent) string) (models.AuditEvent, error) {
+	return event, nil
+}
+
+func (s jobTestStore) AuditList(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	return []models.AuditEvent{}, nil
+}
+
+func TestAdminCanGetQueuedAuditVerifyJob(t *testing.T) {
+	conf := &config.Config{Env: config.LOCAL_ENV, SecretKey: "a-real-session-secret", AdminToken: "admin-token"}
+	runtime, err := config.NewReloader(config.RuntimeConfig{LogLevel: "info"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	store := jobTestStore{jobs: queue.NewMemoryStore()}
+	m := models.New(store, conf)
+	s := New(conf, runtime, *m)
+
+	request := func(method string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-XSRF-Protection", "1")
+		req.Header.Set("Authorization", "Bearer admin-token")
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request("POST", "/api/v1/admin/audit/verify")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var queued struct {
+		Job models.JobStatusResponse `json:"job"`
+	}
+	if err := encjson.Unmarshal(rr.Body.Bytes(), &queued); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	job, err := store.jobs.JobGetByID(context.Background(), queued.Job.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	ctx := models.WithActor(context.Background(), models.SystemActor("test"))
+	result, err := m.AuditVerifyRun(ctx, job, func(int, string) {})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := store.jobs.JobComplete(ctx, job.ID, result); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	rr = request("GET", fmt.Sprintf("/api/v1/admin/jobs/%d", job.ID))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if !strings.Contains(rr.Body.String(), `\"valid\":true`) {
+		t.Errorf("Expected the verify report in the result, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,378 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func HandleGetOrganizations(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizations, err := m.OrganizationGetAll(ctx)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Organization not found.", "getting organizations")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"organizations": organizations})
+	}
+}
+
+func HandleCreateOrganization(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var organizationParams models.OrganizationParams
+
+		if err := ctx.ShouldBindJSON(&organizationParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		organization, err := m.OrganizationCreate(ctx, organizationParams)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Organization not found.", "saving organization")
+			return
+		}
+
+		json(ctx, http.StatusCreated, gin.H{"organization": organization})
+	}
+}
+
+// HandleGetVaults lists the vaults the caller can read, with their role in each.
+func HandleGetVaults(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		vaults, err := m.VaultGetAll(ctx)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Vault not found.", "getting vaults")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"vaults": vaults})
+	}
+}
+
+func HandleCreateVault(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var vaultParams models.VaultParams
+
+		if err := ctx.ShouldBindJSON(&vaultParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		vault, err := m.VaultCreate(ctx, organizationID, vaultParams)
+		if err != nil {
+			if errors.Is(err, models.ErrAlreadyExists) {
+				json(ctx, http.StatusConflict, gin.H{"error": "A vault with this name already exists in the organization."})
+				return
+			}
+			respondOrganizationError(ctx, err, "Organization not found.", "saving vault")
+			return
+		}
+
+		json(ctx, http.StatusCreated, gin.H{"vault": vault})
+	}
+}
+
+// HandleUpdateVaultSettings changes the settings of a vault, such as the number of versions kept
+// per note.
+func HandleUpdateVaultSettings(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		vaultID, ok := parseNamedIDParam(ctx, "vault_id")
+		if !ok {
+			return
+		}
+
+		var settingsParams models.VaultSettingsParams
+
+		if err := ctx.ShouldBindJSON(&settingsParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		vault, err := m.VaultUpdateSettings(ctx, organizationID, vaultID, settingsParams)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Vault not found.", "updating vault")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"vault": vault})
+	}
+}
+
+// HandleGetPasswordPolicy returns the password policy of an organization, the instance default
+// if it has none of its own.
+func HandleGetPasswordPolicy(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		policy, err := m.PasswordPolicyGet(ctx, organizationID)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Organization not found.", "getting password policy")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"password_policy": policy})
+	}
+}
+
+// HandleSetPasswordPolicy replaces the password policy of an organization.
+func HandleSetPasswordPolicy(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var policyParams models.PasswordPolicyParams
+
+		if err := ctx.ShouldBindJSON(&policyParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		policy, err := m.PasswordPolicySet(ctx, organizationID, policyParams)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Organization not found.", "saving password policy")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"password_policy": policy})
+	}
+}
+
+// HandleResetPasswordPolicy removes the password policy of an organization, so the instance
+// default applies again.
+func HandleResetPasswordPolicy(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		policy, err := m.PasswordPolicyReset(ctx, organizationID)
+		if err != nil {
+			respondOrganizationError(ctx, err, "The organization has no password policy of its own.", "removing password policy")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"password_policy": policy})
+	}
+}
+
+func HandleGetMembers(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		members, err := m.MemberGetAll(ctx, organizationID)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Organization not found.", "getting members")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"members": members})
+	}
+}
+
+// HandleSetMemberRole changes the role of a member. Only owners can grant or take away the owner
+// role, and an organization always keeps at least one owner.
+func HandleSetMemberRole(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		memberID, ok := parseNamedIDParam(ctx, "member_id")
+		if !ok {
+			return
+		}
+
+		var roleParams models.MemberRoleParams
+
+		if err := ctx.ShouldBindJSON(&roleParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		member, err := m.MemberSetRole(ctx, organizationID, memberID, roleParams)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Member not found.", "updating member")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"member": member})
+	}
+}
+
+// HandleRemoveMember removes a member from an organization or vault. Members can always remove
+// themselves, unless they are its last owner.
+func HandleRemoveMember(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		memberID, ok := parseNamedIDParam(ctx, "member_id")
+		if !ok {
+			return
+		}
+
+		if err := m.MemberRemove(ctx, organizationID, memberID); err != nil {
+			respondOrganizationError(ctx, err, "Member not found.", "removing member")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
+
+func HandleGetInvitations(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		invitations, err := m.InvitationGetAll(ctx, organizationID)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Organization not found.", "getting invitations")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"invitations": invitations})
+	}
+}
+
+// HandleInviteMember invites a user to the organization, or to one of its vaults when vault_id is
+// set. The invitation expires after a week unless the user accepts it.
+func HandleInviteMember(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var invitationParams models.InvitationParams
+
+		if err := ctx.ShouldBindJSON(&invitationParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		invitation, err := m.MemberInvite(ctx, organizationID, invitationParams)
+		if err != nil {
+			if errors.Is(err, models.ErrAlreadyExists) {
+				json(ctx, http.StatusConflict, gin.H{"error": "The user is already a member or has a pending invitation."})
+				return
+			}
+			respondOrganizationError(ctx, err, "Organization not found.", "inviting member")
+			return
+		}
+
+		json(ctx, http.StatusCreated, gin.H{"invitation": invitation})
+	}
+}
+
+func HandleRevokeInvitation(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		invitationID, ok := parseNamedIDParam(ctx, "invitation_id")
+		if !ok {
+			return
+		}
+
+		if err := m.InvitationRevoke(ctx, organizationID, invitationID); err != nil {
+			respondOrganizationError(ctx, err, "Invitation not found.", "revoking invitation")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
+
+// HandleGetMyInvitations lists the caller's pending invitations.
+func HandleGetMyInvitations(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		invitations, err := m.InvitationGetMine(ctx)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Invitation not found.", "getting invitations")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"invitations": invitations})
+	}
+}
+
+func HandleAcceptInvitation(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		invitationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		member, err := m.InvitationAccept(ctx, invitationID)
+		if err != nil {
+			respondOrganizationError(ctx, err, "Invitation not found.", "accepting invitation")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"member": member})
+	}
+}
+
+func HandleDeclineInvitation(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		invitationID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := m.InvitationDecline(ctx, invitationID); err != nil {
+			respondOrganizationError(ctx, err, "Invitation not found.", "declining invitation")
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
+
+// respondOrganizationError responds to a failed organization operation with 401 or 403 if access
+// was denied, 404 with the message if something is missing and 400 for invalid input.
+func respondOrganizationError(ctx *gin.Context, err error, notFound string, action string) {
+	if respondAccessError(ctx, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		json(ctx, http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, models.ErrInvalidInput):
+		json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+	default:
+		json(ctx, http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Something went wrong while %s.", action)})
+	}
+}
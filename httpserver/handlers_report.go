@@ -0,0 +1,24 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// HandleGetSecurityReport returns the strength, reuse and age findings for every note.
+func HandleGetSecurityReport(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report, err := m.SecurityReport(ctx)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while building the security report."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"report": report})
+	}
+}
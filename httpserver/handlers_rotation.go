@@ -0,0 +1,127 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func HandleGetRotationPolicy(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		policy, err := m.NoteRotationPolicyGet(ctx, noteID)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Rotation policy not found."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting rotation policy."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"policy": policy})
+	}
+}
+
+func HandleSetRotationPolicy(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		var policyParams models.NoteRotationPolicyParams
+
+		if err := ctx.ShouldBindJSON(&policyParams); err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			return
+		}
+
+		policy, err := m.NoteRotationPolicySet(ctx, noteID, policyParams)
+		if err != nil {
+			if respondAccessError(ctx, err) || respondPolicyError(ctx, err) {
+				return
+			}
+			switch {
+			case errors.Is(err, models.ErrNotFound):
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found."})
+			case errors.Is(err, models.ErrInvalidInput):
+				json(ctx, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %s.", err)})
+			default:
+				json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while saving rotation policy."})
+			}
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"policy": policy})
+	}
+}
+
+func HandleDeleteRotationPolicy(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := m.NoteRotationPolicyDelete(ctx, noteID); err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Rotation policy not found."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while deleting rotation policy."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
+
+// HandleRotateNote regenerates a note's value now. With ?dry_run=true the value is left
+// unchanged.
+func HandleRotateNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+		if err != nil {
+			json(ctx, http.StatusBadRequest, gin.H{"error": "Invalid request: dry_run must be true or false."})
+			return
+		}
+
+		result, err := m.NoteRotate(ctx, noteID, dryRun)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if respondPolicyError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Rotation policy not found."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while rotating note."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"rotation": result})
+	}
+}
@@ -1,11 +1,14 @@
 package httpserver
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
 )
 
 func TestHelloWorldHandler(t *testing.T) {
@@ -30,3 +33,60 @@ func TestHelloWorldHandler(t *testing.T) {
 		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
+
+func TestRespondNameConflict(t *testing.T) {
+	r := gin.New()
+	r.Use(requestIDMiddleware)
+	r.GET("/", func(ctx *gin.Context) {
+		err := fmt.Errorf("line 3: %w", &models.NameConflictError{NoteID: 42})
+		if !respondNameConflict(ctx, err, noteNameConflict) {
+			t.Error("Expected the conflict to be handled")
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	if !strings.Contains(rr.Body.String(), `"conflict_id":42`) {
+		t.Errorf("Expected the conflicting note ID in the body, got %v", rr.Body.String())
+	}
+}
+
+func TestRespondAccessError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{models.ErrUnauthenticated, http.StatusUnauthorized},
+		{fmt.Errorf("vault 1: %w", models.ErrForbidden), http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		r := gin.New()
+		r.Use(requestIDMiddleware)
+		r.GET("/", func(ctx *gin.Context) {
+			if !respondAccessError(ctx, test.err) {
+				t.Errorf("Expected %v to be handled", test.err)
+			}
+		})
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+		if rr.Code != test.want {
+			t.Errorf("Handler returned wrong status code for %v: got %v want %v", test.err, rr.Code, test.want)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/", func(ctx *gin.Context) {
+		if respondAccessError(ctx, models.ErrNotFound) {
+			t.Error("Expected other errors not to be handled")
+		}
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,96 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func HandleDeleteNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := m.NoteDeleteByID(ctx, noteID); err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while deleting note."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
+
+func HandleGetTrash(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		notes, err := m.NoteGetTrash(ctx)
+		if err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while getting the trash."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{"notes": notes})
+	}
+}
+
+func HandleRestoreTrashNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := m.NoteRestoreFromTrash(ctx, noteID); err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found in trash."})
+				return
+			}
+			if respondNameConflict(ctx, err, noteNameConflict) {
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while restoring note."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
+
+func HandlePurgeTrashNote(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		noteID, ok := parseIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := m.NotePurge(ctx, noteID); err != nil {
+			if respondAccessError(ctx, err) {
+				return
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				json(ctx, http.StatusNotFound, gin.H{"error": "Note not found in trash."})
+				return
+			}
+			json(ctx, http.StatusInternalServerError, gin.H{"error": "Something went wrong while purging note."})
+			return
+		}
+
+		json(ctx, http.StatusOK, gin.H{})
+	}
+}
@@ -1,10 +1,15 @@
 package httpserver
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/models"
 )
 
 const (
@@ -38,3 +43,73 @@ func csrfHeaderMiddleware(ctx *gin.Context) {
 
 	ctx.Next()
 }
+
+// getCORSMiddleware allows cross-origin requests from the configured origins. Only origins
+// listed explicitly may send credentials: a * entry allows reading responses from any origin,
+// but without the session cookie. Preflight requests from allowed origins are answered here,
+// any other OPTIONS request is passed on.
+func getCORSMiddleware(runtime *config.Reloader) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		origin := ctx.Request.Header.Get("Origin")
+		allowed := runtime.Current().CORSOrigins
+
+		listed := origin != "" && slices.Contains(allowed, origin)
+		wildcard := origin != "" && !listed && slices.Contains(allowed, "*")
+
+		ctx.Header("Vary", "Origin")
+
+		if listed {
+			ctx.Header("Access-Control-Allow-Origin", origin)
+			ctx.Header("Access-Control-Allow-Credentials", "true")
+		} else if wildcard {
+			ctx.Header("Access-Control-Allow-Origin", "*")
+		}
+
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.Request.Header.Get("Access-Control-Request-Method") != ""
+		if preflight && (listed || wildcard) {
+			ctx.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-XSRF-Protection")
+			ctx.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			ctx.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// getAdminAuthMiddleware authenticates admin requests with the admin token. Each refused
+// attempt is audited, uses of the token only on the routes that add auditAdminUse.
+func getAdminAuthMiddleware(conf *config.Config, m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if conf.AdminToken == "" {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		token, ok := strings.CutPrefix(ctx.Request.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(conf.AdminToken)) != 1 {
+			m.AuditAdminToken(ctx.Request.Context(), models.AUDIT_OUTCOME_DENIED)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// the admin token grants operator access to instance wide operations
+		actor := models.ActorFromContext(ctx.Request.Context())
+		actor.System = true
+		actor.Username = "admin"
+		ctx.Request = ctx.Request.WithContext(models.WithActor(ctx.Request.Context(), actor))
+
+		ctx.Next()
+	}
+}
+
+// auditAdminUse audits a use of the admin token. It is only added to admin routes that change
+// the instance or return the audit log, so polled routes such as metrics don't flood the
+// audit log and its hash chain.
+func auditAdminUse(m models.Models) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		m.AuditAdminToken(ctx.Request.Context(), models.AUDIT_OUTCOME_SUCCESS)
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/models"
+	"github.com/oalexander6/web-app-template/queue"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	runtime, err := config.NewReloader(config.RuntimeConfig{LogLevel: "info", CORSOrigins: []string{"https://app.example.com", "*"}}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	r := gin.New()
+	r.Use(getCORSMiddleware(runtime))
+	r.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+		credentials string
+	}{
+		{"listed origin", "GET", "https://app.example.com", false, http.StatusOK, "https://app.example.com", "true"},
+		{"wildcard origin", "GET", "https://evil.example.com", false, http.StatusOK, "*", ""},
+		{"listed preflight", "OPTIONS", "https://app.example.com", true, http.StatusNoContent, "https://app.example.com", "true"},
+		{"options without preflight headers", "OPTIONS", "https://app.example.com", false, http.StatusNotFound, "https://app.example.com", "true"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/", nil)
+		req.Header.Set("Origin", test.origin)
+		if test.preflight {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, rr.Code, test.status)
+		}
+
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != test.allowOrigin {
+			t.Errorf("%s: got allowed origin %q, want %q", test.name, got, test.allowOrigin)
+		}
+
+		if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != test.credentials {
+			t.Errorf("%s: got credentials %q, want %q", test.name, got, test.credentials)
+		}
+	}
+}
+
+func TestCORSMiddlewareRejectsUnlistedPreflight(t *testing.T) {
+	runtime, err := config.NewReloader(config.RuntimeConfig{LogLevel: "info", CORSOrigins: []string{"https://app.example.com"}}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	r := gin.New()
+	r.Use(getCORSMiddleware(runtime))
+	r.POST("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code == http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("Expected the preflight to be refused, got %d with %v", rr.Code, rr.Header())
+	}
+}
+
+// auditTestStore records the audit events appended by the admin routes.
+type auditTestStore struct {
+	jobTestStore
+	events *[]models.AuditEvent
+}
+
+func (s auditTestStore) AuditAppend(ctx context.Context, event models.AuditEvent, hash func(prevHash string, event models.AuditEvent) string) (models.AuditEvent, error) {
+	*s.events = append(*s.events, event)
+	return event, nil
+}
+
+func TestAdminAuthAuditsOnlyRefusalsAndChanges(t *testing.T) {
+	conf := &config.Config{Env: config.LOCAL_ENV, SecretKey: "a-real-session-secret", AdminToken: "admin-token"}
+	runtime, err := config.NewReloader(config.RuntimeConfig{LogLevel: "info"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var events []models.AuditEvent
+	m := models.New(auditTestStore{jobTestStore: jobTestStore{jobs: queue.NewMemoryStore()}, events: &events}, conf)
+	s := New(conf, runtime, *m)
+
+	request := func(method string, path string, token string) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-XSRF-Protection", "1")
+		req.Header.Set("Authorization", "Bearer "+token)
+		s.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("GET", "/api/v1/admin/metrics", "admin-token")
+	if len(events) != 0 {
+		t.Fatalf("Expected metrics scrapes not to be audited, got %+v", events)
+	}
+
+	request("GET", "/api/v1/admin/metrics", "wrong-token")
+	if len(events) != 1 || events[0].Outcome != models.AUDIT_OUTCOME_DENIED {
+		t.Fatalf("Expected the refused token to be audited, got %+v", events)
+	}
+
+	request("POST", "/api/v1/admin/audit/verify", "admin-token")
+	if len(events) < 2 || events[1].Action != models.AUDIT_ACTION_ADMIN_TOKEN || events[1].Outcome != models.AUDIT_OUTCOME_SUCCESS {
+		t.Fatalf("Expected the use of the token to be audited, got %+v", events)
+	}
+}
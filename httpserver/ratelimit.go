@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/config"
+)
+
+const rateLimitBucketTTL = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter implements a token bucket per client IP. Limits are read from the runtime
+// configuration on every request so reloaded limits apply immediately.
+type rateLimiter struct {
+	runtime *config.Reloader
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(runtime *config.Reloader) *rateLimiter {
+	return &rateLimiter{
+		runtime:   runtime,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow reports whether the client may make another request, consuming a token if so.
+func (l *rateLimiter) allow(clientIP string, limits config.RateLimitConfig, now time.Time) bool {
+	if limits.RequestsPerSecond <= 0 {
+		return true
+	}
+
+	burst := float64(limits.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitBucketTTL {
+		for ip, bucket := range l.buckets {
+			if now.Sub(bucket.lastSeen) > rateLimitBucketTTL {
+				delete(l.buckets, ip)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.buckets[clientIP]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, lastSeen: now}
+		l.buckets[clientIP] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.lastSeen).Seconds() * limits.RequestsPerSecond
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.lastSeen = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+func (l *rateLimiter) middleware(ctx *gin.Context) {
+	if !l.allow(ctx.ClientIP(), l.runtime.Current().RateLimit, time.Now()) {
+		ctx.Header("Retry-After", "1")
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+		return
+	}
+
+	ctx.Next()
+}
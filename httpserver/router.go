@@ -5,12 +5,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/oalexander6/web-app-template/config"
 	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/metrics"
 	"github.com/oalexander6/web-app-template/models"
 )
 
 func (s *Server) createRouter(m models.Models) *gin.Engine {
 	r := gin.New()
 	r.SetTrustedProxies(nil)
+	// let models read the actor attached to the request context through *gin.Context
+	r.ContextWithFallback = true
+
+	sessions := newSessionManager(s.config)
 
 	r.Use(gin.Recovery())
 	r.Use(gin.LoggerWithWriter(logger.Log))
@@ -21,13 +26,108 @@ func (s *Server) createRouter(m models.Models) *gin.Engine {
 
 	r.Use(requestIDMiddleware)
 	r.Use(getSecurityHeadersMiddleware())
+	r.Use(getCORSMiddleware(s.runtime))
+	r.Use(newRateLimiter(s.runtime).middleware)
 	r.Use(csrfHeaderMiddleware)
+	r.Use(getActorMiddleware(sessions))
 
 	apiGroup := r.Group("/api/v1")
 	{
 		apiGroup.GET("", HandleHello())
+		apiGroup.POST("/generate", HandleGenerate())
+		apiGroup.POST("/check-password", HandleCheckPassword(m))
 		apiGroup.GET("/notes", HandleGetAllNotes(m))
 		apiGroup.POST("/notes", HandleCreateNote(m))
+		apiGroup.POST("/notes/random", HandleCreateRandomNote(m))
+		apiGroup.POST("/notes/import", HandleImportNotes(m))
+		apiGroup.GET("/notes/search", HandleSearchNotes(m))
+		apiGroup.GET("/notes/expiring", HandleGetExpiringNotes(m, s.config.Notes.ExpiryWarning))
+		apiGroup.PUT("/notes/:id", HandleUpdateNote(m))
+		apiGroup.DELETE("/notes/:id", HandleDeleteNote(m))
+		apiGroup.POST("/notes/:id/reveal", HandleRevealNote(m))
+		apiGroup.POST("/notes/:id/review", HandleReviewNote(m))
+		apiGroup.GET("/notes/:id/rotation", HandleGetRotationPolicy(m))
+		apiGroup.PUT("/notes/:id/rotation", HandleSetRotationPolicy(m))
+		apiGroup.DELETE("/notes/:id/rotation", HandleDeleteRotationPolicy(m))
+		apiGroup.POST("/notes/:id/rotate", HandleRotateNote(m))
+		apiGroup.GET("/notes/:id/versions", HandleGetNoteVersions(m))
+		apiGroup.POST("/notes/:id/versions/:version/reveal", HandleRevealNoteVersion(m))
+		apiGroup.POST("/notes/:id/versions/:version/restore", HandleRestoreNoteVersion(m))
+		apiGroup.PUT("/notes/:id/folder", HandleMoveNote(m))
+		apiGroup.PUT("/notes/:id/tags", HandleSetNoteTags(m))
+		apiGroup.POST("/notes/:id/favorite", HandleSetNoteFavorite(m, true))
+		apiGroup.DELETE("/notes/:id/favorite", HandleSetNoteFavorite(m, false))
+	}
+
+	folderGroup := apiGroup.Group("/folders")
+	{
+		folderGroup.GET("", HandleGetFolders(m))
+		folderGroup.POST("", HandleCreateFolder(m))
+		folderGroup.PUT("/:id", HandleUpdateFolder(m))
+		folderGroup.DELETE("/:id", HandleDeleteFolder(m))
+	}
+
+	apiGroup.GET("/tags", HandleGetTags(m))
+
+	apiGroup.GET("/jobs/:id", HandleGetJob(m))
+	apiGroup.GET("/reports/security", HandleGetSecurityReport(m))
+
+	trashGroup := apiGroup.Group("/trash")
+	{
+		trashGroup.GET("", HandleGetTrash(m))
+		trashGroup.POST("/:id/restore", HandleRestoreTrashNote(m))
+		trashGroup.DELETE("/:id", HandlePurgeTrashNote(m))
+	}
+
+	organizationGroup := apiGroup.Group("/organizations")
+	{
+		organizationGroup.GET("", HandleGetOrganizations(m))
+		organizationGroup.POST("", HandleCreateOrganization(m))
+		organizationGroup.POST("/:id/vaults", HandleCreateVault(m))
+		organizationGroup.PUT("/:id/vaults/:vault_id", HandleUpdateVaultSettings(m))
+		organizationGroup.GET("/:id/password-policy", HandleGetPasswordPolicy(m))
+		organizationGroup.PUT("/:id/password-policy", HandleSetPasswordPolicy(m))
+		organizationGroup.DELETE("/:id/password-policy", HandleResetPasswordPolicy(m))
+		organizationGroup.GET("/:id/members", HandleGetMembers(m))
+		organizationGroup.PUT("/:id/members/:member_id", HandleSetMemberRole(m))
+		organizationGroup.DELETE("/:id/members/:member_id", HandleRemoveMember(m))
+		organizationGroup.GET("/:id/invitations", HandleGetInvitations(m))
+		organizationGroup.POST("/:id/invitations", HandleInviteMember(m))
+		organizationGroup.DELETE("/:id/invitations/:invitation_id", HandleRevokeInvitation(m))
+	}
+
+	apiGroup.GET("/vaults", HandleGetVaults(m))
+
+	invitationGroup := apiGroup.Group("/invitations")
+	{
+		invitationGroup.GET("", HandleGetMyInvitations(m))
+		invitationGroup.POST("/:id/accept", HandleAcceptInvitation(m))
+		invitationGroup.POST("/:id/decline", HandleDeclineInvitation(m))
+	}
+
+	authGroup := apiGroup.Group("/auth")
+	{
+		authGroup.POST("/login", HandleLogin(m, sessions))
+		authGroup.POST("/logout", HandleLogout(m, sessions))
+		authGroup.POST("/reauth", requireSessionMiddleware, HandleReauth(m, sessions))
+		authGroup.GET("/me", requireSessionMiddleware, HandleGetCurrentUser(m))
+	}
+
+	adminGroup := apiGroup.Group("/admin", getAdminAuthMiddleware(s.config, m))
+	{
+		auditUse := auditAdminUse(m)
+
+		adminGroup.GET("/config/reload", HandleGetReloadStatus(s.runtime))
+		adminGroup.POST("/config/reload", auditUse, HandleReload(s.runtime))
+		adminGroup.GET("/doctor", HandleDoctor(m))
+		adminGroup.GET("/metrics", gin.WrapH(metrics.Handler()))
+		adminGroup.GET("/notes/quarantine", HandleGetQuarantinedNotes(m))
+		adminGroup.POST("/notes/quarantine/repair", auditUse, HandleRepairQuarantinedNotes(m))
+		adminGroup.GET("/audit", auditUse, HandleGetAuditEvents(m))
+		adminGroup.GET("/audit/export", auditUse, HandleExportAuditEvents(m))
+		adminGroup.GET("/audit/verify", auditUse, HandleVerifyAuditLog(m))
+		adminGroup.POST("/audit/verify", auditUse, HandleQueueAuditVerify(m))
+		adminGroup.GET("/jobs/:id", HandleGetJob(m))
 	}
 
 	return r
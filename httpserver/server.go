@@ -2,6 +2,7 @@ package httpserver
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,19 +16,22 @@ import (
 )
 
 type Server struct {
-	config *config.Config
-	router http.Handler
+	config  *config.Config
+	runtime *config.Reloader
+	router  http.Handler
 }
 
 // Initializes a new instance of a Gin HTTP server. If the environment set in the provided
-// config is PROD, Gin will run in release mode, otherwise debug mode.
-func New(conf *config.Config, m models.Models) *Server {
+// config is PROD, Gin will run in release mode, otherwise debug mode. Rate limits, CORS
+// origins and TLS certificates are read from the runtime config so reloads apply immediately.
+func New(conf *config.Config, runtime *config.Reloader, m models.Models) *Server {
 	if conf.Env == config.PROD_ENV {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	s := &Server{
-		config: conf,
+		config:  conf,
+		runtime: runtime,
 	}
 
 	s.router = s.createRouter(m)
@@ -42,8 +46,24 @@ func (s *Server) Run() {
 		Handler: s.router,
 	}
 
+	useTLS := s.runtime.Current().Certificate != nil
+	if useTLS {
+		srv.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return s.runtime.Current().Certificate, nil
+			},
+		}
+	}
+
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Log.Fatal().Msgf("Error while serving: %s\n", err)
 		}
 	}()
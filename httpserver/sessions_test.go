@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	sm := &sessionManager{secret: []byte("test-secret"), ttl: time.Hour}
+
+	r := gin.New()
+	r.POST("/login", func(ctx *gin.Context) {
+		sm.issue(ctx, models.UserGetResponse{ID: 7, Username: "alice"})
+	})
+	r.GET("/me", func(ctx *gin.Context) {
+		sess, ok := sm.parse(ctx)
+		if !ok {
+			ctx.Status(http.StatusUnauthorized)
+			return
+		}
+		ctx.String(http.StatusOK, sess.Username)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/login", nil))
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("Expected one HttpOnly session cookie, got %v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "alice" {
+		t.Fatalf("Expected valid session, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	tampered := *cookies[0]
+	tampered.Value = "eyJ1aWQiOjF9." + tampered.Value[len(tampered.Value)-10:]
+	req = httptest.NewRequest("GET", "/me", nil)
+	req.AddCookie(&tampered)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected tampered session to be rejected, got %d", rr.Code)
+	}
+}
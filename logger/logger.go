@@ -2,16 +2,69 @@ package logger
 
 import (
 	"io"
+	"os"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
+const (
+	FORMAT_JSON    = "json"
+	FORMAT_CONSOLE = "console"
+)
+
 var Log zerolog.Logger
 
-func Init(level zerolog.Level, w io.Writer) {
-	zerolog.SetGlobalLevel(level)
+// Options configures the global logger.
+type Options struct {
+	// minimum level to log
+	Level zerolog.Level
+	// json or console, defaults to json
+	Format string
+	// destination for console or JSON output, defaults to stdout
+	Writer io.Writer
+	// optional rotating file output, always written as JSON
+	File FileOptions
+	// log only 1 of every N debug and trace messages, 0 or 1 logs all of them
+	DebugSampleRate uint32
+}
+
+// Init initializes the global logger. All output passes through the redaction writer so
+// fields named like secrets or note values are scrubbed before being written.
+func Init(opts Options) error {
+	out := opts.Writer
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if opts.Format == FORMAT_CONSOLE {
+		out = zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
+	}
+
+	if opts.File.Path != "" {
+		file, err := NewRotatingFile(opts.File)
+		if err != nil {
+			return err
+		}
+		out = io.MultiWriter(out, file)
+	}
+
+	zerolog.SetGlobalLevel(opts.Level)
 	zerolog.TimeFieldFormat = time.RFC3339
-	Log = zerolog.New(w).With().Timestamp().Logger()
-	Log.Debug().Msgf("Logger initialized with level %d", level)
+
+	Log = zerolog.New(newRedactWriter(out)).With().Timestamp().Logger()
+
+	if opts.DebugSampleRate > 1 {
+		sampler := &zerolog.BasicSampler{N: opts.DebugSampleRate}
+		Log = Log.Sample(&zerolog.LevelSampler{TraceSampler: sampler, DebugSampler: sampler})
+	}
+
+	Log.Debug().Msgf("Logger initialized with level %s", opts.Level)
+
+	return nil
+}
+
+// SetLevel changes the minimum level logged by all loggers.
+func SetLevel(level zerolog.Level) {
+	zerolog.SetGlobalLevel(level)
 }
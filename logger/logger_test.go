@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRedactWriterScrubsSensitiveFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(newRedactWriter(&buf))
+
+	log.Info().
+		Str("password", "hunter2").
+		Str("value", "note plaintext").
+		Interface("config", map[string]any{"ENCRYPTION": map[string]string{"EncSecret": "abc"}, "PORT": "8000"}).
+		Str("name", "visible").
+		Msg("test")
+
+	out := buf.String()
+	for _, leaked := range []string{"hunter2", "note plaintext", "abc"} {
+		if strings.Contains(out, leaked) {
+			t.Fatalf("Expected %q to be redacted, got %s", leaked, out)
+		}
+	}
+
+	if !strings.Contains(out, "visible") || !strings.Contains(out, "8000") {
+		t.Fatalf("Expected non-sensitive fields to be kept, got %s", out)
+	}
+}
+
+func TestRedactWriterPassesThroughPlainEvents(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(newRedactWriter(&buf))
+
+	log.Info().Str("name", "visible").Msg("test")
+
+	if buf.String() != `{"level":"info","name":"visible","message":"test"}`+"\n" {
+		t.Fatalf("Expected event to be unchanged, got %s", buf.String())
+	}
+}
+
+func TestRotatingFileRotatesAndPrunes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	r, err := NewRotatingFile(FileOptions{Path: path, MaxSizeMB: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer r.Close()
+
+	chunk := bytes.Repeat([]byte("a"), 600*1024)
+	for i := 0; i < 4; i++ {
+		if _, err := r.Write(chunk); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 1 {
+		t.Fatalf("Expected 1 rotated file to be kept, got %d", len(rotated))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != int64(len(chunk)) {
+		t.Fatalf("Expected active file to hold the last chunk, got %v, err %v", info.Size(), err)
+	}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveFieldPattern matches field names that may hold secrets or note values.
+var sensitiveFieldPattern = regexp.MustCompile(`(?i)(pass(word|wd|phrase)?|secret|token|api[_-]?key|private[_-]?key|authorization|cookie|credential|^value$|^note[_-]?value$|^enc[_-]?(iv|secret)$|^cvv$)`)
+
+// sensitiveKeyHint is a cheap pre-check so events without suspicious keys skip decoding.
+var sensitiveKeyHint = regexp.MustCompile(`(?i)"[^"]*(pass|secret|token|key|authorization|cookie|credential|value|enc|cvv)[^"]*"\s*:`)
+
+// redactWriter scrubs sensitive fields from JSON log events before passing them on.
+type redactWriter struct {
+	next io.Writer
+}
+
+func newRedactWriter(next io.Writer) io.Writer {
+	return redactWriter{next: next}
+}
+
+// Write implements io.Writer. Events that cannot be decoded are passed through unchanged.
+func (w redactWriter) Write(p []byte) (int, error) {
+	if !sensitiveKeyHint.Match(p) {
+		return w.next.Write(p)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+
+	var event map[string]any
+	if err := dec.Decode(&event); err != nil {
+		return w.next.Write(p)
+	}
+
+	if !redactFields(event) {
+		return w.next.Write(p)
+	}
+
+	out, err := json.Marshal(event)
+	if err != nil {
+		return w.next.Write(p)
+	}
+
+	if _, err := w.next.Write(append(out, '\n')); err != nil {
+		return 0, err
+	}
+
+	// report the original length so callers don't treat the rewrite as a short write
+	return len(p), nil
+}
+
+// redactFields replaces sensitive values in place, recursing into nested objects and arrays.
+// Returns true if anything was redacted.
+func redactFields(fields map[string]any) bool {
+	redacted := false
+
+	for key, val := range fields {
+		if sensitiveFieldPattern.MatchString(key) {
+			fields[key] = redactedValue
+			redacted = true
+			continue
+		}
+
+		if redactValue(val) {
+			redacted = true
+		}
+	}
+
+	return redacted
+}
+
+func redactValue(val any) bool {
+	switch typed := val.(type) {
+	case map[string]any:
+		return redactFields(typed)
+	case []any:
+		redacted := false
+		for _, item := range typed {
+			if redactValue(item) {
+				redacted = true
+			}
+		}
+		return redacted
+	}
+
+	return false
+}
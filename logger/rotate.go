@@ -0,0 +1,147 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxSizeMB   = 100
+	rotatedTimeFormat  = "2006-01-02T15-04-05.000000000"
+	rotatedFilePerm    = 0640
+	rotatedFileDirPerm = 0750
+)
+
+// FileOptions configures rotating file output.
+type FileOptions struct {
+	// path of the active log file, file output is disabled when empty
+	Path string
+	// size in megabytes at which the file is rotated, defaults to 100
+	MaxSizeMB int
+	// rotated files older than this many days are removed, 0 keeps them regardless of age
+	MaxAgeDays int
+	// maximum number of rotated files to keep, 0 keeps all of them
+	MaxBackups int
+}
+
+// RotatingFile is an io.Writer that writes to a file and rotates it once it grows past the
+// configured size. Rotated files are renamed with a timestamp suffix and pruned by age and count.
+type RotatingFile struct {
+	opts FileOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens or creates the log file described by opts.
+func NewRotatingFile(opts FileOptions) (*RotatingFile, error) {
+	if opts.MaxSizeMB <= 0 {
+		opts.MaxSizeMB = defaultMaxSizeMB
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.Path), rotatedFileDirPerm); err != nil {
+		return nil, err
+	}
+
+	r := &RotatingFile{opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Write implements io.Writer.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size+int64(len(p)) > int64(r.opts.MaxSizeMB)*1024*1024 && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the active log file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, rotatedFilePerm)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	rotatedPath := fmt.Sprintf("%s.%s", r.opts.Path, time.Now().UTC().Format(rotatedTimeFormat))
+	if err := os.Rename(r.opts.Path, rotatedPath); err != nil {
+		return err
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.prune()
+
+	return nil
+}
+
+// prune removes rotated files beyond the configured age and count limits.
+func (r *RotatingFile) prune() {
+	matches, err := filepath.Glob(r.opts.Path + ".*")
+	if err != nil {
+		return
+	}
+
+	// timestamp suffixes sort chronologically, newest first after reversing
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -r.opts.MaxAgeDays)
+
+	for i, path := range matches {
+		rotatedAt, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(path, r.opts.Path+"."))
+		if err != nil {
+			continue
+		}
+
+		tooMany := r.opts.MaxBackups > 0 && i >= r.opts.MaxBackups
+		tooOld := r.opts.MaxAgeDays > 0 && rotatedAt.Before(cutoff)
+
+		if tooMany || tooOld {
+			os.Remove(path)
+		}
+	}
+}
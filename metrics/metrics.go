@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// Counters are published through expvar so they can be scraped from the admin metrics endpoint.
+var (
+	// notes that failed to decrypt with the configured key
+	NoteDecryptFailures = expvar.NewInt("note_decrypt_failures_total")
+	// quarantined notes repaired with a keyring key
+	NoteRepairs = expvar.NewInt("note_repairs_total")
+	// scheduled job runs keyed by "<job>.<success|failure|skipped>"
+	SchedulerJobRuns = expvar.NewMap("scheduler_job_runs_total")
+	// total time spent running each scheduled job, keyed by job name
+	SchedulerJobDurationSeconds = expvar.NewMap("scheduler_job_duration_seconds_total")
+	// queued job outcomes keyed by "<kind>.<succeeded|queued|dead>", queued counts retries
+	QueueJobs = expvar.NewMap("queue_jobs_total")
+	// notification deliveries keyed by "<event type>.<success|failure>"
+	Notifications = expvar.NewMap("notifications_total")
+	// values looked up in the breach dataset and how many of them were found
+	BreachChecks  = expvar.NewInt("breach_checks_total")
+	BreachMatches = expvar.NewInt("breach_matches_total")
+)
+
+// Handler serves all published metrics as JSON.
+func Handler() http.Handler {
+	return expvar.Handler()
+}
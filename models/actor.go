@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type actorContextKey struct{}
+
+// Actor describes who is performing an operation. It is attached to the request context by
+// the HTTP layer and read by models methods for authorization and auditing.
+type Actor struct {
+	// 0 when the request is not authenticated
+	UserID          int64
+	Username        string
+	IP              string
+	UserAgent       string
+	RequestID       string
+	AuthenticatedAt time.Time
+	// trusted operators, the CLI, scheduled jobs and the admin API, are allowed every operation
+	System bool
+}
+
+// WithActor returns a copy of ctx carrying the actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// SystemActor returns a trusted operator actor with the name recorded in the audit log.
+func SystemActor(name string) Actor {
+	return Actor{Username: name, System: true}
+}
+
+// ActorFromContext returns the actor attached to ctx, or the zero Actor if there is none.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorContextKey{}).(Actor)
+	return actor
+}
+
+// Authenticated reports whether the actor is a signed in user.
+func (a Actor) Authenticated() bool {
+	return a.UserID != 0
+}
@@ -0,0 +1,338 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	encjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/oalexander6/web-app-template/logger"
+)
+
+const (
+	AUDIT_ACTION_NOTE_CREATE           = "note.create"
+	AUDIT_ACTION_NOTE_REVEAL           = "note.reveal"
+	AUDIT_ACTION_NOTE_UPDATE           = "note.update"
+	AUDIT_ACTION_NOTE_DELETE           = "note.delete"
+	AUDIT_ACTION_NOTE_EXPORT           = "note.export"
+	AUDIT_ACTION_NOTE_RESTORE          = "note.restore"
+	AUDIT_ACTION_NOTE_UNDELETE         = "note.undelete"
+	AUDIT_ACTION_NOTE_PURGE            = "note.purge"
+	AUDIT_ACTION_NOTE_VERSION_REVEAL   = "note.version.reveal"
+	AUDIT_ACTION_NOTE_REVIEW           = "note.review"
+	AUDIT_ACTION_NOTE_ROTATE           = "note.rotate"
+	AUDIT_ACTION_NOTE_ROTATION_SET     = "note.rotation.set"
+	AUDIT_ACTION_NOTE_ROTATION_CLEAR   = "note.rotation.clear"
+	AUDIT_ACTION_NOTE_COMPROMISED      = "note.compromised"
+	AUDIT_ACTION_NOTE_MOVE             = "note.move"
+	AUDIT_ACTION_NOTE_TAG              = "note.tag"
+	AUDIT_ACTION_FOLDER_CREATE         = "folder.create"
+	AUDIT_ACTION_FOLDER_UPDATE         = "folder.update"
+	AUDIT_ACTION_FOLDER_DELETE         = "folder.delete"
+	AUDIT_ACTION_KEYS_ROTATE           = "keys.rotate"
+	AUDIT_ACTION_USER_CREATE           = "user.create"
+	AUDIT_ACTION_USER_LOGIN            = "user.login"
+	AUDIT_ACTION_USER_REAUTH           = "user.reauth"
+	AUDIT_ACTION_SESSION_END           = "session.logout"
+	AUDIT_ACTION_ADMIN_TOKEN           = "token.admin.use"
+	AUDIT_ACTION_ACCESS_DENY           = "access.deny"
+	AUDIT_ACTION_ORGANIZATION_CREATE   = "organization.create"
+	AUDIT_ACTION_VAULT_CREATE          = "vault.create"
+	AUDIT_ACTION_VAULT_UPDATE          = "vault.update"
+	AUDIT_ACTION_PASSWORD_POLICY_SET   = "password_policy.set"
+	AUDIT_ACTION_PASSWORD_POLICY_RESET = "password_policy.reset"
+	AUDIT_ACTION_MEMBER_INVITE         = "member.invite"
+	AUDIT_ACTION_MEMBER_REVOKE         = "member.invite.revoke"
+	AUDIT_ACTION_MEMBER_ACCEPT         = "member.invite.accept"
+	AUDIT_ACTION_MEMBER_DECLINE        = "member.invite.decline"
+	AUDIT_ACTION_MEMBER_ROLE           = "member.role"
+	AUDIT_ACTION_MEMBER_REMOVE         = "member.remove"
+
+	AUDIT_OUTCOME_SUCCESS = "success"
+	AUDIT_OUTCOME_FAILURE = "failure"
+	AUDIT_OUTCOME_DENIED  = "denied"
+
+	AUDIT_TARGET_NOTE   = "note"
+	AUDIT_TARGET_USER   = "user"
+	AUDIT_TARGET_FOLDER = "folder"
+	// organizations, vaults, and memberships and invitations by their ID
+	AUDIT_TARGET_ORGANIZATION = "organization"
+	AUDIT_TARGET_VAULT        = "vault"
+	AUDIT_TARGET_MEMBER       = "member"
+	AUDIT_TARGET_INVITATION   = "invitation"
+
+	defaultAuditQueryLimit = 100
+	maxAuditQueryLimit     = 1000
+	auditBatchSize         = 500
+)
+
+// AuditEvent is an append-only record of an access to or change of a secret, or an
+// authentication event. Each event's hash is an HMAC over its fields and the previous event's
+// hash, so modifying or removing a historical row breaks the chain.
+type AuditEvent struct {
+	ID         int64  `json:"id"`
+	OccurredAt string `json:"occurred_at"`
+	Action     string `json:"action"`
+	Outcome    string `json:"outcome"`
+	ActorID    int64  `json:"actor_id,omitempty"`
+	ActorName  string `json:"actor_name,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	TargetType string `json:"target_type,omitempty"`
+	TargetID   int64  `json:"target_id,omitempty"`
+	Details    string `json:"details,omitempty"`
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash"`
+}
+
+// AuditFilter narrows audit queries. Zero values match everything.
+type AuditFilter struct {
+	Action     string `form:"action"`
+	Outcome    string `form:"outcome"`
+	ActorID    int64  `form:"actor_id"`
+	TargetType string `form:"target_type"`
+	TargetID   int64  `form:"target_id"`
+	// RFC3339 timestamps bounding occurred_at, inclusive
+	Since string `form:"since"`
+	Until string `form:"until"`
+	// cursor for paging, only events with IDs strictly between these are returned
+	AfterID  int64 `form:"after_id"`
+	BeforeID int64 `form:"before_id"`
+	// newest events first when true
+	Descending bool `form:"desc"`
+	Limit      int  `form:"limit"`
+}
+
+// AuditHead identifies the latest event of the audit chain. Every append logs the new head, so
+// a head recorded outside the database shows whether events were removed from the end.
+type AuditHead struct {
+	ID   int64  `json:"head_id" form:"head_id"`
+	Hash string `json:"head_hash" form:"head_hash"`
+}
+
+// AuditVerifyReport is the result of checking the audit hash chain.
+type AuditVerifyReport struct {
+	Valid    bool `json:"valid"`
+	Verified int  `json:"verified"`
+	// ID of the first event whose hash or link doesn't match, 0 when the chain is valid
+	BrokenAtID int64  `json:"broken_at_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	// latest verified event, compare it with the head from the logs to detect truncation
+	Head AuditHead `json:"head"`
+}
+
+// auditStore defines the interface required to persist audit events.
+type auditStore interface {
+	// AuditAppend stores the event, calling hash with the hash of the latest stored event while
+	// holding a lock so the chain has no forks.
+	AuditAppend(ctx context.Context, event AuditEvent, hash func(prevHash string, event AuditEvent) string) (AuditEvent, error)
+	AuditList(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+// audit records an event for the actor in ctx. The event is also written to the log.
+func (m *Models) audit(ctx context.Context, event AuditEvent) error {
+	actor := ActorFromContext(ctx)
+
+	event.OccurredAt = time.Now().UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+	if event.ActorID == 0 {
+		event.ActorID = actor.UserID
+	}
+	if event.ActorName == "" {
+		event.ActorName = actor.Username
+	}
+	event.IP = actor.IP
+	event.UserAgent = actor.UserAgent
+	event.RequestID = actor.RequestID
+
+	logger.Log.Info().
+		Str("audit_action", event.Action).
+		Str("outcome", event.Outcome).
+		Str("target_type", event.TargetType).
+		Int64("target_id", event.TargetID).
+		Int64("actor_id", event.ActorID).
+		Str("actor", event.ActorName).
+		Str("ip", event.IP).
+		Str("request_id", event.RequestID).
+		Msg("Audit event")
+
+	stored, err := m.store.AuditAppend(ctx, event, m.auditHash)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("audit_action", event.Action).Msg("Failed to write audit event")
+		return err
+	}
+
+	logger.Log.Info().Int64("head_id", stored.ID).Str("head_hash", stored.Hash).Msg("Audit chain head")
+
+	return nil
+}
+
+// auditNote records an event about a note.
+func (m *Models) auditNote(ctx context.Context, action string, noteID int64, outcome string) error {
+	return m.audit(ctx, AuditEvent{Action: action, TargetType: AUDIT_TARGET_NOTE, TargetID: noteID, Outcome: outcome})
+}
+
+// auditHash computes the chained HMAC of an event with the audit key. The ID and hash fields are
+// excluded since they are assigned after hashing.
+func (m *Models) auditHash(prevHash string, event AuditEvent) string {
+	event.ID = 0
+	event.Hash = ""
+	event.PrevHash = prevHash
+
+	payload, _ := encjson.Marshal(event)
+	mac := hmac.New(sha256.New, []byte(m.config.Audit.HMACKey))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuditAdminToken records a request authenticated, or refused, by the admin token.
+func (m *Models) AuditAdminToken(ctx context.Context, outcome string) {
+	m.audit(ctx, AuditEvent{Action: AUDIT_ACTION_ADMIN_TOKEN, Outcome: outcome, ActorName: "admin"})
+}
+
+// auditDetails encodes key value pairs as a JSON object for AuditEvent.Details.
+func auditDetails(keyvals ...string) string {
+	details := make(map[string]string, len(keyvals)/2)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		details[keyvals[i]] = keyvals[i+1]
+	}
+
+	encoded, _ := encjson.Marshal(details)
+
+	return string(encoded)
+}
+
+// AuditQuery returns audit events matching the filter, at most 1000 at a time. Like the other
+// audit log methods, only system actors may call it.
+func (m *Models) AuditQuery(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return []AuditEvent{}, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditQueryLimit
+	}
+	if filter.Limit > maxAuditQueryLimit {
+		filter.Limit = maxAuditQueryLimit
+	}
+
+	if err := validateAuditFilter(filter); err != nil {
+		return []AuditEvent{}, err
+	}
+
+	return m.store.AuditList(ctx, filter)
+}
+
+// AuditExport writes every event matching the filter to w as JSON lines, oldest first.
+// Returns the number of events written.
+func (m *Models) AuditExport(ctx context.Context, filter AuditFilter, w io.Writer) (int, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return 0, err
+	}
+
+	if err := validateAuditFilter(filter); err != nil {
+		return 0, err
+	}
+
+	enc := encjson.NewEncoder(w)
+	written := 0
+
+	err := m.auditEach(ctx, filter, func(event AuditEvent) error {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+		written++
+		return nil
+	})
+
+	return written, err
+}
+
+// AuditVerify walks the whole audit log in order and checks that every event links to the
+// previous one and that its HMAC matches its contents. When expected is set, the chain must
+// also still contain that head, otherwise events were removed from the end.
+func (m *Models) AuditVerify(ctx context.Context, expected AuditHead) (AuditVerifyReport, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return AuditVerifyReport{}, err
+	}
+
+	report := AuditVerifyReport{Valid: true}
+	prevHash := ""
+
+	errBroken := errors.New("chain broken")
+
+	err := m.auditEach(ctx, AuditFilter{}, func(event AuditEvent) error {
+		switch {
+		case event.PrevHash != prevHash:
+			report.Reason = "previous hash does not match the preceding event, an event was removed or reordered"
+		case !hmac.Equal([]byte(m.auditHash(event.PrevHash, event)), []byte(event.Hash)):
+			report.Reason = "hash does not match the event contents, the event was modified"
+		case event.ID == expected.ID && event.Hash != expected.Hash:
+			report.Reason = "hash does not match the expected head"
+		default:
+			report.Verified++
+			report.Head = AuditHead{ID: event.ID, Hash: event.Hash}
+			prevHash = event.Hash
+			return nil
+		}
+
+		report.Valid = false
+		report.BrokenAtID = event.ID
+
+		return errBroken
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return AuditVerifyReport{}, err
+	}
+
+	if report.Valid && expected.ID > report.Head.ID {
+		report.Valid = false
+		report.Reason = "the expected head is missing, events were removed from the end of the log"
+	}
+
+	return report, nil
+}
+
+// auditEach calls fn for every event matching the filter in ascending ID order.
+func (m *Models) auditEach(ctx context.Context, filter AuditFilter, fn func(AuditEvent) error) error {
+	filter.Descending = false
+	filter.Limit = auditBatchSize
+
+	for {
+		events, err := m.store.AuditList(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < auditBatchSize {
+			return nil
+		}
+
+		filter.AfterID = events[len(events)-1].ID
+	}
+}
+
+func validateAuditFilter(filter AuditFilter) error {
+	for _, ts := range []string{filter.Since, filter.Until} {
+		if ts == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, ts); err != nil {
+			return fmt.Errorf("%w: timestamps must be RFC3339", ErrInvalidInput)
+		}
+	}
+
+	return nil
+}
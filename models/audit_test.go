@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func TestAuditHashCoversFieldsAndChain(t *testing.T) {
+	m := &Models{config: &config.Config{Audit: config.AuditConfig{HMACKey: "0123456789abcdef0123456789abcdef"}}}
+	other := &Models{config: &config.Config{Audit: config.AuditConfig{HMACKey: "fedcba9876543210fedcba9876543210"}}}
+
+	event := AuditEvent{
+		OccurredAt: "2024-01-02T03:04:05.123456Z",
+		Action:     AUDIT_ACTION_NOTE_REVEAL,
+		Outcome:    AUDIT_OUTCOME_SUCCESS,
+		ActorID:    1,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   42,
+	}
+
+	base := m.auditHash("prev", event)
+
+	if m.auditHash("prev", event) != base {
+		t.Fatal("Expected the hash to be deterministic")
+	}
+
+	withID := event
+	withID.ID = 7
+	withID.Hash = base
+	if m.auditHash("prev", withID) != base {
+		t.Fatal("Expected the ID and stored hash to be excluded")
+	}
+
+	if m.auditHash("other", event) == base {
+		t.Fatal("Expected the previous hash to change the hash")
+	}
+
+	tampered := event
+	tampered.TargetID = 43
+	if m.auditHash("prev", tampered) == base {
+		t.Fatal("Expected a modified field to change the hash")
+	}
+
+	if other.auditHash("prev", event) == base {
+		t.Fatal("Expected the hash to depend on the audit key")
+	}
+}
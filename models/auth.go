@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrReauthRequired     = errors.New("recent authentication required")
+)
+
+// LoginParams represents the data required to sign in or re-authenticate.
+type LoginParams struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+// dummyHash is compared against when the user doesn't exist so response timing doesn't reveal
+// which usernames are registered.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password for timing"), bcrypt.DefaultCost)
+
+// UserAuthenticate checks the username and password for a sign in. Returns ErrInvalidCredentials
+// if the user doesn't exist or the password doesn't match. Sign in, re-authentication and sign
+// out establish or end the actor, so unlike other methods they aren't authorized.
+func (m *Models) UserAuthenticate(ctx context.Context, params LoginParams) (UserGetResponse, error) {
+	return m.authenticate(ctx, AUDIT_ACTION_USER_LOGIN, params)
+}
+
+// UserReauthenticate checks the password of a signed in user before a session is refreshed.
+// Returns ErrInvalidCredentials if the password doesn't match.
+func (m *Models) UserReauthenticate(ctx context.Context, params LoginParams) (UserGetResponse, error) {
+	return m.authenticate(ctx, AUDIT_ACTION_USER_REAUTH, params)
+}
+
+// UserLogout records that the actor's session was ended.
+func (m *Models) UserLogout(ctx context.Context) {
+	m.audit(ctx, AuditEvent{Action: AUDIT_ACTION_SESSION_END, Outcome: AUDIT_OUTCOME_SUCCESS})
+}
+
+func (m *Models) authenticate(ctx context.Context, action string, params LoginParams) (UserGetResponse, error) {
+	username := strings.ToLower(strings.TrimSpace(params.Username))
+	denied := AuditEvent{Action: action, Outcome: AUDIT_OUTCOME_DENIED, ActorName: username}
+
+	user, err := m.store.UserGetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(params.Password))
+			m.audit(ctx, denied)
+			return UserGetResponse{}, ErrInvalidCredentials
+		}
+		return UserGetResponse{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(params.Password)); err != nil {
+		denied.ActorID = user.ID
+		m.audit(ctx, denied)
+		return UserGetResponse{}, ErrInvalidCredentials
+	}
+
+	m.audit(ctx, AuditEvent{Action: action, Outcome: AUDIT_OUTCOME_SUCCESS, ActorID: user.ID, ActorName: user.Username})
+
+	return userToResponse(user), nil
+}
+
+// UserGetByID returns the user with the provided ID. Users may only look themselves up.
+func (m *Models) UserGetByID(ctx context.Context, userID int64) (UserGetResponse, error) {
+	if userID != ActorFromContext(ctx).UserID {
+		if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+			return UserGetResponse{}, err
+		}
+	}
+
+	user, err := m.store.UserGetByID(ctx, userID)
+	if err != nil {
+		return UserGetResponse{}, err
+	}
+
+	return userToResponse(user), nil
+}
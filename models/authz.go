@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	ROLE_OWNER  = "owner"
+	ROLE_ADMIN  = "admin"
+	ROLE_EDITOR = "editor"
+	ROLE_VIEWER = "viewer"
+)
+
+// Permission is what an operation requires of the actor, see authorize.
+type Permission string
+
+const (
+	// any signed in user, for their own organizations and invitations
+	PERMISSION_SIGNED_IN Permission = "signed_in"
+	// list and reveal the notes and folders of a vault, list the members of an organization
+	PERMISSION_READ Permission = "read"
+	// create, change and delete the notes and folders of a vault
+	PERMISSION_WRITE Permission = "write"
+	// create vaults and invite, change the role of and remove the members of an organization
+	PERMISSION_MANAGE Permission = "manage"
+	// grant, change or remove the owner role
+	PERMISSION_OWN Permission = "own"
+	// instance wide operations such as migrations, key rotation, the audit log and scheduled jobs
+	PERMISSION_SYSTEM Permission = "system"
+)
+
+// roleRank orders roles from least to most privileged. Each role has the permissions of the
+// roles below it.
+var roleRank = map[string]int{
+	ROLE_VIEWER: 1,
+	ROLE_EDITOR: 2,
+	ROLE_ADMIN:  3,
+	ROLE_OWNER:  4,
+}
+
+// permissionRole is the least privileged role with each permission.
+var permissionRole = map[Permission]string{
+	PERMISSION_READ:   ROLE_VIEWER,
+	PERMISSION_WRITE:  ROLE_EDITOR,
+	PERMISSION_MANAGE: ROLE_ADMIN,
+	PERMISSION_OWN:    ROLE_OWNER,
+}
+
+// Resource is what an operation accesses: a vault, or an organization when VaultID is 0.
+type Resource struct {
+	OrganizationID int64
+	VaultID        int64
+}
+
+// authorize decides whether the actor in ctx may perform an operation needing the permission on
+// the resource. Every exported method that reads or changes stored data calls it first.
+//
+// System actors may do anything. Everyone else must be signed in, and for vault and
+// organization permissions hold a role granting them: their organization wide role or, for a
+// vault, the higher of that and their role in the vault. Returns ErrUnauthenticated,
+// ErrForbidden, or ErrNotFound if the user has no role there so its existence isn't revealed.
+func (m *Models) authorize(ctx context.Context, permission Permission, resource Resource) error {
+	actor := ActorFromContext(ctx)
+
+	if actor.System {
+		return nil
+	}
+
+	if !actor.Authenticated() {
+		return ErrUnauthenticated
+	}
+
+	if permission == PERMISSION_SIGNED_IN {
+		return nil
+	}
+
+	if permission == PERMISSION_SYSTEM {
+		return m.deny(ctx, permission, resource, ErrForbidden)
+	}
+
+	roles, err := m.store.MembershipRoles(ctx, actor.UserID, resource.OrganizationID, resource.VaultID)
+	if err != nil {
+		return err
+	}
+
+	role := highestRole(roles)
+	if role == "" {
+		return m.deny(ctx, permission, resource, ErrNotFound)
+	}
+
+	if !roleAllows(role, permission) {
+		return m.deny(ctx, permission, resource, ErrForbidden)
+	}
+
+	return nil
+}
+
+// authorizeNote authorizes the permission on the vault of a note, which may be in the trash.
+// Returns ErrNotFound if the note doesn't exist.
+func (m *Models) authorizeNote(ctx context.Context, permission Permission, noteID int64) error {
+	if actor := ActorFromContext(ctx); actor.System || !actor.Authenticated() {
+		return m.authorize(ctx, permission, Resource{})
+	}
+
+	vaultID, err := m.store.NoteGetVaultID(ctx, noteID)
+	if err != nil {
+		return err
+	}
+
+	return m.authorize(ctx, permission, Resource{VaultID: vaultID})
+}
+
+// authorizedVaults returns the IDs of the vaults where the actor has the permission, every vault
+// for system actors.
+func (m *Models) authorizedVaults(ctx context.Context, permission Permission) ([]int64, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return nil, err
+	}
+
+	actor := ActorFromContext(ctx)
+
+	var vaults []Vault
+	var err error
+	if actor.System {
+		vaults, err = m.store.VaultGetAll(ctx)
+	} else {
+		vaults, err = m.store.VaultGetForUser(ctx, actor.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(vaults))
+	for _, vault := range vaults {
+		if actor.System || roleAllows(vault.Role, permission) {
+			ids = append(ids, vault.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// deny records the denied access and returns err.
+func (m *Models) deny(ctx context.Context, permission Permission, resource Resource, err error) error {
+	event := AuditEvent{
+		Action:     AUDIT_ACTION_ACCESS_DENY,
+		TargetType: AUDIT_TARGET_ORGANIZATION,
+		TargetID:   resource.OrganizationID,
+		Details:    auditDetails("permission", string(permission)),
+		Outcome:    AUDIT_OUTCOME_DENIED,
+	}
+	if resource.VaultID != 0 {
+		event.TargetType = AUDIT_TARGET_VAULT
+		event.TargetID = resource.VaultID
+	}
+
+	m.audit(ctx, event)
+
+	return err
+}
+
+// requireVault returns an ErrInvalidInput error for a missing vault ID.
+func requireVault(vaultID int64) error {
+	if vaultID == 0 {
+		return fmt.Errorf("%w: vault_id is required", ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// roleAllows reports whether the role grants the permission.
+func roleAllows(role string, permission Permission) bool {
+	required, ok := permissionRole[permission]
+	return ok && roleRank[role] >= roleRank[required]
+}
+
+// highestRole returns the most privileged of the roles, or "" for none.
+func highestRole(roles []string) string {
+	highest := ""
+	for _, role := range roles {
+		if roleRank[role] > roleRank[highest] {
+			highest = role
+		}
+	}
+
+	return highest
+}
+
+// validRole returns an ErrInvalidInput error unless role is one of the ROLE_ constants. Roles
+// in a single vault can only be editor or viewer.
+func validRole(role string, vaultID int64) error {
+	if _, ok := roleRank[role]; !ok {
+		return fmt.Errorf("%w: role must be %s, %s, %s or %s", ErrInvalidInput, ROLE_OWNER, ROLE_ADMIN, ROLE_EDITOR, ROLE_VIEWER)
+	}
+
+	if vaultID != 0 && roleRank[role] > roleRank[ROLE_EDITOR] {
+		return fmt.Errorf("%w: a role in a single vault must be %s or %s", ErrInvalidInput, ROLE_EDITOR, ROLE_VIEWER)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{ROLE_VIEWER, PERMISSION_READ, true},
+		{ROLE_VIEWER, PERMISSION_WRITE, false},
+		{ROLE_EDITOR, PERMISSION_WRITE, true},
+		{ROLE_EDITOR, PERMISSION_MANAGE, false},
+		{ROLE_ADMIN, PERMISSION_MANAGE, true},
+		{ROLE_ADMIN, PERMISSION_OWN, false},
+		{ROLE_OWNER, PERMISSION_OWN, true},
+		{ROLE_OWNER, PERMISSION_SYSTEM, false},
+		{"", PERMISSION_READ, false},
+	}
+
+	for _, test := range tests {
+		if got := roleAllows(test.role, test.permission); got != test.want {
+			t.Errorf("roleAllows(%q, %q) = %v, want %v", test.role, test.permission, got, test.want)
+		}
+	}
+}
+
+func TestHighestRole(t *testing.T) {
+	if got := highestRole([]string{ROLE_EDITOR, ROLE_ADMIN, ROLE_VIEWER}); got != ROLE_ADMIN {
+		t.Errorf("Expected %q, got %q", ROLE_ADMIN, got)
+	}
+
+	if got := highestRole(nil); got != "" {
+		t.Errorf("Expected no role, got %q", got)
+	}
+}
+
+func TestValidRole(t *testing.T) {
+	if err := validRole("superuser", 0); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected an unknown role to be invalid, got %v", err)
+	}
+
+	if err := validRole(ROLE_ADMIN, 1); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected admin in a single vault to be invalid, got %v", err)
+	}
+
+	if err := validRole(ROLE_EDITOR, 1); err != nil {
+		t.Errorf("Expected editor in a single vault to be valid, got %v", err)
+	}
+
+	if err := validRole(ROLE_OWNER, 0); err != nil {
+		t.Errorf("Expected an organization owner to be valid, got %v", err)
+	}
+}
+
+func TestAuthorizeActors(t *testing.T) {
+	m := &Models{}
+
+	if err := m.authorize(context.Background(), PERMISSION_READ, Resource{VaultID: 1}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected anonymous actors to be unauthenticated, got %v", err)
+	}
+
+	ctx := WithActor(context.Background(), SystemActor("test"))
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		t.Errorf("Expected system actors to be allowed, got %v", err)
+	}
+}
+
+func TestAuthorizeMemberships(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{})
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.vaults[2] = Vault{ID: 2, OrganizationID: 1}
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Shared", DeletedAt: "2024-01-02T00:00:00Z"}
+	store.notes[2] = Note{ID: 2, VaultID: 2, Name: "Private", DeletedAt: "2024-01-03T00:00:00Z"}
+	store.notes[3] = Note{ID: 3, VaultID: 1, Name: "Live"}
+	store.memberships = []Member{{ID: 1, OrganizationID: 1, VaultID: 1, UserID: 2, Role: ROLE_VIEWER}}
+
+	viewer := WithActor(context.Background(), Actor{UserID: 2})
+	stranger := WithActor(context.Background(), Actor{UserID: 3})
+
+	if err := m.NoteMove(viewer, 3, NoteFolderParams{}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Expected ErrForbidden for a viewer moving a note, got %v", err)
+	}
+
+	if err := m.NoteDeleteByID(viewer, 3); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Expected ErrForbidden for a viewer deleting a note, got %v", err)
+	}
+
+	if err := m.NoteMove(stranger, 3, NoteFolderParams{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a non-member, got %v", err)
+	}
+
+	if denied := store.audit[len(store.audit)-1]; denied.Action != AUDIT_ACTION_ACCESS_DENY || denied.TargetID != 1 {
+		t.Fatalf("Expected the denied access to vault 1 to be audited, got %+v", denied)
+	}
+
+	trash, err := m.NoteGetTrash(viewer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(trash) != 1 || trash[0].ID != 1 {
+		t.Fatalf("Expected only the trash of the viewer's vault, got %+v", trash)
+	}
+
+	if trash, _ := m.NoteGetTrash(stranger); len(trash) != 0 {
+		t.Fatalf("Expected no trash for a non-member, got %+v", trash)
+	}
+}
+
+func TestMemberKeepsLastOwner(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{})
+
+	store.memberships = []Member{
+		{ID: 1, OrganizationID: 1, UserID: 1, Role: ROLE_OWNER},
+		{ID: 2, OrganizationID: 1, UserID: 2, Role: ROLE_ADMIN},
+	}
+
+	owner := WithActor(context.Background(), Actor{UserID: 1})
+	admin := WithActor(context.Background(), Actor{UserID: 2})
+
+	if err := m.MemberRemove(owner, 1, 1); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected the last owner not to leave, got %v", err)
+	}
+
+	if _, err := m.MemberSetRole(owner, 1, 1, MemberRoleParams{Role: ROLE_ADMIN}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected the last owner not to be demoted, got %v", err)
+	}
+
+	if _, err := m.MemberSetRole(admin, 1, 1, MemberRoleParams{Role: ROLE_ADMIN}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Expected ErrForbidden for an admin demoting an owner, got %v", err)
+	}
+
+	if _, err := m.MemberSetRole(owner, 1, 2, MemberRoleParams{Role: ROLE_OWNER}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := m.MemberRemove(owner, 1, 1); err != nil {
+		t.Fatalf("Expected an owner to leave once another owner remains, got %s", err)
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/oalexander6/web-app-template/breach"
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/metrics"
+	"github.com/oalexander6/web-app-template/notify"
+)
+
+const (
+	NOTIFY_EVENT_NOTE_COMPROMISED = "note.compromised"
+)
+
+// PasswordCheckParams represents the data required for an ad-hoc breach check.
+type PasswordCheckParams struct {
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+// PasswordCheckResponse represents the result of a breach check. It never includes the value.
+type PasswordCheckResponse struct {
+	Breached bool `json:"breached"`
+	// number of times the value appears in the breach dataset
+	Count int `json:"count"`
+}
+
+// NoteBreachScanReport represents the outcome of checking every note against the dataset.
+type NoteBreachScanReport struct {
+	Checked     int `json:"checked"`
+	Compromised int `json:"compromised"`
+	// notes that couldn't be decrypted
+	Skipped int `json:"skipped"`
+}
+
+// noteBreachStore defines the interface required to mark notes with breached values.
+type noteBreachStore interface {
+	// NoteSetCompromised marks the note as compromised at the time with the breach count, or
+	// clears the mark when count is 0. Returns ErrNotFound if the note doesn't exist.
+	NoteSetCompromised(ctx context.Context, id int64, count int, at time.Time) error
+}
+
+// CheckPassword looks the value up in the breach dataset.
+// Returns ErrBreachCheckUnavailable if no dataset is configured or it can't be read.
+func (m *Models) CheckPassword(params PasswordCheckParams) (PasswordCheckResponse, error) {
+	count, err := m.breachCount(params.Password)
+	if err != nil {
+		return PasswordCheckResponse{}, err
+	}
+
+	return PasswordCheckResponse{Breached: count > 0, Count: count}, nil
+}
+
+// NoteScanBreaches checks every note against the breach dataset and updates the compromised
+// marks, for use after the dataset is updated. Only system actors may scan every note.
+func (m *Models) NoteScanBreaches(ctx context.Context) (NoteBreachScanReport, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return NoteBreachScanReport{}, err
+	}
+
+	if !m.breach.Enabled() {
+		return NoteBreachScanReport{}, ErrBreachCheckUnavailable
+	}
+
+	notes, err := m.store.NoteGetAll(ctx, nil)
+	if err != nil {
+		return NoteBreachScanReport{}, err
+	}
+
+	var report NoteBreachScanReport
+
+	for _, note := range notes {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+
+		if !noteTypeFreeform(note.Type) {
+			continue
+		}
+
+		value, _, err := m.decryptNote(ctx, note)
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+
+		count, err := m.noteCheckBreach(ctx, note, value)
+		if err != nil {
+			return report, err
+		}
+
+		report.Checked++
+		if count > 0 {
+			report.Compromised++
+		}
+	}
+
+	return report, nil
+}
+
+// noteCheckBreach looks the note's plaintext value up in the breach dataset and updates its
+// compromised mark, sending an event when a note is newly compromised. Does nothing when no
+// dataset is configured or the note's value isn't a password.
+func (m *Models) noteCheckBreach(ctx context.Context, note Note, value string) (int, error) {
+	if !m.breach.Enabled() || !noteTypeFreeform(note.Type) {
+		return 0, nil
+	}
+
+	count, err := m.breachCount(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 0 && note.CompromisedAt == "" {
+		return 0, nil
+	}
+
+	if count > 0 && note.CompromisedAt != "" && note.BreachCount == count {
+		return count, nil
+	}
+
+	if err := m.store.NoteSetCompromised(ctx, note.ID, count, time.Now()); err != nil {
+		return 0, err
+	}
+
+	if count > 0 && note.CompromisedAt == "" {
+		m.auditNoteCompromised(ctx, note, count)
+	}
+
+	return count, nil
+}
+
+// noteCheckBreachOnWrite checks a value that was just written. The write has already succeeded,
+// so a failed check is logged rather than returned.
+func (m *Models) noteCheckBreachOnWrite(ctx context.Context, note Note, value string) {
+	if _, err := m.noteCheckBreach(ctx, note, value); err != nil {
+		logger.Log.Error().Err(err).Int64("note_id", note.ID).Msg("Failed to check note against the breach dataset")
+	}
+}
+
+func (m *Models) auditNoteCompromised(ctx context.Context, note Note, count int) {
+	m.audit(ctx, AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_COMPROMISED,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   note.ID,
+		Details:    auditDetails("count", strconv.Itoa(count)),
+		Outcome:    AUDIT_OUTCOME_SUCCESS,
+	})
+
+	event := notify.NewEvent(NOTIFY_EVENT_NOTE_COMPROMISED, fmt.Sprintf("Note %q has a value found in a data breach", note.Name), map[string]any{
+		"note_id": note.ID,
+		"name":    note.Name,
+		"count":   count,
+	})
+
+	if err := m.notifier.Notify(ctx, event); err != nil {
+		logger.Log.Warn().Err(err).Int64("note_id", note.ID).Msg("Failed to send note compromised event")
+	}
+}
+
+func (m *Models) breachCount(value string) (int, error) {
+	count, err := m.breach.Count(value)
+	if err != nil {
+		if !errors.Is(err, breach.ErrNoDataset) {
+			logger.Log.Error().Err(err).Msg("Failed to read the breach dataset")
+		}
+		return 0, ErrBreachCheckUnavailable
+	}
+
+	metrics.BreachChecks.Add(1)
+	if count > 0 {
+		metrics.BreachMatches.Add(1)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	CUSTOM_FIELD_TEXT = "text"
+	// encrypted and masked in listings
+	CUSTOM_FIELD_HIDDEN  = "hidden"
+	CUSTOM_FIELD_URL     = "url"
+	CUSTOM_FIELD_BOOLEAN = "boolean"
+
+	// shown in listings in place of hidden values
+	CUSTOM_FIELD_MASK = "********"
+
+	maxCustomFields           = 50
+	maxCustomFieldNameLength  = 100
+	maxCustomFieldValueLength = 4096
+)
+
+// CustomField is a user defined field of a note, kept in the order it was given. Hidden values
+// are stored encrypted individually, the others are stored as plaintext and can be searched.
+type CustomField struct {
+	Name string `json:"name"`
+	// text (default), hidden, url or boolean
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// validateCustomFields checks the count, names, sizes and values of the fields and returns them
+// normalized. Returns an ErrInvalidInput error naming the first invalid field.
+func validateCustomFields(fields []CustomField) ([]CustomField, error) {
+	if len(fields) > maxCustomFields {
+		return nil, fmt.Errorf("%w: at most %d custom fields are allowed", ErrInvalidInput, maxCustomFields)
+	}
+
+	normalized := make([]CustomField, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+
+	for i, field := range fields {
+		field.Name = strings.TrimSpace(field.Name)
+
+		invalid := func(reason string) ([]CustomField, error) {
+			if field.Name == "" {
+				return nil, fmt.Errorf("%w: custom field %d %s", ErrInvalidInput, i+1, reason)
+			}
+			return nil, fmt.Errorf("%w: custom field %q %s", ErrInvalidInput, field.Name, reason)
+		}
+
+		switch {
+		case field.Name == "":
+			return invalid("needs a name")
+		case utf8.RuneCountInString(field.Name) > maxCustomFieldNameLength:
+			return invalid(fmt.Sprintf("name must be at most %d characters", maxCustomFieldNameLength))
+		case seen[strings.ToLower(field.Name)]:
+			return invalid("is given more than once")
+		case len(field.Value) > maxCustomFieldValueLength:
+			return invalid(fmt.Sprintf("must be at most %d bytes", maxCustomFieldValueLength))
+		case !utf8.ValidString(field.Value):
+			return invalid("must be valid UTF-8")
+		}
+		seen[strings.ToLower(field.Name)] = true
+
+		if field.Kind == "" {
+			field.Kind = CUSTOM_FIELD_TEXT
+		}
+
+		switch field.Kind {
+		case CUSTOM_FIELD_TEXT, CUSTOM_FIELD_HIDDEN:
+		case CUSTOM_FIELD_URL:
+			field.Value = strings.TrimSpace(field.Value)
+			if field.Value != "" {
+				u, err := url.Parse(field.Value)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return invalid("must be an http or https URL")
+				}
+			}
+		case CUSTOM_FIELD_BOOLEAN:
+			value, err := strconv.ParseBool(strings.TrimSpace(field.Value))
+			if err != nil {
+				return invalid("must be true or false")
+			}
+			field.Value = strconv.FormatBool(value)
+		default:
+			return invalid(fmt.Sprintf("has unknown kind %q", field.Kind))
+		}
+
+		normalized = append(normalized, field)
+	}
+
+	return normalized, nil
+}
+
+// encryptCustomFields encrypts the value of every hidden field.
+func (c noteCipher) encryptCustomFields(fields []CustomField) ([]CustomField, error) {
+	encrypted := make([]CustomField, len(fields))
+
+	for i, field := range fields {
+		if field.Kind == CUSTOM_FIELD_HIDDEN {
+			encVal, err := c.encrypt([]byte(field.Value))
+			if err != nil {
+				return nil, ErrEncryptFailed
+			}
+			field.Value = encVal
+		}
+		encrypted[i] = field
+	}
+
+	return encrypted, nil
+}
+
+// decryptCustomFields returns the fields with hidden values decrypted.
+// Returns ErrDecryptFailed if a hidden value can't be decrypted.
+func (c noteCipher) decryptCustomFields(fields []CustomField) ([]CustomField, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	decrypted := make([]CustomField, len(fields))
+
+	for i, field := range fields {
+		if field.Kind == CUSTOM_FIELD_HIDDEN {
+			value, err := c.decrypt(field.Value)
+			if err != nil {
+				return nil, ErrDecryptFailed
+			}
+			field.Value = value
+		}
+		decrypted[i] = field
+	}
+
+	return decrypted, nil
+}
+
+// maskCustomFields returns the fields with hidden values masked, for listings.
+func maskCustomFields(fields []CustomField) []CustomField {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	masked := make([]CustomField, len(fields))
+
+	for i, field := range fields {
+		if field.Kind == CUSTOM_FIELD_HIDDEN {
+			field.Value = CUSTOM_FIELD_MASK
+		}
+		masked[i] = field
+	}
+
+	return masked
+}
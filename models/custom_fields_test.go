@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func TestValidateCustomFields(t *testing.T) {
+	fields, err := validateCustomFields([]CustomField{
+		{Name: " Account ID ", Value: "42"},
+		{Name: "Recovery", Kind: CUSTOM_FIELD_URL, Value: " https://example.com/recover "},
+		{Name: "MFA", Kind: CUSTOM_FIELD_BOOLEAN, Value: "1"},
+		{Name: "API secret", Kind: CUSTOM_FIELD_HIDDEN, Value: "s3cret"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if fields[0].Name != "Account ID" || fields[0].Kind != CUSTOM_FIELD_TEXT {
+		t.Fatalf("Expected a trimmed text field, got %+v", fields[0])
+	}
+
+	if fields[1].Value != "https://example.com/recover" || fields[2].Value != "true" {
+		t.Fatalf("Expected normalized values, got %+v", fields)
+	}
+
+	tooMany := make([]CustomField, maxCustomFields+1)
+	for i := range tooMany {
+		tooMany[i] = CustomField{Name: strings.Repeat("f", i+1)}
+	}
+
+	invalid := [][]CustomField{
+		{{Name: "", Value: "x"}},
+		{{Name: "a"}, {Name: "A"}},
+		{{Name: "url", Kind: CUSTOM_FIELD_URL, Value: "example.com"}},
+		{{Name: "flag", Kind: CUSTOM_FIELD_BOOLEAN, Value: "maybe"}},
+		{{Name: "kind", Kind: "date"}},
+		{{Name: "big", Value: strings.Repeat("x", maxCustomFieldValueLength+1)}},
+		{{Name: strings.Repeat("n", maxCustomFieldNameLength+1)}},
+		tooMany,
+	}
+
+	for _, fields := range invalid {
+		if _, err := validateCustomFields(fields); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput for %.60v, got %v", fields, err)
+		}
+	}
+}
+
+func TestHiddenCustomFieldsEncryptedAndMasked(t *testing.T) {
+	m := &Models{config: &config.Config{Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"}}}
+	fields := []CustomField{
+		{Name: "Account ID", Kind: CUSTOM_FIELD_TEXT, Value: "42"},
+		{Name: "API secret", Kind: CUSTOM_FIELD_HIDDEN, Value: "s3cret"},
+	}
+
+	c, _, err := m.newNoteKey()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	encrypted, err := c.encryptCustomFields(fields)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if encrypted[0].Value != "42" || encrypted[1].Value == "s3cret" {
+		t.Fatalf("Expected only the hidden value to be encrypted, got %+v", encrypted)
+	}
+
+	if masked := maskCustomFields(encrypted); masked[0].Value != "42" || masked[1].Value != CUSTOM_FIELD_MASK {
+		t.Fatalf("Expected the hidden value to be masked, got %+v", masked)
+	}
+
+	decrypted, err := c.decryptCustomFields(encrypted)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if decrypted[1].Value != "s3cret" {
+		t.Fatalf("Expected the hidden value to round trip, got %+v", decrypted)
+	}
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/web-app-template/logger"
+)
+
+const (
+	DOCTOR_STATUS_OK   = "ok"
+	DOCTOR_STATUS_WARN = "warn"
+	DOCTOR_STATUS_FAIL = "fail"
+
+	// encryptionCanary is encrypted with the active key and stored so the key can be checked
+	// against the data without decrypting real notes.
+	encryptionCanary = "web-app-template encryption canary"
+
+	defaultDoctorSampleSize = 20
+	doctorMaxReportedIDs    = 10
+)
+
+// DoctorFinding is the result of a single deployment check.
+type DoctorFinding struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// what an operator should do to resolve the finding, empty when the check passed
+	Action string `json:"action,omitempty"`
+}
+
+// DoctorReport is the result of all deployment checks. Healthy is false if any check failed.
+type DoctorReport struct {
+	Healthy   bool            `json:"healthy"`
+	CheckedAt string          `json:"checked_at"`
+	Findings  []DoctorFinding `json:"findings"`
+}
+
+// DoctorOptions configures the deployment checks.
+type DoctorOptions struct {
+	// number of random notes to decrypt, defaults to 20
+	SampleSize int
+}
+
+// canaryStore defines the interface required to persist the encryption canary.
+type canaryStore interface {
+	CanaryGet(ctx context.Context) (string, error)
+	CanarySet(ctx context.Context, value string) error
+}
+
+// Doctor validates the deployment end to end: configuration, store connectivity, schema
+// version, that the configured key matches the canary, and that a sample of notes decrypts.
+// It never modifies data. Only system actors may run it, others get an unhealthy report.
+func (m *Models) Doctor(ctx context.Context, opts DoctorOptions) DoctorReport {
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = defaultDoctorSampleSize
+	}
+
+	report := DoctorReport{
+		Healthy:   true,
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		report.Healthy = false
+		report.Findings = append(report.Findings, DoctorFinding{Check: "authorization", Status: DOCTOR_STATUS_FAIL, Message: err.Error()})
+		return report
+	}
+
+	add := func(finding DoctorFinding) {
+		if finding.Status == DOCTOR_STATUS_FAIL {
+			report.Healthy = false
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+
+	add(m.doctorConfig())
+
+	storeFinding := m.doctorStore(ctx)
+	add(storeFinding)
+	if storeFinding.Status == DOCTOR_STATUS_FAIL {
+		return report
+	}
+
+	add(m.doctorMigrations(ctx))
+	add(m.doctorCanary(ctx))
+	add(m.doctorSample(ctx, opts.SampleSize))
+
+	return report
+}
+
+func (m *Models) doctorConfig() DoctorFinding {
+	if err := m.config.Validate(); err != nil {
+		return DoctorFinding{Check: "config", Status: DOCTOR_STATUS_FAIL, Message: err.Error(), Action: "Fix the listed settings in the environment or .env file."}
+	}
+
+	if placeholders := m.config.PlaceholderFields(); len(placeholders) > 0 {
+		return DoctorFinding{
+			Check:   "config",
+			Status:  DOCTOR_STATUS_WARN,
+			Message: fmt.Sprintf("placeholder values in use: %s", strings.Join(placeholders, ", ")),
+			Action:  "Replace the .env.template values before deploying outside LOCAL.",
+		}
+	}
+
+	return DoctorFinding{Check: "config", Status: DOCTOR_STATUS_OK, Message: "valid"}
+}
+
+func (m *Models) doctorStore(ctx context.Context) DoctorFinding {
+	if err := m.store.Ping(ctx); err != nil {
+		return DoctorFinding{Check: "store", Status: DOCTOR_STATUS_FAIL, Message: err.Error(), Action: "Check DB_URI and that the database is reachable from this host."}
+	}
+
+	return DoctorFinding{Check: "store", Status: DOCTOR_STATUS_OK, Message: fmt.Sprintf("connected to %s", m.config.StoreType)}
+}
+
+func (m *Models) doctorMigrations(ctx context.Context) DoctorFinding {
+	statuses, err := m.store.MigrationStatus(ctx)
+	if err != nil {
+		return DoctorFinding{Check: "migrations", Status: DOCTOR_STATUS_FAIL, Message: err.Error(), Action: "Check that the database user can read schema_migrations."}
+	}
+
+	var pending []string
+	latest := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, fmt.Sprintf("%d_%s", status.Version, status.Name))
+			continue
+		}
+		latest = status.Version
+	}
+
+	if len(pending) > 0 {
+		return DoctorFinding{
+			Check:   "migrations",
+			Status:  DOCTOR_STATUS_FAIL,
+			Message: fmt.Sprintf("%d pending: %s", len(pending), strings.Join(pending, ", ")),
+			Action:  "Run 'webapp migrate up'.",
+		}
+	}
+
+	return DoctorFinding{Check: "migrations", Status: DOCTOR_STATUS_OK, Message: fmt.Sprintf("up to date at version %d", latest)}
+}
+
+func (m *Models) doctorCanary(ctx context.Context) DoctorFinding {
+	canary, err := m.store.CanaryGet(ctx)
+	if errors.Is(err, ErrNotFound) {
+		return DoctorFinding{Check: "encryption key", Status: DOCTOR_STATUS_WARN, Message: "no canary record", Action: "Start the server once to create the canary."}
+	}
+	if err != nil {
+		return DoctorFinding{Check: "encryption key", Status: DOCTOR_STATUS_FAIL, Message: err.Error(), Action: "Check database connectivity and permissions."}
+	}
+
+	if decrypted, err := m.Decyrpt([]byte(canary)); err != nil || decrypted != encryptionCanary {
+		return DoctorFinding{
+			Check:   "encryption key",
+			Status:  DOCTOR_STATUS_FAIL,
+			Message: "the configured ENCRYPTION_SECRET/ENCRYPTION_IV do not match the stored data",
+			Action:  "Restore the previous key, or re-encrypt with 'webapp keys rotate' while the old key is configured.",
+		}
+	}
+
+	return DoctorFinding{Check: "encryption key", Status: DOCTOR_STATUS_OK, Message: "canary decrypted with the configured key"}
+}
+
+func (m *Models) doctorSample(ctx context.Context, sampleSize int) DoctorFinding {
+	notes, err := m.store.NoteSample(ctx, sampleSize)
+	if err != nil {
+		return DoctorFinding{Check: "note sample", Status: DOCTOR_STATUS_FAIL, Message: err.Error(), Action: "Check database connectivity and permissions."}
+	}
+
+	if len(notes) == 0 {
+		return DoctorFinding{Check: "note sample", Status: DOCTOR_STATUS_OK, Message: "no notes stored"}
+	}
+
+	var failedIDs []string
+	failed := 0
+	for _, note := range notes {
+		if !m.noteDecrypts(ctx, note) {
+			failed++
+			if len(failedIDs) < doctorMaxReportedIDs {
+				failedIDs = append(failedIDs, fmt.Sprint(note.ID))
+			}
+		}
+	}
+
+	if failed > 0 {
+		return DoctorFinding{
+			Check:   "note sample",
+			Status:  DOCTOR_STATUS_FAIL,
+			Message: fmt.Sprintf("%d of %d sampled notes failed to decrypt (ids %s)", failed, len(notes), strings.Join(failedIDs, ", ")),
+			Action:  "These notes were written with a different key. Add it to ENCRYPTION_PREVIOUS_KEYS and run 'webapp notes repair'.",
+		}
+	}
+
+	return DoctorFinding{Check: "note sample", Status: DOCTOR_STATUS_OK, Message: fmt.Sprintf("%d sampled notes decrypted", len(notes))}
+}
+
+// EnsureCanary stores the encryption canary if it does not exist yet. To avoid blessing the
+// wrong key, the canary is only created when existing notes decrypt with the configured key.
+// Only system actors may create it.
+func (m *Models) EnsureCanary(ctx context.Context) error {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return err
+	}
+
+	_, err := m.store.CanaryGet(ctx)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	notes, err := m.store.NoteSample(ctx, 1)
+	if err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		if !m.noteDecrypts(ctx, note) {
+			return fmt.Errorf("not creating encryption canary, note %d does not decrypt with the configured key: %w", note.ID, ErrDecryptFailed)
+		}
+	}
+
+	encrypted, err := m.Encrypt([]byte(encryptionCanary))
+	if err != nil {
+		return ErrEncryptFailed
+	}
+
+	if err := m.store.CanarySet(ctx, encrypted); err != nil {
+		return err
+	}
+
+	logger.Log.Info().Msg("Created encryption canary")
+
+	return nil
+}
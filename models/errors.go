@@ -7,4 +7,15 @@ var (
 	ErrAlreadyExists = errors.New("entity already exists")
 	ErrEncryptFailed = errors.New("encryption failed")
 	ErrDecryptFailed = errors.New("decryption failed")
+	ErrInvalidInput  = errors.New("invalid input")
+	// no breach dataset is configured or it can't be read
+	ErrBreachCheckUnavailable = errors.New("breach check unavailable")
+	// see PolicyError for the rules that were broken
+	ErrPolicyViolation = errors.New("password policy violation")
+	// NOTE_ENCRYPT_NAMES is disabled
+	ErrNameEncryptionDisabled = errors.New("note name encryption disabled")
+	// the operation needs a signed in user
+	ErrUnauthenticated = errors.New("authentication required")
+	// the actor's role doesn't allow the operation
+	ErrForbidden = errors.New("permission denied")
 )
@@ -0,0 +1,368 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/web-app-template/notify"
+)
+
+const (
+	NOTE_EXPIRY_STATUS_EXPIRED          = "expired"
+	NOTE_EXPIRY_STATUS_EXPIRING         = "expiring"
+	NOTE_EXPIRY_STATUS_ROTATION_OVERDUE = "rotation_overdue"
+	NOTE_EXPIRY_STATUS_ROTATION_DUE     = "rotation_due"
+
+	// reminder events are sent as "note.<status>"
+	NOTIFY_EVENT_NOTE_PREFIX = "note."
+
+	// shortest rotation interval accepted, anything shorter would remind constantly
+	minRotateEvery = time.Hour
+)
+
+// NoteExpiry holds when a note expires and how often its value should be rotated. Zero values
+// mean never.
+type NoteExpiry struct {
+	ExpiresAt   time.Time
+	RotateEvery time.Duration
+}
+
+// NoteReviewParams represents the data accepted when reviewing a note. Omitted fields keep
+// their current value, empty strings clear them.
+type NoteReviewParams struct {
+	ExpiresAt   *string `json:"expires_at"`
+	RotateEvery *string `json:"rotate_every"`
+}
+
+// NoteExpiryMetadata represents a note that is expired or due soon. It never includes the
+// value.
+type NoteExpiryMetadata struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// when the note expired or is due for rotation, whichever the status refers to
+	DueAt         string `json:"due_at"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	RotateEvery   string `json:"rotate_every,omitempty"`
+	RotationDueAt string `json:"rotation_due_at,omitempty"`
+	ReviewedAt    string `json:"reviewed_at,omitempty"`
+}
+
+// NoteReminder records the last expiry reminder sent for a note, so the same reminder isn't
+// sent on every run.
+type NoteReminder struct {
+	NoteID     int64
+	Status     string
+	DueAt      string
+	RemindedAt string
+}
+
+// noteExpiryStore defines the interface required to track note expiry and review.
+type noteExpiryStore interface {
+	// NoteGetDueBefore returns the notes outside the trash of the vaults, every vault for nil
+	// vaultIDs, that expire before the cutoff without having been reviewed since expiring, or
+	// whose rotation falls due before it. Rotation is due RotateEvery after the later of the last
+	// update and the last review.
+	NoteGetDueBefore(ctx context.Context, before time.Time, vaultIDs []int64) ([]Note, error)
+	// NoteReview sets the note's reviewed time to now and replaces its expiry.
+	NoteReview(ctx context.Context, id int64, expiry NoteExpiry) (Note, error)
+	// NoteReminderGet returns the last reminder sent for a note. Returns ErrNotFound if none
+	// was sent.
+	NoteReminderGet(ctx context.Context, noteID int64) (NoteReminder, error)
+	// NoteReminderSave replaces the last reminder sent for a note.
+	NoteReminderSave(ctx context.Context, reminder NoteReminder) error
+}
+
+// ParseNoteExpiry parses the optional expiry time and rotation interval accepted when creating
+// or reviewing notes. Returns an ErrInvalidInput error if either is malformed.
+func ParseNoteExpiry(expiresAt, rotateEvery string) (NoteExpiry, error) {
+	var expiry NoteExpiry
+	var err error
+
+	if expiry.ExpiresAt, err = parseExpiresAt(expiresAt); err != nil {
+		return NoteExpiry{}, err
+	}
+
+	if expiry.RotateEvery, err = parseRotateEvery(rotateEvery); err != nil {
+		return NoteExpiry{}, err
+	}
+
+	return expiry, nil
+}
+
+// NoteGetExpiring returns the notes of the vaults the actor can read that are expired, overdue
+// for rotation, or will be within the window, soonest first. Expired notes that were reviewed
+// after expiring are not included.
+func (m *Models) NoteGetExpiring(ctx context.Context, within time.Duration) ([]NoteExpiryMetadata, error) {
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
+	if err != nil {
+		return []NoteExpiryMetadata{}, err
+	}
+
+	now := time.Now()
+
+	notes, err := m.store.NoteGetDueBefore(ctx, now.Add(within), vaultIDs)
+	if err != nil {
+		return []NoteExpiryMetadata{}, err
+	}
+
+	results := make([]NoteExpiryMetadata, 0, len(notes))
+	for _, note := range notes {
+		if metadata, ok := noteToExpiryMetadata(note, now, within); ok {
+			results = append(results, metadata)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].DueAt < results[j].DueAt })
+
+	return results, nil
+}
+
+// NoteReview records that someone confirmed the note is still valid, optionally changing its
+// expiry. Reviewing restarts the rotation interval and unhides an expired note. Needs the write
+// permission. Returns ErrNotFound if the note doesn't exist.
+func (m *Models) NoteReview(ctx context.Context, noteID int64, params NoteReviewParams) (NoteMetadata, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return NoteMetadata{}, err
+	}
+
+	note, err := m.store.NoteGetByID(ctx, noteID)
+	if err != nil {
+		return NoteMetadata{}, err
+	}
+
+	expiry := noteExpiryOf(note)
+
+	if params.ExpiresAt != nil {
+		if expiry.ExpiresAt, err = parseExpiresAt(*params.ExpiresAt); err != nil {
+			return NoteMetadata{}, err
+		}
+	}
+
+	if params.RotateEvery != nil {
+		if expiry.RotateEvery, err = parseRotateEvery(*params.RotateEvery); err != nil {
+			return NoteMetadata{}, err
+		}
+	}
+
+	reviewed, err := m.store.NoteReview(ctx, noteID, expiry)
+	if err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_REVIEW, noteID, AUDIT_OUTCOME_FAILURE)
+		return NoteMetadata{}, err
+	}
+
+	m.audit(ctx, AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_REVIEW,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   noteID,
+		Outcome:    AUDIT_OUTCOME_SUCCESS,
+		Details:    auditDetails("expires_at", reviewed.ExpiresAt, "rotate_every", formatInterval(reviewed.RotateEvery)),
+	})
+
+	return m.writtenNoteMetadata(ctx, reviewed), nil
+}
+
+// NoteSendExpiryReminders sends a notification for every note that is expired or due within
+// the configured warning window. Each note is reminded once per status and due time, so a note
+// is reminded again when it goes from expiring to expired or a review moves its due time. Only
+// system actors may send reminders. Returns the number of reminders sent.
+func (m *Models) NoteSendExpiryReminders(ctx context.Context) (int, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return 0, err
+	}
+
+	due, err := m.NoteGetExpiring(ctx, m.config.Notes.ExpiryWarning)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var errs []error
+
+	for _, note := range due {
+		last, err := m.store.NoteReminderGet(ctx, note.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Errorf("note %d: %w", note.ID, err))
+			continue
+		}
+		if err == nil && last.Status == note.Status && parseNoteTime(last.DueAt).Equal(parseNoteTime(note.DueAt)) {
+			continue
+		}
+
+		event := notify.NewEvent(NOTIFY_EVENT_NOTE_PREFIX+note.Status, expiryReminderMessage(note), map[string]any{
+			"note_id": note.ID,
+			"name":    note.Name,
+			"status":  note.Status,
+			"due_at":  note.DueAt,
+		})
+
+		if err := m.notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("note %d: %w", note.ID, err))
+			continue
+		}
+		sent++
+
+		reminder := NoteReminder{NoteID: note.ID, Status: note.Status, DueAt: note.DueAt, RemindedAt: time.Now().UTC().Format(time.RFC3339)}
+		if err := m.store.NoteReminderSave(ctx, reminder); err != nil {
+			errs = append(errs, fmt.Errorf("note %d: %w", note.ID, err))
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+func expiryReminderMessage(note NoteExpiryMetadata) string {
+	switch note.Status {
+	case NOTE_EXPIRY_STATUS_EXPIRED:
+		return fmt.Sprintf("Note %q expired at %s", note.Name, note.DueAt)
+	case NOTE_EXPIRY_STATUS_EXPIRING:
+		return fmt.Sprintf("Note %q expires at %s", note.Name, note.DueAt)
+	case NOTE_EXPIRY_STATUS_ROTATION_OVERDUE:
+		return fmt.Sprintf("Note %q was due for rotation at %s", note.Name, note.DueAt)
+	default:
+		return fmt.Sprintf("Note %q is due for rotation at %s", note.Name, note.DueAt)
+	}
+}
+
+// noteToExpiryMetadata works out why a note is due. Returns false when nothing about the note
+// falls due within the window.
+func noteToExpiryMetadata(note Note, now time.Time, within time.Duration) (NoteExpiryMetadata, bool) {
+	metadata := NoteExpiryMetadata{
+		ID:          note.ID,
+		Name:        note.Name,
+		ExpiresAt:   note.ExpiresAt,
+		RotateEvery: formatInterval(note.RotateEvery),
+		ReviewedAt:  note.ReviewedAt,
+	}
+
+	cutoff := now.Add(within)
+	expiresAt := parseNoteTime(note.ExpiresAt)
+	rotationDue := noteRotationDue(note)
+
+	if !rotationDue.IsZero() {
+		metadata.RotationDueAt = rotationDue.UTC().Format(time.RFC3339)
+	}
+
+	// the most urgent reason wins: expiry before rotation, past before upcoming
+	switch {
+	case noteAwaitingReview(note, now):
+		metadata.Status, metadata.DueAt = NOTE_EXPIRY_STATUS_EXPIRED, note.ExpiresAt
+	case !rotationDue.IsZero() && !rotationDue.After(now):
+		metadata.Status, metadata.DueAt = NOTE_EXPIRY_STATUS_ROTATION_OVERDUE, metadata.RotationDueAt
+	case expiresAt.After(now) && !expiresAt.After(cutoff):
+		metadata.Status, metadata.DueAt = NOTE_EXPIRY_STATUS_EXPIRING, note.ExpiresAt
+	case !rotationDue.IsZero() && !rotationDue.After(cutoff):
+		metadata.Status, metadata.DueAt = NOTE_EXPIRY_STATUS_ROTATION_DUE, metadata.RotationDueAt
+	default:
+		return NoteExpiryMetadata{}, false
+	}
+
+	return metadata, true
+}
+
+// noteExpired reports whether the note's expiry time has passed.
+func noteExpired(note Note, now time.Time) bool {
+	expiresAt := parseNoteTime(note.ExpiresAt)
+	return !expiresAt.IsZero() && !expiresAt.After(now)
+}
+
+// noteAwaitingReview reports whether the note expired and nobody has reviewed it since.
+func noteAwaitingReview(note Note, now time.Time) bool {
+	if !noteExpired(note, now) {
+		return false
+	}
+
+	reviewedAt := parseNoteTime(note.ReviewedAt)
+
+	return reviewedAt.Before(parseNoteTime(note.ExpiresAt))
+}
+
+// noteRotationDue returns when the note's value is next due for rotation, zero if it has no
+// rotation interval.
+func noteRotationDue(note Note) time.Time {
+	if note.RotateEvery <= 0 {
+		return time.Time{}
+	}
+
+	last := parseNoteTime(note.UpdatedAt)
+	if reviewedAt := parseNoteTime(note.ReviewedAt); reviewedAt.After(last) {
+		last = reviewedAt
+	}
+
+	return last.Add(note.RotateEvery)
+}
+
+// noteExpiryOf returns the expiry currently stored on a note.
+func noteExpiryOf(note Note) NoteExpiry {
+	return NoteExpiry{ExpiresAt: parseNoteTime(note.ExpiresAt), RotateEvery: note.RotateEvery}
+}
+
+// parseNoteTime parses a stored RFC 3339 timestamp, returning the zero time when it is empty.
+func parseNoteTime(raw string) time.Time {
+	t, _ := time.Parse(time.RFC3339, raw)
+	return t
+}
+
+// parseExpiresAt accepts an RFC 3339 time or a date, which means midnight UTC at its start.
+func parseExpiresAt(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: expires_at must be an RFC 3339 time or a YYYY-MM-DD date", ErrInvalidInput)
+}
+
+// parseRotateEvery accepts a Go duration such as 720h, or a whole number of days such as 90d.
+func parseRotateEvery(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+
+	var d time.Duration
+
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%w: rotate_every must be a duration such as 720h or a number of days such as 90d", ErrInvalidInput)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(raw); err != nil {
+			return 0, fmt.Errorf("%w: rotate_every must be a duration such as 720h or a number of days such as 90d", ErrInvalidInput)
+		}
+	}
+
+	if d < minRotateEvery {
+		return 0, fmt.Errorf("%w: rotate_every must be at least %s", ErrInvalidInput, minRotateEvery)
+	}
+
+	return d, nil
+}
+
+// formatInterval formats a rotation interval in days when it is a whole number of them.
+func formatInterval(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return ""
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	default:
+		return d.String()
+	}
+}
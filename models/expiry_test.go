@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/notify"
+)
+
+func TestParseNoteExpiry(t *testing.T) {
+	expiry, err := ParseNoteExpiry("2030-01-02", "90d")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !expiry.ExpiresAt.Equal(time.Date(2030, time.January, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected a date to mean midnight UTC, got %s", expiry.ExpiresAt)
+	}
+
+	if expiry.RotateEvery != 90*24*time.Hour {
+		t.Fatalf("Expected 90 days, got %s", expiry.RotateEvery)
+	}
+
+	if expiry, err := ParseNoteExpiry("", ""); err != nil || expiry != (NoteExpiry{}) {
+		t.Fatalf("Expected empty values to mean never, got %+v, %v", expiry, err)
+	}
+
+	for _, tc := range [][2]string{{"tomorrow", ""}, {"", "soon"}, {"", "30m"}} {
+		if _, err := ParseNoteExpiry(tc[0], tc[1]); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for %q, got %v", tc, err)
+		}
+	}
+}
+
+func TestNoteToExpiryMetadataStatus(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	format := func(t time.Time) string { return t.Format(time.RFC3339) }
+
+	tests := []struct {
+		name   string
+		note   Note
+		status string
+	}{
+		{"expired", Note{ExpiresAt: format(now.Add(-time.Hour))}, NOTE_EXPIRY_STATUS_EXPIRED},
+		{"expiring", Note{ExpiresAt: format(now.Add(24 * time.Hour))}, NOTE_EXPIRY_STATUS_EXPIRING},
+		{"rotation overdue", Note{UpdatedAt: format(now.Add(-40 * 24 * time.Hour)), RotateEvery: 30 * 24 * time.Hour}, NOTE_EXPIRY_STATUS_ROTATION_OVERDUE},
+		{"rotation due", Note{UpdatedAt: format(now.Add(-25 * 24 * time.Hour)), RotateEvery: 30 * 24 * time.Hour}, NOTE_EXPIRY_STATUS_ROTATION_DUE},
+		{"review restarts rotation", Note{
+			UpdatedAt:   format(now.Add(-40 * 24 * time.Hour)),
+			ReviewedAt:  format(now.Add(-time.Hour)),
+			RotateEvery: 30 * 24 * time.Hour,
+		}, ""},
+		{"reviewed after expiring", Note{ExpiresAt: format(now.Add(-time.Hour)), ReviewedAt: format(now)}, ""},
+		{"not due", Note{ExpiresAt: format(now.Add(30 * 24 * time.Hour))}, ""},
+	}
+
+	for _, tc := range tests {
+		metadata, ok := noteToExpiryMetadata(tc.note, now, week)
+		if ok != (tc.status != "") || metadata.Status != tc.status {
+			t.Errorf("%s: expected status %q, got %q (due %t)", tc.name, tc.status, metadata.Status, ok)
+		}
+	}
+}
+
+type recordingNotifier struct {
+	events []notify.Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+func TestNoteSendExpiryRemindersOncePerStatus(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{Notes: config.NotesConfig{ExpiryWarning: 7 * 24 * time.Hour}})
+	notifier := &recordingNotifier{}
+	m.notifier = notifier
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Cert", ExpiresAt: time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)}
+
+	for run := 0; run < 2; run++ {
+		if _, err := m.NoteSendExpiryReminders(ctx); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	if len(notifier.events) != 1 || notifier.events[0].Type != NOTIFY_EVENT_NOTE_PREFIX+NOTE_EXPIRY_STATUS_EXPIRING {
+		t.Fatalf("Expected a single expiring reminder, got %+v", notifier.events)
+	}
+
+	// the note expiring moves it to a new status, which is reminded once more
+	note := store.notes[1]
+	note.ExpiresAt = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	store.notes[1] = note
+
+	for run := 0; run < 2; run++ {
+		if _, err := m.NoteSendExpiryReminders(ctx); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	if len(notifier.events) != 2 || notifier.events[1].Type != NOTIFY_EVENT_NOTE_PREFIX+NOTE_EXPIRY_STATUS_EXPIRED {
+		t.Fatalf("Expected one more reminder once expired, got %+v", notifier.events)
+	}
+}
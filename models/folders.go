@@ -0,0 +1,443 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/oalexander6/web-app-template/logger"
+)
+
+const (
+	// what happens to the notes of a deleted folder and its subfolders
+	FOLDER_DELETE_NOTES_ROOT  = "root"
+	FOLDER_DELETE_NOTES_TRASH = "trash"
+
+	maxFolderNameLength = 100
+	maxTagsPerNote      = 20
+	maxTagLength        = 50
+)
+
+// Folder is a named group of notes in a vault. Folders nest, a ParentID of 0 is a top level
+// folder.
+type Folder struct {
+	ID        int64  `json:"id"`
+	VaultID   int64  `json:"vault_id"`
+	Name      string `json:"name"`
+	ParentID  int64  `json:"parent_id,omitempty"`
+	NoteCount int    `json:"note_count"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// FolderParams represents the data required to create, rename or move a folder.
+type FolderParams struct {
+	Name string `json:"name" form:"name" binding:"required"`
+	// the vault to create the folder in, folders can't be moved to another vault
+	VaultID int64 `json:"vault_id" form:"vault_id"`
+	// 0 for a top level folder, otherwise a folder in the same vault
+	ParentID int64 `json:"parent_id" form:"parent_id"`
+}
+
+// FolderDeleteResult represents the outcome of deleting a folder.
+type FolderDeleteResult struct {
+	// folders removed, including subfolders
+	Folders int `json:"folders"`
+	// notes moved out of any folder or to the trash
+	Notes int    `json:"notes"`
+	Mode  string `json:"mode"`
+}
+
+// Tag is a label on notes. Tags are created when first used and removed with their last note.
+type Tag struct {
+	Name      string `json:"name"`
+	NoteCount int    `json:"note_count"`
+}
+
+// NoteFolderParams represents the data required to move a note.
+type NoteFolderParams struct {
+	// a folder in the note's vault, 0 to move the note out of any folder
+	FolderID int64 `json:"folder_id" form:"folder_id"`
+}
+
+// NoteTagsParams represents the data required to replace a note's tags.
+type NoteTagsParams struct {
+	Tags []string `json:"tags" form:"tags"`
+}
+
+// NoteFilter narrows the notes listed by NoteGetAll. The zero value lists every note.
+type NoteFilter struct {
+	// only notes in the vault, 0 for any vault
+	VaultID int64
+	// only notes directly in the folder, 0 for notes outside any folder, nil for any folder
+	FolderID *int64
+	// only notes with the tag, compared case-insensitively
+	Tag string
+	// only notes UserID marked as favorites
+	Favorite bool
+	// the user whose favorites are marked and filtered, set by NoteGetAll to the actor, 0 for
+	// none
+	UserID int64
+	// only notes with this name, compared case-insensitively
+	Name string
+	// blind index token of Name that matches encrypted names, set by NoteGetAll
+	NameToken string
+	// only notes in these vaults, set by NoteGetAll to the vaults the actor can read, nil for
+	// every vault
+	VaultIDs []int64
+}
+
+// folderStore defines the interface required to persist folders, tags and favorites.
+type folderStore interface {
+	FolderGetAll(ctx context.Context) ([]Folder, error)
+	FolderGetByID(ctx context.Context, id int64) (Folder, error)
+	// FolderCreate returns ErrAlreadyExists if the parent has a folder with the same name.
+	FolderCreate(ctx context.Context, params FolderParams) (Folder, error)
+	// FolderUpdate renames and moves a folder. Returns ErrAlreadyExists if the new parent has a
+	// folder with the same name, or ErrInvalidInput if the new parent is inside the folder.
+	FolderUpdate(ctx context.Context, id int64, params FolderParams) (Folder, error)
+	// FolderDelete removes the folder and its subfolders. Their notes are moved out of any folder
+	// or, with trashNotes, to the trash. Returns the number of folders removed and the IDs of the
+	// notes that were in them, or a *NameConflictError if a moved note's name is already taken.
+	FolderDelete(ctx context.Context, id int64, trashNotes bool, deletedBy int64) (int, []int64, error)
+	// NoteList returns the notes matching the filter with their tags.
+	NoteList(ctx context.Context, filter NoteFilter) ([]Note, error)
+	// NoteSetFolder returns a *NameConflictError if the folder has a note with the same name.
+	NoteSetFolder(ctx context.Context, noteID int64, folderID int64) error
+	// NoteSetFavorite marks or unmarks the note as a favorite of the user. Returns ErrNotFound
+	// if the note doesn't exist.
+	NoteSetFavorite(ctx context.Context, userID int64, noteID int64, favorite bool) error
+	// NoteFavoriteIDs returns the IDs of the user's favorite notes.
+	NoteFavoriteIDs(ctx context.Context, userID int64) ([]int64, error)
+	// NoteSetTags replaces the note's tags, creating new tags and removing unused ones.
+	NoteSetTags(ctx context.Context, noteID int64, tags []string) error
+	// TagGetAll returns the tags of notes in the vaults, counting the notes in them.
+	TagGetAll(ctx context.Context, vaultIDs []int64) ([]Tag, error)
+}
+
+// FolderGetAll returns the folders of the vaults the actor can read with the number of notes
+// directly in each.
+func (m *Models) FolderGetAll(ctx context.Context) ([]Folder, error) {
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
+	if err != nil {
+		return []Folder{}, err
+	}
+
+	folders, err := m.store.FolderGetAll(ctx)
+	if err != nil {
+		return []Folder{}, err
+	}
+
+	return slices.DeleteFunc(folders, func(folder Folder) bool {
+		return !slices.Contains(vaultIDs, folder.VaultID)
+	}), nil
+}
+
+// FolderCreate creates a folder in a vault, which needs the write permission. Returns
+// ErrAlreadyExists if the parent already has a folder with the name, or an ErrInvalidInput error
+// for an invalid name or a parent outside the vault.
+func (m *Models) FolderCreate(ctx context.Context, params FolderParams) (Folder, error) {
+	if err := requireVault(params.VaultID); err != nil {
+		return Folder{}, err
+	}
+
+	if err := m.authorize(ctx, PERMISSION_WRITE, Resource{VaultID: params.VaultID}); err != nil {
+		return Folder{}, err
+	}
+
+	params, err := m.validateFolderParams(ctx, params)
+	if err != nil {
+		return Folder{}, err
+	}
+
+	folder, err := m.store.FolderCreate(ctx, params)
+	if err != nil {
+		m.auditFolder(ctx, AUDIT_ACTION_FOLDER_CREATE, 0, AUDIT_OUTCOME_FAILURE, "name", params.Name)
+		return Folder{}, err
+	}
+
+	m.auditFolder(ctx, AUDIT_ACTION_FOLDER_CREATE, folder.ID, AUDIT_OUTCOME_SUCCESS, "name", params.Name)
+
+	return folder, nil
+}
+
+// FolderUpdate renames a folder or moves it to another parent in its vault. Needs the write
+// permission. Returns ErrNotFound if the folder doesn't exist, ErrAlreadyExists if the parent
+// already has a folder with the name, or an ErrInvalidInput error for an invalid name, a parent
+// outside the vault or a parent inside the folder.
+func (m *Models) FolderUpdate(ctx context.Context, folderID int64, params FolderParams) (Folder, error) {
+	folder, err := m.authorizeFolder(ctx, PERMISSION_WRITE, folderID)
+	if err != nil {
+		return Folder{}, err
+	}
+	params.VaultID = folder.VaultID
+
+	params, err = m.validateFolderParams(ctx, params)
+	if err != nil {
+		return Folder{}, err
+	}
+
+	if params.ParentID == folderID {
+		return Folder{}, fmt.Errorf("%w: a folder can't be its own parent", ErrInvalidInput)
+	}
+
+	folder, err = m.store.FolderUpdate(ctx, folderID, params)
+	if err != nil {
+		m.auditFolder(ctx, AUDIT_ACTION_FOLDER_UPDATE, folderID, AUDIT_OUTCOME_FAILURE, "name", params.Name)
+		return Folder{}, err
+	}
+
+	m.auditFolder(ctx, AUDIT_ACTION_FOLDER_UPDATE, folderID, AUDIT_OUTCOME_SUCCESS, "name", params.Name)
+
+	return folder, nil
+}
+
+// FolderDelete removes a folder and its subfolders. Their notes are moved out of any folder with
+// the root mode (the default) or to the trash with the trash mode. Needs the write permission.
+// Returns ErrNotFound if the folder doesn't exist, an ErrInvalidInput error for other modes or a
+// *NameConflictError if a note moved out of any folder has the name of a note already there.
+func (m *Models) FolderDelete(ctx context.Context, folderID int64, mode string) (FolderDeleteResult, error) {
+	if mode == "" {
+		mode = FOLDER_DELETE_NOTES_ROOT
+	}
+
+	if mode != FOLDER_DELETE_NOTES_ROOT && mode != FOLDER_DELETE_NOTES_TRASH {
+		return FolderDeleteResult{}, fmt.Errorf("%w: notes must be %s or %s", ErrInvalidInput, FOLDER_DELETE_NOTES_ROOT, FOLDER_DELETE_NOTES_TRASH)
+	}
+
+	if _, err := m.authorizeFolder(ctx, PERMISSION_WRITE, folderID); err != nil {
+		return FolderDeleteResult{}, err
+	}
+
+	trash := mode == FOLDER_DELETE_NOTES_TRASH
+
+	folders, noteIDs, err := m.store.FolderDelete(ctx, folderID, trash, ActorFromContext(ctx).UserID)
+	if err != nil {
+		m.auditFolder(ctx, AUDIT_ACTION_FOLDER_DELETE, folderID, AUDIT_OUTCOME_FAILURE, "notes", mode)
+		return FolderDeleteResult{}, err
+	}
+
+	m.auditFolder(ctx, AUDIT_ACTION_FOLDER_DELETE, folderID, AUDIT_OUTCOME_SUCCESS, "notes", mode, "count", strconv.Itoa(len(noteIDs)))
+
+	if trash {
+		for _, noteID := range noteIDs {
+			m.auditNote(ctx, AUDIT_ACTION_NOTE_DELETE, noteID, AUDIT_OUTCOME_SUCCESS)
+		}
+	}
+
+	return FolderDeleteResult{Folders: folders, Notes: len(noteIDs), Mode: mode}, nil
+}
+
+// NoteMove moves a note into a folder of its vault, or out of any folder with a folder ID of 0.
+// Needs the write permission. Returns ErrNotFound if the note doesn't exist, an ErrInvalidInput
+// error if the folder isn't in the note's vault or a *NameConflictError if the folder has a note
+// with the same name.
+func (m *Models) NoteMove(ctx context.Context, noteID int64, params NoteFolderParams) error {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return err
+	}
+
+	vaultID, err := m.store.NoteGetVaultID(ctx, noteID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.checkFolderExists(ctx, params.FolderID, vaultID); err != nil {
+		return err
+	}
+
+	event := AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_MOVE,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   noteID,
+		Details:    auditDetails("folder_id", strconv.FormatInt(params.FolderID, 10)),
+		Outcome:    AUDIT_OUTCOME_FAILURE,
+	}
+
+	if err := m.store.NoteSetFolder(ctx, noteID, params.FolderID); err != nil {
+		m.audit(ctx, event)
+		return err
+	}
+
+	event.Outcome = AUDIT_OUTCOME_SUCCESS
+	m.audit(ctx, event)
+
+	return nil
+}
+
+// NoteSetFavorite marks or unmarks a note as a favorite of the actor. Favorites are personal, so
+// only the read permission is needed. Returns ErrNotFound if the note doesn't exist, or an
+// ErrInvalidInput error for actors that aren't signed in users.
+func (m *Models) NoteSetFavorite(ctx context.Context, noteID int64, favorite bool) error {
+	if err := m.authorizeNote(ctx, PERMISSION_READ, noteID); err != nil {
+		return err
+	}
+
+	actor := ActorFromContext(ctx)
+	if !actor.Authenticated() {
+		return fmt.Errorf("%w: favorites belong to a signed in user", ErrInvalidInput)
+	}
+
+	return m.store.NoteSetFavorite(ctx, actor.UserID, noteID, favorite)
+}
+
+// actorFavoriteIDs returns the IDs of the actor's favorite notes, none for actors that aren't
+// signed in users.
+func (m *Models) actorFavoriteIDs(ctx context.Context) ([]int64, error) {
+	actor := ActorFromContext(ctx)
+	if !actor.Authenticated() {
+		return []int64{}, nil
+	}
+
+	return m.store.NoteFavoriteIDs(ctx, actor.UserID)
+}
+
+// writtenNoteMetadata converts a note the actor just wrote to its listing representation with
+// the actor's favorite mark. A failure to read the favorites is logged and leaves the note
+// unmarked, as the write has already succeeded.
+func (m *Models) writtenNoteMetadata(ctx context.Context, note Note) NoteMetadata {
+	favorites, err := m.actorFavoriteIDs(ctx)
+	if err != nil {
+		logger.Log.Error().Err(err).Int64("note_id", note.ID).Msg("Failed to read favorites")
+	}
+
+	note.Favorite = slices.Contains(favorites, note.ID)
+
+	return noteToMetadata(note)
+}
+
+// NoteSetTags replaces a note's tags. Needs the write permission. Returns ErrNotFound if the note
+// doesn't exist or an ErrInvalidInput error for invalid tags.
+func (m *Models) NoteSetTags(ctx context.Context, noteID int64, params NoteTagsParams) ([]string, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return nil, err
+	}
+
+	tags, err := normalizeTags(params.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	event := AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_TAG,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   noteID,
+		Details:    auditDetails("tags", strings.Join(tags, ",")),
+		Outcome:    AUDIT_OUTCOME_FAILURE,
+	}
+
+	if err := m.store.NoteSetTags(ctx, noteID, tags); err != nil {
+		m.audit(ctx, event)
+		return nil, err
+	}
+
+	event.Outcome = AUDIT_OUTCOME_SUCCESS
+	m.audit(ctx, event)
+
+	return tags, nil
+}
+
+// TagGetAll returns the tags used in the vaults the actor can read with the number of notes
+// there that have each.
+func (m *Models) TagGetAll(ctx context.Context) ([]Tag, error) {
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
+	if err != nil {
+		return []Tag{}, err
+	}
+
+	return m.store.TagGetAll(ctx, vaultIDs)
+}
+
+// authorizeFolder authorizes the permission on the vault of a folder and returns the folder.
+// Returns ErrNotFound if the folder doesn't exist.
+func (m *Models) authorizeFolder(ctx context.Context, permission Permission, folderID int64) (Folder, error) {
+	folder, err := m.store.FolderGetByID(ctx, folderID)
+	if err != nil {
+		return Folder{}, err
+	}
+
+	if err := m.authorize(ctx, permission, Resource{VaultID: folder.VaultID}); err != nil {
+		return Folder{}, err
+	}
+
+	return folder, nil
+}
+
+func (m *Models) validateFolderParams(ctx context.Context, params FolderParams) (FolderParams, error) {
+	params.Name = strings.TrimSpace(params.Name)
+
+	if params.Name == "" || utf8.RuneCountInString(params.Name) > maxFolderNameLength {
+		return FolderParams{}, fmt.Errorf("%w: folder name must be 1 to %d characters", ErrInvalidInput, maxFolderNameLength)
+	}
+
+	if err := m.checkFolderExists(ctx, params.ParentID, params.VaultID); err != nil {
+		return FolderParams{}, err
+	}
+
+	return params, nil
+}
+
+// checkFolderExists returns an ErrInvalidInput error if the folder doesn't exist in the vault.
+// The root folder 0 exists in every vault.
+func (m *Models) checkFolderExists(ctx context.Context, folderID int64, vaultID int64) error {
+	if folderID == 0 {
+		return nil
+	}
+
+	folder, err := m.store.FolderGetByID(ctx, folderID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err != nil || folder.VaultID != vaultID {
+		return fmt.Errorf("%w: folder %d doesn't exist in vault %d", ErrInvalidInput, folderID, vaultID)
+	}
+
+	return nil
+}
+
+func (m *Models) auditFolder(ctx context.Context, action string, folderID int64, outcome string, details ...string) error {
+	return m.audit(ctx, AuditEvent{
+		Action:     action,
+		TargetType: AUDIT_TARGET_FOLDER,
+		TargetID:   folderID,
+		Details:    auditDetails(details...),
+		Outcome:    outcome,
+	})
+}
+
+// normalizeTags trims the tags, drops empty and duplicate ones (ignoring case, the first
+// spelling wins) and sorts them. Returns an ErrInvalidInput error for too many or too long tags.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("%w: tag %q must be at most %d characters", ErrInvalidInput, tag, maxTagLength)
+		}
+
+		seen[strings.ToLower(tag)] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > maxTagsPerNote {
+		return nil, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidInput, maxTagsPerNote)
+	}
+
+	sort.Slice(normalized, func(i, j int) bool {
+		return strings.ToLower(normalized[i]) < strings.ToLower(normalized[j])
+	})
+
+	return normalized, nil
+}
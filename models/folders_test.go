@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" work ", "Banking", "WORK", "", "api"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if expected := []string{"api", "Banking", "work"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("Expected %v, got %v", expected, tags)
+	}
+
+	tooMany := make([]string, maxTagsPerNote+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	invalid := [][]string{
+		{strings.Repeat("t", maxTagLength+1)},
+		tooMany,
+	}
+
+	for _, tags := range invalid {
+		if _, err := normalizeTags(tags); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput for %.60v, got %v", tags, err)
+		}
+	}
+}
+
+func TestNoteSetFavoriteIsPersonal(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{})
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Shared", Type: NOTE_TYPE_SECURE_NOTE}
+	store.memberships = []Member{
+		{OrganizationID: 1, VaultID: 1, UserID: 2, Role: ROLE_VIEWER},
+		{OrganizationID: 1, UserID: 3, Role: ROLE_EDITOR},
+	}
+
+	viewer := WithActor(context.Background(), Actor{UserID: 2})
+	editor := WithActor(context.Background(), Actor{UserID: 3})
+
+	// favorites don't change the note, so reading it is enough
+	if err := m.NoteSetFavorite(viewer, 1, true); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if favorites, _ := m.actorFavoriteIDs(viewer); !reflect.DeepEqual(favorites, []int64{1}) {
+		t.Fatalf("Expected the viewer's favorite, got %v", favorites)
+	}
+
+	if favorites, _ := m.actorFavoriteIDs(editor); len(favorites) != 0 {
+		t.Fatalf("Expected another member's favorites to be unchanged, got %v", favorites)
+	}
+
+	if err := m.NoteSetFavorite(WithActor(context.Background(), Actor{UserID: 4}), 1, true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a non-member, got %v", err)
+	}
+
+	if err := m.NoteSetFavorite(WithActor(context.Background(), SystemActor("test")), 1, true); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for an actor without a user, got %v", err)
+	}
+}
@@ -0,0 +1,446 @@
+package models
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	NOTE_TYPE_LOGIN    = "login"
+	NOTE_TYPE_CARD     = "card"
+	NOTE_TYPE_SSH_KEY  = "ssh_key"
+	NOTE_TYPE_IDENTITY = "identity"
+
+	FIELD_KIND_TEXT = "text"
+	// http(s) URLs, one per line
+	FIELD_KIND_URLS = "urls"
+	// otpauth:// URI or base32 secret
+	FIELD_KIND_TOTP        = "totp"
+	FIELD_KIND_CARD_NUMBER = "card_number"
+	// MM/YY or MM/YYYY, stored as MM/YYYY
+	FIELD_KIND_CARD_EXPIRY     = "card_expiry"
+	FIELD_KIND_CVV             = "cvv"
+	FIELD_KIND_SSH_PRIVATE_KEY = "ssh_private_key"
+	FIELD_KIND_SSH_PUBLIC_KEY  = "ssh_public_key"
+	FIELD_KIND_EMAIL           = "email"
+	FIELD_KIND_PHONE           = "phone"
+	// YYYY-MM-DD
+	FIELD_KIND_DATE = "date"
+
+	// longest field value in bytes, large enough for a 16384 bit RSA private key
+	maxFieldLength = 16 * 1024
+)
+
+// NoteField is a field of a typed note. Encrypted values are ciphertext.
+type NoteField struct {
+	Value     string `json:"value"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+// itemField describes a field of a note type.
+type itemField struct {
+	name     string
+	kind     string
+	required bool
+	// listed values are stored as plaintext so they can be listed and searched, every other
+	// field is encrypted individually and only returned when the note is revealed
+	listed bool
+}
+
+// itemSchema describes the fields of a note type. The type's main secret is stored encrypted
+// as the note's value, so versions, rotation and key rotation work the same for every type.
+type itemSchema struct {
+	value  itemField
+	fields []itemField
+	// the value is a password, so the password policy applies to it
+	password bool
+	// the value is a password or free text rather than structured data, so it is scored in
+	// the security report, checked against the breach dataset and can be rotated
+	freeform bool
+}
+
+var itemSchemas = map[string]itemSchema{
+	NOTE_TYPE_SECURE_NOTE: {
+		value:    itemField{name: "text", kind: FIELD_KIND_TEXT, required: true},
+		freeform: true,
+	},
+	NOTE_TYPE_PASSWORD: {
+		value:    itemField{name: "password", kind: FIELD_KIND_TEXT, required: true},
+		password: true,
+		freeform: true,
+	},
+	NOTE_TYPE_LOGIN: {
+		value: itemField{name: "password", kind: FIELD_KIND_TEXT, required: true},
+		fields: []itemField{
+			{name: "username", kind: FIELD_KIND_TEXT},
+			// the sites a login is for, so logins can be found by site without revealing them
+			{name: "urls", kind: FIELD_KIND_URLS, listed: true},
+			{name: "totp", kind: FIELD_KIND_TOTP},
+		},
+		password: true,
+		freeform: true,
+	},
+	NOTE_TYPE_CARD: {
+		value: itemField{name: "number", kind: FIELD_KIND_CARD_NUMBER, required: true},
+		fields: []itemField{
+			{name: "cardholder", kind: FIELD_KIND_TEXT},
+			{name: "expiry", kind: FIELD_KIND_CARD_EXPIRY, required: true},
+			{name: "cvv", kind: FIELD_KIND_CVV},
+		},
+	},
+	NOTE_TYPE_SSH_KEY: {
+		value: itemField{name: "private_key", kind: FIELD_KIND_SSH_PRIVATE_KEY, required: true},
+		fields: []itemField{
+			// public by definition
+			{name: "public_key", kind: FIELD_KIND_SSH_PUBLIC_KEY, listed: true},
+			{name: "passphrase", kind: FIELD_KIND_TEXT},
+		},
+	},
+	NOTE_TYPE_IDENTITY: {
+		value: itemField{name: "document_number", kind: FIELD_KIND_TEXT},
+		fields: []itemField{
+			{name: "first_name", kind: FIELD_KIND_TEXT},
+			{name: "last_name", kind: FIELD_KIND_TEXT},
+			{name: "email", kind: FIELD_KIND_EMAIL},
+			{name: "phone", kind: FIELD_KIND_PHONE},
+			{name: "address", kind: FIELD_KIND_TEXT},
+			{name: "birth_date", kind: FIELD_KIND_DATE},
+		},
+	},
+}
+
+// noteSchema returns the schema of the note type, notes saved before types existed are secure
+// notes. Returns an ErrInvalidInput error for unknown types.
+func noteSchema(noteType string) (itemSchema, error) {
+	if noteType == "" {
+		noteType = NOTE_TYPE_SECURE_NOTE
+	}
+
+	schema, ok := itemSchemas[noteType]
+	if !ok {
+		return itemSchema{}, fmt.Errorf("%w: unknown note type %q", ErrInvalidInput, noteType)
+	}
+
+	return schema, nil
+}
+
+// noteTypeFreeform reports whether the value of notes of the type is a password or free text.
+func noteTypeFreeform(noteType string) bool {
+	schema, err := noteSchema(noteType)
+	return err == nil && schema.freeform
+}
+
+// noteTypePassword reports whether the password policy applies to notes of the type.
+func noteTypePassword(noteType string) bool {
+	schema, err := noteSchema(noteType)
+	return err == nil && schema.password
+}
+
+// validate checks the value and fields against the schema and returns them normalized.
+// Returns an ErrInvalidInput error naming the first invalid field.
+func (s itemSchema) validate(value string, fields map[string]string) (string, map[string]string, error) {
+	value, err := validateField(s.value, value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	known := make(map[string]bool, len(s.fields))
+	normalized := make(map[string]string, len(fields))
+
+	for _, field := range s.fields {
+		known[field.name] = true
+
+		fieldValue, err := validateField(field, fields[field.name])
+		if err != nil {
+			return "", nil, err
+		}
+
+		if fieldValue != "" {
+			normalized[field.name] = fieldValue
+		}
+	}
+
+	for name := range fields {
+		if name == s.value.name {
+			return "", nil, fmt.Errorf("%w: %s is the note's value, not a field", ErrInvalidInput, name)
+		}
+		if !known[name] {
+			return "", nil, fmt.Errorf("%w: unknown field %q", ErrInvalidInput, name)
+		}
+	}
+
+	return value, normalized, nil
+}
+
+// validateField checks and normalizes one value of the field's kind.
+func validateField(field itemField, value string) (string, error) {
+	invalid := func(reason string) (string, error) {
+		return "", fmt.Errorf("%w: %s %s", ErrInvalidInput, field.name, reason)
+	}
+
+	if len(value) > maxFieldLength {
+		return invalid(fmt.Sprintf("must be at most %d bytes", maxFieldLength))
+	}
+
+	if !utf8.ValidString(value) {
+		return invalid("must be valid UTF-8")
+	}
+
+	if field.kind != FIELD_KIND_TEXT && field.kind != FIELD_KIND_SSH_PRIVATE_KEY {
+		value = strings.TrimSpace(value)
+	}
+
+	if value == "" {
+		if field.required {
+			return invalid("is required")
+		}
+		return "", nil
+	}
+
+	switch field.kind {
+	case FIELD_KIND_URLS:
+		var urls []string
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+
+			u, err := url.Parse(line)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return invalid(fmt.Sprintf("must be http or https URLs, one per line, got %q", line))
+			}
+			urls = append(urls, line)
+		}
+		return strings.Join(urls, "\n"), nil
+
+	case FIELD_KIND_TOTP:
+		if strings.HasPrefix(value, "otpauth://") {
+			if u, err := url.Parse(value); err != nil || u.Query().Get("secret") == "" {
+				return invalid("must be an otpauth:// URI with a secret")
+			}
+			return value, nil
+		}
+
+		secret := strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+		if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "=")); err != nil {
+			return invalid("must be an otpauth:// URI or a base32 secret")
+		}
+		return secret, nil
+
+	case FIELD_KIND_CARD_NUMBER:
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(value)
+		if len(digits) < 12 || len(digits) > 19 || !allDigits(digits) || !luhnValid(digits) {
+			return invalid("must be a valid card number")
+		}
+		return digits, nil
+
+	case FIELD_KIND_CARD_EXPIRY:
+		for _, layout := range []string{"01/06", "01/2006"} {
+			if expiry, err := time.Parse(layout, value); err == nil {
+				return expiry.Format("01/2006"), nil
+			}
+		}
+		return invalid("must be MM/YY or MM/YYYY")
+
+	case FIELD_KIND_CVV:
+		if len(value) < 3 || len(value) > 4 || !allDigits(value) {
+			return invalid("must be 3 or 4 digits")
+		}
+
+	case FIELD_KIND_SSH_PRIVATE_KEY:
+		block, _ := pem.Decode([]byte(strings.TrimSpace(value)))
+		if block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			return invalid("must be a PEM encoded private key")
+		}
+
+	case FIELD_KIND_SSH_PUBLIC_KEY:
+		parts := strings.Fields(value)
+		if len(parts) < 2 {
+			return invalid("must be in authorized_keys format")
+		}
+		if _, err := base64.StdEncoding.DecodeString(parts[1]); err != nil {
+			return invalid("must be in authorized_keys format")
+		}
+
+	case FIELD_KIND_EMAIL:
+		if _, err := mail.ParseAddress(value); err != nil {
+			return invalid("must be an email address")
+		}
+
+	case FIELD_KIND_PHONE:
+		digits := 0
+		for _, r := range value {
+			switch {
+			case r >= '0' && r <= '9':
+				digits++
+			case strings.ContainsRune("+-() .", r):
+			default:
+				return invalid("must be a phone number")
+			}
+		}
+		if digits < 3 {
+			return invalid("must be a phone number")
+		}
+
+	case FIELD_KIND_DATE:
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			return invalid("must be a YYYY-MM-DD date")
+		}
+	}
+
+	return value, nil
+}
+
+func allDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhnValid reports whether the digits pass the Luhn checksum used by card numbers.
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// listed reports whether the field of the schema is stored as plaintext.
+func (s itemSchema) listed(name string) bool {
+	for _, field := range s.fields {
+		if field.name == name {
+			return field.listed
+		}
+	}
+
+	return false
+}
+
+// encryptFields encrypts each field of the schema individually, except listed fields, which are
+// stored as plaintext.
+func (c noteCipher) encryptFields(schema itemSchema, fields map[string]string) (map[string]NoteField, error) {
+	encrypted := make(map[string]NoteField, len(fields))
+
+	for name, value := range fields {
+		if schema.listed(name) {
+			encrypted[name] = NoteField{Value: value}
+			continue
+		}
+
+		encVal, err := c.encrypt([]byte(value))
+		if err != nil {
+			return nil, ErrEncryptFailed
+		}
+		encrypted[name] = NoteField{Value: encVal, Encrypted: true}
+	}
+
+	return encrypted, nil
+}
+
+// NoteEncryptFields encrypts the fields stored as plaintext before every field other than listed
+// ones was encrypted, in notes, the trash and versions, returning the number of notes changed.
+// Only system actors may encrypt fields.
+func (m *Models) NoteEncryptFields(ctx context.Context) (int, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return 0, err
+	}
+
+	ciphers := map[int64]noteCipher{}
+
+	return m.store.NoteEncryptFields(ctx, func(noteID int64, noteType string, fields map[string]NoteField) (bool, error) {
+		schema, err := noteSchema(noteType)
+		if err != nil {
+			return false, err
+		}
+
+		changed := false
+		for name, field := range fields {
+			if field.Encrypted || schema.listed(name) {
+				continue
+			}
+
+			c, ok := ciphers[noteID]
+			if !ok {
+				// notes without a data key keep the master key, which keys rotate re-encrypts
+				if c, err = m.noteCipher(ctx, noteID); err != nil {
+					return false, err
+				}
+				ciphers[noteID] = c
+			}
+
+			encVal, err := c.encrypt([]byte(field.Value))
+			if err != nil {
+				return false, ErrEncryptFailed
+			}
+
+			fields[name] = NoteField{Value: encVal, Encrypted: true}
+			changed = true
+		}
+
+		return changed, nil
+	})
+}
+
+// decryptFields returns the plaintext of every field.
+// Returns ErrDecryptFailed if an encrypted field can't be decrypted.
+func (c noteCipher) decryptFields(fields map[string]NoteField) (map[string]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	decrypted := make(map[string]string, len(fields))
+
+	for name, field := range fields {
+		if !field.Encrypted {
+			decrypted[name] = field.Value
+			continue
+		}
+
+		value, err := c.decrypt(field.Value)
+		if err != nil {
+			return nil, ErrDecryptFailed
+		}
+		decrypted[name] = value
+	}
+
+	return decrypted, nil
+}
+
+// visibleFields returns the fields that are stored as plaintext, for listings.
+func visibleFields(fields map[string]NoteField) map[string]string {
+	visible := make(map[string]string, len(fields))
+
+	for name, field := range fields {
+		if !field.Encrypted {
+			visible[name] = field.Value
+		}
+	}
+
+	if len(visible) == 0 {
+		return nil
+	}
+
+	return visible
+}
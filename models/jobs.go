@@ -0,0 +1,314 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	JOB_STATUS_QUEUED    = "queued"
+	JOB_STATUS_RUNNING   = "running"
+	JOB_STATUS_SUCCEEDED = "succeeded"
+	// jobs that failed on every attempt or failed permanently
+	JOB_STATUS_DEAD = "dead"
+
+	JOB_KIND_NOTE_IMPORT  = "notes.import"
+	JOB_KIND_AUDIT_VERIFY = "audit.verify"
+
+	defaultJobMaxAttempts = 5
+)
+
+// Job is a unit of background work. The payload is opaque to the queue and may be encrypted.
+type Job struct {
+	ID              int64
+	Kind            string
+	Payload         string
+	Status          string
+	Attempts        int
+	MaxAttempts     int
+	RunAt           string
+	Progress        int
+	ProgressMessage string
+	Result          string
+	LastError       string
+	CreatedBy       int64
+	CreatedAt       string
+	UpdatedAt       string
+	FinishedAt      string
+}
+
+// JobEnqueueParams represents the data required to queue a job.
+type JobEnqueueParams struct {
+	Kind        string
+	Payload     string
+	MaxAttempts int
+	RunAt       time.Time
+	CreatedBy   int64
+}
+
+// JobStatusResponse represents the data returned for job status requests. It never includes
+// the payload.
+type JobStatusResponse struct {
+	ID              int64  `json:"id"`
+	Kind            string `json:"kind"`
+	Status          string `json:"status"`
+	Attempts        int    `json:"attempts"`
+	MaxAttempts     int    `json:"max_attempts"`
+	Progress        int    `json:"progress"`
+	ProgressMessage string `json:"progress_message,omitempty"`
+	Result          string `json:"result,omitempty"`
+	LastError       string `json:"last_error,omitempty"`
+	RunAt           string `json:"run_at"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+	FinishedAt      string `json:"finished_at,omitempty"`
+}
+
+// jobStore defines the interface required to persist the job queue.
+type jobStore interface {
+	JobEnqueue(ctx context.Context, params JobEnqueueParams) (Job, error)
+	JobGetByID(ctx context.Context, id int64) (Job, error)
+	// JobClaim marks the next due job of one of the kinds as running until the lease expires,
+	// skipping jobs claimed by other workers. Jobs whose lease expired are claimed again.
+	// Returns ErrNotFound when no job is due.
+	JobClaim(ctx context.Context, kinds []string, lease time.Duration) (Job, error)
+	JobProgress(ctx context.Context, id int64, progress int, message string) error
+	JobComplete(ctx context.Context, id int64, result string) error
+	// JobFail records the error and queues the job again at retryAt, or moves it to the dead
+	// state when retryAt is zero.
+	JobFail(ctx context.Context, id int64, message string, retryAt time.Time) error
+	// JobDeleteFinishedBefore removes succeeded and dead jobs that finished before the cutoff.
+	JobDeleteFinishedBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// JobEnqueue queues a job to run as soon as a worker is free. Only system actors may queue
+// arbitrary jobs, users queue them through methods such as NoteImportEnqueue.
+func (m *Models) JobEnqueue(ctx context.Context, params JobEnqueueParams) (Job, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return Job{}, err
+	}
+
+	return m.enqueue(ctx, params)
+}
+
+// enqueue queues a job once the caller has authorized it.
+func (m *Models) enqueue(ctx context.Context, params JobEnqueueParams) (Job, error) {
+	if params.MaxAttempts <= 0 {
+		params.MaxAttempts = defaultJobMaxAttempts
+	}
+	if params.RunAt.IsZero() {
+		params.RunAt = time.Now()
+	}
+
+	return m.store.JobEnqueue(ctx, params)
+}
+
+// JobGetByID returns the status of a job. Users may only see the jobs they queued. Returns
+// ErrNotFound if the job doesn't exist.
+func (m *Models) JobGetByID(ctx context.Context, id int64) (JobStatusResponse, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	job, err := m.store.JobGetByID(ctx, id)
+	if err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	if actor := ActorFromContext(ctx); !actor.System && job.CreatedBy != actor.UserID {
+		return JobStatusResponse{}, ErrNotFound
+	}
+
+	return JobToStatus(job), nil
+}
+
+// JobClaim is used by queue workers, see the store method. Like the other worker methods, only
+// system actors may call it.
+func (m *Models) JobClaim(ctx context.Context, kinds []string, lease time.Duration) (Job, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return Job{}, err
+	}
+
+	return m.store.JobClaim(ctx, kinds, lease)
+}
+
+// JobProgress records how far along a running job is, as a percentage.
+func (m *Models) JobProgress(ctx context.Context, id int64, progress int, message string) error {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return err
+	}
+
+	return m.store.JobProgress(ctx, id, min(max(progress, 0), 100), message)
+}
+
+// JobComplete marks a job as succeeded with its result.
+func (m *Models) JobComplete(ctx context.Context, id int64, result string) error {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return err
+	}
+
+	return m.store.JobComplete(ctx, id, result)
+}
+
+// JobFail records a failed attempt, see the store method.
+func (m *Models) JobFail(ctx context.Context, id int64, message string, retryAt time.Time) error {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return err
+	}
+
+	return m.store.JobFail(ctx, id, message, retryAt)
+}
+
+// JobDeleteFinished removes finished jobs older than the retention. Returns the number removed.
+func (m *Models) JobDeleteFinished(ctx context.Context, retention time.Duration) (int, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return 0, err
+	}
+
+	return m.store.JobDeleteFinishedBefore(ctx, time.Now().Add(-retention))
+}
+
+// noteImportPayload is the payload of a notes.import job.
+type noteImportPayload struct {
+	VaultID    int64  `json:"vault_id"`
+	OnConflict string `json:"on_conflict"`
+	Data       []byte `json:"data"`
+}
+
+// NoteImportEnqueue queues an import of JSON lines in the NoteExportRecord format into a vault,
+// see NoteImport for onConflict. Needs the write permission on the vault, which is checked again
+// when the job runs. The payload holds plaintext values, so it is stored encrypted.
+func (m *Models) NoteImportEnqueue(ctx context.Context, data []byte, vaultID int64, onConflict string) (JobStatusResponse, error) {
+	if err := requireVault(vaultID); err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	if err := m.authorize(ctx, PERMISSION_WRITE, Resource{VaultID: vaultID}); err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	if err := checkImportConflict(onConflict); err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	payload, err := json.Marshal(noteImportPayload{VaultID: vaultID, OnConflict: onConflict, Data: data})
+	if err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	encrypted, err := m.Encrypt(payload)
+	if err != nil {
+		return JobStatusResponse{}, ErrEncryptFailed
+	}
+
+	job, err := m.enqueue(ctx, JobEnqueueParams{
+		Kind:      JOB_KIND_NOTE_IMPORT,
+		Payload:   encrypted,
+		CreatedBy: ActorFromContext(ctx).UserID,
+		// a partially applied import would create duplicates when retried
+		MaxAttempts: 1,
+	})
+	if err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	return JobToStatus(job), nil
+}
+
+// NoteImportRun runs a queued note import, reporting progress as the share of the payload
+// read. Returns the NoteImportResult as JSON, or an ErrInvalidInput error for a payload that
+// can't be read.
+func (m *Models) NoteImportRun(ctx context.Context, job Job, progress func(percent int, message string)) (string, error) {
+	decrypted, err := m.Decyrpt([]byte(job.Payload))
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+
+	var payload noteImportPayload
+	if err := json.Unmarshal([]byte(decrypted), &payload); err != nil || payload.Data == nil {
+		return "", fmt.Errorf("%w: invalid import payload", ErrInvalidInput)
+	}
+
+	r := &progressReader{r: bytes.NewReader(payload.Data), total: len(payload.Data), report: func(read, total int) {
+		progress(read*100/max(total, 1), "importing")
+	}}
+
+	imported, err := m.NoteImport(ctx, r, payload.VaultID, payload.OnConflict)
+	if err != nil {
+		return "", fmt.Errorf("imported %d notes before failing: %w", imported.Imported, err)
+	}
+
+	result, _ := json.Marshal(imported)
+
+	return string(result), nil
+}
+
+// AuditVerifyEnqueue queues a verification of the audit log hash chain, optionally against an
+// expected head. Only system actors may queue it.
+func (m *Models) AuditVerifyEnqueue(ctx context.Context, expected AuditHead) (JobStatusResponse, error) {
+	payload, _ := json.Marshal(expected)
+
+	job, err := m.JobEnqueue(ctx, JobEnqueueParams{Kind: JOB_KIND_AUDIT_VERIFY, Payload: string(payload), CreatedBy: ActorFromContext(ctx).UserID})
+	if err != nil {
+		return JobStatusResponse{}, err
+	}
+
+	return JobToStatus(job), nil
+}
+
+// AuditVerifyRun runs a queued audit verification. The result is the AuditVerifyReport as JSON.
+func (m *Models) AuditVerifyRun(ctx context.Context, job Job, progress func(percent int, message string)) (string, error) {
+	var expected AuditHead
+	if job.Payload != "" {
+		if err := json.Unmarshal([]byte(job.Payload), &expected); err != nil {
+			return "", fmt.Errorf("%w: invalid audit verify payload: %s", ErrInvalidInput, err)
+		}
+	}
+
+	report, err := m.AuditVerify(ctx, expected)
+	if err != nil {
+		return "", err
+	}
+
+	result, _ := json.Marshal(report)
+
+	return string(result), nil
+}
+
+// JobToStatus converts a Job to its status response, dropping the payload.
+func JobToStatus(job Job) JobStatusResponse {
+	return JobStatusResponse{
+		ID:              job.ID,
+		Kind:            job.Kind,
+		Status:          job.Status,
+		Attempts:        job.Attempts,
+		MaxAttempts:     job.MaxAttempts,
+		Progress:        job.Progress,
+		ProgressMessage: job.ProgressMessage,
+		Result:          job.Result,
+		LastError:       job.LastError,
+		RunAt:           job.RunAt,
+		CreatedAt:       job.CreatedAt,
+		UpdatedAt:       job.UpdatedAt,
+		FinishedAt:      job.FinishedAt,
+	}
+}
+
+// progressReader reports how much of the underlying reader has been consumed.
+type progressReader struct {
+	r      io.Reader
+	read   int
+	total  int
+	report func(read, total int)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += n
+	p.report(p.read, p.total)
+	return n, err
+}
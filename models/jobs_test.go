@@ -0,0 +1,25 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func TestNoteImportRunRejectsInvalidPayload(t *testing.T) {
+	m := New(newTestStore(), &config.Config{Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"}})
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	for _, payload := range []string{`{"name": "not a payload"}`, `{"vault_id": 1}`, "not json"} {
+		encrypted, err := m.Encrypt([]byte(payload))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if _, err := m.NoteImportRun(ctx, Job{Payload: encrypted}, func(int, string) {}); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for %q, got %v", payload, err)
+		}
+	}
+}
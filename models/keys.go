@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+// NoteRotateKeys re-encrypts every stored note value, including deleted notes, and the
+// encryption canary from the currently configured encryption key to newKeys. Values encrypted
+// with a note's data key are left as they are, the data keys are wrapped again. The store applies
+// the change atomically, so either everything is re-encrypted or nothing is. Returns the
+// number of notes re-encrypted. Only system actors may rotate keys.
+func (m *Models) NoteRotateKeys(ctx context.Context, newKeys config.EncryptionConfig) (int, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return 0, err
+	}
+
+	if len(newKeys.EncSecret) != 32 || len(newKeys.EncIV) != 16 {
+		return 0, fmt.Errorf("%w: new key must be 32 bytes and new IV 16 bytes", ErrInvalidInput)
+	}
+
+	count, err := m.store.NoteReencryptAll(ctx, func(value string) (string, error) {
+		if strings.HasPrefix(value, dataKeyPrefix) {
+			return value, nil
+		}
+
+		plaintext, err := m.Decyrpt([]byte(value))
+		if err != nil {
+			return "", ErrDecryptFailed
+		}
+
+		encrypted, err := encryptWith(newKeys, []byte(plaintext))
+		if err != nil {
+			return "", ErrEncryptFailed
+		}
+
+		return encrypted, nil
+	})
+
+	outcome := AUDIT_OUTCOME_SUCCESS
+	if err != nil {
+		outcome = AUDIT_OUTCOME_FAILURE
+	}
+	m.audit(ctx, AuditEvent{Action: AUDIT_ACTION_KEYS_ROTATE, Outcome: outcome, Details: auditDetails("notes", fmt.Sprint(count))})
+
+	return count, err
+}
@@ -0,0 +1,47 @@
+package models
+
+import "context"
+
+// MigrationStatus describes a schema migration and whether it has been applied.
+type MigrationStatus struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+// migrationStore defines the interface required to manage the store's schema.
+type migrationStore interface {
+	MigrateUp(ctx context.Context) ([]MigrationStatus, error)
+	MigrateDown(ctx context.Context, steps int) ([]MigrationStatus, error)
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+// MigrateUp applies all pending migrations and returns the migrations that were applied. Like
+// the other migration methods, only system actors may call it.
+func (m *Models) MigrateUp(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return []MigrationStatus{}, err
+	}
+
+	return m.store.MigrateUp(ctx)
+}
+
+// MigrateDown reverts the most recent steps migrations and returns the migrations that were
+// reverted.
+func (m *Models) MigrateDown(ctx context.Context, steps int) ([]MigrationStatus, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return []MigrationStatus{}, err
+	}
+
+	return m.store.MigrateDown(ctx, steps)
+}
+
+// MigrationStatus returns every known migration and whether it has been applied.
+func (m *Models) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return []MigrationStatus{}, err
+	}
+
+	return m.store.MigrationStatus(ctx)
+}
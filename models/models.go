@@ -1,20 +1,52 @@
 package models
 
-import "github.com/oalexander6/web-app-template/config"
+import (
+	"context"
+
+	"github.com/oalexander6/web-app-template/breach"
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/notify"
+	"github.com/oalexander6/web-app-template/policy"
+)
 
 type Store interface {
 	noteStore
+	noteKeyStore
+	noteVersionStore
+	noteExpiryStore
+	noteRotationStore
+	noteAnalysisStore
+	noteBreachStore
+	folderStore
+	organizationStore
+	passwordPolicyStore
+	noteSearchStore
+	trashStore
+	jobStore
+	userStore
+	migrationStore
+	canaryStore
+	auditStore
+	Ping(ctx context.Context) error
 	Close()
 }
 
 type Models struct {
-	config *config.Config
-	store  Store
+	config   *config.Config
+	store    Store
+	notifier notify.Notifier
+	breach   *breach.Checker
+	policy   policy.Policy
 }
 
 func New(store Store, config *config.Config) *Models {
-	return &Models{
-		config: config,
-		store:  store,
+	m := &Models{
+		config:   config,
+		notifier: notify.New(config.Notify),
+		breach:   breach.NewChecker(config.Breach.DatasetPath),
+		policy:   policy.New(config.PasswordPolicy),
 	}
+	m.store = nameStore{Store: store, m: m}
+
+	return m
 }
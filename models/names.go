@@ -0,0 +1,242 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oalexander6/web-app-template/logger"
+)
+
+const (
+	// shown in place of an encrypted name that no configured key can decrypt
+	NOTE_NAME_UNREADABLE = "(name could not be decrypted)"
+
+	// prefixes of this many to maxIndexedNamePrefix characters are indexed, shorter ones would
+	// split names into small guessable groups; longer lookups match exact names only
+	minIndexedNamePrefix = 3
+	maxIndexedNamePrefix = 32
+
+	nameTokenExact  = "exact"
+	nameTokenPrefix = "prefix"
+)
+
+// NameConflictError names the note that already has a name in a folder. Names are compared
+// case-insensitively among the notes outside the trash. It matches ErrAlreadyExists.
+type NameConflictError struct {
+	NoteID int64
+}
+
+func (e *NameConflictError) Error() string {
+	return fmt.Sprintf("%s: note %d has the same name", ErrAlreadyExists, e.NoteID)
+}
+
+func (e *NameConflictError) Unwrap() error {
+	return ErrAlreadyExists
+}
+
+// NoteNameIndex is the blind index of an encrypted note name: HMAC tokens of the lowercased name
+// that let the store find and compare names without seeing them. It is empty for plaintext names.
+type NoteNameIndex struct {
+	Encrypted bool
+	// token of the whole name, unique among the notes of a folder
+	Exact string
+	// tokens of every prefix of minIndexedNamePrefix to maxIndexedNamePrefix characters and of
+	// the whole name
+	Lookup []string
+}
+
+// sealName returns the name as it should be stored and its blind index. Names are only
+// encrypted when NOTE_ENCRYPT_NAMES is enabled. The ciphertext uses a random nonce like values,
+// so names are only found and compared through the index tokens.
+func (m *Models) sealName(name string) (string, NoteNameIndex, error) {
+	if !m.config.Notes.EncryptNames {
+		return name, NoteNameIndex{}, nil
+	}
+
+	encName, err := m.Encrypt([]byte(name))
+	if err != nil {
+		return "", NoteNameIndex{}, ErrEncryptFailed
+	}
+
+	normalized := normalizeName(name)
+	index := NoteNameIndex{
+		Encrypted: true,
+		Exact:     m.nameToken(nameTokenExact, normalized),
+	}
+
+	runes := []rune(normalized)
+	for i := minIndexedNamePrefix; i <= len(runes) && i <= maxIndexedNamePrefix; i++ {
+		index.Lookup = append(index.Lookup, m.nameToken(nameTokenPrefix, string(runes[:i])))
+	}
+	index.Lookup = append(index.Lookup, index.Exact)
+
+	return encName, index, nil
+}
+
+// nameLookupTokens returns the tokens matching encrypted names that equal or start with the
+// query, or nil without a name index key.
+func (m *Models) nameLookupTokens(query string) []string {
+	if m.config.Notes.NameIndexKey == "" {
+		return nil
+	}
+
+	normalized := normalizeName(query)
+	tokens := []string{m.nameToken(nameTokenExact, normalized)}
+
+	if length := utf8.RuneCountInString(normalized); length >= minIndexedNamePrefix && length <= maxIndexedNamePrefix {
+		tokens = append(tokens, m.nameToken(nameTokenPrefix, normalized))
+	}
+
+	return tokens
+}
+
+// nameToken returns the hex truncated HMAC-SHA256 of the kind and value keyed by the name
+// index key. The kind keeps exact and prefix tokens of the same text distinct.
+func (m *Models) nameToken(kind string, value string) string {
+	mac := hmac.New(sha256.New, []byte(m.config.Notes.NameIndexKey))
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// openName returns the plaintext of a stored name, trying the current key and then the previous
+// keys so names outlive a partial key rotation. Names that can't be decrypted are replaced by
+// NOTE_NAME_UNREADABLE rather than failing whole listings.
+func (m *Models) openName(noteID int64, name string, encrypted bool) string {
+	if !encrypted {
+		return name
+	}
+
+	if plaintext, err := m.Decyrpt([]byte(name)); err == nil {
+		return plaintext
+	}
+
+	for _, keys := range m.config.Encryption.Previous {
+		if plaintext, err := decryptWith(keys, []byte(name)); err == nil {
+			return plaintext
+		}
+	}
+
+	logger.Log.Error().Int64("note_id", noteID).Msg("Failed to decrypt note name")
+
+	return NOTE_NAME_UNREADABLE
+}
+
+// normalizeName is the form of a name that blind index tokens are computed from, so lookups
+// are case-insensitive like those of plaintext names.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NoteEncryptNames encrypts the names of notes and versions stored before NOTE_ENCRYPT_NAMES
+// was enabled, returning the number of notes changed. Returns ErrAlreadyExists if two notes in
+// the same folder share a name, which must be resolved first. Only system actors may encrypt
+// names.
+func (m *Models) NoteEncryptNames(ctx context.Context) (int, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return 0, err
+	}
+
+	if !m.config.Notes.EncryptNames {
+		return 0, ErrNameEncryptionDisabled
+	}
+
+	return m.store.NoteEncryptNames(ctx, m.sealName)
+}
+
+// nameStore decrypts the names of the notes and versions returned by the store, so the rest of
+// the models only ever handle plaintext names.
+type nameStore struct {
+	Store
+	m *Models
+}
+
+func (s nameStore) open(note Note) Note {
+	note.Name = s.m.openName(note.ID, note.Name, note.NameEncrypted)
+	return note
+}
+
+func (s nameStore) openAll(notes []Note, err error) ([]Note, error) {
+	for i := range notes {
+		notes[i] = s.open(notes[i])
+	}
+	return notes, err
+}
+
+func (s nameStore) openVersion(version NoteVersion) NoteVersion {
+	version.Name = s.m.openName(version.NoteID, version.Name, version.NameEncrypted)
+	return version
+}
+
+func (s nameStore) NoteGetByID(ctx context.Context, id int64) (Note, error) {
+	note, err := s.Store.NoteGetByID(ctx, id)
+	return s.open(note), err
+}
+
+func (s nameStore) NoteGetAll(ctx context.Context, vaultIDs []int64) ([]Note, error) {
+	return s.openAll(s.Store.NoteGetAll(ctx, vaultIDs))
+}
+
+func (s nameStore) NoteList(ctx context.Context, filter NoteFilter) ([]Note, error) {
+	return s.openAll(s.Store.NoteList(ctx, filter))
+}
+
+func (s nameStore) NoteSample(ctx context.Context, limit int) ([]Note, error) {
+	return s.openAll(s.Store.NoteSample(ctx, limit))
+}
+
+func (s nameStore) NoteGetQuarantined(ctx context.Context) ([]Note, error) {
+	return s.openAll(s.Store.NoteGetQuarantined(ctx))
+}
+
+func (s nameStore) NoteGetDeleted(ctx context.Context, vaultIDs []int64) ([]Note, error) {
+	return s.openAll(s.Store.NoteGetDeleted(ctx, vaultIDs))
+}
+
+func (s nameStore) NoteGetDueBefore(ctx context.Context, before time.Time, vaultIDs []int64) ([]Note, error) {
+	return s.openAll(s.Store.NoteGetDueBefore(ctx, before, vaultIDs))
+}
+
+func (s nameStore) NoteCreate(ctx context.Context, write NoteWrite) (Note, error) {
+	note, err := s.Store.NoteCreate(ctx, write)
+	return s.open(note), err
+}
+
+func (s nameStore) NoteUpdate(ctx context.Context, id int64, write NoteWrite) (Note, error) {
+	note, err := s.Store.NoteUpdate(ctx, id, write)
+	return s.open(note), err
+}
+
+func (s nameStore) NoteReview(ctx context.Context, id int64, expiry NoteExpiry) (Note, error) {
+	note, err := s.Store.NoteReview(ctx, id, expiry)
+	return s.open(note), err
+}
+
+func (s nameStore) NoteGetVersions(ctx context.Context, noteID int64) ([]NoteVersion, error) {
+	versions, err := s.Store.NoteGetVersions(ctx, noteID)
+	for i := range versions {
+		versions[i] = s.openVersion(versions[i])
+	}
+	return versions, err
+}
+
+func (s nameStore) NoteGetVersion(ctx context.Context, noteID int64, version int) (NoteVersion, error) {
+	noteVersion, err := s.Store.NoteGetVersion(ctx, noteID, version)
+	return s.openVersion(noteVersion), err
+}
+
+func (s nameStore) NoteSearch(ctx context.Context, query string, nameTokens []string, vaultIDs []int64, limit int) ([]NoteMatch, error) {
+	matches, err := s.Store.NoteSearch(ctx, query, nameTokens, vaultIDs, limit)
+	for i := range matches {
+		matches[i].Note = s.open(matches[i].Note)
+	}
+	return matches, err
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func newNameModels(encrypt bool) *Models {
+	return &Models{config: &config.Config{
+		Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"},
+		Notes:      config.NotesConfig{EncryptNames: encrypt, NameIndexKey: "fedcba9876543210fedcba9876543210"},
+	}}
+}
+
+func TestSealNameIndexesPrefixes(t *testing.T) {
+	m := newNameModels(true)
+
+	sealed, index, err := m.sealName("Prod AWS")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if sealed == "Prod AWS" || !index.Encrypted {
+		t.Fatalf("Expected the name to be encrypted, got %q", sealed)
+	}
+
+	if len(index.Lookup) != len("prod aws")-minIndexedNamePrefix+2 {
+		t.Fatalf("Expected a token per prefix of at least %d characters and the exact token, got %d", minIndexedNamePrefix, len(index.Lookup))
+	}
+
+	again, _, _ := m.sealName("Prod AWS")
+	if again == sealed {
+		t.Fatal("Expected equal names to encrypt to different ciphertexts")
+	}
+
+	_, other, err := m.sealName("  prod aws ")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if other.Exact != index.Exact {
+		t.Fatal("Expected names differing only by case and spaces to share an exact token")
+	}
+
+	for _, query := range []string{"prod", "PROD AWS"} {
+		tokens := m.nameLookupTokens(query)
+		if !slices.ContainsFunc(tokens, func(token string) bool { return slices.Contains(index.Lookup, token) }) {
+			t.Fatalf("Expected %q to match the index", query)
+		}
+	}
+
+	for _, query := range []string{"aws", "pr"} {
+		if tokens := m.nameLookupTokens(query); slices.ContainsFunc(tokens, func(token string) bool { return slices.Contains(index.Lookup, token) }) {
+			t.Fatalf("Expected %q to not match the index", query)
+		}
+	}
+}
+
+func TestSealNamePlaintextWhenDisabled(t *testing.T) {
+	m := newNameModels(false)
+
+	sealed, index, err := m.sealName("Prod AWS")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if sealed != "Prod AWS" || index.Encrypted || index.Exact != "" || len(index.Lookup) != 0 {
+		t.Fatalf("Expected the name to be stored as is, got %q %+v", sealed, index)
+	}
+}
+
+func TestOpenName(t *testing.T) {
+	m := newNameModels(true)
+
+	sealed, _, err := m.sealName("Prod AWS")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if name := m.openName(1, sealed, true); name != "Prod AWS" {
+		t.Fatalf("Expected the name to round trip, got %q", name)
+	}
+
+	if name := m.openName(1, "Prod AWS", false); name != "Prod AWS" {
+		t.Fatalf("Expected a plaintext name to be returned as is, got %q", name)
+	}
+
+	rotated := newNameModels(true)
+	rotated.config.Encryption = config.EncryptionConfig{
+		EncIV:     "abcdef0123456789",
+		EncSecret: "abcdef0123456789abcdef0123456789",
+		Previous:  []config.EncryptionConfig{m.config.Encryption},
+	}
+
+	if name := rotated.openName(1, sealed, true); name != "Prod AWS" {
+		t.Fatalf("Expected a previous key to decrypt the name, got %q", name)
+	}
+
+	if name := newNameModels(true).openName(1, "not ciphertext", true); name != NOTE_NAME_UNREADABLE {
+		t.Fatalf("Expected the unreadable placeholder, got %q", name)
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+// dataKeyPrefix marks values encrypted with the note's own data key rather than the master key.
+const dataKeyPrefix = "k1:"
+
+// noteDataKeySize is the size of a note data key, for AES-256-GCM.
+const noteDataKeySize = 32
+
+// noteKeyStore defines the interface required to persist note data keys. Keys are stored
+// wrapped with the master key, apart from the notes, so destroying a key makes every copy of the
+// note's values unreadable, including copies in backups of the notes.
+type noteKeyStore interface {
+	// NoteKeyGet returns the wrapped data key of a note. Returns ErrNotFound for notes saved
+	// before data keys were introduced.
+	NoteKeyGet(ctx context.Context, noteID int64) (string, error)
+	// NoteKeyCreate saves the wrapped data key of a note unless it already has one, and returns
+	// the key the note ends up with.
+	NoteKeyCreate(ctx context.Context, noteID int64, key string) (string, error)
+	// NoteKeySet replaces the wrapped data key of a note, used when the key is wrapped again.
+	NoteKeySet(ctx context.Context, noteID int64, key string) error
+}
+
+// noteCipher encrypts and decrypts the values, encrypted fields and hidden custom fields of one
+// note with its data key. Values of notes saved before data keys were introduced are still
+// read with the master key.
+type noteCipher struct {
+	m   *Models
+	key []byte
+}
+
+// newNoteKey generates a data key for a new note. Returns the cipher using it and the key
+// wrapped with the master key, for the store.
+func (m *Models) newNoteKey() (noteCipher, string, error) {
+	key := make([]byte, noteDataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return noteCipher{}, "", err
+	}
+
+	wrapped, err := m.Encrypt(key)
+	if err != nil {
+		return noteCipher{}, "", ErrEncryptFailed
+	}
+
+	return noteCipher{m: m, key: key}, wrapped, nil
+}
+
+// noteCipher returns the cipher of a note. Notes without a data key get the master key
+// fallback. Returns ErrDecryptFailed if the data key can't be unwrapped.
+func (m *Models) noteCipher(ctx context.Context, noteID int64) (noteCipher, error) {
+	wrapped, err := m.store.NoteKeyGet(ctx, noteID)
+	if errors.Is(err, ErrNotFound) {
+		return noteCipher{m: m}, nil
+	}
+	if err != nil {
+		return noteCipher{}, err
+	}
+
+	key, err := m.Decyrpt([]byte(wrapped))
+	if err != nil || len(key) != noteDataKeySize {
+		return noteCipher{}, ErrDecryptFailed
+	}
+
+	return noteCipher{m: m, key: []byte(key)}, nil
+}
+
+// noteCipherForWrite returns the cipher of a note that is about to be written, giving notes
+// saved before data keys were introduced a key of their own.
+func (m *Models) noteCipherForWrite(ctx context.Context, noteID int64) (noteCipher, error) {
+	c, err := m.noteCipher(ctx, noteID)
+	if err != nil || c.key != nil {
+		return c, err
+	}
+
+	_, wrapped, err := m.newNoteKey()
+	if err != nil {
+		return noteCipher{}, err
+	}
+
+	// a concurrent write may have created a key first, in which case that key is used
+	if _, err := m.store.NoteKeyCreate(ctx, noteID, wrapped); err != nil {
+		return noteCipher{}, err
+	}
+
+	return m.noteCipher(ctx, noteID)
+}
+
+// encrypt encrypts the plaintext with the data key, or with the master key for notes without
+// one.
+func (c noteCipher) encrypt(plaintext []byte) (string, error) {
+	if c.key == nil {
+		return c.m.Encrypt(plaintext)
+	}
+
+	encrypted, err := encryptWith(config.EncryptionConfig{EncSecret: string(c.key)}, plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return dataKeyPrefix + strings.TrimPrefix(encrypted, sealedPrefix), nil
+}
+
+// decrypt decrypts a value encrypted with the data key, or with the master key for values
+// written before the note had one.
+func (c noteCipher) decrypt(encrypted string) (string, error) {
+	sealed, ok := strings.CutPrefix(encrypted, dataKeyPrefix)
+	if !ok {
+		return c.m.Decyrpt([]byte(encrypted))
+	}
+
+	if c.key == nil {
+		return "", ErrDecryptFailed
+	}
+
+	return decryptWith(config.EncryptionConfig{EncSecret: string(c.key)}, []byte(sealedPrefix+sealed))
+}
+
+// noteDecrypts reports whether the note's value decrypts with the configured key. Unlike
+// decryptNote, notes that don't are left out of quarantine.
+func (m *Models) noteDecrypts(ctx context.Context, note Note) bool {
+	c, err := m.noteCipher(ctx, note.ID)
+	if err != nil {
+		return false
+	}
+
+	_, err = c.decrypt(note.Value)
+
+	return err == nil
+}
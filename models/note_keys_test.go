@@ -0,0 +1,88 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func TestNotePurgeDestroysDataKey(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"}})
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	c, wrapped, err := m.newNoteKey()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	value, err := c.encrypt([]byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Secret", Type: NOTE_TYPE_SECURE_NOTE, Value: value, DeletedAt: "2026-01-01T00:00:00Z"}
+	store.noteKeys[1] = wrapped
+
+	if plaintext, err := m.noteCipher(ctx, 1); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if decrypted, err := plaintext.decrypt(value); err != nil || decrypted != "hunter2" {
+		t.Fatalf("Expected the value to decrypt with the note's key, got %q, %v", decrypted, err)
+	}
+
+	if err := m.NotePurge(ctx, 1); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, ok := store.noteKeys[1]; ok {
+		t.Fatal("Expected the purge to destroy the data key")
+	}
+
+	// a copy of the value, as kept by a backup, can't be read without the key
+	purged, err := m.noteCipher(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := purged.decrypt(value); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("Expected the purged value to be unreadable, got %v", err)
+	}
+}
+
+func TestNoteUpdateGivesLegacyNoteDataKey(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"}})
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	legacy, err := m.Encrypt([]byte("old"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Secret", Type: NOTE_TYPE_SECURE_NOTE, Value: legacy}
+
+	if got, err := m.NoteGetByID(ctx, 1); err != nil || got.Value != "old" {
+		t.Fatalf("Expected a note without a data key to decrypt with the master key, got %+v, %v", got, err)
+	}
+
+	if _, err := m.NoteUpdate(ctx, 1, NoteUpdateParams{Name: "Secret", Value: "new"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, ok := store.noteKeys[1]; !ok {
+		t.Fatal("Expected the update to create a data key")
+	}
+
+	if !strings.HasPrefix(store.notes[1].Value, dataKeyPrefix) {
+		t.Fatalf("Expected the new value to be encrypted with the data key, got %q", store.notes[1].Value)
+	}
+
+	if got, err := m.NoteGetByID(ctx, 1); err != nil || got.Value != "new" {
+		t.Fatalf("Expected the updated value to decrypt, got %+v, %v", got, err)
+	}
+}
@@ -1,154 +1,599 @@
 package models
 
 import (
-	"bytes"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/generator"
 )
 
 // Note represents a note/password. The value field will always be stored encrypted.
 type Note struct {
-	ID        int64
-	Name      string
-	Value     string
-	CreatedAt string
-	UpdatedAt string
-	Deleted   bool
+	ID            int64
+	Name          string
+	Value         string
+	CreatedAt     string
+	UpdatedAt     string
+	QuarantinedAt string
+	// set when the note is in the trash
+	DeletedAt string
+	DeletedBy int64
+	// when the note expires and how often its value should be rotated, empty and 0 for never
+	ExpiresAt   string
+	RotateEvery time.Duration
+	// when someone last confirmed the note is still valid
+	ReviewedAt string
+	// when the value was found in the breach dataset and how often it appears there, empty
+	// and 0 if it wasn't
+	CompromisedAt string
+	BreachCount   int
+	// one of the NOTE_TYPE_ constants, see itemSchemas for the fields of each type
+	Type   string
+	Fields map[string]NoteField
+	// user defined fields, hidden values are encrypted
+	CustomFields []CustomField
+	// the vault the note belongs to, and 0 when the note isn't in a folder of it
+	VaultID  int64
+	FolderID int64
+	Favorite bool
+	Tags     []string
+	// Name is encrypted in the store, models only see it decrypted
+	NameEncrypted bool
 }
 
 // NoteCreateParams represents the data required to create a new note.
 type NoteCreateParams struct {
-	Name  string `json:"name" form:"name" binding:"required"`
-	Value string `json:"value" form:"value" binding:"required"`
+	Name string `json:"name" form:"name" binding:"required"`
+	// the type's main secret: the text of a secure note, the password of a password or login,
+	// the number of a card, the private key of an SSH key or the document number of an identity
+	Value string `json:"value" form:"value"`
+	// secure_note (default), password, login, card, ssh_key or identity, passwords and logins
+	// must satisfy the password policy
+	Type string `json:"type" form:"type" binding:"omitempty,oneof=secure_note password login card ssh_key identity"`
+	// the other fields of the type, e.g. username, urls and totp for a login
+	Fields map[string]string `json:"fields" form:"fields"`
+	// user defined fields, hidden ones are encrypted
+	CustomFields []CustomField `json:"custom_fields" form:"custom_fields"`
+	// the vault to create the note in, the actor needs the write permission on it
+	VaultID int64 `json:"vault_id" form:"vault_id" binding:"required"`
+	// optional folder of the vault to create the note in, tags and favorite mark
+	FolderID int64    `json:"folder_id" form:"folder_id"`
+	Tags     []string `json:"tags" form:"tags"`
+	Favorite bool     `json:"favorite" form:"favorite"`
+	// optional RFC 3339 time or date after which the note is expired
+	ExpiresAt string `json:"expires_at" form:"expires_at"`
+	// optional interval after which the value is due for rotation, e.g. 90d or 720h
+	RotateEvery string `json:"rotate_every" form:"rotate_every"`
 }
 
-// NoteCreateRandomParams represents the data required to create a new random note.
+// NoteCreateRandomParams represents the data required to create a new random note. The
+// generator options select a password of Length characters or a passphrase.
 type NoteCreateRandomParams struct {
-	Name   string `json:"name" form:"name" binding:"required"`
-	Length int    `json:"length" form:"length" binding:"required,lte=2048"`
+	Name    string `json:"name" form:"name" binding:"required"`
+	VaultID int64  `json:"vault_id" form:"vault_id" binding:"required"`
+	generator.Options
+	// see NoteCreateParams
+	ExpiresAt   string `json:"expires_at" form:"expires_at"`
+	RotateEvery string `json:"rotate_every" form:"rotate_every"`
+}
+
+const (
+	NOTE_TYPE_SECURE_NOTE = "secure_note"
+	NOTE_TYPE_PASSWORD    = "password"
+)
+
+// NoteMetadata represents the data returned when listing notes. It never includes the value.
+type NoteMetadata struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// listed fields, the others are only returned when revealed
+	Fields map[string]string `json:"fields,omitempty"`
+	// hidden values are masked
+	CustomFields []CustomField `json:"custom_fields,omitempty"`
+	VaultID      int64         `json:"vault_id"`
+	FolderID     int64         `json:"folder_id,omitempty"`
+	Tags         []string      `json:"tags"`
+	Favorite     bool          `json:"favorite"`
+	Quarantined  bool          `json:"quarantined"`
+	ExpiresAt    string        `json:"expires_at,omitempty"`
+	RotateEvery  string        `json:"rotate_every,omitempty"`
+	Expired      bool          `json:"expired"`
+	Compromised  bool          `json:"compromised"`
+	CreatedAt    string        `json:"created_at"`
+	UpdatedAt    string        `json:"updated_at"`
+}
+
+// NoteRevealResponse represents the data returned when a note's value is revealed.
+type NoteRevealResponse struct {
+	ID           int64             `json:"id"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Value        string            `json:"value"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	CustomFields []CustomField     `json:"custom_fields,omitempty"`
+	RevealedAt   string            `json:"revealed_at"`
+}
+
+// NoteCreateRandomResponse represents the data returned when a random note is created.
+type NoteCreateRandomResponse struct {
+	NoteGetResponse
+	// estimated entropy of the generated value
+	EntropyBits float64 `json:"entropy_bits"`
+}
+
+// GenerateResponse represents a generated value that was not saved.
+type GenerateResponse struct {
+	Value       string  `json:"value"`
+	EntropyBits float64 `json:"entropy_bits"`
 }
 
 // NoteGetResponse represents the data returned for note GET requests.
 type NoteGetResponse struct {
-	ID        int64  `json:"id"`
-	Name      string `json:"name"`
-	Value     string `json:"value"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID           int64             `json:"id"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Value        string            `json:"value"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	CustomFields []CustomField     `json:"custom_fields,omitempty"`
+	VaultID      int64             `json:"vault_id"`
+	FolderID     int64             `json:"folder_id,omitempty"`
+	Tags         []string          `json:"tags"`
+	Favorite     bool              `json:"favorite"`
+	CreatedAt    string            `json:"created_at"`
+	UpdatedAt    string            `json:"updated_at"`
+}
+
+// NoteWrite is a note as it is saved by the store. The value, fields and hidden custom fields
+// are already encrypted, and the name is sealed when NameIndex is encrypted.
+type NoteWrite struct {
+	Name      string
+	NameIndex NoteNameIndex
+	// leaves the stored name and its index as they are, used by NoteUpdate so unchanged names
+	// that can't be decrypted keep their ciphertext
+	KeepName     bool
+	Value        string
+	Type         string
+	Fields       map[string]NoteField
+	CustomFields []CustomField
+	// where and how a new note is created, ignored by NoteUpdate
+	VaultID  int64
+	FolderID int64
+	Tags     []string
+	// marks the new note as a favorite of CreatedBy, ignored without a user
+	Favorite  bool
+	CreatedBy int64
+	Expiry    NoteExpiry
+	// the new note's data key, wrapped with the master key
+	DataKey string
+	// the user replacing the current value and the number of versions to keep, used by
+	// NoteUpdate
+	ReplacedBy int64
+	Retain     int
+	// records the update as a rotation of the note's value, used by NoteUpdate
+	Rotated bool
 }
 
 // NoteStore defines the interface required to implement persistent storage functionality
 // for notes.
 type noteStore interface {
 	NoteGetByID(ctx context.Context, id int64) (Note, error)
-	NoteGetAll(ctx context.Context) ([]Note, error)
-	NoteCreate(ctx context.Context, noteInput NoteCreateParams) (Note, error)
-	NoteDeleteByID(ctx context.Context, id int64) error
+	// NoteGetAll returns the notes outside the trash in the vaults, every vault for nil vaultIDs.
+	NoteGetAll(ctx context.Context, vaultIDs []int64) ([]Note, error)
+	// NoteGetVaultID returns the vault of a note, including notes in the trash.
+	NoteGetVaultID(ctx context.Context, id int64) (int64, error)
+	// NoteCreate saves a new note with its tags. Returns a *NameConflictError if the folder
+	// already has a note with the same name.
+	NoteCreate(ctx context.Context, note NoteWrite) (Note, error)
+	NoteDeleteByID(ctx context.Context, id int64, deletedBy int64) error
+	NoteSample(ctx context.Context, limit int) ([]Note, error)
+	NoteUpdateValue(ctx context.Context, id int64, value string) error
+	NoteQuarantine(ctx context.Context, id int64) error
+	NoteGetQuarantined(ctx context.Context) ([]Note, error)
+	NoteReencryptAll(ctx context.Context, reencrypt func(value string) (string, error)) (int, error)
+	// NoteEncryptNames seals every plaintext note and version name, returning the number of
+	// notes changed.
+	NoteEncryptNames(ctx context.Context, seal func(name string) (string, NoteNameIndex, error)) (int, error)
+	// NoteEncryptFields lets seal encrypt the fields of every note and version in place,
+	// including notes in the trash, and returns the number of notes changed. seal reports
+	// whether it changed the fields.
+	NoteEncryptFields(ctx context.Context, seal func(noteID int64, noteType string, fields map[string]NoteField) (bool, error)) (int, error)
 }
 
-// NoteGetByID returns the note with the provided ID with the value decrypted.
-// Returns an error if the note is not found.
+// NoteGetByID returns the note with the provided ID with the value decrypted. Needs the read
+// permission. Returns an error if the note is not found or cannot be decrypted.
 func (m *Models) NoteGetByID(ctx context.Context, noteID int64) (NoteGetResponse, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_READ, noteID); err != nil {
+		return NoteGetResponse{}, err
+	}
+
 	note, err := m.store.NoteGetByID(ctx, noteID)
 	if err != nil {
 		return NoteGetResponse{}, err
 	}
 
-	decryptedVal, err := m.Decyrpt([]byte(note.Value))
+	decryptedVal, c, err := m.decryptNote(ctx, note)
 	if err != nil {
 		return NoteGetResponse{}, ErrDecryptFailed
 	}
 
-	note.Value = decryptedVal
+	fields, err := c.decryptFields(note.Fields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	customFields, err := c.decryptCustomFields(note.CustomFields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	favorites, err := m.actorFavoriteIDs(ctx)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
 
 	return NoteGetResponse{
-		Name:  note.Name,
-		Value: note.Value,
+		ID:           note.ID,
+		Name:         note.Name,
+		Type:         note.Type,
+		Value:        decryptedVal,
+		Fields:       fields,
+		CustomFields: customFields,
+		VaultID:      note.VaultID,
+		FolderID:     note.FolderID,
+		Tags:         noteTags(note.Tags),
+		Favorite:     slices.Contains(favorites, note.ID),
+		CreatedAt:    note.CreatedAt,
+		UpdatedAt:    note.UpdatedAt,
 	}, nil
 }
 
-// NoteGetAll returns all notes with their value's decrypted.
-// Returns an error if the note is not found.
-func (m *Models) NoteGetAll(ctx context.Context) ([]NoteGetResponse, error) {
-	notes, err := m.store.NoteGetAll(ctx)
+// NoteGetAll returns the metadata of the notes matching the filter in the vaults the actor can
+// read, the actor's favorites first. Values are not decrypted or returned, use NoteReveal to read a
+// value.
+func (m *Models) NoteGetAll(ctx context.Context, filter NoteFilter) ([]NoteMetadata, error) {
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
 	if err != nil {
-		return []NoteGetResponse{}, err
+		return []NoteMetadata{}, err
 	}
+	filter.VaultIDs = vaultIDs
+	filter.UserID = ActorFromContext(ctx).UserID
 
-	results := make([]NoteGetResponse, len(notes))
-	for i := range notes {
-		decryptedVal, err := m.Decyrpt([]byte(notes[i].Value))
-		if err != nil {
-			return []NoteGetResponse{}, ErrDecryptFailed
-		}
+	if filter.Name != "" && m.config.Notes.NameIndexKey != "" {
+		filter.NameToken = m.nameToken(nameTokenExact, normalizeName(filter.Name))
+	}
+
+	notes, err := m.store.NoteList(ctx, filter)
+	if err != nil {
+		return []NoteMetadata{}, err
+	}
+
+	now := time.Now()
+	results := make([]NoteMetadata, 0, len(notes))
 
-		results[i] = NoteGetResponse{
-			ID:        notes[i].ID,
-			Name:      notes[i].Name,
-			Value:     decryptedVal,
-			CreatedAt: notes[i].CreatedAt,
-			UpdatedAt: notes[i].UpdatedAt,
+	for _, note := range notes {
+		if m.config.Notes.HideExpired && noteAwaitingReview(note, now) {
+			continue
 		}
+		results = append(results, noteToMetadata(note))
 	}
 
 	return results, nil
 }
 
-// NoteCreate saves a new note. It will encrypt the value of the note if it is marked as secure.
-// Returns an error if the note fails to save.
+// NoteReveal returns the decrypted value of the note with the provided ID and records an audit
+// event. Needs the read permission. The value is withheld if the audit event can't be written. When
+// a reveal re-authentication window is configured, the actor must have signed in or
+// re-authenticated within it, otherwise ErrReauthRequired is returned.
+func (m *Models) NoteReveal(ctx context.Context, noteID int64) (NoteRevealResponse, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_READ, noteID); err != nil {
+		return NoteRevealResponse{}, err
+	}
+
+	if !m.recentlyAuthenticated(ctx) {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_REVEAL, noteID, AUDIT_OUTCOME_DENIED)
+		return NoteRevealResponse{}, ErrReauthRequired
+	}
+
+	note, err := m.store.NoteGetByID(ctx, noteID)
+	if err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_REVEAL, noteID, AUDIT_OUTCOME_FAILURE)
+		return NoteRevealResponse{}, err
+	}
+
+	decryptedVal, c, err := m.decryptNote(ctx, note)
+	if err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_REVEAL, noteID, AUDIT_OUTCOME_FAILURE)
+		return NoteRevealResponse{}, err
+	}
+
+	fields, err := c.decryptFields(note.Fields)
+	if err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_REVEAL, noteID, AUDIT_OUTCOME_FAILURE)
+		return NoteRevealResponse{}, err
+	}
+
+	customFields, err := c.decryptCustomFields(note.CustomFields)
+	if err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_REVEAL, noteID, AUDIT_OUTCOME_FAILURE)
+		return NoteRevealResponse{}, err
+	}
+
+	if err := m.auditNote(ctx, AUDIT_ACTION_NOTE_REVEAL, noteID, AUDIT_OUTCOME_SUCCESS); err != nil {
+		return NoteRevealResponse{}, err
+	}
+
+	return NoteRevealResponse{
+		ID:           note.ID,
+		Name:         note.Name,
+		Type:         note.Type,
+		Value:        decryptedVal,
+		Fields:       fields,
+		CustomFields: customFields,
+		RevealedAt:   time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// recentlyAuthenticated reports whether the actor signed in or re-authenticated within the
+// reveal re-authentication window. Always true when no window is configured.
+func (m *Models) recentlyAuthenticated(ctx context.Context) bool {
+	window := m.config.Auth.RevealReauthWindow
+	if window <= 0 {
+		return true
+	}
+
+	actor := ActorFromContext(ctx)
+
+	return actor.Authenticated() && time.Since(actor.AuthenticatedAt) <= window
+}
+
+// NoteCreate saves a new note in a vault, which needs the write permission. The value and fields
+// are validated against the note type and the value, unlisted fields and hidden custom fields are
+// encrypted individually. Password values are checked against the password policy first, a
+// *PolicyError lists the rules a value breaks.
+// Returns an ErrInvalidInput error for invalid fields or a folder outside the vault, a
+// *NameConflictError if the folder already has a note with the name or an error if the note fails
+// to save.
 func (m *Models) NoteCreate(ctx context.Context, noteInput NoteCreateParams) (NoteGetResponse, error) {
-	encVal, err := m.Encrypt([]byte(noteInput.Value))
+	if err := requireVault(noteInput.VaultID); err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	if err := m.authorize(ctx, PERMISSION_WRITE, Resource{VaultID: noteInput.VaultID}); err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	expiry, err := ParseNoteExpiry(noteInput.ExpiresAt, noteInput.RotateEvery)
 	if err != nil {
 		return NoteGetResponse{}, err
 	}
 
-	noteInput.Value = encVal
+	if noteInput.Type == "" {
+		noteInput.Type = NOTE_TYPE_SECURE_NOTE
+	}
+
+	schema, err := noteSchema(noteInput.Type)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	value, fields, err := schema.validate(noteInput.Value, noteInput.Fields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	customFields, err := validateCustomFields(noteInput.CustomFields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	if noteInput.Tags, err = normalizeTags(noteInput.Tags); err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	if err := m.checkFolderExists(ctx, noteInput.FolderID, noteInput.VaultID); err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	if schema.password {
+		if err := m.checkPasswordPolicy(ctx, noteInput.VaultID, value); err != nil {
+			return NoteGetResponse{}, err
+		}
+	}
+
+	c, dataKey, err := m.newNoteKey()
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
 
-	savedNote, err := m.store.NoteCreate(ctx, noteInput)
+	encVal, err := c.encrypt([]byte(value))
 	if err != nil {
 		return NoteGetResponse{}, err
 	}
 
-	decryptedVal, err := m.Decyrpt([]byte(savedNote.Value))
+	encFields, err := c.encryptFields(schema, fields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	encCustomFields, err := c.encryptCustomFields(customFields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	name, nameIndex, err := m.sealName(noteInput.Name)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	savedNote, err := m.store.NoteCreate(ctx, NoteWrite{
+		Name:         name,
+		NameIndex:    nameIndex,
+		Value:        encVal,
+		Type:         noteInput.Type,
+		Fields:       encFields,
+		CustomFields: encCustomFields,
+		VaultID:      noteInput.VaultID,
+		FolderID:     noteInput.FolderID,
+		Tags:         noteInput.Tags,
+		Favorite:     noteInput.Favorite,
+		CreatedBy:    ActorFromContext(ctx).UserID,
+		Expiry:       expiry,
+		DataKey:      dataKey,
+	})
+	if err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_CREATE, 0, AUDIT_OUTCOME_FAILURE)
+		return NoteGetResponse{}, err
+	}
+
+	m.auditNote(ctx, AUDIT_ACTION_NOTE_CREATE, savedNote.ID, AUDIT_OUTCOME_SUCCESS)
+	if schema.freeform {
+		m.noteAnalyze(ctx, savedNote, value)
+		m.noteCheckBreachOnWrite(ctx, savedNote, value)
+	}
+
+	decryptedVal, err := c.decrypt(savedNote.Value)
 	if err != nil {
 		return NoteGetResponse{}, ErrDecryptFailed
 	}
 
+	decryptedFields, err := c.decryptFields(savedNote.Fields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	decryptedCustomFields, err := c.decryptCustomFields(savedNote.CustomFields)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
 	return NoteGetResponse{
-		ID:        savedNote.ID,
-		Name:      savedNote.Name,
-		Value:     decryptedVal,
-		CreatedAt: savedNote.CreatedAt,
-		UpdatedAt: savedNote.UpdatedAt,
+		ID:           savedNote.ID,
+		Name:         savedNote.Name,
+		Type:         savedNote.Type,
+		Value:        decryptedVal,
+		Fields:       decryptedFields,
+		CustomFields: decryptedCustomFields,
+		VaultID:      savedNote.VaultID,
+		FolderID:     savedNote.FolderID,
+		Tags:         noteTags(savedNote.Tags),
+		Favorite:     savedNote.Favorite,
+		CreatedAt:    savedNote.CreatedAt,
+		UpdatedAt:    savedNote.UpdatedAt,
 	}, nil
 }
 
-// NoteCreateRandom saves a new note with a randomly generated value.
-// Returns an error if the note fails to save or the random value generation fails.
-func (m *Models) NoteCreateRandom(ctx context.Context, noteInput NoteCreateRandomParams) (NoteGetResponse, error) {
-	validCharacters := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!?.#$"
-	randomVal, err := generateRandomString(noteInput.Length, validCharacters)
+// NoteCreateRandom saves a new password note with a generated password or passphrase. The
+// options are adjusted so values meet the length and character class rules of the vault's
+// password policy, and values are regenerated only if they contain a banned substring or a
+// breached value. Needs the write permission on the vault.
+// Returns an ErrInvalidInput error if the generator options can't produce a value, or a
+// *PolicyError if they can't produce one the policy allows.
+func (m *Models) NoteCreateRandom(ctx context.Context, noteInput NoteCreateRandomParams) (NoteCreateRandomResponse, error) {
+	if err := requireVault(noteInput.VaultID); err != nil {
+		return NoteCreateRandomResponse{}, err
+	}
+
+	if err := m.authorize(ctx, PERMISSION_WRITE, Resource{VaultID: noteInput.VaultID}); err != nil {
+		return NoteCreateRandomResponse{}, err
+	}
+
+	p, err := m.passwordPolicy(ctx, noteInput.VaultID)
 	if err != nil {
-		return NoteGetResponse{}, err
+		return NoteCreateRandomResponse{}, err
+	}
+
+	opts := p.Options(noteInput.Options)
+
+	var generated GenerateResponse
+	_, err = m.generateCompliant(p, func() (string, error) {
+		var err error
+		generated, err = Generate(opts)
+		return generated.Value, err
+	})
+	if err != nil {
+		return NoteCreateRandomResponse{}, err
 	}
 
 	noteCreateParams := NoteCreateParams{
-		Name:  noteInput.Name,
-		Value: randomVal,
+		Name:        noteInput.Name,
+		VaultID:     noteInput.VaultID,
+		Value:       generated.Value,
+		Type:        NOTE_TYPE_PASSWORD,
+		ExpiresAt:   noteInput.ExpiresAt,
+		RotateEvery: noteInput.RotateEvery,
 	}
 
-	return m.NoteCreate(ctx, noteCreateParams)
+	note, err := m.NoteCreate(ctx, noteCreateParams)
+	if err != nil {
+		return NoteCreateRandomResponse{}, err
+	}
+
+	return NoteCreateRandomResponse{NoteGetResponse: note, EntropyBits: generated.EntropyBits}, nil
 }
 
-// DeleteNoteByID will remove the note with the provided ID.
-// Returns an error if a note with that ID is not found.
+// Generate creates a password or passphrase without saving it.
+// Returns an ErrInvalidInput error if the options can't produce a value.
+func Generate(opts generator.Options) (GenerateResponse, error) {
+	result, err := generator.Generate(opts)
+	if err != nil {
+		if errors.Is(err, generator.ErrInvalidOptions) {
+			return GenerateResponse{}, fmt.Errorf("%w: %s", ErrInvalidInput, strings.TrimPrefix(err.Error(), generator.ErrInvalidOptions.Error()+": "))
+		}
+		return GenerateResponse{}, err
+	}
+
+	return GenerateResponse{Value: result.Value, EntropyBits: result.EntropyBits}, nil
+}
+
+// DeleteNoteByID moves the note with the provided ID to the trash, from where it can be restored
+// until it is purged. Needs the write permission. Returns an error if a note with that ID is not
+// found.
 func (m *Models) NoteDeleteByID(ctx context.Context, noteID int64) error {
-	return m.store.NoteDeleteByID(ctx, noteID)
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return err
+	}
+
+	if err := m.store.NoteDeleteByID(ctx, noteID, ActorFromContext(ctx).UserID); err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_DELETE, noteID, AUDIT_OUTCOME_FAILURE)
+		return err
+	}
+
+	m.auditNote(ctx, AUDIT_ACTION_NOTE_DELETE, noteID, AUDIT_OUTCOME_SUCCESS)
+
+	return nil
+}
+
+// noteToMetadata converts a Note to its listing representation, dropping the value.
+func noteToMetadata(note Note) NoteMetadata {
+	return NoteMetadata{
+		ID:           note.ID,
+		Name:         note.Name,
+		Type:         note.Type,
+		Fields:       visibleFields(note.Fields),
+		CustomFields: maskCustomFields(note.CustomFields),
+		VaultID:      note.VaultID,
+		FolderID:     note.FolderID,
+		Tags:         noteTags(note.Tags),
+		Favorite:     note.Favorite,
+		Quarantined:  note.QuarantinedAt != "",
+		ExpiresAt:    note.ExpiresAt,
+		RotateEvery:  formatInterval(note.RotateEvery),
+		Expired:      noteExpired(note, time.Now()),
+		Compromised:  note.CompromisedAt != "",
+		CreatedAt:    note.CreatedAt,
+		UpdatedAt:    note.UpdatedAt,
+	}
 }
 
 // generateRandomString returns a cryptographically secure random string of the provided length.
@@ -171,75 +616,119 @@ func generateRandomString(length int, validCharacters string) (string, error) {
 	return string(result), nil
 }
 
-// Encrypt implements AES-256 encryption using PKCS7 padding.
+// sealedPrefix marks values encrypted with AES-256-GCM under a random nonce. Values without it
+// were written with the earlier fixed IV CBC scheme and can still be decrypted.
+const sealedPrefix = "v2:"
+
+// Encrypt implements AES-256-GCM encryption with a random nonce, so equal plaintexts produce
+// different ciphertexts.
 func (m *Models) Encrypt(plaintext []byte) (string, error) {
-	block, err := aes.NewCipher([]byte(m.config.Encryption.EncSecret))
+	return encryptWith(m.config.Encryption, plaintext)
+}
+
+// Decrypt implements AES-256-GCM decryption, falling back to CBC with PKCS7 unpadding for
+// values written before GCM was used.
+func (m *Models) Decyrpt(encrypted []byte) (string, error) {
+	return decryptWith(m.config.Encryption, encrypted)
+}
+
+// encryptWith encrypts the plaintext with the provided key under a random nonce, which is
+// stored in front of the ciphertext.
+func encryptWith(keys config.EncryptionConfig, plaintext []byte) (string, error) {
+	block, err := aes.NewCipher([]byte(keys.EncSecret))
 	if err != nil {
 		return "", err
 	}
 
-	paddedPlaintext := pkcs7Pad(plaintext, block.BlockSize())
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
 
-	ciphertext := make([]byte, len(paddedPlaintext))
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
 
-	mode := cipher.NewCBCEncrypter(block, []byte(m.config.Encryption.EncIV))
-	mode.CryptBlocks(ciphertext, paddedPlaintext)
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
 
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
 }
 
-// Decrypt implements AES-256 decryption using PKCS7 unpadding.
-func (m *Models) Decyrpt(encrypted []byte) (string, error) {
-	ciphertext, err := base64.StdEncoding.DecodeString(string(encrypted))
+// decryptWith decrypts the base64 encoded ciphertext with the provided key, or with the key
+// and IV for values written with CBC.
+func decryptWith(keys config.EncryptionConfig, encrypted []byte) (string, error) {
+	encoded, sealed := strings.CutPrefix(string(encrypted), sealedPrefix)
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher([]byte(m.config.Encryption.EncSecret))
+	block, err := aes.NewCipher([]byte(keys.EncSecret))
 	if err != nil {
 		return "", err
 	}
 
-	mode := cipher.NewCBCDecrypter(block, []byte(m.config.Encryption.EncIV))
-	mode.CryptBlocks(ciphertext, ciphertext)
+	if sealed {
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return "", err
+		}
 
-	plaintext, err := pkcs7UnPad(ciphertext, block.BlockSize())
-	if err != nil {
-		return "", err
-	}
+		if len(ciphertext) < gcm.NonceSize() {
+			return "", ErrDecryptFailed
+		}
 
-	return string(plaintext), nil
-}
+		plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+		if err != nil {
+			return "", ErrDecryptFailed
+		}
 
-// pkcs7Pad implements PKCS7 padding by checking the length of the provided
-// buffer, and adding the number of bytes required to increase the length to
-// the next multiple of padToMultipleOf. It always pads with at least one byte.
-// The inserted bytes are all set to the number of bytes inserted.
-func pkcs7Pad(original []byte, padToMultipleOf int) []byte {
-	ogLength := len(original)
+		return string(plaintext), nil
+	}
 
-	bytesToAdd := padToMultipleOf - ogLength%padToMultipleOf
-	if bytesToAdd == 0 {
-		bytesToAdd = padToMultipleOf
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return "", ErrDecryptFailed
 	}
 
-	newBuf := make([]byte, ogLength+bytesToAdd)
+	mode := cipher.NewCBCDecrypter(block, []byte(keys.EncIV))
+	mode.CryptBlocks(ciphertext, ciphertext)
 
-	copy(newBuf, original)
-	copy(newBuf[ogLength:], bytes.Repeat([]byte{uint8(bytesToAdd)}, bytesToAdd))
+	plaintext, err := pkcs7UnPad(ciphertext, block.BlockSize())
+	if err != nil {
+		return "", err
+	}
 
-	return newBuf
+	return string(plaintext), nil
 }
 
 // pkcs7UnPad implements removal of PKCS7 padding by checking the value of the
 // last byte and removing that many bytes from the end of the original buffer.
 func pkcs7UnPad(original []byte, blockSize int) ([]byte, error) {
 	ogLength := len(original)
-	if ogLength%blockSize != 0 {
+	if ogLength == 0 || ogLength%blockSize != 0 {
 		return []byte{}, ErrDecryptFailed
 	}
 
 	bytesToRemove := int(original[ogLength-1])
+	if bytesToRemove == 0 || bytesToRemove > blockSize {
+		return []byte{}, ErrDecryptFailed
+	}
+
+	for _, b := range original[ogLength-bytesToRemove:] {
+		if int(b) != bytesToRemove {
+			return []byte{}, ErrDecryptFailed
+		}
+	}
 
 	return original[:(ogLength - bytesToRemove)], nil
 }
+
+// noteTags returns the tags for JSON, an empty list rather than null for untagged notes.
+func noteTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// NoteExportRecord is the JSON lines format used to export and import notes. Values are
+// plaintext, so exports must be handled as secrets.
+type NoteExportRecord struct {
+	Name         string            `json:"name"`
+	Value        string            `json:"value"`
+	Type         string            `json:"type,omitempty"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	CustomFields []CustomField     `json:"custom_fields,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	Favorite     bool              `json:"favorite,omitempty"`
+	CreatedAt    string            `json:"created_at,omitempty"`
+	UpdatedAt    string            `json:"updated_at,omitempty"`
+}
+
+const (
+	// fail the import on the first record whose name is taken
+	IMPORT_CONFLICT_FAIL = ""
+	// import the record as "<name> (2)", "<name> (3)", ...
+	IMPORT_CONFLICT_RENAME = "rename"
+	// update the note that has the name with the record, keeping its previous value as a version
+	IMPORT_CONFLICT_REPLACE = "replace"
+
+	// renamed records give up after "<name> (101)"
+	maxImportRenames = 100
+)
+
+// NoteImportResult counts the notes imported. Renamed and replaced records are included in
+// Imported.
+type NoteImportResult struct {
+	Imported int `json:"imported"`
+	Renamed  int `json:"renamed"`
+	Replaced int `json:"replaced"`
+}
+
+// NoteExport writes every note of the vaults the actor can read with its decrypted value to w as
+// JSON lines. Notes that fail to decrypt are skipped and reported in the returned error. Nothing is
+// written if the audit event can't be recorded. Returns the number of notes written.
+func (m *Models) NoteExport(ctx context.Context, w io.Writer) (int, error) {
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
+	if err != nil {
+		return 0, err
+	}
+
+	notes, err := m.store.NoteGetAll(ctx, vaultIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	favorites, err := m.actorFavoriteIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := m.audit(ctx, AuditEvent{Action: AUDIT_ACTION_NOTE_EXPORT, Outcome: AUDIT_OUTCOME_SUCCESS, Details: auditDetails("notes", fmt.Sprint(len(notes)))}); err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+
+	written := 0
+	var skipped []int64
+
+	for _, note := range notes {
+		decryptedVal, c, err := m.decryptNote(ctx, note)
+		if err != nil {
+			skipped = append(skipped, note.ID)
+			continue
+		}
+
+		fields, err := c.decryptFields(note.Fields)
+		if err != nil {
+			skipped = append(skipped, note.ID)
+			continue
+		}
+
+		customFields, err := c.decryptCustomFields(note.CustomFields)
+		if err != nil {
+			skipped = append(skipped, note.ID)
+			continue
+		}
+
+		record := NoteExportRecord{
+			Name:         note.Name,
+			Value:        decryptedVal,
+			Type:         note.Type,
+			Fields:       fields,
+			CustomFields: customFields,
+			Tags:         note.Tags,
+			Favorite:     slices.Contains(favorites, note.ID),
+			CreatedAt:    note.CreatedAt,
+			UpdatedAt:    note.UpdatedAt,
+		}
+
+		if err := enc.Encode(record); err != nil {
+			return written, err
+		}
+
+		written++
+	}
+
+	if len(skipped) > 0 {
+		return written, fmt.Errorf("%w: skipped notes %v", ErrDecryptFailed, skipped)
+	}
+
+	return written, nil
+}
+
+// NoteImport reads JSON lines in the NoteExportRecord format from r and creates a note in the vault
+// for each record, which needs the write permission. Records whose name is already taken fail the
+// import, or with onConflict are renamed or replace the existing note. Stops at the first invalid
+// or failing record.
+func (m *Models) NoteImport(ctx context.Context, r io.Reader, vaultID int64, onConflict string) (NoteImportResult, error) {
+	var result NoteImportResult
+
+	if err := requireVault(vaultID); err != nil {
+		return result, err
+	}
+
+	if err := m.authorize(ctx, PERMISSION_WRITE, Resource{VaultID: vaultID}); err != nil {
+		return result, err
+	}
+
+	if err := checkImportConflict(onConflict); err != nil {
+		return result, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record NoteExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return result, fmt.Errorf("%w: line %d: %s", ErrInvalidInput, line, err)
+		}
+
+		if record.Name == "" {
+			return result, fmt.Errorf("%w: line %d: name is required", ErrInvalidInput, line)
+		}
+
+		if err := m.importRecord(ctx, record, vaultID, onConflict, &result); err != nil {
+			return result, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		result.Imported++
+	}
+
+	return result, scanner.Err()
+}
+
+// importRecord creates the note for a record, renaming it or replacing the note that has its
+// name on conflict.
+func (m *Models) importRecord(ctx context.Context, record NoteExportRecord, vaultID int64, onConflict string, result *NoteImportResult) error {
+	params := NoteCreateParams{Name: record.Name, VaultID: vaultID, Value: record.Value, Type: record.Type, Fields: record.Fields, CustomFields: record.CustomFields, Tags: record.Tags, Favorite: record.Favorite}
+
+	// the value and fields are validated against the type by NoteCreate
+	_, err := m.NoteCreate(ctx, params)
+
+	var conflict *NameConflictError
+	if !errors.As(err, &conflict) || onConflict == IMPORT_CONFLICT_FAIL {
+		return err
+	}
+
+	if onConflict == IMPORT_CONFLICT_REPLACE {
+		if err := m.importReplace(ctx, conflict.NoteID, record); err != nil {
+			return err
+		}
+		result.Replaced++
+		return nil
+	}
+
+	for suffix := 2; suffix <= maxImportRenames+1; suffix++ {
+		params.Name = fmt.Sprintf("%s (%d)", record.Name, suffix)
+
+		if _, err = m.NoteCreate(ctx, params); !errors.As(err, &conflict) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	result.Renamed++
+
+	return nil
+}
+
+// importReplace overwrites the note with the record, keeping its previous value as a version.
+// The record must have the type of the note.
+func (m *Models) importReplace(ctx context.Context, noteID int64, record NoteExportRecord) error {
+	current, err := m.store.NoteGetByID(ctx, noteID)
+	if err != nil {
+		return err
+	}
+
+	recordType := record.Type
+	if recordType == "" {
+		recordType = NOTE_TYPE_SECURE_NOTE
+	}
+
+	if recordType != current.Type {
+		return fmt.Errorf("%w: can't replace %s note %d with a %s note", ErrInvalidInput, current.Type, noteID, recordType)
+	}
+
+	// fields and custom fields missing from the record are cleared rather than kept
+	fields := record.Fields
+	if fields == nil {
+		fields = map[string]string{}
+	}
+
+	customFields := record.CustomFields
+	if customFields == nil {
+		customFields = []CustomField{}
+	}
+
+	if _, err := m.NoteUpdate(ctx, noteID, NoteUpdateParams{Name: record.Name, Value: record.Value, Fields: fields, CustomFields: customFields}); err != nil {
+		return err
+	}
+
+	if _, err := m.NoteSetTags(ctx, noteID, NoteTagsParams{Tags: record.Tags}); err != nil {
+		return err
+	}
+
+	// favorites are the importing user's, imports run by the CLI have none to set
+	if !ActorFromContext(ctx).Authenticated() {
+		return nil
+	}
+
+	return m.NoteSetFavorite(ctx, noteID, record.Favorite)
+}
+
+// checkImportConflict returns an ErrInvalidInput error for unknown on_conflict modes.
+func checkImportConflict(onConflict string) error {
+	if onConflict != IMPORT_CONFLICT_FAIL && onConflict != IMPORT_CONFLICT_RENAME && onConflict != IMPORT_CONFLICT_REPLACE {
+		return fmt.Errorf("%w: on_conflict must be %s or %s", ErrInvalidInput, IMPORT_CONFLICT_RENAME, IMPORT_CONFLICT_REPLACE)
+	}
+
+	return nil
+}
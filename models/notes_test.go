@@ -0,0 +1,58 @@
+package models
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func TestEncryptUsesRandomNonce(t *testing.T) {
+	m := &Models{config: &config.Config{Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"}}}
+
+	first, err := m.Encrypt([]byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	second, _ := m.Encrypt([]byte("hunter2"))
+	if first == second || !strings.HasPrefix(first, sealedPrefix) {
+		t.Fatalf("Expected different sealed ciphertexts for equal plaintexts, got %q and %q", first, second)
+	}
+
+	if plaintext, err := m.Decyrpt([]byte(first)); err != nil || plaintext != "hunter2" {
+		t.Fatalf("Expected hunter2, got %q, %v", plaintext, err)
+	}
+
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(second, sealedPrefix))
+	sealed[len(sealed)-1] ^= 1
+	tampered := []byte(sealedPrefix + base64.StdEncoding.EncodeToString(sealed))
+	if _, err := m.Decyrpt(tampered); err == nil {
+		t.Fatal("Expected a modified ciphertext to fail to decrypt")
+	}
+
+	other := &Models{config: &config.Config{Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "fedcba9876543210fedcba9876543210"}}}
+	if _, err := other.Decyrpt([]byte(first)); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("Expected ErrDecryptFailed with another key, got %v", err)
+	}
+}
+
+func TestDecryptReadsLegacyCBC(t *testing.T) {
+	keys := config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"}
+	m := &Models{config: &config.Config{Encryption: keys}}
+
+	block, _ := aes.NewCipher([]byte(keys.EncSecret))
+	padded := append([]byte("hunter2"), bytes.Repeat([]byte{9}, 9)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, []byte(keys.EncIV)).CryptBlocks(ciphertext, padded)
+
+	plaintext, err := m.Decyrpt([]byte(base64.StdEncoding.EncodeToString(ciphertext)))
+	if err != nil || plaintext != "hunter2" {
+		t.Fatalf("Expected hunter2, got %q, %v", plaintext, err)
+	}
+}
@@ -0,0 +1,565 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxOrganizationNameLength = 100
+	maxVaultNameLength        = 100
+	// invitations not accepted within this time are ignored and can be sent again
+	invitationTTL = 7 * 24 * time.Hour
+)
+
+// Organization is a team sharing vaults of notes.
+type Organization struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// the actor's organization wide role, empty if they are only a member of some vaults
+	Role      string `json:"role,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Vault is a collection of notes in an organization. Every note and folder belongs to a vault.
+type Vault struct {
+	ID             int64  `json:"id"`
+	OrganizationID int64  `json:"organization_id"`
+	Name           string `json:"name"`
+	// number of previous versions kept per note, nil uses NOTE_VERSION_RETENTION
+	VersionRetention *int `json:"version_retention"`
+	// the actor's role in the vault, the higher of their organization wide and vault role
+	Role      string `json:"role,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Member is a user's role in an organization, or in a single vault of it.
+type Member struct {
+	ID             int64 `json:"id"`
+	OrganizationID int64 `json:"organization_id"`
+	// 0 for an organization wide role
+	VaultID   int64  `json:"vault_id,omitempty"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// Invitation offers a user a role in an organization, or in a single vault of it. The user
+// becomes a member when they accept it.
+type Invitation struct {
+	ID               int64  `json:"id"`
+	OrganizationID   int64  `json:"organization_id"`
+	OrganizationName string `json:"organization_name"`
+	// 0 for an organization wide role
+	VaultID   int64  `json:"vault_id,omitempty"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	InvitedBy int64  `json:"invited_by"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// OrganizationParams represents the data required to create an organization.
+type OrganizationParams struct {
+	Name string `json:"name" form:"name" binding:"required"`
+}
+
+// VaultParams represents the data required to create a vault.
+type VaultParams struct {
+	Name string `json:"name" form:"name" binding:"required"`
+}
+
+// VaultSettingsParams represents the settings of a vault that can be changed.
+type VaultSettingsParams struct {
+	// number of previous versions kept per note, null to use the instance default
+	VersionRetention *int `json:"version_retention" binding:"omitempty,gte=0"`
+}
+
+// InvitationParams represents the data required to invite a user.
+type InvitationParams struct {
+	Username string `json:"username" form:"username" binding:"required"`
+	Role     string `json:"role" form:"role" binding:"required,oneof=owner admin editor viewer"`
+	// optional vault to limit the role to, which must then be editor or viewer
+	VaultID int64 `json:"vault_id" form:"vault_id"`
+}
+
+// MemberRoleParams represents the data required to change a member's role.
+type MemberRoleParams struct {
+	Role string `json:"role" form:"role" binding:"required,oneof=owner admin editor viewer"`
+}
+
+// organizationStore defines the interface required to persist organizations, vaults and their
+// members.
+type organizationStore interface {
+	// OrganizationCreate saves the organization with the user as its owner.
+	OrganizationCreate(ctx context.Context, name string, ownerID int64) (Organization, error)
+	// OrganizationGetForUser returns the organizations where the user has any role.
+	OrganizationGetForUser(ctx context.Context, userID int64) ([]Organization, error)
+	// VaultCreate returns ErrAlreadyExists if the organization has a vault with the name.
+	VaultCreate(ctx context.Context, organizationID int64, name string) (Vault, error)
+	VaultGetByID(ctx context.Context, id int64) (Vault, error)
+	// VaultSetVersionRetention changes the number of versions kept per note, nil restores the
+	// instance default.
+	VaultSetVersionRetention(ctx context.Context, id int64, retention *int) (Vault, error)
+	VaultGetAll(ctx context.Context) ([]Vault, error)
+	// VaultGetForUser returns the vaults where the user has a role, with the highest.
+	VaultGetForUser(ctx context.Context, userID int64) ([]Vault, error)
+	// MembershipRoles returns the user's organization wide role and, when vaultID isn't 0,
+	// their role in the vault. organizationID is ignored for vaults.
+	MembershipRoles(ctx context.Context, userID int64, organizationID int64, vaultID int64) ([]string, error)
+	MemberGetAll(ctx context.Context, organizationID int64) ([]Member, error)
+	MemberGetByID(ctx context.Context, id int64) (Member, error)
+	// MemberSetRole and MemberDelete return an ErrInvalidInput error if the organization would
+	// be left without an owner.
+	MemberSetRole(ctx context.Context, id int64, role string) (Member, error)
+	MemberDelete(ctx context.Context, id int64) error
+	// InvitationCreate replaces any expired invitation of the user to the same organization and
+	// vault, and returns ErrAlreadyExists if one is pending.
+	InvitationCreate(ctx context.Context, invitation Invitation) (Invitation, error)
+	InvitationGetByID(ctx context.Context, id int64) (Invitation, error)
+	// InvitationGetForOrganization and InvitationGetForUser return pending invitations.
+	InvitationGetForOrganization(ctx context.Context, organizationID int64) ([]Invitation, error)
+	InvitationGetForUser(ctx context.Context, userID int64) ([]Invitation, error)
+	// InvitationAccept removes the invitation and gives the user its role, replacing any role
+	// they had in the same organization and vault.
+	InvitationAccept(ctx context.Context, id int64) (Member, error)
+	InvitationDelete(ctx context.Context, id int64) error
+}
+
+// OrganizationGetAll returns the organizations where the actor has a role.
+func (m *Models) OrganizationGetAll(ctx context.Context) ([]Organization, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return []Organization{}, err
+	}
+
+	return m.store.OrganizationGetForUser(ctx, ActorFromContext(ctx).UserID)
+}
+
+// OrganizationCreate creates an organization owned by the actor, who must be a signed in user.
+// Returns an ErrInvalidInput error for an invalid name.
+func (m *Models) OrganizationCreate(ctx context.Context, params OrganizationParams) (Organization, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return Organization{}, err
+	}
+
+	actor := ActorFromContext(ctx)
+	if !actor.Authenticated() {
+		return Organization{}, fmt.Errorf("%w: organizations are created by the user who will own them", ErrInvalidInput)
+	}
+
+	name, err := validName("organization", params.Name, maxOrganizationNameLength)
+	if err != nil {
+		return Organization{}, err
+	}
+
+	organization, err := m.store.OrganizationCreate(ctx, name, actor.UserID)
+	if err != nil {
+		m.auditOrganization(ctx, AUDIT_ACTION_ORGANIZATION_CREATE, AUDIT_TARGET_ORGANIZATION, 0, AUDIT_OUTCOME_FAILURE, "name", name)
+		return Organization{}, err
+	}
+
+	m.auditOrganization(ctx, AUDIT_ACTION_ORGANIZATION_CREATE, AUDIT_TARGET_ORGANIZATION, organization.ID, AUDIT_OUTCOME_SUCCESS, "name", name)
+
+	return organization, nil
+}
+
+// VaultGetAll returns the vaults where the actor can read notes, with their role in each.
+func (m *Models) VaultGetAll(ctx context.Context) ([]Vault, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return []Vault{}, err
+	}
+
+	actor := ActorFromContext(ctx)
+	if actor.System {
+		return m.store.VaultGetAll(ctx)
+	}
+
+	return m.store.VaultGetForUser(ctx, actor.UserID)
+}
+
+// VaultCreate creates a vault in an organization. Needs the manage permission.
+// Returns ErrAlreadyExists if the organization has a vault with the name or an ErrInvalidInput
+// error for an invalid name.
+func (m *Models) VaultCreate(ctx context.Context, organizationID int64, params VaultParams) (Vault, error) {
+	if err := m.authorize(ctx, PERMISSION_MANAGE, Resource{OrganizationID: organizationID}); err != nil {
+		return Vault{}, err
+	}
+
+	name, err := validName("vault", params.Name, maxVaultNameLength)
+	if err != nil {
+		return Vault{}, err
+	}
+
+	vault, err := m.store.VaultCreate(ctx, organizationID, name)
+	if err != nil {
+		m.auditOrganization(ctx, AUDIT_ACTION_VAULT_CREATE, AUDIT_TARGET_VAULT, 0, AUDIT_OUTCOME_FAILURE, "organization_id", strconv.FormatInt(organizationID, 10), "name", name)
+		return Vault{}, err
+	}
+
+	m.auditOrganization(ctx, AUDIT_ACTION_VAULT_CREATE, AUDIT_TARGET_VAULT, vault.ID, AUDIT_OUTCOME_SUCCESS, "organization_id", strconv.FormatInt(organizationID, 10), "name", name)
+
+	return vault, nil
+}
+
+// VaultUpdateSettings changes the settings of a vault of an organization. Needs the manage
+// permission on the vault. The new retention applies from the next update of each note.
+// Returns ErrNotFound if the vault isn't in the organization.
+func (m *Models) VaultUpdateSettings(ctx context.Context, organizationID int64, vaultID int64, params VaultSettingsParams) (Vault, error) {
+	if err := m.authorize(ctx, PERMISSION_MANAGE, Resource{OrganizationID: organizationID, VaultID: vaultID}); err != nil {
+		return Vault{}, err
+	}
+
+	if params.VersionRetention != nil && *params.VersionRetention < 0 {
+		return Vault{}, fmt.Errorf("%w: version_retention must not be negative", ErrInvalidInput)
+	}
+
+	current, err := m.store.VaultGetByID(ctx, vaultID)
+	if err != nil {
+		return Vault{}, err
+	}
+
+	if current.OrganizationID != organizationID {
+		return Vault{}, ErrNotFound
+	}
+
+	retention := "default"
+	if params.VersionRetention != nil {
+		retention = strconv.Itoa(*params.VersionRetention)
+	}
+
+	vault, err := m.store.VaultSetVersionRetention(ctx, vaultID, params.VersionRetention)
+	if err != nil {
+		m.auditOrganization(ctx, AUDIT_ACTION_VAULT_UPDATE, AUDIT_TARGET_VAULT, vaultID, AUDIT_OUTCOME_FAILURE, "version_retention", retention)
+		return Vault{}, err
+	}
+
+	m.auditOrganization(ctx, AUDIT_ACTION_VAULT_UPDATE, AUDIT_TARGET_VAULT, vaultID, AUDIT_OUTCOME_SUCCESS, "version_retention", retention)
+
+	return vault, nil
+}
+
+// versionRetention returns the number of versions kept per note in the vault.
+func (m *Models) versionRetention(ctx context.Context, vaultID int64) (int, error) {
+	vault, err := m.store.VaultGetByID(ctx, vaultID)
+	if err != nil {
+		return 0, err
+	}
+
+	if vault.VersionRetention != nil {
+		return *vault.VersionRetention, nil
+	}
+
+	return m.config.Notes.VersionRetention, nil
+}
+
+// MemberGetAll returns the members of an organization. Needs an organization wide role.
+func (m *Models) MemberGetAll(ctx context.Context, organizationID int64) ([]Member, error) {
+	if err := m.authorize(ctx, PERMISSION_READ, Resource{OrganizationID: organizationID}); err != nil {
+		return []Member{}, err
+	}
+
+	return m.store.MemberGetAll(ctx, organizationID)
+}
+
+// MemberInvite invites a user to an organization, or to a single vault of it. Needs the manage
+// permission, and the own permission to invite an owner.
+// Returns ErrAlreadyExists if the user already has a role there or a pending invitation, or an
+// ErrInvalidInput error for an unknown user, vault or role.
+func (m *Models) MemberInvite(ctx context.Context, organizationID int64, params InvitationParams) (Invitation, error) {
+	if err := m.authorizeRole(ctx, organizationID, params.Role); err != nil {
+		return Invitation{}, err
+	}
+
+	if err := validRole(params.Role, params.VaultID); err != nil {
+		return Invitation{}, err
+	}
+
+	if err := m.checkVaultInOrganization(ctx, organizationID, params.VaultID); err != nil {
+		return Invitation{}, err
+	}
+
+	user, err := m.store.UserGetByUsername(ctx, strings.ToLower(strings.TrimSpace(params.Username)))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Invitation{}, fmt.Errorf("%w: user %q doesn't exist", ErrInvalidInput, params.Username)
+		}
+		return Invitation{}, err
+	}
+
+	members, err := m.store.MemberGetAll(ctx, organizationID)
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	for _, member := range members {
+		if member.UserID == user.ID && member.VaultID == params.VaultID {
+			return Invitation{}, fmt.Errorf("%w: %s is already a member", ErrAlreadyExists, user.Username)
+		}
+	}
+
+	now := time.Now().UTC()
+	invitation, err := m.store.InvitationCreate(ctx, Invitation{
+		OrganizationID: organizationID,
+		VaultID:        params.VaultID,
+		UserID:         user.ID,
+		Role:           params.Role,
+		InvitedBy:      ActorFromContext(ctx).UserID,
+		CreatedAt:      now.Format(time.RFC3339),
+		ExpiresAt:      now.Add(invitationTTL).Format(time.RFC3339),
+	})
+
+	details := []string{"organization_id", strconv.FormatInt(organizationID, 10), "user_id", strconv.FormatInt(user.ID, 10), "role", params.Role}
+	if params.VaultID != 0 {
+		details = append(details, "vault_id", strconv.FormatInt(params.VaultID, 10))
+	}
+
+	if err != nil {
+		m.auditOrganization(ctx, AUDIT_ACTION_MEMBER_INVITE, AUDIT_TARGET_INVITATION, 0, AUDIT_OUTCOME_FAILURE, details...)
+		return Invitation{}, err
+	}
+
+	m.auditOrganization(ctx, AUDIT_ACTION_MEMBER_INVITE, AUDIT_TARGET_INVITATION, invitation.ID, AUDIT_OUTCOME_SUCCESS, details...)
+
+	return invitation, nil
+}
+
+// InvitationGetAll returns the pending invitations of an organization. Needs the manage
+// permission.
+func (m *Models) InvitationGetAll(ctx context.Context, organizationID int64) ([]Invitation, error) {
+	if err := m.authorize(ctx, PERMISSION_MANAGE, Resource{OrganizationID: organizationID}); err != nil {
+		return []Invitation{}, err
+	}
+
+	return m.store.InvitationGetForOrganization(ctx, organizationID)
+}
+
+// InvitationRevoke withdraws a pending invitation. Needs the manage permission.
+// Returns ErrNotFound if the organization has no such invitation.
+func (m *Models) InvitationRevoke(ctx context.Context, organizationID int64, invitationID int64) error {
+	if err := m.authorize(ctx, PERMISSION_MANAGE, Resource{OrganizationID: organizationID}); err != nil {
+		return err
+	}
+
+	invitation, err := m.store.InvitationGetByID(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+
+	if invitation.OrganizationID != organizationID {
+		return ErrNotFound
+	}
+
+	return m.respondToInvitation(ctx, AUDIT_ACTION_MEMBER_REVOKE, invitation, m.store.InvitationDelete)
+}
+
+// InvitationGetMine returns the pending invitations of the actor.
+func (m *Models) InvitationGetMine(ctx context.Context) ([]Invitation, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return []Invitation{}, err
+	}
+
+	return m.store.InvitationGetForUser(ctx, ActorFromContext(ctx).UserID)
+}
+
+// InvitationAccept makes the actor a member with the role of their invitation.
+// Returns ErrNotFound if the actor has no such pending invitation.
+func (m *Models) InvitationAccept(ctx context.Context, invitationID int64) (Member, error) {
+	invitation, err := m.actorInvitation(ctx, invitationID)
+	if err != nil {
+		return Member{}, err
+	}
+
+	var member Member
+	err = m.respondToInvitation(ctx, AUDIT_ACTION_MEMBER_ACCEPT, invitation, func(ctx context.Context, id int64) error {
+		member, err = m.store.InvitationAccept(ctx, id)
+		return err
+	})
+
+	return member, err
+}
+
+// InvitationDecline removes an invitation of the actor.
+// Returns ErrNotFound if the actor has no such pending invitation.
+func (m *Models) InvitationDecline(ctx context.Context, invitationID int64) error {
+	invitation, err := m.actorInvitation(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+
+	return m.respondToInvitation(ctx, AUDIT_ACTION_MEMBER_DECLINE, invitation, m.store.InvitationDelete)
+}
+
+// MemberSetRole changes the role of a member. Needs the manage permission, and the own
+// permission if the member is or would become an owner.
+// Returns ErrNotFound if the organization has no such member, or an ErrInvalidInput error for a
+// role a vault member can't have or if the organization would be left without an owner.
+func (m *Models) MemberSetRole(ctx context.Context, organizationID int64, memberID int64, params MemberRoleParams) (Member, error) {
+	member, err := m.organizationMember(ctx, organizationID, memberID)
+	if err != nil {
+		return Member{}, err
+	}
+
+	if err := m.authorizeRole(ctx, organizationID, highestRole([]string{member.Role, params.Role})); err != nil {
+		return Member{}, err
+	}
+
+	if err := validRole(params.Role, member.VaultID); err != nil {
+		return Member{}, err
+	}
+
+	updated, err := m.store.MemberSetRole(ctx, memberID, params.Role)
+	if err != nil {
+		m.auditMember(ctx, AUDIT_ACTION_MEMBER_ROLE, member, AUDIT_OUTCOME_FAILURE, "role", params.Role)
+		return Member{}, err
+	}
+
+	m.auditMember(ctx, AUDIT_ACTION_MEMBER_ROLE, member, AUDIT_OUTCOME_SUCCESS, "role", params.Role, "previous_role", member.Role)
+
+	return updated, nil
+}
+
+// MemberRemove removes a member's role. Members may remove themselves, otherwise this needs the
+// manage permission, and the own permission to remove an owner.
+// Returns ErrNotFound if the organization has no such member, or an ErrInvalidInput error if it
+// would be left without an owner.
+func (m *Models) MemberRemove(ctx context.Context, organizationID int64, memberID int64) error {
+	member, err := m.organizationMember(ctx, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if member.UserID != ActorFromContext(ctx).UserID {
+		if err := m.authorizeRole(ctx, organizationID, member.Role); err != nil {
+			return err
+		}
+	}
+
+	if err := m.store.MemberDelete(ctx, memberID); err != nil {
+		m.auditMember(ctx, AUDIT_ACTION_MEMBER_REMOVE, member, AUDIT_OUTCOME_FAILURE)
+		return err
+	}
+
+	m.auditMember(ctx, AUDIT_ACTION_MEMBER_REMOVE, member, AUDIT_OUTCOME_SUCCESS, "role", member.Role)
+
+	return nil
+}
+
+// authorizeRole authorizes granting, changing or removing the role in an organization: the
+// manage permission, and the own permission for the owner role.
+func (m *Models) authorizeRole(ctx context.Context, organizationID int64, role string) error {
+	permission := PERMISSION_MANAGE
+	if role == ROLE_OWNER {
+		permission = PERMISSION_OWN
+	}
+
+	return m.authorize(ctx, permission, Resource{OrganizationID: organizationID})
+}
+
+// organizationMember returns a member of the organization after checking the actor is signed in.
+// Returns ErrNotFound if the member is in another organization.
+func (m *Models) organizationMember(ctx context.Context, organizationID int64, memberID int64) (Member, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return Member{}, err
+	}
+
+	member, err := m.store.MemberGetByID(ctx, memberID)
+	if err != nil {
+		return Member{}, err
+	}
+
+	if member.OrganizationID != organizationID {
+		return Member{}, ErrNotFound
+	}
+
+	return member, nil
+}
+
+// actorInvitation returns a pending invitation of the actor, or ErrNotFound.
+func (m *Models) actorInvitation(ctx context.Context, invitationID int64) (Invitation, error) {
+	if err := m.authorize(ctx, PERMISSION_SIGNED_IN, Resource{}); err != nil {
+		return Invitation{}, err
+	}
+
+	invitation, err := m.store.InvitationGetByID(ctx, invitationID)
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	expiresAt, _ := time.Parse(time.RFC3339, invitation.ExpiresAt)
+	if invitation.UserID != ActorFromContext(ctx).UserID || time.Now().After(expiresAt) {
+		return Invitation{}, ErrNotFound
+	}
+
+	return invitation, nil
+}
+
+// respondToInvitation applies the response to an invitation and audits it.
+func (m *Models) respondToInvitation(ctx context.Context, action string, invitation Invitation, respond func(ctx context.Context, id int64) error) error {
+	details := []string{"organization_id", strconv.FormatInt(invitation.OrganizationID, 10), "user_id", strconv.FormatInt(invitation.UserID, 10), "role", invitation.Role}
+
+	if err := respond(ctx, invitation.ID); err != nil {
+		m.auditOrganization(ctx, action, AUDIT_TARGET_INVITATION, invitation.ID, AUDIT_OUTCOME_FAILURE, details...)
+		return err
+	}
+
+	m.auditOrganization(ctx, action, AUDIT_TARGET_INVITATION, invitation.ID, AUDIT_OUTCOME_SUCCESS, details...)
+
+	return nil
+}
+
+// checkVaultInOrganization returns an ErrInvalidInput error unless the vault is in the
+// organization. Vault 0 stands for the whole organization.
+func (m *Models) checkVaultInOrganization(ctx context.Context, organizationID int64, vaultID int64) error {
+	if vaultID == 0 {
+		return nil
+	}
+
+	vault, err := m.store.VaultGetByID(ctx, vaultID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err != nil || vault.OrganizationID != organizationID {
+		return fmt.Errorf("%w: vault %d isn't in the organization", ErrInvalidInput, vaultID)
+	}
+
+	return nil
+}
+
+// validName trims a name and returns an ErrInvalidInput error if it is empty or too long.
+func validName(kind string, name string, maxLength int) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" || utf8.RuneCountInString(name) > maxLength {
+		return "", fmt.Errorf("%w: %s name must be 1 to %d characters", ErrInvalidInput, kind, maxLength)
+	}
+
+	return name, nil
+}
+
+func (m *Models) auditOrganization(ctx context.Context, action string, targetType string, targetID int64, outcome string, details ...string) error {
+	return m.audit(ctx, AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    auditDetails(details...),
+		Outcome:    outcome,
+	})
+}
+
+func (m *Models) auditMember(ctx context.Context, action string, member Member, outcome string, details ...string) error {
+	details = append(details, "organization_id", strconv.FormatInt(member.OrganizationID, 10), "user_id", strconv.FormatInt(member.UserID, 10))
+	if member.VaultID != 0 {
+		details = append(details, "vault_id", strconv.FormatInt(member.VaultID, 10))
+	}
+
+	return m.auditOrganization(ctx, action, AUDIT_TARGET_MEMBER, member.ID, outcome, details...)
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/policy"
+)
+
+// attempts at generating a value free of banned substrings and breached values
+const maxPolicyAttempts = 100
+
+// PasswordPolicy is the password policy of an organization, which applies to the password notes
+// in its vaults in place of the instance default.
+type PasswordPolicy struct {
+	OrganizationID int64 `json:"organization_id"`
+	// minimum number of characters, 0 for no minimum
+	MinLength int `json:"min_length"`
+	// character classes every password must contain: lower, upper, digit and symbol
+	Require []string `json:"require"`
+	// substrings passwords must not contain, case-insensitive
+	BannedSubstrings []string `json:"banned_substrings"`
+	RejectBreached   bool     `json:"reject_breached"`
+	// true when the organization has no policy of its own and the instance default applies
+	Default   bool   `json:"default"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// PasswordPolicyParams represents the data required to set an organization's password policy.
+type PasswordPolicyParams struct {
+	MinLength        int      `json:"min_length" binding:"gte=0,lte=2048"`
+	Require          []string `json:"require" binding:"dive,oneof=lower upper digit symbol"`
+	BannedSubstrings []string `json:"banned_substrings"`
+	RejectBreached   bool     `json:"reject_breached"`
+}
+
+// passwordPolicyStore defines the interface required to persist organization password policies.
+type passwordPolicyStore interface {
+	// PasswordPolicyGet returns ErrNotFound if the organization uses the instance default.
+	PasswordPolicyGet(ctx context.Context, organizationID int64) (PasswordPolicy, error)
+	PasswordPolicySet(ctx context.Context, p PasswordPolicy) (PasswordPolicy, error)
+	// PasswordPolicyDelete returns ErrNotFound if the organization has no policy of its own.
+	PasswordPolicyDelete(ctx context.Context, organizationID int64) error
+}
+
+// PolicyError lists the password policy rules a value breaks. It matches ErrPolicyViolation.
+type PolicyError struct {
+	Violations []policy.Violation
+}
+
+func (e *PolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		rules[i] = violation.Rule
+	}
+
+	return ErrPolicyViolation.Error() + ": " + strings.Join(rules, ", ")
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// PasswordPolicyGet returns the password policy of an organization, or the instance default if
+// it has none. Needs the read permission on the organization.
+func (m *Models) PasswordPolicyGet(ctx context.Context, organizationID int64) (PasswordPolicy, error) {
+	if err := m.authorize(ctx, PERMISSION_READ, Resource{OrganizationID: organizationID}); err != nil {
+		return PasswordPolicy{}, err
+	}
+
+	return m.organizationPasswordPolicy(ctx, organizationID)
+}
+
+// PasswordPolicySet replaces the password policy of an organization. Needs the manage permission
+// on the organization. The policy applies to values written from now on, existing values are
+// not checked again.
+// Returns an ErrInvalidInput error for a negative length or an unknown character class.
+func (m *Models) PasswordPolicySet(ctx context.Context, organizationID int64, params PasswordPolicyParams) (PasswordPolicy, error) {
+	if err := m.authorize(ctx, PERMISSION_MANAGE, Resource{OrganizationID: organizationID}); err != nil {
+		return PasswordPolicy{}, err
+	}
+
+	if params.MinLength < 0 {
+		return PasswordPolicy{}, fmt.Errorf("%w: min_length must not be negative", ErrInvalidInput)
+	}
+
+	require := []string{}
+	for _, class := range params.Require {
+		switch class {
+		case config.PASSWORD_CLASS_LOWER, config.PASSWORD_CLASS_UPPER, config.PASSWORD_CLASS_DIGIT, config.PASSWORD_CLASS_SYMBOL:
+			require = append(require, class)
+		default:
+			return PasswordPolicy{}, fmt.Errorf("%w: unknown character class %q, must be lower, upper, digit or symbol", ErrInvalidInput, class)
+		}
+	}
+
+	banned := []string{}
+	for _, substring := range params.BannedSubstrings {
+		if substring = strings.ToLower(strings.TrimSpace(substring)); substring != "" {
+			banned = append(banned, substring)
+		}
+	}
+
+	saved, err := m.store.PasswordPolicySet(ctx, PasswordPolicy{
+		OrganizationID:   organizationID,
+		MinLength:        params.MinLength,
+		Require:          require,
+		BannedSubstrings: banned,
+		RejectBreached:   params.RejectBreached,
+		UpdatedAt:        time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		m.auditOrganization(ctx, AUDIT_ACTION_PASSWORD_POLICY_SET, AUDIT_TARGET_ORGANIZATION, organizationID, AUDIT_OUTCOME_FAILURE)
+		return PasswordPolicy{}, err
+	}
+
+	// banned substrings are left out, they often name the organization's own products
+	m.auditOrganization(ctx, AUDIT_ACTION_PASSWORD_POLICY_SET, AUDIT_TARGET_ORGANIZATION, organizationID, AUDIT_OUTCOME_SUCCESS,
+		"min_length", strconv.Itoa(saved.MinLength), "require", strings.Join(saved.Require, ","),
+		"banned_count", strconv.Itoa(len(saved.BannedSubstrings)), "reject_breached", strconv.FormatBool(saved.RejectBreached))
+
+	return saved, nil
+}
+
+// PasswordPolicyReset removes the password policy of an organization, so the instance default
+// applies again. Needs the manage permission on the organization. Returns ErrNotFound if the
+// organization has no policy of its own.
+func (m *Models) PasswordPolicyReset(ctx context.Context, organizationID int64) (PasswordPolicy, error) {
+	if err := m.authorize(ctx, PERMISSION_MANAGE, Resource{OrganizationID: organizationID}); err != nil {
+		return PasswordPolicy{}, err
+	}
+
+	if err := m.store.PasswordPolicyDelete(ctx, organizationID); err != nil {
+		m.auditOrganization(ctx, AUDIT_ACTION_PASSWORD_POLICY_RESET, AUDIT_TARGET_ORGANIZATION, organizationID, AUDIT_OUTCOME_FAILURE)
+		return PasswordPolicy{}, err
+	}
+
+	m.auditOrganization(ctx, AUDIT_ACTION_PASSWORD_POLICY_RESET, AUDIT_TARGET_ORGANIZATION, organizationID, AUDIT_OUTCOME_SUCCESS)
+
+	return m.defaultPasswordPolicy(organizationID), nil
+}
+
+// organizationPasswordPolicy returns the organization's own policy or the instance default.
+func (m *Models) organizationPasswordPolicy(ctx context.Context, organizationID int64) (PasswordPolicy, error) {
+	p, err := m.store.PasswordPolicyGet(ctx, organizationID)
+	if errors.Is(err, ErrNotFound) {
+		return m.defaultPasswordPolicy(organizationID), nil
+	}
+
+	return p, err
+}
+
+func (m *Models) defaultPasswordPolicy(organizationID int64) PasswordPolicy {
+	c := m.config.PasswordPolicy
+
+	return PasswordPolicy{
+		OrganizationID:   organizationID,
+		MinLength:        c.MinLength,
+		Require:          append([]string{}, c.Require...),
+		BannedSubstrings: append([]string{}, c.BannedSubstrings...),
+		RejectBreached:   c.RejectBreached,
+		Default:          true,
+	}
+}
+
+// passwordPolicy returns the password policy of the vault's organization, the instance default
+// if it has none.
+func (m *Models) passwordPolicy(ctx context.Context, vaultID int64) (policy.Policy, error) {
+	vault, err := m.store.VaultGetByID(ctx, vaultID)
+	if err != nil {
+		return policy.Policy{}, err
+	}
+
+	p, err := m.store.PasswordPolicyGet(ctx, vault.OrganizationID)
+	if errors.Is(err, ErrNotFound) {
+		return m.policy, nil
+	}
+	if err != nil {
+		return policy.Policy{}, err
+	}
+
+	return policy.New(config.PasswordPolicyConfig{
+		MinLength:        p.MinLength,
+		Require:          p.Require,
+		BannedSubstrings: p.BannedSubstrings,
+		RejectBreached:   p.RejectBreached,
+	}), nil
+}
+
+// checkPasswordPolicy evaluates the value against the password policy of the vault. Returns a
+// *PolicyError listing the broken rules, or ErrBreachCheckUnavailable if the policy rejects
+// breached values and the dataset can't be read.
+func (m *Models) checkPasswordPolicy(ctx context.Context, vaultID int64, value string) error {
+	p, err := m.passwordPolicy(ctx, vaultID)
+	if err != nil {
+		return err
+	}
+
+	return m.evaluatePasswordPolicy(p, value)
+}
+
+func (m *Models) evaluatePasswordPolicy(p policy.Policy, value string) error {
+	violations, err := p.Evaluate(value, func(password string) (bool, error) {
+		count, err := m.breachCount(password)
+		return count > 0, err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// generateCompliant calls generate, which must build values that meet the length and character
+// class rules, until a value also avoids banned substrings and breached values, which
+// construction can't rule out. Returns the last *PolicyError if no value does within a bounded
+// number of attempts.
+func (m *Models) generateCompliant(p policy.Policy, generate func() (string, error)) (string, error) {
+	var policyErr error
+
+	for attempt := 0; attempt < maxPolicyAttempts; attempt++ {
+		value, err := generate()
+		if err != nil {
+			return "", err
+		}
+
+		policyErr = m.evaluatePasswordPolicy(p, value)
+		if policyErr == nil {
+			return value, nil
+		}
+
+		if !errors.Is(policyErr, ErrPolicyViolation) {
+			return "", policyErr
+		}
+	}
+
+	return "", policyErr
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/policy"
+)
+
+func TestPasswordPolicyResolvesFromVaultOrganization(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{PasswordPolicy: config.PasswordPolicyConfig{MinLength: 8}})
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.vaults[2] = Vault{ID: 2, OrganizationID: 2}
+
+	if _, err := m.PasswordPolicySet(ctx, 2, PasswordPolicyParams{MinLength: 20, BannedSubstrings: []string{" Acme "}}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := m.checkPasswordPolicy(ctx, 1, "correct-horse"); err != nil {
+		t.Fatalf("Expected the instance default to apply to the first organization, got %v", err)
+	}
+
+	var policyErr *PolicyError
+	if err := m.checkPasswordPolicy(ctx, 2, "correct-horse-ACME"); !errors.As(err, &policyErr) || len(policyErr.Violations) != 2 {
+		t.Fatalf("Expected the second organization's length and banned substring rules, got %v", err)
+	}
+
+	reset, err := m.PasswordPolicyReset(ctx, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !reset.Default || reset.MinLength != 8 {
+		t.Fatalf("Expected the reset to return the instance default, got %+v", reset)
+	}
+
+	if err := m.checkPasswordPolicy(ctx, 2, "correct-horse-ACME"); err != nil {
+		t.Fatalf("Expected the instance default after the reset, got %v", err)
+	}
+}
+
+func TestPasswordPolicySetRejectsUnknownClass(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{})
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	if _, err := m.PasswordPolicySet(ctx, 1, PasswordPolicyParams{Require: []string{"emoji"}}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput, got %v", err)
+	}
+
+	if _, ok := store.policies[1]; ok {
+		t.Fatal("Expected the rejected policy not to be saved")
+	}
+}
+
+func TestGenerateRotationPasswordNeverGivesUp(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{})
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.policies[1] = PasswordPolicy{OrganizationID: 1, Require: []string{config.PASSWORD_CLASS_LOWER, config.PASSWORD_CLASS_UPPER, config.PASSWORD_CLASS_DIGIT, config.PASSWORD_CLASS_SYMBOL}}
+	p := policy.Policy{RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+
+	// a random 4 character value has all four classes less than 1% of the time
+	for i := 0; i < 200; i++ {
+		value, err := m.generateRotationPassword(ctx, 1, 4, noteCharsets[NOTE_CHARSET_DEFAULT])
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if violations, _ := p.Evaluate(value, nil); len(violations) != 0 {
+			t.Fatalf("Expected %q to satisfy the policy, got %v", value, violations)
+		}
+	}
+}
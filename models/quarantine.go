@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/metrics"
+)
+
+// NoteQuarantineResponse represents a note that failed to decrypt. Values are never included.
+type NoteQuarantineResponse struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+	QuarantinedAt string `json:"quarantined_at"`
+}
+
+// NoteRepairReport lists the outcome of retrying quarantined notes with the keyring.
+type NoteRepairReport struct {
+	Repaired []int64 `json:"repaired"`
+	Failed   []int64 `json:"failed"`
+}
+
+// decryptNote decrypts the note's value with its data key and returns the cipher for the
+// note's fields. On failure the note is counted, logged and quarantined so it shows up in the
+// quarantine view.
+func (m *Models) decryptNote(ctx context.Context, note Note) (string, noteCipher, error) {
+	c, err := m.noteCipher(ctx, note.ID)
+	if err != nil && !errors.Is(err, ErrDecryptFailed) {
+		return "", noteCipher{}, err
+	}
+
+	if err == nil {
+		if decryptedVal, err := c.decrypt(note.Value); err == nil {
+			return decryptedVal, c, nil
+		}
+	}
+
+	metrics.NoteDecryptFailures.Add(1)
+	logger.Log.Error().Int64("note_id", note.ID).Msg("Failed to decrypt note")
+
+	if note.QuarantinedAt == "" {
+		if err := m.store.NoteQuarantine(ctx, note.ID); err != nil {
+			logger.Log.Error().Int64("note_id", note.ID).Err(err).Msg("Failed to quarantine note")
+		}
+	}
+
+	return "", noteCipher{}, ErrDecryptFailed
+}
+
+// NoteGetQuarantined returns the notes that have failed to decrypt. Only system actors may list
+// them.
+func (m *Models) NoteGetQuarantined(ctx context.Context) ([]NoteQuarantineResponse, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return []NoteQuarantineResponse{}, err
+	}
+
+	notes, err := m.store.NoteGetQuarantined(ctx)
+	if err != nil {
+		return []NoteQuarantineResponse{}, err
+	}
+
+	results := make([]NoteQuarantineResponse, len(notes))
+	for i, note := range notes {
+		results[i] = NoteQuarantineResponse{
+			ID:            note.ID,
+			Name:          note.Name,
+			CreatedAt:     note.CreatedAt,
+			UpdatedAt:     note.UpdatedAt,
+			QuarantinedAt: note.QuarantinedAt,
+		}
+	}
+
+	return results, nil
+}
+
+// NoteRepairQuarantined retries every quarantined note with the current key and then each previous
+// key in the keyring. Notes that decrypt are re-encrypted, or have their data key wrapped again,
+// with the current key and released from quarantine. Only system actors may repair notes.
+func (m *Models) NoteRepairQuarantined(ctx context.Context) (NoteRepairReport, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return NoteRepairReport{}, err
+	}
+
+	notes, err := m.store.NoteGetQuarantined(ctx)
+	if err != nil {
+		return NoteRepairReport{}, err
+	}
+
+	report := NoteRepairReport{Repaired: []int64{}, Failed: []int64{}}
+
+	keyring := append([]config.EncryptionConfig{m.config.Encryption}, m.config.Encryption.Previous...)
+
+	for _, note := range notes {
+		value, ok, err := m.repairNoteValue(ctx, keyring, note)
+		if err != nil {
+			return report, err
+		}
+		if !ok {
+			report.Failed = append(report.Failed, note.ID)
+			continue
+		}
+
+		if err := m.store.NoteUpdateValue(ctx, note.ID, value); err != nil {
+			m.auditNote(ctx, AUDIT_ACTION_NOTE_UPDATE, note.ID, AUDIT_OUTCOME_FAILURE)
+			return report, err
+		}
+
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_UPDATE, note.ID, AUDIT_OUTCOME_SUCCESS)
+
+		metrics.NoteRepairs.Add(1)
+		logger.Log.Info().Int64("note_id", note.ID).Msg("Repaired quarantined note")
+
+		report.Repaired = append(report.Repaired, note.ID)
+	}
+
+	return report, nil
+}
+
+// repairNoteValue returns the note's value readable with the current key. A data key that only
+// a previous key can unwrap is wrapped again with the current key, values encrypted with the
+// master key are re-encrypted. Reports false if no key in the keyring can read the value.
+func (m *Models) repairNoteValue(ctx context.Context, keyring []config.EncryptionConfig, note Note) (string, bool, error) {
+	wrapped, err := m.store.NoteKeyGet(ctx, note.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", false, err
+	}
+
+	if err == nil {
+		key, ok := decryptWithKeyring(keyring, wrapped)
+		if !ok {
+			return "", false, nil
+		}
+
+		if _, err := (noteCipher{m: m, key: []byte(key)}).decrypt(note.Value); err != nil {
+			return "", false, nil
+		}
+
+		rewrapped, err := m.Encrypt([]byte(key))
+		if err != nil {
+			return "", false, ErrEncryptFailed
+		}
+
+		if err := m.store.NoteKeySet(ctx, note.ID, rewrapped); err != nil {
+			return "", false, err
+		}
+
+		return note.Value, true, nil
+	}
+
+	plaintext, ok := decryptWithKeyring(keyring, note.Value)
+	if !ok {
+		return "", false, nil
+	}
+
+	encrypted, err := m.Encrypt([]byte(plaintext))
+	if err != nil {
+		return "", false, ErrEncryptFailed
+	}
+
+	return encrypted, true, nil
+}
+
+// decryptWithKeyring tries each key in order and returns the first successful decryption.
+func decryptWithKeyring(keyring []config.EncryptionConfig, value string) (string, bool) {
+	for _, keys := range keyring {
+		if plaintext, err := decryptWith(keys, []byte(value)); err == nil {
+			return plaintext, true
+		}
+	}
+
+	return "", false
+}
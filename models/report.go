@@ -0,0 +1,287 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/strength"
+)
+
+const (
+	NOTE_FINDING_WEAK   = "weak"
+	NOTE_FINDING_REUSED = "reused"
+	NOTE_FINDING_OLD    = "old"
+	// the value appears in the breach dataset
+	NOTE_FINDING_BREACHED = "breached"
+	// the value couldn't be decrypted, so it wasn't analyzed
+	NOTE_FINDING_UNREADABLE = "unreadable"
+
+	// label mixed into the encryption key to derive the fingerprint key
+	fingerprintKeyLabel = "note-fingerprint-v1"
+
+	reportBreachedPenalty = 60
+	reportReusedPenalty   = 30
+	reportOldPenalty      = 10
+)
+
+// reportStrengthPenalty is taken off a note's report score for each strength score.
+var reportStrengthPenalty = [5]int{60, 50, 30, 0, 0}
+
+// NoteAnalysis is the strength and fingerprint of a note's value, computed and stored whenever
+// the value is written so the security report doesn't have to decrypt every note.
+type NoteAnalysis struct {
+	NoteID int64
+	// hex HMAC-SHA256 of the value, equal values have equal fingerprints
+	Fingerprint string
+	// identifies the key the fingerprint was computed with
+	KeyID        string
+	Score        int
+	GuessesLog10 float64
+	Warnings     []string
+	// hash of the stored ciphertext when it was analyzed, a different one means the value has
+	// been rewritten
+	ValueHash  string
+	AnalyzedAt string
+}
+
+// SecurityReport represents the strength, reuse and age findings for every note.
+type SecurityReport struct {
+	GeneratedAt string `json:"generated_at"`
+	// 0 to 100, the average of the note scores
+	Score           int                    `json:"score"`
+	NoteCount       int                    `json:"note_count"`
+	WeakCount       int                    `json:"weak_count"`
+	ReusedCount     int                    `json:"reused_count"`
+	OldCount        int                    `json:"old_count"`
+	BreachedCount   int                    `json:"breached_count"`
+	UnreadableCount int                    `json:"unreadable_count"`
+	Notes           []NoteSecurityFindings `json:"notes"`
+}
+
+// NoteSecurityFindings represents the report entry for one note. It never includes the value.
+type NoteSecurityFindings struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// 0 to 100, lower when the value is weak, breached, reused or old
+	Score int `json:"score"`
+	// estimated strength of the value from 0 (too guessable) to 4 (very unguessable)
+	Strength     int      `json:"strength"`
+	GuessesLog10 float64  `json:"guesses_log10"`
+	Findings     []string `json:"findings"`
+	Warnings     []string `json:"warnings"`
+	// IDs of the other notes with the same value
+	ReusedWith []int64 `json:"reused_with,omitempty"`
+	// how often the value appears in the breach dataset
+	BreachCount int    `json:"breach_count,omitempty"`
+	AgeDays     int    `json:"age_days"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// noteAnalysisStore defines the interface required to persist note analyses.
+type noteAnalysisStore interface {
+	// NoteAnalysisSave creates or replaces the note's analysis. Analyses of deleted notes are
+	// removed with the note.
+	NoteAnalysisSave(ctx context.Context, analysis NoteAnalysis) error
+	// NoteAnalysisGetAll returns the analyses of the notes outside the trash in the vaults, every
+	// vault for nil vaultIDs.
+	NoteAnalysisGetAll(ctx context.Context, vaultIDs []int64) ([]NoteAnalysis, error)
+}
+
+// SecurityReport scores the value of every note in the vaults the actor can read and flags weak,
+// breached, reused and old values. Stored analyses are used where they are current, other notes are
+// decrypted, analyzed and stored. Cards, SSH keys and identities are left out.
+func (m *Models) SecurityReport(ctx context.Context) (SecurityReport, error) {
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
+	if err != nil {
+		return SecurityReport{}, err
+	}
+
+	all, err := m.store.NoteGetAll(ctx, vaultIDs)
+	if err != nil {
+		return SecurityReport{}, err
+	}
+
+	notes := make([]Note, 0, len(all))
+	for _, note := range all {
+		if noteTypeFreeform(note.Type) {
+			notes = append(notes, note)
+		}
+	}
+
+	stored, err := m.store.NoteAnalysisGetAll(ctx, vaultIDs)
+	if err != nil {
+		return SecurityReport{}, err
+	}
+
+	analyses := make(map[int64]NoteAnalysis, len(stored))
+	for _, analysis := range stored {
+		analyses[analysis.NoteID] = analysis
+	}
+
+	keyID := m.fingerprintKeyID()
+	now := time.Now()
+	report := SecurityReport{
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+		NoteCount:   len(notes),
+		Notes:       make([]NoteSecurityFindings, 0, len(notes)),
+	}
+
+	byFingerprint := make(map[string][]int64)
+	current := make(map[int64]NoteAnalysis, len(notes))
+
+	for _, note := range notes {
+		analysis, ok := analyses[note.ID]
+		if !ok || analysis.KeyID != keyID || analysis.ValueHash != noteValueHash(note) {
+			value, _, err := m.decryptNote(ctx, note)
+			if err != nil {
+				continue
+			}
+
+			analysis = m.noteAnalyze(ctx, note, value)
+		}
+
+		current[note.ID] = analysis
+		byFingerprint[analysis.Fingerprint] = append(byFingerprint[analysis.Fingerprint], note.ID)
+	}
+
+	total := 0
+
+	for _, note := range notes {
+		findings := NoteSecurityFindings{
+			ID:        note.ID,
+			Name:      note.Name,
+			Findings:  []string{},
+			Warnings:  []string{},
+			AgeDays:   int(now.Sub(parseNoteTime(note.UpdatedAt)).Hours() / 24),
+			UpdatedAt: note.UpdatedAt,
+		}
+
+		analysis, ok := current[note.ID]
+		if !ok {
+			findings.Findings = append(findings.Findings, NOTE_FINDING_UNREADABLE)
+			report.UnreadableCount++
+			report.Notes = append(report.Notes, findings)
+			continue
+		}
+
+		findings.Score = 100
+		findings.Strength = analysis.Score
+		findings.GuessesLog10 = analysis.GuessesLog10
+		findings.Warnings = analysis.Warnings
+
+		if analysis.Score < strength.SCORE_STRONG {
+			findings.Findings = append(findings.Findings, NOTE_FINDING_WEAK)
+			findings.Score -= reportStrengthPenalty[analysis.Score]
+			report.WeakCount++
+		}
+
+		if note.CompromisedAt != "" {
+			findings.Findings = append(findings.Findings, NOTE_FINDING_BREACHED)
+			findings.BreachCount = note.BreachCount
+			findings.Score -= reportBreachedPenalty
+			report.BreachedCount++
+		}
+
+		for _, id := range byFingerprint[analysis.Fingerprint] {
+			if id != note.ID {
+				findings.ReusedWith = append(findings.ReusedWith, id)
+			}
+		}
+
+		if len(findings.ReusedWith) > 0 {
+			findings.Findings = append(findings.Findings, NOTE_FINDING_REUSED)
+			findings.Score -= reportReusedPenalty
+			report.ReusedCount++
+		}
+
+		if now.Sub(parseNoteTime(note.UpdatedAt)) > m.config.Notes.MaxAge {
+			findings.Findings = append(findings.Findings, NOTE_FINDING_OLD)
+			findings.Score -= reportOldPenalty
+			report.OldCount++
+		}
+
+		findings.Score = max(findings.Score, 0)
+		total += findings.Score
+		report.Notes = append(report.Notes, findings)
+	}
+
+	report.Score = 100
+	if analyzed := len(notes) - report.UnreadableCount; analyzed > 0 {
+		report.Score = int(math.Round(float64(total) / float64(analyzed)))
+	}
+
+	// worst first, unreadable notes last
+	sort.SliceStable(report.Notes, func(i, j int) bool {
+		a, b := report.Notes[i], report.Notes[j]
+		aUnreadable, bUnreadable := len(a.Findings) > 0 && a.Findings[0] == NOTE_FINDING_UNREADABLE, len(b.Findings) > 0 && b.Findings[0] == NOTE_FINDING_UNREADABLE
+		if aUnreadable != bUnreadable {
+			return bUnreadable
+		}
+		if a.Score != b.Score {
+			return a.Score < b.Score
+		}
+		return a.ID < b.ID
+	})
+
+	return report, nil
+}
+
+// noteValueHash returns the hex SHA-256 of the note's stored ciphertext. Every write encrypts
+// under a fresh nonce, so it changes whenever the value is rewritten, even within the same
+// second, and reveals nothing about the plaintext.
+func noteValueHash(note Note) string {
+	sum := sha256.Sum256([]byte(note.Value))
+	return hex.EncodeToString(sum[:])
+}
+
+// noteAnalyze scores the note's plaintext value and stores the analysis. A failure to store is
+// logged, the report recomputes missing analyses.
+func (m *Models) noteAnalyze(ctx context.Context, note Note, value string) NoteAnalysis {
+	result := strength.Estimate(value)
+
+	analysis := NoteAnalysis{
+		NoteID:       note.ID,
+		Fingerprint:  m.fingerprint(value),
+		KeyID:        m.fingerprintKeyID(),
+		Score:        result.Score,
+		GuessesLog10: result.GuessesLog10,
+		Warnings:     result.Warnings,
+		ValueHash:    noteValueHash(note),
+		AnalyzedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if err := m.store.NoteAnalysisSave(ctx, analysis); err != nil {
+		logger.Log.Error().Err(err).Int64("note_id", note.ID).Msg("Failed to save note analysis")
+	}
+
+	return analysis
+}
+
+// fingerprint returns the hex HMAC-SHA256 of the value. The key is derived from the encryption
+// key, so fingerprints can't be checked against guesses without it.
+func (m *Models) fingerprint(value string) string {
+	mac := hmac.New(sha256.New, m.fingerprintKey())
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fingerprintKeyID identifies the fingerprint key without revealing it, so fingerprints made
+// before a key rotation are recomputed.
+func (m *Models) fingerprintKeyID() string {
+	sum := sha256.Sum256(m.fingerprintKey())
+	return hex.EncodeToString(sum[:8])
+}
+
+func (m *Models) fingerprintKey() []byte {
+	mac := hmac.New(sha256.New, []byte(m.config.Encryption.EncSecret))
+	mac.Write([]byte(fingerprintKeyLabel))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/strength"
+)
+
+func TestFingerprintDependsOnValueAndKey(t *testing.T) {
+	m := &Models{config: &config.Config{Encryption: config.EncryptionConfig{EncSecret: "0123456789abcdef0123456789abcdef"}}}
+	other := &Models{config: &config.Config{Encryption: config.EncryptionConfig{EncSecret: "fedcba9876543210fedcba9876543210"}}}
+
+	if m.fingerprint("hunter2") != m.fingerprint("hunter2") {
+		t.Fatal("Expected equal values to have equal fingerprints")
+	}
+
+	if m.fingerprint("hunter2") == m.fingerprint("hunter3") {
+		t.Fatal("Expected different values to have different fingerprints")
+	}
+
+	if m.fingerprint("hunter2") == other.fingerprint("hunter2") {
+		t.Fatal("Expected the fingerprint to depend on the key")
+	}
+
+	if m.fingerprintKeyID() == other.fingerprintKeyID() {
+		t.Fatal("Expected the key ID to change with the key")
+	}
+}
+
+func TestSecurityReportReanalyzesRewrittenValue(t *testing.T) {
+	store := newTestStore()
+	m := New(store, &config.Config{Encryption: config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"}})
+	ctx := WithActor(context.Background(), SystemActor("test"))
+
+	strong, err := noteCipher{m: m}.encrypt([]byte("correct horse battery staple 1987!"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	weak, err := noteCipher{m: m}.encrypt([]byte("password"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Login", Type: NOTE_TYPE_SECURE_NOTE, Value: strong, UpdatedAt: "2026-01-01T00:00:00Z"}
+
+	if report, err := m.SecurityReport(ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if len(report.Notes) != 1 || report.Notes[0].Strength < strength.SCORE_STRONG {
+		t.Fatalf("Expected the first value to be strong, got %+v", report.Notes)
+	}
+
+	// a second write within the same second leaves the update time unchanged
+	note := store.notes[1]
+	note.Value = weak
+	store.notes[1] = note
+
+	report, err := m.SecurityReport(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(report.Notes) != 1 || report.Notes[0].Strength >= strength.SCORE_STRONG {
+		t.Fatalf("Expected the rewritten value to be analyzed again, got %+v", report.Notes)
+	}
+
+	if store.analyses[1].ValueHash != noteValueHash(store.notes[1]) {
+		t.Fatal("Expected the new analysis to be stored with the hash of the new value")
+	}
+}
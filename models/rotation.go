@@ -0,0 +1,365 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/oalexander6/web-app-template/generator"
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/notify"
+)
+
+const (
+	// letters, digits and !?.#$, used for random notes
+	NOTE_CHARSET_DEFAULT      = "default"
+	NOTE_CHARSET_ALPHANUMERIC = "alphanumeric"
+	NOTE_CHARSET_HEX          = "hex"
+	NOTE_CHARSET_NUMERIC      = "numeric"
+
+	NOTIFY_EVENT_NOTE_ROTATED          = "note.rotated"
+	NOTIFY_EVENT_NOTE_ROTATION_DRY_RUN = "note.rotation_dry_run"
+)
+
+var noteCharsets = map[string]string{
+	NOTE_CHARSET_DEFAULT:      "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!?.#$",
+	NOTE_CHARSET_ALPHANUMERIC: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	NOTE_CHARSET_HEX:          "0123456789abcdef",
+	NOTE_CHARSET_NUMERIC:      "0123456789",
+}
+
+// NoteRotationPolicy describes how and how often a note's value is regenerated.
+type NoteRotationPolicy struct {
+	NoteID  int64
+	Length  int
+	Charset string
+	// the note's RotateEvery, which also drives its rotation reminders
+	Interval time.Duration
+	// when the rotation job will next regenerate the value, Interval after the later of the
+	// last update, the last review and the last change to the policy
+	NextRotationAt string
+	LastRotatedAt  string
+	// when a dry run last announced the rotation
+	LastDryRunAt string
+	CreatedAt    string
+	UpdatedAt    string
+}
+
+// NoteRotationPolicyParams represents the data required to set a rotation policy.
+type NoteRotationPolicyParams struct {
+	Length int `json:"length" binding:"required,gte=8,lte=2048"`
+	// default, alphanumeric, hex or numeric, defaults to default
+	Charset string `json:"charset" binding:"omitempty,oneof=default alphanumeric hex numeric"`
+	// e.g. 30d or 720h, at least 1h
+	Interval string `json:"interval" binding:"required"`
+}
+
+// NoteRotationPolicyResponse represents the data returned for rotation policy requests.
+type NoteRotationPolicyResponse struct {
+	NoteID         int64  `json:"note_id"`
+	Length         int    `json:"length"`
+	Charset        string `json:"charset"`
+	Interval       string `json:"interval"`
+	NextRotationAt string `json:"next_rotation_at"`
+	LastRotatedAt  string `json:"last_rotated_at,omitempty"`
+	LastDryRunAt   string `json:"last_dry_run_at,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// NoteRotationResult represents the outcome of rotating a note. It never includes the value.
+type NoteRotationResult struct {
+	NoteID int64  `json:"note_id"`
+	Name   string `json:"name"`
+	// true when the value was left unchanged
+	DryRun         bool   `json:"dry_run"`
+	RotatedAt      string `json:"rotated_at"`
+	NextRotationAt string `json:"next_rotation_at"`
+}
+
+// noteRotationStore defines the interface required to persist note rotation policies.
+type noteRotationStore interface {
+	// NoteRotationPolicySet creates or replaces the note's policy and sets the note's
+	// RotateEvery to its interval, restarting the schedule. Returns ErrNotFound if the note
+	// doesn't exist or is in the trash.
+	NoteRotationPolicySet(ctx context.Context, policy NoteRotationPolicy) (NoteRotationPolicy, error)
+	NoteRotationPolicyGet(ctx context.Context, noteID int64) (NoteRotationPolicy, error)
+	NoteRotationPolicyDelete(ctx context.Context, noteID int64) error
+	// NoteRotationPoliciesDue returns the policies of notes outside the trash that are due
+	// for rotation before the cutoff, most overdue first.
+	NoteRotationPoliciesDue(ctx context.Context, before time.Time) ([]NoteRotationPolicy, error)
+	// NoteRotationRecordDryRun records that a dry run announced the rotation. Rotations are
+	// recorded by NoteUpdate.
+	NoteRotationRecordDryRun(ctx context.Context, noteID int64, at time.Time) error
+}
+
+// NoteRotationPolicySet sets how a note's value is regenerated. The interval replaces the
+// note's rotate_every and the first rotation is due one interval from now. For password notes the
+// length and charset must be able to satisfy the password policy, otherwise a *PolicyError lists
+// the rules generated values would break. Needs the write permission. Returns ErrNotFound if the
+// note doesn't exist.
+func (m *Models) NoteRotationPolicySet(ctx context.Context, noteID int64, params NoteRotationPolicyParams) (NoteRotationPolicyResponse, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return NoteRotationPolicyResponse{}, err
+	}
+
+	interval, err := parseRotateEvery(params.Interval)
+	if err != nil {
+		return NoteRotationPolicyResponse{}, err
+	}
+
+	if interval == 0 {
+		return NoteRotationPolicyResponse{}, fmt.Errorf("%w: interval is required", ErrInvalidInput)
+	}
+
+	if params.Charset == "" {
+		params.Charset = NOTE_CHARSET_DEFAULT
+	}
+
+	if _, ok := noteCharsets[params.Charset]; !ok {
+		return NoteRotationPolicyResponse{}, fmt.Errorf("%w: unknown charset %q", ErrInvalidInput, params.Charset)
+	}
+
+	note, err := m.store.NoteGetByID(ctx, noteID)
+	if err != nil {
+		return NoteRotationPolicyResponse{}, err
+	}
+
+	// cards, keys and identities hold structured values a random string would break
+	if !noteTypeFreeform(note.Type) {
+		return NoteRotationPolicyResponse{}, fmt.Errorf("%w: %s notes can't be rotated", ErrInvalidInput, note.Type)
+	}
+
+	if noteTypePassword(note.Type) {
+		p, err := m.passwordPolicy(ctx, note.VaultID)
+		if err != nil {
+			return NoteRotationPolicyResponse{}, err
+		}
+
+		if violations := p.Unsatisfiable(noteCharsets[params.Charset], params.Length); len(violations) > 0 {
+			return NoteRotationPolicyResponse{}, &PolicyError{Violations: violations}
+		}
+	}
+
+	event := AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_ROTATION_SET,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   noteID,
+		Details:    auditDetails("length", strconv.Itoa(params.Length), "charset", params.Charset, "interval", formatInterval(interval)),
+		Outcome:    AUDIT_OUTCOME_FAILURE,
+	}
+
+	policy, err := m.store.NoteRotationPolicySet(ctx, NoteRotationPolicy{
+		NoteID:   noteID,
+		Length:   params.Length,
+		Charset:  params.Charset,
+		Interval: interval,
+	})
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteRotationPolicyResponse{}, err
+	}
+
+	event.Outcome = AUDIT_OUTCOME_SUCCESS
+	m.audit(ctx, event)
+
+	return noteRotationPolicyToResponse(policy), nil
+}
+
+// NoteRotationPolicyGet returns the note's rotation policy. Needs the read permission. Returns
+// ErrNotFound if the note has none.
+func (m *Models) NoteRotationPolicyGet(ctx context.Context, noteID int64) (NoteRotationPolicyResponse, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_READ, noteID); err != nil {
+		return NoteRotationPolicyResponse{}, err
+	}
+
+	policy, err := m.store.NoteRotationPolicyGet(ctx, noteID)
+	if err != nil {
+		return NoteRotationPolicyResponse{}, err
+	}
+
+	return noteRotationPolicyToResponse(policy), nil
+}
+
+// NoteRotationPolicyDelete stops rotating the note. The note keeps its rotate_every, so it is
+// still reminded when rotation falls due. Needs the write permission. Returns ErrNotFound if the
+// note has no policy.
+func (m *Models) NoteRotationPolicyDelete(ctx context.Context, noteID int64) error {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return err
+	}
+
+	if err := m.store.NoteRotationPolicyDelete(ctx, noteID); err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_ROTATION_CLEAR, noteID, AUDIT_OUTCOME_FAILURE)
+		return err
+	}
+
+	m.auditNote(ctx, AUDIT_ACTION_NOTE_ROTATION_CLEAR, noteID, AUDIT_OUTCOME_SUCCESS)
+
+	return nil
+}
+
+// NoteRotate regenerates the note's value according to its policy, keeping the previous value
+// as a version, and sends a rotation event. In a dry run the value and schedule are left
+// unchanged but the rotation is still audited, recorded as a dry run and announced with a dry
+// run event. Needs the write permission.
+// Returns ErrNotFound if the note has no policy.
+func (m *Models) NoteRotate(ctx context.Context, noteID int64, dryRun bool) (NoteRotationResult, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return NoteRotationResult{}, err
+	}
+
+	event := AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_ROTATE,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   noteID,
+		Details:    auditDetails("dry_run", strconv.FormatBool(dryRun)),
+		Outcome:    AUDIT_OUTCOME_FAILURE,
+	}
+
+	policy, err := m.store.NoteRotationPolicyGet(ctx, noteID)
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteRotationResult{}, err
+	}
+
+	note, err := m.store.NoteGetByID(ctx, noteID)
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteRotationResult{}, err
+	}
+
+	charset := noteCharsets[policy.Charset]
+
+	// password notes get a character of each class the password policy requires, and are
+	// regenerated only for banned substrings and breached values
+	var value string
+	if noteTypePassword(note.Type) {
+		value, err = m.generateRotationPassword(ctx, note.VaultID, policy.Length, charset)
+	} else {
+		value, err = generateRandomString(policy.Length, charset)
+	}
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteRotationResult{}, err
+	}
+
+	now := time.Now()
+	result := NoteRotationResult{
+		NoteID:         noteID,
+		Name:           note.Name,
+		DryRun:         dryRun,
+		RotatedAt:      now.UTC().Format(time.RFC3339),
+		NextRotationAt: policy.NextRotationAt,
+	}
+
+	if dryRun {
+		if err := m.store.NoteRotationRecordDryRun(ctx, noteID, now); err != nil {
+			m.audit(ctx, event)
+			return NoteRotationResult{}, err
+		}
+	} else {
+		// the update keeps the replaced value as a version, records the rotation, which moves
+		// the schedule on, and audits the update
+		if _, err := m.noteUpdate(ctx, noteID, NoteUpdateParams{Name: note.Name, Value: value}, true); err != nil {
+			m.audit(ctx, event)
+			return NoteRotationResult{}, err
+		}
+
+		result.NextRotationAt = now.Add(policy.Interval).UTC().Format(time.RFC3339)
+	}
+
+	event.Outcome = AUDIT_OUTCOME_SUCCESS
+	m.audit(ctx, event)
+
+	eventType, message := NOTIFY_EVENT_NOTE_ROTATED, fmt.Sprintf("Note %q was rotated", note.Name)
+	if dryRun {
+		eventType, message = NOTIFY_EVENT_NOTE_ROTATION_DRY_RUN, fmt.Sprintf("Note %q would have been rotated", note.Name)
+	}
+
+	notification := notify.NewEvent(eventType, message, map[string]any{
+		"note_id":          noteID,
+		"name":             note.Name,
+		"dry_run":          dryRun,
+		"rotated_at":       result.RotatedAt,
+		"next_rotation_at": result.NextRotationAt,
+	})
+
+	if err := m.notifier.Notify(ctx, notification); err != nil {
+		logger.Log.Warn().Err(err).Int64("note_id", noteID).Msg("Failed to send note rotation event")
+	}
+
+	return result, nil
+}
+
+// NoteRotateDue rotates every note whose policy is due. A dry run announces each due rotation
+// once rather than on every run. Only system actors may rotate every note. Returns the
+// rotations performed.
+func (m *Models) NoteRotateDue(ctx context.Context, dryRun bool) ([]NoteRotationResult, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return []NoteRotationResult{}, err
+	}
+
+	policies, err := m.store.NoteRotationPoliciesDue(ctx, time.Now())
+	if err != nil {
+		return []NoteRotationResult{}, err
+	}
+
+	results := make([]NoteRotationResult, 0, len(policies))
+	var errs []error
+
+	for _, policy := range policies {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		if dryRun && !parseNoteTime(policy.LastDryRunAt).Before(parseNoteTime(policy.NextRotationAt)) {
+			continue
+		}
+
+		result, err := m.NoteRotate(ctx, policy.NoteID, dryRun)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("note %d: %w", policy.NoteID, err))
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+func noteRotationPolicyToResponse(policy NoteRotationPolicy) NoteRotationPolicyResponse {
+	return NoteRotationPolicyResponse{
+		NoteID:         policy.NoteID,
+		Length:         policy.Length,
+		Charset:        policy.Charset,
+		Interval:       formatInterval(policy.Interval),
+		NextRotationAt: policy.NextRotationAt,
+		LastRotatedAt:  policy.LastRotatedAt,
+		LastDryRunAt:   policy.LastDryRunAt,
+		CreatedAt:      policy.CreatedAt,
+		UpdatedAt:      policy.UpdatedAt,
+	}
+}
+
+// generateRotationPassword generates a value of the length from the charset that satisfies the
+// password policy of the vault. Returns a *PolicyError if the policy has changed since the
+// rotation policy was saved and the length or charset no longer satisfy it.
+func (m *Models) generateRotationPassword(ctx context.Context, vaultID int64, length int, charset string) (string, error) {
+	p, err := m.passwordPolicy(ctx, vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	if violations := p.Unsatisfiable(charset, length); len(violations) > 0 {
+		return "", &PolicyError{Violations: violations}
+	}
+
+	return m.generateCompliant(p, func() (string, error) {
+		return generator.Compose(length, charset, p.RequiredClasses(charset))
+	})
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oalexander6/web-app-template/config"
+)
+
+func newRotationTestModels(t *testing.T, passwordPolicy config.PasswordPolicyConfig) (*Models, *testStore, *recordingNotifier, context.Context) {
+	t.Helper()
+
+	store := newTestStore()
+	m := New(store, &config.Config{
+		Encryption:     config.EncryptionConfig{EncIV: "0123456789abcdef", EncSecret: "0123456789abcdef0123456789abcdef"},
+		PasswordPolicy: passwordPolicy,
+	})
+	notifier := &recordingNotifier{}
+	m.notifier = notifier
+
+	store.vaults[1] = Vault{ID: 1, OrganizationID: 1}
+
+	return m, store, notifier, WithActor(context.Background(), SystemActor("test"))
+}
+
+func TestNoteRotationPolicySetChecksPasswordPolicy(t *testing.T) {
+	m, store, _, ctx := newRotationTestModels(t, config.PasswordPolicyConfig{Require: []string{config.PASSWORD_CLASS_UPPER}})
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "DB", Type: NOTE_TYPE_PASSWORD}
+
+	_, err := m.NoteRotationPolicySet(ctx, 1, NoteRotationPolicyParams{Length: 16, Charset: NOTE_CHARSET_HEX, Interval: "30d"})
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 {
+		t.Fatalf("Expected a hex charset to break the uppercase rule, got %v", err)
+	}
+
+	if _, ok := store.rotations[1]; ok {
+		t.Fatal("Expected the rejected policy not to be saved")
+	}
+
+	policy, err := m.NoteRotationPolicySet(ctx, 1, NoteRotationPolicyParams{Length: 16, Charset: NOTE_CHARSET_ALPHANUMERIC, Interval: "30d"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if policy.Interval != "30d" || store.notes[1].RotateEvery != 30*24*time.Hour {
+		t.Fatalf("Expected the interval to be the note's rotate_every, got %+v and %s", policy, store.notes[1].RotateEvery)
+	}
+}
+
+func TestNoteRotateRecordsRotationWithUpdate(t *testing.T) {
+	m, store, notifier, ctx := newRotationTestModels(t, config.PasswordPolicyConfig{})
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Token", Type: NOTE_TYPE_SECURE_NOTE}
+
+	if _, err := m.NoteRotationPolicySet(ctx, 1, NoteRotationPolicyParams{Length: 24, Charset: NOTE_CHARSET_HEX, Interval: "1h"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	result, err := m.NoteRotate(ctx, 1, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	value, err := m.NoteGetByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(value.Value) != 24 {
+		t.Fatalf("Expected a 24 character value, got %q", value.Value)
+	}
+
+	if store.rotations[1].LastRotatedAt == "" || result.DryRun {
+		t.Fatalf("Expected the update to record the rotation, got %+v", store.rotations[1])
+	}
+
+	if len(notifier.events) != 1 || notifier.events[0].Type != NOTIFY_EVENT_NOTE_ROTATED {
+		t.Fatalf("Expected a rotated event, got %+v", notifier.events)
+	}
+}
+
+func TestNoteRotateKeepsUnreadableName(t *testing.T) {
+	m, store, _, ctx := newRotationTestModels(t, config.PasswordPolicyConfig{})
+	// sealed with a key that is no longer configured
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "c2VhbGVkIHdpdGggYW4gb2xkIGtleQ==", NameEncrypted: true, Type: NOTE_TYPE_SECURE_NOTE}
+
+	if _, err := m.NoteRotationPolicySet(ctx, 1, NoteRotationPolicyParams{Length: 24, Charset: NOTE_CHARSET_HEX, Interval: "1h"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	result, err := m.NoteRotate(ctx, 1, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if result.Name != NOTE_NAME_UNREADABLE {
+		t.Fatalf("Expected the unreadable name placeholder, got %q", result.Name)
+	}
+
+	if note := store.notes[1]; note.Name != "c2VhbGVkIHdpdGggYW4gb2xkIGtleQ==" || !note.NameEncrypted {
+		t.Fatalf("Expected the rotation to keep the stored name, got %q", note.Name)
+	}
+}
+
+func TestNoteRotateDueDryRunAnnouncesOnce(t *testing.T) {
+	m, store, notifier, ctx := newRotationTestModels(t, config.PasswordPolicyConfig{})
+	store.notes[1] = Note{ID: 1, VaultID: 1, Name: "Token", Type: NOTE_TYPE_SECURE_NOTE, Value: "unchanged"}
+	store.rotations[1] = NoteRotationPolicy{NoteID: 1, Length: 16, Charset: NOTE_CHARSET_HEX, Interval: time.Hour,
+		NextRotationAt: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)}
+
+	for run := 0; run < 2; run++ {
+		if _, err := m.NoteRotateDue(ctx, true); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	if len(notifier.events) != 1 || notifier.events[0].Type != NOTIFY_EVENT_NOTE_ROTATION_DRY_RUN {
+		t.Fatalf("Expected a single dry run event, got %+v", notifier.events)
+	}
+
+	if store.notes[1].Value != "unchanged" || store.rotations[1].LastRotatedAt != "" {
+		t.Fatalf("Expected a dry run to leave the note unchanged, got %+v", store.rotations[1])
+	}
+
+	// switching dry runs off rotates the note that is still due
+	results, err := m.NoteRotateDue(ctx, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(results) != 1 || store.notes[1].Value == "unchanged" {
+		t.Fatalf("Expected the due note to be rotated, got %+v", results)
+	}
+}
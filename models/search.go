@@ -0,0 +1,316 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchLength    = 200
+
+	// weights of the places a term can match, a match in the name ranks highest
+	searchWeightName  = 3
+	searchWeightTag   = 2
+	searchWeightField = 1
+)
+
+type noteSearchStore interface {
+	// NoteSearch returns up to limit notes matching the query, best first. Only the name, tags,
+	// plaintext fields and non-hidden text and url custom fields may be searched.
+	// nameTokens are the blind index tokens of the query, matching encrypted names that equal or
+	// start with it. Only notes in the vaults are searched.
+	NoteSearch(ctx context.Context, query string, nameTokens []string, vaultIDs []int64, limit int) ([]NoteMatch, error)
+}
+
+// NoteMatch is a note matching a search and its relevance, higher is better.
+type NoteMatch struct {
+	Note  Note
+	Score float64
+}
+
+type NoteSearchResult struct {
+	Note       NoteMetadata      `json:"note"`
+	Score      float64           `json:"score"`
+	Highlights []SearchHighlight `json:"highlights"`
+}
+
+// SearchHighlight is a searched value of a note with the words the query matched.
+type SearchHighlight struct {
+	// name, tag, field:<field name> or custom_field:<custom field name>
+	Field string `json:"field"`
+	Text  string `json:"text"`
+	// [start, end) rune offsets of the matched words in text
+	Matches [][2]int `json:"matches"`
+}
+
+// NoteSearch returns up to limit notes of the vaults the actor can read matching the query, best
+// first, with the matched words highlighted. Values and encrypted fields are never searched.
+// Returns an ErrInvalidInput error for an empty or too long query.
+func (m *Models) NoteSearch(ctx context.Context, query string, limit int) ([]NoteSearchResult, error) {
+	query = strings.TrimSpace(query)
+	terms := searchTerms(query)
+
+	if len(terms) == 0 {
+		return []NoteSearchResult{}, fmt.Errorf("%w: q must contain a letter or digit", ErrInvalidInput)
+	}
+
+	if utf8.RuneCountInString(query) > maxSearchLength {
+		return []NoteSearchResult{}, fmt.Errorf("%w: q must be at most %d characters", ErrInvalidInput, maxSearchLength)
+	}
+
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
+	if err != nil {
+		return []NoteSearchResult{}, err
+	}
+
+	matches, err := m.store.NoteSearch(ctx, query, m.nameLookupTokens(query), vaultIDs, limit)
+	if err != nil {
+		return []NoteSearchResult{}, err
+	}
+
+	favorites, err := m.actorFavoriteIDs(ctx)
+	if err != nil {
+		return []NoteSearchResult{}, err
+	}
+
+	results := make([]NoteSearchResult, len(matches))
+
+	for i, match := range matches {
+		_, highlights := scoreNote(match.Note, terms)
+		match.Note.Favorite = slices.Contains(favorites, match.Note.ID)
+		results[i] = NoteSearchResult{
+			Note:       noteToMetadata(match.Note),
+			Score:      math.Round(match.Score*1000) / 1000,
+			Highlights: highlights,
+		}
+	}
+
+	return results, nil
+}
+
+type searchField struct {
+	field  string
+	text   string
+	weight float64
+}
+
+// searchFields returns the searchable values of a note. This must stay in line with what the
+// store indexes: never the value, encrypted fields, hidden custom fields or boolean custom fields.
+func searchFields(note Note) []searchField {
+	fields := []searchField{{field: "name", text: note.Name, weight: searchWeightName}}
+
+	for _, tag := range note.Tags {
+		fields = append(fields, searchField{field: "tag", text: tag, weight: searchWeightTag})
+	}
+
+	names := make([]string, 0, len(note.Fields))
+	for name, field := range note.Fields {
+		if !field.Encrypted {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fields = append(fields, searchField{field: "field:" + name, text: note.Fields[name].Value, weight: searchWeightField})
+	}
+
+	for _, field := range note.CustomFields {
+		if field.Kind == CUSTOM_FIELD_TEXT || field.Kind == CUSTOM_FIELD_URL {
+			fields = append(fields, searchField{field: "custom_field:" + field.Name, text: field.Value, weight: searchWeightField})
+		}
+	}
+
+	return fields
+}
+
+// scoreNote returns the average over the terms of their best weighted match in the note, or 0 if
+// any term doesn't match, and the highlighted values.
+func scoreNote(note Note, terms []string) (float64, []SearchHighlight) {
+	fields := searchFields(note)
+	best := make([]float64, len(terms))
+	highlights := []SearchHighlight{}
+
+	for _, field := range fields {
+		words := searchWords(field.text)
+		var ranges [][2]int
+
+		for i, term := range terms {
+			score, matched := matchTerm(term, words)
+			best[i] = max(best[i], score*field.weight)
+			ranges = append(ranges, matched...)
+		}
+
+		if len(ranges) > 0 {
+			highlights = append(highlights, SearchHighlight{Field: field.field, Text: field.text, Matches: mergeRanges(ranges)})
+		}
+	}
+
+	total := 0.0
+	for _, score := range best {
+		if score == 0 {
+			return 0, highlights
+		}
+		total += score
+	}
+
+	return total / float64(len(terms)), highlights
+}
+
+type searchWord struct {
+	text       string
+	start, end int
+}
+
+// searchWords splits text into lowercase words of letters and digits with their rune offsets.
+func searchWords(text string) []searchWord {
+	var words []searchWord
+	var current []rune
+	start, pos := 0, 0
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if len(current) == 0 {
+				start = pos
+			}
+			current = append(current, unicode.ToLower(r))
+		} else if len(current) > 0 {
+			words = append(words, searchWord{text: string(current), start: start, end: pos})
+			current = current[:0]
+		}
+		pos++
+	}
+
+	if len(current) > 0 {
+		words = append(words, searchWord{text: string(current), start: start, end: pos})
+	}
+
+	return words
+}
+
+// searchTerms returns the distinct lowercase words of a query.
+func searchTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+
+	for _, word := range searchWords(query) {
+		if !seen[word.text] {
+			seen[word.text] = true
+			terms = append(terms, word.text)
+		}
+	}
+
+	return terms
+}
+
+// matchTerm returns how well the term matches its best word, from 0 to 1, and the ranges of
+// every word it matches.
+func matchTerm(term string, words []searchWord) (float64, [][2]int) {
+	best := 0.0
+	var ranges [][2]int
+	termLength := utf8.RuneCountInString(term)
+
+	for _, word := range words {
+		score := 0.0
+
+		switch {
+		case word.text == term:
+			score = 1
+		case strings.HasPrefix(word.text, term):
+			score = 0.9
+		case termLength >= 3 && strings.Contains(word.text, term):
+			score = 0.6
+		default:
+			edits := allowedEdits(termLength)
+			if edits == 0 {
+				break
+			}
+
+			distance := levenshtein(term, word.text)
+			if prefix := []rune(word.text); len(prefix) > termLength {
+				distance = min(distance, levenshtein(term, string(prefix[:termLength])))
+			}
+
+			if distance <= edits {
+				score = 0.8 - 0.2*float64(distance)
+			}
+		}
+
+		if score > 0 {
+			best = max(best, score)
+			ranges = append(ranges, [2]int{word.start, word.end})
+		}
+	}
+
+	return best, ranges
+}
+
+// allowedEdits is the number of typos tolerated in a term, none for short terms.
+func allowedEdits(termLength int) int {
+	switch {
+	case termLength < 4:
+		return 0
+	case termLength < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// levenshtein returns the edit distance between two strings, counted in runes.
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(br)]
+}
+
+// mergeRanges sorts the ranges and merges duplicates and overlaps.
+func mergeRanges(ranges [][2]int) [][2]int {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	merged := [][2]int{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1] {
+			last[1] = max(last[1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
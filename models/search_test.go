@@ -0,0 +1,66 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestScoreNote(t *testing.T) {
+	notes := []Note{
+		{ID: 1, Name: "GitHub", Value: "ciphertext"},
+		{ID: 2, Name: "Work laptop", Tags: []string{"github"}},
+		{ID: 3, Name: "Bank", Fields: map[string]NoteField{"urls": {Value: "https://github.com/login"}}},
+		{ID: 4, Name: "Deploy key", Fields: map[string]NoteField{"passphrase": {Value: "github", Encrypted: true}}},
+		{ID: 5, Name: "Secret", CustomFields: []CustomField{{Name: "token", Kind: CUSTOM_FIELD_HIDDEN, Value: "github"}}},
+	}
+
+	// the IDs of the notes matching the query, best first
+	matching := func(query string) []int64 {
+		var matches []NoteMatch
+		for _, note := range notes {
+			if score, _ := scoreNote(note, searchTerms(query)); score > 0 {
+				matches = append(matches, NoteMatch{Note: note, Score: score})
+			}
+		}
+
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+		ids := []int64{}
+		for _, match := range matches {
+			ids = append(ids, match.Note.ID)
+		}
+		return ids
+	}
+
+	if ids, expected := matching("github"), []int64{1, 2, 3}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Expected notes %v ranked name, tag then field without secrets, got %v", expected, ids)
+	}
+
+	if typo := matching("githb laptp"); !reflect.DeepEqual(typo, []int64{2}) {
+		t.Fatalf("Expected every term to match with typos, got %v", typo)
+	}
+
+	if prefix := matching("dep"); !reflect.DeepEqual(prefix, []int64{4}) {
+		t.Fatalf("Expected a prefix match, got %v", prefix)
+	}
+
+	if none := matching("bnk"); len(none) != 0 {
+		t.Fatalf("Expected no typos to be allowed in short terms, got %v", none)
+	}
+}
+
+func TestScoreNoteHighlights(t *testing.T) {
+	note := Note{Name: "Work GitHub", CustomFields: []CustomField{{Name: "site", Kind: CUSTOM_FIELD_URL, Value: "https://github.com"}}}
+
+	_, highlights := scoreNote(note, searchTerms("git work"))
+
+	expected := []SearchHighlight{
+		{Field: "name", Text: "Work GitHub", Matches: [][2]int{{0, 4}, {5, 11}}},
+		{Field: "custom_field:site", Text: "https://github.com", Matches: [][2]int{{8, 14}}},
+	}
+
+	if !reflect.DeepEqual(highlights, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, highlights)
+	}
+}
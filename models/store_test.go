@@ -0,0 +1,527 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// testStore keeps notes, vaults, memberships and audit events in memory for model tests. Store
+// methods a test doesn't need are left unimplemented and panic when called.
+type testStore struct {
+	Store
+
+	mu          sync.Mutex
+	notes       map[int64]Note
+	noteKeys    map[int64]string
+	vaults      map[int64]Vault
+	analyses    map[int64]NoteAnalysis
+	reminders   map[int64]NoteReminder
+	rotations   map[int64]NoteRotationPolicy
+	policies    map[int64]PasswordPolicy
+	favorites   map[[2]int64]bool
+	memberships []Member
+	audit       []AuditEvent
+}
+
+func newTestStore() *testStore {
+	return &testStore{notes: map[int64]Note{}, noteKeys: map[int64]string{}, vaults: map[int64]Vault{}, analyses: map[int64]NoteAnalysis{}, reminders: map[int64]NoteReminder{},
+		rotations: map[int64]NoteRotationPolicy{}, policies: map[int64]PasswordPolicy{},
+		favorites: map[[2]int64]bool{}}
+}
+
+func (s *testStore) NoteGetByID(ctx context.Context, id int64) (Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok || note.DeletedAt != "" {
+		return Note{}, ErrNotFound
+	}
+
+	return note, nil
+}
+
+func (s *testStore) NoteGetVaultID(ctx context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	return note.VaultID, nil
+}
+
+func (s *testStore) NoteUpdate(ctx context.Context, id int64, write NoteWrite) (Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok || note.DeletedAt != "" {
+		return Note{}, ErrNotFound
+	}
+
+	if !write.KeepName {
+		note.Name = write.Name
+		note.NameEncrypted = write.NameIndex.Encrypted
+	}
+	note.Value = write.Value
+	note.Fields = write.Fields
+	note.CustomFields = write.CustomFields
+	note.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	s.notes[id] = note
+
+	if policy, ok := s.rotations[id]; ok && write.Rotated {
+		policy.LastRotatedAt = note.UpdatedAt
+		policy.NextRotationAt = time.Now().Add(policy.Interval).UTC().Format(time.RFC3339)
+		s.rotations[id] = policy
+	}
+
+	return note, nil
+}
+
+func (s *testStore) NotePurge(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok || note.DeletedAt == "" {
+		return ErrNotFound
+	}
+
+	delete(s.notes, id)
+	delete(s.noteKeys, id)
+
+	return nil
+}
+
+func (s *testStore) NoteSetFavorite(ctx context.Context, userID int64, noteID int64, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteID]; !ok || note.DeletedAt != "" {
+		return ErrNotFound
+	}
+
+	if favorite {
+		s.favorites[[2]int64{userID, noteID}] = true
+	} else {
+		delete(s.favorites, [2]int64{userID, noteID})
+	}
+
+	return nil
+}
+
+func (s *testStore) NoteFavoriteIDs(ctx context.Context, userID int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := []int64{}
+	for key := range s.favorites {
+		if key[0] == userID {
+			ids = append(ids, key[1])
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, nil
+}
+
+func (s *testStore) NoteKeyGet(ctx context.Context, noteID int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.noteKeys[noteID]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return key, nil
+}
+
+func (s *testStore) NoteKeyCreate(ctx context.Context, noteID int64, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.notes[noteID]; !ok {
+		return "", ErrNotFound
+	}
+
+	if existing, ok := s.noteKeys[noteID]; ok {
+		return existing, nil
+	}
+	s.noteKeys[noteID] = key
+
+	return key, nil
+}
+
+func (s *testStore) NoteKeySet(ctx context.Context, noteID int64, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.noteKeys[noteID]; !ok {
+		return ErrNotFound
+	}
+	s.noteKeys[noteID] = key
+
+	return nil
+}
+
+func (s *testStore) NoteAnalysisSave(ctx context.Context, analysis NoteAnalysis) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.analyses[analysis.NoteID] = analysis
+
+	return nil
+}
+
+func (s *testStore) NoteAnalysisGetAll(ctx context.Context, vaultIDs []int64) ([]NoteAnalysis, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	analyses := []NoteAnalysis{}
+	for _, analysis := range s.analyses {
+		if note, ok := s.notes[analysis.NoteID]; ok && note.DeletedAt == "" && inVaults(note, vaultIDs) {
+			analyses = append(analyses, analysis)
+		}
+	}
+
+	return analyses, nil
+}
+
+func (s *testStore) NoteGetAll(ctx context.Context, vaultIDs []int64) ([]Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := []Note{}
+	for _, note := range s.notes {
+		if note.DeletedAt == "" && inVaults(note, vaultIDs) {
+			notes = append(notes, note)
+		}
+	}
+
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+
+	return notes, nil
+}
+
+func (s *testStore) NoteGetDueBefore(ctx context.Context, before time.Time, vaultIDs []int64) ([]Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := []Note{}
+	for _, note := range s.notes {
+		if note.DeletedAt == "" && inVaults(note, vaultIDs) {
+			notes = append(notes, note)
+		}
+	}
+
+	return notes, nil
+}
+
+func (s *testStore) NoteEncryptFields(ctx context.Context, seal func(noteID int64, noteType string, fields map[string]NoteField) (bool, error)) (int, error) {
+	// seal reads note keys, so the lock isn't held while it runs
+	s.mu.Lock()
+	notes := make([]Note, 0, len(s.notes))
+	for _, note := range s.notes {
+		notes = append(notes, note)
+	}
+	s.mu.Unlock()
+
+	count := 0
+	for _, note := range notes {
+		changed, err := seal(note.ID, note.Type, note.Fields)
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// inVaults reports whether the note is in the vaults, every vault for nil vaultIDs.
+func inVaults(note Note, vaultIDs []int64) bool {
+	return vaultIDs == nil || slices.Contains(vaultIDs, note.VaultID)
+}
+
+func (s *testStore) NoteGetDeleted(ctx context.Context, vaultIDs []int64) ([]Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := []Note{}
+	for _, note := range s.notes {
+		if note.DeletedAt != "" && inVaults(note, vaultIDs) {
+			notes = append(notes, note)
+		}
+	}
+
+	sort.Slice(notes, func(i, j int) bool { return notes[i].DeletedAt > notes[j].DeletedAt })
+
+	return notes, nil
+}
+
+func (s *testStore) NoteReminderGet(ctx context.Context, noteID int64) (NoteReminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, ok := s.reminders[noteID]
+	if !ok {
+		return NoteReminder{}, ErrNotFound
+	}
+
+	return reminder, nil
+}
+
+func (s *testStore) NoteReminderSave(ctx context.Context, reminder NoteReminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reminders[reminder.NoteID] = reminder
+
+	return nil
+}
+
+func (s *testStore) NoteRotationPolicySet(ctx context.Context, policy NoteRotationPolicy) (NoteRotationPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[policy.NoteID]
+	if !ok || note.DeletedAt != "" {
+		return NoteRotationPolicy{}, ErrNotFound
+	}
+	note.RotateEvery = policy.Interval
+	s.notes[policy.NoteID] = note
+
+	policy.NextRotationAt = time.Now().Add(policy.Interval).UTC().Format(time.RFC3339)
+	s.rotations[policy.NoteID] = policy
+
+	return policy, nil
+}
+
+func (s *testStore) NoteRotationPolicyGet(ctx context.Context, noteID int64) (NoteRotationPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, ok := s.rotations[noteID]
+	if !ok {
+		return NoteRotationPolicy{}, ErrNotFound
+	}
+
+	return policy, nil
+}
+
+func (s *testStore) NoteRotationPoliciesDue(ctx context.Context, before time.Time) ([]NoteRotationPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []NoteRotationPolicy{}
+	for _, policy := range s.rotations {
+		if !parseNoteTime(policy.NextRotationAt).After(before) {
+			due = append(due, policy)
+		}
+	}
+
+	return due, nil
+}
+
+func (s *testStore) NoteRotationRecordDryRun(ctx context.Context, noteID int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, ok := s.rotations[noteID]
+	if !ok {
+		return ErrNotFound
+	}
+	policy.LastDryRunAt = at.UTC().Format(time.RFC3339)
+	s.rotations[noteID] = policy
+
+	return nil
+}
+
+func (s *testStore) VaultGetAll(ctx context.Context) ([]Vault, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vaults := []Vault{}
+	for _, vault := range s.vaults {
+		vaults = append(vaults, vault)
+	}
+
+	return vaults, nil
+}
+
+func (s *testStore) VaultGetForUser(ctx context.Context, userID int64) ([]Vault, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vaults := []Vault{}
+	for _, vault := range s.vaults {
+		roles := []string{}
+		for _, member := range s.memberships {
+			if member.UserID == userID && member.OrganizationID == vault.OrganizationID && (member.VaultID == 0 || member.VaultID == vault.ID) {
+				roles = append(roles, member.Role)
+			}
+		}
+
+		if len(roles) > 0 {
+			vault.Role = highestRole(roles)
+			vaults = append(vaults, vault)
+		}
+	}
+
+	return vaults, nil
+}
+
+func (s *testStore) VaultGetByID(ctx context.Context, id int64) (Vault, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vault, ok := s.vaults[id]
+	if !ok {
+		return Vault{}, ErrNotFound
+	}
+
+	return vault, nil
+}
+
+func (s *testStore) PasswordPolicyGet(ctx context.Context, organizationID int64) (PasswordPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.policies[organizationID]
+	if !ok {
+		return PasswordPolicy{}, ErrNotFound
+	}
+
+	return p, nil
+}
+
+func (s *testStore) PasswordPolicySet(ctx context.Context, p PasswordPolicy) (PasswordPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policies[p.OrganizationID] = p
+
+	return p, nil
+}
+
+func (s *testStore) PasswordPolicyDelete(ctx context.Context, organizationID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[organizationID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.policies, organizationID)
+
+	return nil
+}
+
+func (s *testStore) MembershipRoles(ctx context.Context, userID int64, organizationID int64, vaultID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if vaultID != 0 {
+		vault, ok := s.vaults[vaultID]
+		if !ok {
+			return []string{}, nil
+		}
+		organizationID = vault.OrganizationID
+	}
+
+	roles := []string{}
+	for _, member := range s.memberships {
+		if member.UserID != userID || member.OrganizationID != organizationID {
+			continue
+		}
+		if member.VaultID == 0 || (vaultID != 0 && member.VaultID == vaultID) {
+			roles = append(roles, member.Role)
+		}
+	}
+
+	return roles, nil
+}
+
+func (s *testStore) MemberGetByID(ctx context.Context, id int64) (Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, member := range s.memberships {
+		if member.ID == id {
+			return member, nil
+		}
+	}
+
+	return Member{}, ErrNotFound
+}
+
+func (s *testStore) MemberSetRole(ctx context.Context, id int64, role string) (Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, member := range s.memberships {
+		if member.ID != id {
+			continue
+		}
+		if role != ROLE_OWNER && s.lastOwner(member) {
+			return Member{}, fmt.Errorf("%w: the organization must keep an owner", ErrInvalidInput)
+		}
+		s.memberships[i].Role = role
+		return s.memberships[i], nil
+	}
+
+	return Member{}, ErrNotFound
+}
+
+func (s *testStore) MemberDelete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, member := range s.memberships {
+		if member.ID != id {
+			continue
+		}
+		if s.lastOwner(member) {
+			return fmt.Errorf("%w: the organization must keep an owner", ErrInvalidInput)
+		}
+		s.memberships = slices.Delete(s.memberships, i, i+1)
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+// lastOwner reports whether the member is the only owner of its organization.
+func (s *testStore) lastOwner(member Member) bool {
+	if member.VaultID != 0 || member.Role != ROLE_OWNER {
+		return false
+	}
+
+	for _, other := range s.memberships {
+		if other.ID != member.ID && other.OrganizationID == member.OrganizationID && other.VaultID == 0 && other.Role == ROLE_OWNER {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *testStore) AuditAppend(ctx context.Context, event AuditEvent, hash func(prevHash string, event AuditEvent) string) (AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.ID = int64(len(s.audit) + 1)
+	s.audit = append(s.audit, event)
+
+	return event, nil
+}
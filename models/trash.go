@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/web-app-template/logger"
+)
+
+// NoteTrashMetadata represents the data returned when listing the trash. It never includes
+// the value.
+type NoteTrashMetadata struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	DeletedAt string `json:"deleted_at"`
+	DeletedBy int64  `json:"deleted_by,omitempty"`
+	// when the background purge will permanently remove the note
+	PurgeAt string `json:"purge_at"`
+}
+
+// trashStore defines the interface required to list, restore and permanently remove deleted
+// notes.
+type trashStore interface {
+	// NoteGetDeleted returns the notes in the trash of the vaults, every vault for nil vaultIDs.
+	NoteGetDeleted(ctx context.Context, vaultIDs []int64) ([]Note, error)
+	// NoteRestore returns a *NameConflictError if the note's name was taken while it was deleted.
+	NoteRestore(ctx context.Context, id int64) error
+	// NotePurge removes a note in the trash with its versions and data key in one transaction.
+	NotePurge(ctx context.Context, id int64) error
+	// NotePurgeDeletedBefore permanently removes notes deleted before the cutoff, like
+	// NotePurge, and returns their IDs.
+	NotePurgeDeletedBefore(ctx context.Context, before time.Time) ([]int64, error)
+}
+
+// NoteGetTrash returns the deleted notes of the vaults the actor can read that have not been
+// purged yet, most recently deleted first.
+func (m *Models) NoteGetTrash(ctx context.Context) ([]NoteTrashMetadata, error) {
+	vaultIDs, err := m.authorizedVaults(ctx, PERMISSION_READ)
+	if err != nil {
+		return []NoteTrashMetadata{}, err
+	}
+
+	notes, err := m.store.NoteGetDeleted(ctx, vaultIDs)
+	if err != nil {
+		return []NoteTrashMetadata{}, err
+	}
+
+	results := make([]NoteTrashMetadata, len(notes))
+	for i, note := range notes {
+		results[i] = m.noteToTrashMetadata(note)
+	}
+
+	return results, nil
+}
+
+// NoteRestoreFromTrash moves a deleted note out of the trash. Needs the write permission.
+// Returns ErrNotFound if the note is not in the trash or a *NameConflictError if another note in
+// its folder has taken its name.
+func (m *Models) NoteRestoreFromTrash(ctx context.Context, noteID int64) error {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return err
+	}
+
+	if err := m.store.NoteRestore(ctx, noteID); err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_UNDELETE, noteID, AUDIT_OUTCOME_FAILURE)
+		return err
+	}
+
+	m.auditNote(ctx, AUDIT_ACTION_NOTE_UNDELETE, noteID, AUDIT_OUTCOME_SUCCESS)
+
+	return nil
+}
+
+// NotePurge permanently removes a note in the trash along with its versions and destroys its
+// data key, so copies of its values left in backups can't be decrypted. Needs the write
+// permission. Returns ErrNotFound if the note is not in the trash.
+func (m *Models) NotePurge(ctx context.Context, noteID int64) error {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return err
+	}
+
+	if err := m.store.NotePurge(ctx, noteID); err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_PURGE, noteID, AUDIT_OUTCOME_FAILURE)
+		return err
+	}
+
+	m.auditNote(ctx, AUDIT_ACTION_NOTE_PURGE, noteID, AUDIT_OUTCOME_SUCCESS)
+
+	return nil
+}
+
+// NotePurgeExpired permanently removes notes that have been in the trash longer than the
+// configured retention. Only system actors may purge. Returns the number of notes purged.
+func (m *Models) NotePurgeExpired(ctx context.Context) (int, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-m.config.Notes.TrashRetention)
+
+	purged, err := m.store.NotePurgeDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, noteID := range purged {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_PURGE, noteID, AUDIT_OUTCOME_SUCCESS)
+	}
+
+	if len(purged) > 0 {
+		logger.Log.Info().Int("purged", len(purged)).Msg("Purged expired notes from the trash")
+	}
+
+	return len(purged), nil
+}
+
+func (m *Models) noteToTrashMetadata(note Note) NoteTrashMetadata {
+	metadata := NoteTrashMetadata{
+		ID:        note.ID,
+		Name:      note.Name,
+		DeletedAt: note.DeletedAt,
+		DeletedBy: note.DeletedBy,
+	}
+
+	if deletedAt, err := time.Parse(time.RFC3339, note.DeletedAt); err == nil {
+		metadata.PurgeAt = deletedAt.Add(m.config.Notes.TrashRetention).UTC().Format(time.RFC3339)
+	}
+
+	return metadata
+}
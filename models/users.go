@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	userMinUsernameLength = 3
+	userMaxUsernameLength = 64
+	userMinPasswordLength = 12
+	// bcrypt only uses the first 72 bytes of the password
+	userMaxPasswordLength = 72
+)
+
+// User represents an account that can sign in. The password is only ever stored hashed.
+type User struct {
+	ID           int64
+	Username     string
+	PasswordHash string
+	CreatedAt    string
+	UpdatedAt    string
+}
+
+// UserCreateParams represents the data required to create a new user.
+type UserCreateParams struct {
+	Username string `json:"username" form:"username" binding:"required,min=3,max=64"`
+	Password string `json:"password" form:"password" binding:"required,min=12,max=72"`
+}
+
+// UserGetResponse represents the data returned for user GET requests.
+type UserGetResponse struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+// userStore defines the interface required to implement persistent storage functionality
+// for users.
+type userStore interface {
+	UserCreate(ctx context.Context, username string, passwordHash string) (User, error)
+	UserGetByID(ctx context.Context, id int64) (User, error)
+	UserGetByUsername(ctx context.Context, username string) (User, error)
+}
+
+// UserCreate validates and saves a new user with a bcrypt hashed password.
+// Only system actors may create users. Returns ErrAlreadyExists if the username is taken.
+func (m *Models) UserCreate(ctx context.Context, userInput UserCreateParams) (UserGetResponse, error) {
+	if err := m.authorize(ctx, PERMISSION_SYSTEM, Resource{}); err != nil {
+		return UserGetResponse{}, err
+	}
+
+	username := strings.ToLower(strings.TrimSpace(userInput.Username))
+
+	if len(username) < userMinUsernameLength || len(username) > userMaxUsernameLength {
+		return UserGetResponse{}, fmt.Errorf("%w: username must be between %d and %d characters", ErrInvalidInput, userMinUsernameLength, userMaxUsernameLength)
+	}
+
+	if len(userInput.Password) < userMinPasswordLength || len(userInput.Password) > userMaxPasswordLength {
+		return UserGetResponse{}, fmt.Errorf("%w: password must be between %d and %d characters", ErrInvalidInput, userMinPasswordLength, userMaxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(userInput.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return UserGetResponse{}, err
+	}
+
+	user, err := m.store.UserCreate(ctx, username, string(hash))
+	if err != nil {
+		m.audit(ctx, AuditEvent{Action: AUDIT_ACTION_USER_CREATE, Outcome: AUDIT_OUTCOME_FAILURE, TargetType: AUDIT_TARGET_USER, Details: auditDetails("username", username)})
+		return UserGetResponse{}, err
+	}
+
+	m.audit(ctx, AuditEvent{Action: AUDIT_ACTION_USER_CREATE, Outcome: AUDIT_OUTCOME_SUCCESS, TargetType: AUDIT_TARGET_USER, TargetID: user.ID})
+
+	return userToResponse(user), nil
+}
+
+// userToResponse converts a User to the response shape, dropping the password hash.
+func userToResponse(user User) UserGetResponse {
+	return UserGetResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		CreatedAt: user.CreatedAt,
+	}
+}
@@ -0,0 +1,372 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// NoteVersion is a previous name, encrypted value and fields of a note, captured when the note
+// was updated.
+type NoteVersion struct {
+	NoteID       int64
+	Version      int
+	Name         string
+	Value        string
+	Fields       map[string]NoteField
+	CustomFields []CustomField
+	// see Note.NameEncrypted
+	NameEncrypted bool
+	// when this version was originally written
+	CreatedAt string
+	// when this version was replaced and the ID of the user who replaced it, 0 if unknown
+	ReplacedAt string
+	ReplacedBy int64
+}
+
+// NoteUpdateParams represents the data required to update a note.
+type NoteUpdateParams struct {
+	Name string `json:"name" form:"name" binding:"required"`
+	// see NoteCreateParams, the type of a note can't be changed
+	Value string `json:"value" form:"value"`
+	// replace every field or custom field, the current ones are kept when omitted
+	Fields       map[string]string `json:"fields" form:"fields"`
+	CustomFields []CustomField     `json:"custom_fields" form:"custom_fields"`
+}
+
+// NoteVersionMetadata represents the data returned when listing note versions. It never
+// includes the value.
+type NoteVersionMetadata struct {
+	NoteID     int64  `json:"note_id"`
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"created_at"`
+	ReplacedAt string `json:"replaced_at"`
+	ReplacedBy int64  `json:"replaced_by,omitempty"`
+}
+
+// NoteVersionRevealResponse represents the data returned when a previous value is revealed.
+type NoteVersionRevealResponse struct {
+	NoteID       int64             `json:"note_id"`
+	Version      int               `json:"version"`
+	Name         string            `json:"name"`
+	Value        string            `json:"value"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	CustomFields []CustomField     `json:"custom_fields,omitempty"`
+	RevealedAt   string            `json:"revealed_at"`
+}
+
+// noteVersionStore defines the interface required to persist note history.
+type noteVersionStore interface {
+	// NoteUpdate saves the note's current name, value and fields as a new version, replaces
+	// them with the name, value, fields and custom fields of note and prunes all but the newest
+	// note.Retain versions, in one transaction. Returns a *NameConflictError if another note in
+	// the folder has the new name.
+	NoteUpdate(ctx context.Context, id int64, note NoteWrite) (Note, error)
+	NoteGetVersions(ctx context.Context, noteID int64) ([]NoteVersion, error)
+	NoteGetVersion(ctx context.Context, noteID int64, version int) (NoteVersion, error)
+}
+
+// NoteUpdate replaces the name, value and fields of a note, keeping the previous ones as a version.
+// The fields and custom fields are each kept when nil in noteInput. New password values must
+// satisfy the password policy, a *PolicyError lists the rules a value breaks. Needs the write
+// permission.
+// Returns ErrNotFound if the note doesn't exist, an ErrInvalidInput error for invalid fields or a
+// *NameConflictError if another note in the folder has the name.
+func (m *Models) NoteUpdate(ctx context.Context, noteID int64, noteInput NoteUpdateParams) (NoteMetadata, error) {
+	return m.noteUpdate(ctx, noteID, noteInput, false)
+}
+
+// noteUpdate implements NoteUpdate. Rotations set rotated, so the store records the rotation in
+// the same transaction as the new value.
+func (m *Models) noteUpdate(ctx context.Context, noteID int64, noteInput NoteUpdateParams, rotated bool) (NoteMetadata, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return NoteMetadata{}, err
+	}
+
+	current, err := m.store.NoteGetByID(ctx, noteID)
+	if err != nil {
+		return NoteMetadata{}, err
+	}
+
+	schema, err := noteSchema(current.Type)
+	if err != nil {
+		return NoteMetadata{}, err
+	}
+
+	// omitted fields keep their current values, which were validated when they were saved
+	var value string
+	var fields map[string]string
+	if noteInput.Fields != nil {
+		value, fields, err = schema.validate(noteInput.Value, noteInput.Fields)
+	} else {
+		value, err = validateField(schema.value, noteInput.Value)
+	}
+	if err != nil {
+		return NoteMetadata{}, err
+	}
+
+	customFields, err := validateCustomFields(noteInput.CustomFields)
+	if err != nil {
+		return NoteMetadata{}, err
+	}
+
+	if schema.password {
+		if err := m.checkPasswordPolicy(ctx, current.VaultID, value); err != nil {
+			return NoteMetadata{}, err
+		}
+	}
+
+	c, err := m.noteCipherForWrite(ctx, noteID)
+	if err != nil {
+		return NoteMetadata{}, err
+	}
+
+	encVal, err := c.encrypt([]byte(value))
+	if err != nil {
+		return NoteMetadata{}, ErrEncryptFailed
+	}
+
+	encFields := current.Fields
+	if noteInput.Fields != nil {
+		if encFields, err = c.encryptFields(schema, fields); err != nil {
+			return NoteMetadata{}, err
+		}
+	}
+
+	encCustomFields := current.CustomFields
+	if noteInput.CustomFields != nil {
+		if encCustomFields, err = c.encryptCustomFields(customFields); err != nil {
+			return NoteMetadata{}, err
+		}
+	}
+
+	// an unchanged name keeps its ciphertext, which may be the only copy of a name no
+	// configured key can decrypt
+	keepName := noteInput.Name == current.Name
+
+	var name string
+	var nameIndex NoteNameIndex
+	if !keepName {
+		if name, nameIndex, err = m.sealName(noteInput.Name); err != nil {
+			return NoteMetadata{}, err
+		}
+	}
+
+	retain, err := m.versionRetention(ctx, current.VaultID)
+	if err != nil {
+		return NoteMetadata{}, err
+	}
+
+	note, err := m.store.NoteUpdate(ctx, noteID, NoteWrite{
+		Name:         name,
+		NameIndex:    nameIndex,
+		KeepName:     keepName,
+		Value:        encVal,
+		Fields:       encFields,
+		CustomFields: encCustomFields,
+		ReplacedBy:   ActorFromContext(ctx).UserID,
+		Retain:       retain,
+		Rotated:      rotated,
+	})
+	if err != nil {
+		m.auditNote(ctx, AUDIT_ACTION_NOTE_UPDATE, noteID, AUDIT_OUTCOME_FAILURE)
+		return NoteMetadata{}, err
+	}
+
+	m.auditNote(ctx, AUDIT_ACTION_NOTE_UPDATE, noteID, AUDIT_OUTCOME_SUCCESS)
+	if schema.freeform {
+		m.noteAnalyze(ctx, note, value)
+		m.noteCheckBreachOnWrite(ctx, note, value)
+	}
+
+	return m.writtenNoteMetadata(ctx, note), nil
+}
+
+// NoteGetVersions returns the retained previous versions of a note, newest first, without
+// their values. Needs the read permission. Returns ErrNotFound if the note doesn't exist.
+func (m *Models) NoteGetVersions(ctx context.Context, noteID int64) ([]NoteVersionMetadata, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_READ, noteID); err != nil {
+		return []NoteVersionMetadata{}, err
+	}
+
+	if _, err := m.store.NoteGetByID(ctx, noteID); err != nil {
+		return []NoteVersionMetadata{}, err
+	}
+
+	versions, err := m.store.NoteGetVersions(ctx, noteID)
+	if err != nil {
+		return []NoteVersionMetadata{}, err
+	}
+
+	results := make([]NoteVersionMetadata, len(versions))
+	for i, version := range versions {
+		results[i] = noteVersionToMetadata(version)
+	}
+
+	return results, nil
+}
+
+// NoteRevealVersion returns the decrypted value of a previous version of a note and records an
+// audit event. It needs the same permission and is subject to the same re-authentication window
+// as NoteReveal.
+func (m *Models) NoteRevealVersion(ctx context.Context, noteID int64, version int) (NoteVersionRevealResponse, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_READ, noteID); err != nil {
+		return NoteVersionRevealResponse{}, err
+	}
+
+	event := AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_VERSION_REVEAL,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   noteID,
+		Details:    auditDetails("version", fmt.Sprint(version)),
+	}
+
+	fail := func(outcome string, err error) (NoteVersionRevealResponse, error) {
+		event.Outcome = outcome
+		m.audit(ctx, event)
+		return NoteVersionRevealResponse{}, err
+	}
+
+	if !m.recentlyAuthenticated(ctx) {
+		return fail(AUDIT_OUTCOME_DENIED, ErrReauthRequired)
+	}
+
+	if _, err := m.store.NoteGetByID(ctx, noteID); err != nil {
+		return fail(AUDIT_OUTCOME_FAILURE, err)
+	}
+
+	noteVersion, err := m.store.NoteGetVersion(ctx, noteID, version)
+	if err != nil {
+		return fail(AUDIT_OUTCOME_FAILURE, err)
+	}
+
+	c, err := m.noteCipher(ctx, noteID)
+	if err != nil {
+		return fail(AUDIT_OUTCOME_FAILURE, err)
+	}
+
+	decryptedVal, err := c.decrypt(noteVersion.Value)
+	if err != nil {
+		return fail(AUDIT_OUTCOME_FAILURE, ErrDecryptFailed)
+	}
+
+	fields, err := c.decryptFields(noteVersion.Fields)
+	if err != nil {
+		return fail(AUDIT_OUTCOME_FAILURE, err)
+	}
+
+	customFields, err := c.decryptCustomFields(noteVersion.CustomFields)
+	if err != nil {
+		return fail(AUDIT_OUTCOME_FAILURE, err)
+	}
+
+	event.Outcome = AUDIT_OUTCOME_SUCCESS
+	if err := m.audit(ctx, event); err != nil {
+		return NoteVersionRevealResponse{}, err
+	}
+
+	return NoteVersionRevealResponse{
+		NoteID:       noteVersion.NoteID,
+		Version:      noteVersion.Version,
+		Name:         noteVersion.Name,
+		Value:        decryptedVal,
+		Fields:       fields,
+		CustomFields: customFields,
+		RevealedAt:   time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// NoteRestoreVersion sets the note's name, value and fields back to a previous version. The
+// replaced ones are kept as a new version, so a restore can itself be undone. Needs the write
+// permission.
+// Returns ErrNotFound if the note or version doesn't exist, ErrDecryptFailed if the version's
+// name can't be decrypted or a *NameConflictError if another note in the folder has taken the
+// version's name.
+func (m *Models) NoteRestoreVersion(ctx context.Context, noteID int64, version int) (NoteMetadata, error) {
+	if err := m.authorizeNote(ctx, PERMISSION_WRITE, noteID); err != nil {
+		return NoteMetadata{}, err
+	}
+
+	event := AuditEvent{
+		Action:     AUDIT_ACTION_NOTE_RESTORE,
+		TargetType: AUDIT_TARGET_NOTE,
+		TargetID:   noteID,
+		Details:    auditDetails("version", fmt.Sprint(version)),
+		Outcome:    AUDIT_OUTCOME_FAILURE,
+	}
+
+	noteVersion, err := m.store.NoteGetVersion(ctx, noteID, version)
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteMetadata{}, err
+	}
+
+	// restoring the placeholder would replace the name for good
+	if noteVersion.NameEncrypted && noteVersion.Name == NOTE_NAME_UNREADABLE {
+		m.audit(ctx, event)
+		return NoteMetadata{}, fmt.Errorf("%w: the version's name can't be decrypted", ErrDecryptFailed)
+	}
+
+	current, err := m.store.NoteGetByID(ctx, noteID)
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteMetadata{}, err
+	}
+
+	keepName := noteVersion.Name == current.Name
+
+	var name string
+	var nameIndex NoteNameIndex
+	if !keepName {
+		if name, nameIndex, err = m.sealName(noteVersion.Name); err != nil {
+			m.audit(ctx, event)
+			return NoteMetadata{}, err
+		}
+	}
+
+	retain, err := m.versionRetention(ctx, current.VaultID)
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteMetadata{}, err
+	}
+
+	note, err := m.store.NoteUpdate(ctx, noteID, NoteWrite{
+		Name:         name,
+		NameIndex:    nameIndex,
+		KeepName:     keepName,
+		Value:        noteVersion.Value,
+		Fields:       noteVersion.Fields,
+		CustomFields: noteVersion.CustomFields,
+		ReplacedBy:   ActorFromContext(ctx).UserID,
+		Retain:       retain,
+	})
+	if err != nil {
+		m.audit(ctx, event)
+		return NoteMetadata{}, err
+	}
+
+	event.Outcome = AUDIT_OUTCOME_SUCCESS
+	m.audit(ctx, event)
+
+	if m.breach.Enabled() && noteTypeFreeform(note.Type) {
+		if c, err := m.noteCipher(ctx, noteID); err == nil {
+			if value, err := c.decrypt(noteVersion.Value); err == nil {
+				m.noteCheckBreachOnWrite(ctx, note, value)
+			}
+		}
+	}
+
+	return m.writtenNoteMetadata(ctx, note), nil
+}
+
+func noteVersionToMetadata(version NoteVersion) NoteVersionMetadata {
+	return NoteVersionMetadata{
+		NoteID:     version.NoteID,
+		Version:    version.Version,
+		Name:       version.Name,
+		CreatedAt:  version.CreatedAt,
+		ReplacedAt: version.ReplacedAt,
+		ReplacedBy: version.ReplacedBy,
+	}
+}
@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/metrics"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex HMAC of the body>" when a webhook secret is set.
+	SignatureHeader = "X-Signature"
+	EventTypeHeader = "X-Event-Type"
+)
+
+// Event is a notification about something that needs attention. Data must never contain
+// secret values.
+type Event struct {
+	// random ID receivers can use to drop duplicate deliveries
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Message    string         `json:"message"`
+	OccurredAt string         `json:"occurred_at"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+// Notifier delivers events.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// NewEvent creates an event with a random ID, occurring now.
+func NewEvent(eventType, message string, data map[string]any) Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return Event{
+		ID:         hex.EncodeToString(id),
+		Type:       eventType,
+		Message:    message,
+		OccurredAt: time.Now().UTC().Format(time.RFC3339),
+		Data:       data,
+	}
+}
+
+// New returns a notifier that logs every event and also POSTs it to the configured webhook,
+// if any.
+func New(c config.NotifyConfig) Notifier {
+	if c.WebhookURL == "" {
+		return LogNotifier{}
+	}
+
+	return Multi{LogNotifier{}, NewWebhookNotifier(c.WebhookURL, c.WebhookSecret, c.Timeout)}
+}
+
+// LogNotifier writes events to the application log.
+type LogNotifier struct{}
+
+// Notify implements Notifier.
+func (LogNotifier) Notify(_ context.Context, event Event) error {
+	logger.Log.Info().Str("event_id", event.ID).Str("event_type", event.Type).Interface("data", event.Data).Msg(event.Message)
+	return nil
+}
+
+// Multi delivers each event to every notifier, returning the joined errors.
+type Multi []Notifier
+
+// Notify implements Notifier.
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var errs []error
+
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// WebhookNotifier POSTs events as JSON, signed with HMAC-SHA256 when a secret is set.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Notify implements Notifier. Any response other than 2xx is an error.
+func (w *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	err := w.post(ctx, event)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	metrics.Notifications.Add(event.Type+"."+outcome, 1)
+
+	return err
+}
+
+func (w *WebhookNotifier) post(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, event.Type)
+
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delivering %s event: %w", event.Type, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("delivering %s event: webhook responded with %d", event.Type, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the signature header value for a webhook body. Receivers should compute the
+// same value and compare it with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
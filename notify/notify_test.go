@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifierSignsBody(t *testing.T) {
+	var gotSignature, gotType string
+	var gotBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotType = r.Header.Get(EventTypeHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, "secret", time.Second)
+	if err := n.Notify(context.Background(), NewEvent("note.expired", "expired", nil)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if gotType != "note.expired" {
+		t.Fatalf("Expected the event type header, got %q", gotType)
+	}
+
+	if !hmac.Equal([]byte(gotSignature), []byte(Sign("secret", gotBody))) {
+		t.Fatalf("Expected the signature to match the body, got %q", gotSignature)
+	}
+}
+
+func TestWebhookNotifierRejectsErrorResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, "", time.Second)
+	if err := n.Notify(context.Background(), NewEvent("note.expired", "expired", nil)); err == nil {
+		t.Fatal("Expected an error for a 502 response")
+	}
+}
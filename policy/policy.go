@@ -0,0 +1,295 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/generator"
+)
+
+const (
+	RULE_MIN_LENGTH       = "min_length"
+	RULE_REQUIRE_LOWER    = "require_lower"
+	RULE_REQUIRE_UPPER    = "require_upper"
+	RULE_REQUIRE_DIGIT    = "require_digit"
+	RULE_REQUIRE_SYMBOL   = "require_symbol"
+	RULE_BANNED_SUBSTRING = "banned_substring"
+	RULE_BREACHED         = "breached"
+)
+
+// Policy is a set of rules a password must satisfy. The zero value allows every password.
+type Policy struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// lowercase substrings the password must not contain, compared case-insensitively
+	BannedSubstrings []string
+	// reject passwords found in the breach dataset
+	RejectBreached bool
+}
+
+// Violation is a rule a password breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// New returns the policy described by the config.
+func New(c config.PasswordPolicyConfig) Policy {
+	p := Policy{MinLength: c.MinLength, RejectBreached: c.RejectBreached}
+
+	for _, class := range c.Require {
+		switch class {
+		case config.PASSWORD_CLASS_LOWER:
+			p.RequireLower = true
+		case config.PASSWORD_CLASS_UPPER:
+			p.RequireUpper = true
+		case config.PASSWORD_CLASS_DIGIT:
+			p.RequireDigit = true
+		case config.PASSWORD_CLASS_SYMBOL:
+			p.RequireSymbol = true
+		}
+	}
+
+	for _, banned := range c.BannedSubstrings {
+		if banned = strings.ToLower(strings.TrimSpace(banned)); banned != "" {
+			p.BannedSubstrings = append(p.BannedSubstrings, banned)
+		}
+	}
+
+	return p
+}
+
+// Enabled reports whether the policy has any rules.
+func (p Policy) Enabled() bool {
+	return p.MinLength > 0 || p.RequireLower || p.RequireUpper || p.RequireDigit || p.RequireSymbol ||
+		len(p.BannedSubstrings) > 0 || p.RejectBreached
+}
+
+// Evaluate returns every rule the password breaks, empty if it satisfies the policy. breached
+// is only called when the policy rejects breached passwords, and its error is returned as is.
+func (p Policy) Evaluate(password string, breached func(password string) (bool, error)) ([]Violation, error) {
+	violations := []Violation{}
+
+	if length := len([]rune(password)); length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RULE_MIN_LENGTH,
+			Message: fmt.Sprintf("must be at least %d characters, got %d", p.MinLength, length),
+		})
+	}
+
+	for _, class := range p.classes(password) {
+		if class.required && !class.present {
+			violations = append(violations, Violation{Rule: class.rule, Message: "must contain " + class.name})
+		}
+	}
+
+	lowered := strings.ToLower(password)
+	for _, banned := range p.BannedSubstrings {
+		if strings.Contains(lowered, banned) {
+			// the banned word is configuration, not part of the secret, so it can be named
+			violations = append(violations, Violation{Rule: RULE_BANNED_SUBSTRING, Message: fmt.Sprintf("must not contain %q", banned)})
+		}
+	}
+
+	if p.RejectBreached {
+		found, err := breached(password)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			violations = append(violations, Violation{Rule: RULE_BREACHED, Message: "must not appear in a known data breach"})
+		}
+	}
+
+	return violations, nil
+}
+
+// Unsatisfiable returns the length and character class rules that no password of the length
+// drawn from the charset can satisfy, empty if a generated password can pass.
+func (p Policy) Unsatisfiable(charset string, length int) []Violation {
+	violations := []Violation{}
+
+	if length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RULE_MIN_LENGTH,
+			Message: fmt.Sprintf("length must be at least %d, got %d", p.MinLength, length),
+		})
+	}
+
+	required := 0
+	for _, class := range p.classes(charset) {
+		if !class.required {
+			continue
+		}
+		required++
+		if !class.present {
+			violations = append(violations, Violation{Rule: class.rule, Message: "charset must contain " + class.name})
+		}
+	}
+
+	if length < required {
+		violations = append(violations, Violation{
+			Rule:    RULE_MIN_LENGTH,
+			Message: fmt.Sprintf("length must be at least %d to fit every required class, got %d", required, length),
+		})
+	}
+
+	return violations
+}
+
+// characterClass is a character class rule and whether a string contains the class.
+type characterClass struct {
+	required bool
+	present  bool
+	rule     string
+	name     string
+}
+
+// classes returns the character class rules with whether s contains each class.
+func (p Policy) classes(s string) []characterClass {
+	var lower, upper, digit, symbol bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+
+	return []characterClass{
+		{p.RequireLower, lower, RULE_REQUIRE_LOWER, "a lowercase letter"},
+		{p.RequireUpper, upper, RULE_REQUIRE_UPPER, "an uppercase letter"},
+		{p.RequireDigit, digit, RULE_REQUIRE_DIGIT, "a digit"},
+		{p.RequireSymbol, symbol, RULE_REQUIRE_SYMBOL, "a symbol"},
+	}
+}
+
+// RequiredClasses returns, for each required character class, the characters of the charset
+// in the class. A class the charset lacks is returned empty, see Unsatisfiable.
+func (p Policy) RequiredClasses(charset string) []string {
+	var lower, upper, digit, symbol strings.Builder
+	for _, r := range charset {
+		switch {
+		case unicode.IsLower(r):
+			lower.WriteRune(r)
+		case unicode.IsUpper(r):
+			upper.WriteRune(r)
+		case unicode.IsDigit(r):
+			digit.WriteRune(r)
+		case !unicode.IsLetter(r):
+			symbol.WriteRune(r)
+		}
+	}
+
+	required := []string{}
+	for _, class := range []struct {
+		required bool
+		chars    string
+	}{
+		{p.RequireLower, lower.String()},
+		{p.RequireUpper, upper.String()},
+		{p.RequireDigit, digit.String()},
+		{p.RequireSymbol, symbol.String()},
+	} {
+		if class.required {
+			required = append(required, class.chars)
+		}
+	}
+
+	return required
+}
+
+// Options returns generator options adjusted so generated values meet the length and character
+// class rules by construction. Passwords are raised to the minimum length and include a
+// character of each required class. Passphrases get enough words for the minimum length, a
+// capitalization that keeps the required letter cases, a digit and a symbol separator as
+// required. Banned substrings and breached values can't be ruled out this way, so generated
+// values must still be evaluated.
+func (p Policy) Options(opts generator.Options) generator.Options {
+	if opts.Mode == generator.MODE_PASSPHRASE {
+		return p.passphraseOptions(opts)
+	}
+
+	opts.Length = max(opts.Length, p.MinLength)
+
+	required := []struct {
+		required bool
+		excluded *bool
+	}{
+		{p.RequireLower, &opts.ExcludeLower},
+		{p.RequireUpper, &opts.ExcludeUpper},
+		{p.RequireDigit, &opts.ExcludeDigits},
+		{p.RequireSymbol, &opts.ExcludeSymbols},
+	}
+
+	for _, class := range required {
+		if class.required {
+			*class.excluded = false
+			opts.RequireEachClass = true
+		}
+	}
+
+	return opts
+}
+
+func (p Policy) passphraseOptions(opts generator.Options) generator.Options {
+	switch {
+	// first letter capitals keep both cases, every word has at least three letters
+	case p.RequireUpper && (opts.Capitalize == "" || opts.Capitalize == generator.CAPITALIZE_NONE || opts.Capitalize == generator.CAPITALIZE_RANDOM):
+		opts.Capitalize = generator.CAPITALIZE_FIRST
+	case p.RequireLower && (opts.Capitalize == generator.CAPITALIZE_ALL || opts.Capitalize == generator.CAPITALIZE_RANDOM):
+		opts.Capitalize = generator.CAPITALIZE_FIRST
+	}
+
+	if p.RequireDigit {
+		opts.IncludeDigit = true
+	}
+
+	if opts.Words == 0 {
+		opts.Words = generator.DefaultWords
+	}
+
+	if p.RequireSymbol {
+		// only the separator can hold a symbol, so it needs one and at least two words
+		if strings.IndexFunc(opts.Separator, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) < 0 {
+			opts.Separator = generator.DefaultSeparator
+		}
+		opts.Words = max(opts.Words, 2)
+	}
+
+	separator := opts.Separator
+	if separator == "" {
+		separator = generator.DefaultSeparator
+	}
+
+	shortest := shortestWord()
+	for opts.Words < generator.MaxWords && opts.Words*shortest+(opts.Words-1)*len(separator) < p.MinLength {
+		opts.Words++
+	}
+
+	return opts
+}
+
+// shortestWord returns the length of the shortest word in the passphrase wordlist.
+var shortestWord = sync.OnceValue(func() int {
+	shortest := 0
+	for _, word := range generator.Words() {
+		if shortest == 0 || len(word) < shortest {
+			shortest = len(word)
+		}
+	}
+
+	return shortest
+})
@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oalexander6/web-app-template/config"
+	"github.com/oalexander6/web-app-template/generator"
+)
+
+func rules(violations []Violation) []string {
+	names := make([]string, len(violations))
+	for i, violation := range violations {
+		names[i] = violation.Rule
+	}
+	return names
+}
+
+func notBreached(string) (bool, error) {
+	return false, nil
+}
+
+func TestNewFromConfig(t *testing.T) {
+	p := New(config.PasswordPolicyConfig{
+		MinLength:        12,
+		Require:          []string{config.PASSWORD_CLASS_UPPER, config.PASSWORD_CLASS_SYMBOL},
+		BannedSubstrings: []string{" Acme ", ""},
+	})
+
+	if p.MinLength != 12 || !p.RequireUpper || !p.RequireSymbol || p.RequireLower || p.RequireDigit {
+		t.Fatalf("Unexpected policy %+v", p)
+	}
+
+	if len(p.BannedSubstrings) != 1 || p.BannedSubstrings[0] != "acme" {
+		t.Fatalf("Expected banned substrings [acme], got %q", p.BannedSubstrings)
+	}
+
+	if (Policy{}).Enabled() {
+		t.Fatal("Expected the zero policy to be disabled")
+	}
+}
+
+func TestEvaluateListsEveryViolation(t *testing.T) {
+	p := Policy{MinLength: 12, RequireUpper: true, RequireDigit: true, RequireSymbol: true, BannedSubstrings: []string{"acme"}}
+
+	violations, err := p.Evaluate("myacmepass", notBreached)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	want := []string{RULE_MIN_LENGTH, RULE_REQUIRE_UPPER, RULE_REQUIRE_DIGIT, RULE_REQUIRE_SYMBOL, RULE_BANNED_SUBSTRING}
+	got := rules(violations)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %q, got %q", want, got)
+		}
+	}
+
+	violations, err = p.Evaluate("Correct-Horse-7-Battery", notBreached)
+	if err != nil || len(violations) != 0 {
+		t.Fatalf("Expected no violations, got %v (%v)", violations, err)
+	}
+}
+
+func TestUnsatisfiable(t *testing.T) {
+	p := Policy{MinLength: 12, RequireUpper: true, RequireDigit: true}
+
+	got := rules(p.Unsatisfiable("0123456789abcdef", 8))
+	want := []string{RULE_MIN_LENGTH, RULE_REQUIRE_UPPER}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+
+	if violations := p.Unsatisfiable("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 16); len(violations) != 0 {
+		t.Fatalf("Expected an alphanumeric charset to be able to pass, got %v", violations)
+	}
+}
+
+func TestEvaluateBreached(t *testing.T) {
+	calls := 0
+	breached := func(string) (bool, error) {
+		calls++
+		return true, nil
+	}
+
+	if violations, _ := (Policy{}).Evaluate("password", breached); len(violations) != 0 || calls != 0 {
+		t.Fatalf("Expected the breach check to be skipped, got %v after %d calls", violations, calls)
+	}
+
+	violations, err := Policy{RejectBreached: true}.Evaluate("password", breached)
+	if err != nil || len(violations) != 1 || violations[0].Rule != RULE_BREACHED {
+		t.Fatalf("Expected a breached violation, got %v (%v)", violations, err)
+	}
+
+	unavailable := errors.New("unavailable")
+	if _, err := (Policy{RejectBreached: true}).Evaluate("password", func(string) (bool, error) { return false, unavailable }); !errors.Is(err, unavailable) {
+		t.Fatalf("Expected the breach check error, got %v", err)
+	}
+}
+
+func TestOptionsGenerateCompliantPasswords(t *testing.T) {
+	p := Policy{MinLength: 20, RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+	opts := p.Options(generator.Options{Length: 8, ExcludeDigits: true, ExcludeSymbols: true})
+
+	if opts.Length != 20 || opts.ExcludeDigits || opts.ExcludeSymbols || !opts.RequireEachClass {
+		t.Fatalf("Unexpected options %+v", opts)
+	}
+
+	for i := 0; i < 50; i++ {
+		result, err := generator.Generate(opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if violations, _ := p.Evaluate(result.Value, notBreached); len(violations) != 0 {
+			t.Fatalf("Expected %q to satisfy the policy, got %v", result.Value, violations)
+		}
+	}
+}
+
+func TestOptionsGenerateCompliantPassphrases(t *testing.T) {
+	p := Policy{MinLength: 40, RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+	opts := p.Options(generator.Options{Mode: generator.MODE_PASSPHRASE, Words: 1, Separator: "x", Capitalize: generator.CAPITALIZE_ALL})
+
+	for i := 0; i < 50; i++ {
+		result, err := generator.Generate(opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if violations, _ := p.Evaluate(result.Value, notBreached); len(violations) != 0 {
+			t.Fatalf("Expected %q to satisfy the policy, got %v", result.Value, violations)
+		}
+	}
+}
+
+func TestRequiredClasses(t *testing.T) {
+	p := Policy{RequireUpper: true, RequireSymbol: true}
+
+	if got := p.RequiredClasses("abcABC012!?"); len(got) != 2 || got[0] != "ABC" || got[1] != "!?" {
+		t.Fatalf("Expected the uppercase letters and symbols, got %q", got)
+	}
+
+	if got := p.RequiredClasses("0123456789abcdef"); len(got) != 2 || got[0] != "" || got[1] != "" {
+		t.Fatalf("Expected empty classes for a charset without them, got %q", got)
+	}
+}
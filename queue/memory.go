@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// MemoryStore is an in-process job queue with the same claiming and retry semantics as the
+// Postgres store. Jobs are lost on restart, so it is meant for tests and local tooling.
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*memoryJob
+}
+
+type memoryJob struct {
+	job         models.Job
+	runAt       time.Time
+	lockedUntil time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[int64]*memoryJob)}
+}
+
+// JobEnqueue queues a job.
+func (s *MemoryStore) JobEnqueue(ctx context.Context, params models.JobEnqueueParams) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	now := time.Now().UTC()
+
+	if params.RunAt.IsZero() {
+		params.RunAt = now
+	}
+
+	j := &memoryJob{
+		job: models.Job{
+			ID:          s.nextID,
+			Kind:        params.Kind,
+			Payload:     params.Payload,
+			Status:      models.JOB_STATUS_QUEUED,
+			MaxAttempts: max(params.MaxAttempts, 1),
+			RunAt:       params.RunAt.UTC().Format(time.RFC3339),
+			CreatedBy:   params.CreatedBy,
+			CreatedAt:   now.Format(time.RFC3339),
+			UpdatedAt:   now.Format(time.RFC3339),
+		},
+		runAt: params.RunAt,
+	}
+	s.jobs[j.job.ID] = j
+
+	return j.job, nil
+}
+
+// JobGetByID returns a job. Returns models.ErrNotFound if it doesn't exist.
+func (s *MemoryStore) JobGetByID(ctx context.Context, id int64) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return models.Job{}, models.ErrNotFound
+	}
+
+	return j.job, nil
+}
+
+// JobClaim implements Store.
+func (s *MemoryStore) JobClaim(ctx context.Context, kinds []string, lease time.Duration) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	var next *memoryJob
+	for _, j := range s.jobs {
+		if !slices.Contains(kinds, j.job.Kind) {
+			continue
+		}
+
+		due := (j.job.Status == models.JOB_STATUS_QUEUED && !j.runAt.After(now)) ||
+			(j.job.Status == models.JOB_STATUS_RUNNING && j.lockedUntil.Before(now))
+		if !due {
+			continue
+		}
+
+		if next == nil || j.runAt.Before(next.runAt) || (j.runAt.Equal(next.runAt) && j.job.ID < next.job.ID) {
+			next = j
+		}
+	}
+
+	if next == nil {
+		return models.Job{}, models.ErrNotFound
+	}
+
+	next.job.Status = models.JOB_STATUS_RUNNING
+	next.job.Attempts++
+	next.job.UpdatedAt = now.UTC().Format(time.RFC3339)
+	next.lockedUntil = now.Add(lease)
+
+	return next.job, nil
+}
+
+// JobProgress implements Store.
+func (s *MemoryStore) JobProgress(ctx context.Context, id int64, progress int, message string) error {
+	return s.update(id, func(j *memoryJob, now time.Time) {
+		if j.job.Status == models.JOB_STATUS_RUNNING {
+			j.job.Progress = progress
+			j.job.ProgressMessage = message
+		}
+	})
+}
+
+// JobComplete implements Store.
+func (s *MemoryStore) JobComplete(ctx context.Context, id int64, result string) error {
+	return s.update(id, func(j *memoryJob, now time.Time) {
+		j.job.Status = models.JOB_STATUS_SUCCEEDED
+		j.job.Progress = 100
+		j.job.Result = result
+		j.job.Payload = ""
+		j.job.FinishedAt = now.Format(time.RFC3339)
+	})
+}
+
+// JobFail implements Store.
+func (s *MemoryStore) JobFail(ctx context.Context, id int64, message string, retryAt time.Time) error {
+	return s.update(id, func(j *memoryJob, now time.Time) {
+		j.job.LastError = message
+		j.lockedUntil = time.Time{}
+
+		if retryAt.IsZero() {
+			j.job.Status = models.JOB_STATUS_DEAD
+			j.job.Payload = ""
+			j.job.FinishedAt = now.Format(time.RFC3339)
+			return
+		}
+
+		j.job.Status = models.JOB_STATUS_QUEUED
+		j.runAt = retryAt
+		j.job.RunAt = retryAt.UTC().Format(time.RFC3339)
+	})
+}
+
+func (s *MemoryStore) update(id int64, fn func(j *memoryJob, now time.Time)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return models.ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	fn(j, now)
+	j.job.UpdatedAt = now.Format(time.RFC3339)
+
+	return nil
+}
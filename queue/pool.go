@@ -0,0 +1,266 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/metrics"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+const (
+	defaultWorkers      = 2
+	defaultPollInterval = time.Second
+	defaultLease        = 15 * time.Minute
+	defaultBackoffBase  = 5 * time.Second
+	defaultBackoffMax   = time.Hour
+
+	// progress updates are written at most this often per job
+	progressInterval = time.Second
+)
+
+// Store is the job persistence used by the pool. *models.Models implements it.
+type Store interface {
+	JobClaim(ctx context.Context, kinds []string, lease time.Duration) (models.Job, error)
+	JobProgress(ctx context.Context, id int64, progress int, message string) error
+	JobComplete(ctx context.Context, id int64, result string) error
+	JobFail(ctx context.Context, id int64, message string, retryAt time.Time) error
+}
+
+// Handler runs a job and returns its result. progress may be called with a percentage and a
+// short message as the job advances.
+type Handler func(ctx context.Context, job models.Job, progress func(percent int, message string)) (string, error)
+
+// Options configures a Pool. Zero values use the defaults.
+type Options struct {
+	// number of jobs run concurrently, defaults to 2
+	Workers int
+	// how often idle workers check for due jobs, defaults to 1s
+	PollInterval time.Duration
+	// how long a job may run before it is cancelled and becomes claimable again, defaults to 15m
+	Lease time.Duration
+	// delay before the first retry, doubled on each further attempt up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// Pool runs queued jobs with a fixed number of workers.
+type Pool struct {
+	store    Store
+	opts     Options
+	handlers map[string]Handler
+	wg       sync.WaitGroup
+}
+
+// permanentError marks an error that retrying won't fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job moves to the dead state without further retries.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func NewPool(store Store, opts Options) *Pool {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultLease
+	}
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = defaultBackoffBase
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = defaultBackoffMax
+	}
+
+	return &Pool{
+		store:    store,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler for a job kind. Must be called before Start.
+func (p *Pool) Handle(kind string, handler Handler) {
+	p.handlers[kind] = handler
+}
+
+// Start runs the workers until ctx is cancelled. Jobs in progress have their context
+// cancelled and are retried later; use Wait to block until the workers have returned.
+func (p *Pool) Start(ctx context.Context) {
+	kinds := make([]string, 0, len(p.handlers))
+	for kind := range p.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	if len(kinds) == 0 {
+		return
+	}
+
+	for i := 0; i < p.opts.Workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work(ctx, kinds)
+		}()
+	}
+
+	logger.Log.Info().Int("workers", p.opts.Workers).Strs("kinds", kinds).Msg("Started job workers")
+}
+
+// Wait blocks until all workers have stopped after the Start context is cancelled.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context, kinds []string) {
+	for {
+		processed, err := p.processNext(ctx, kinds)
+		if err != nil && ctx.Err() == nil {
+			logger.Log.Error().Err(err).Msg("Failed to claim job")
+		}
+
+		// keep draining the queue while there is work, otherwise wait for the next poll
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.opts.PollInterval):
+		}
+	}
+}
+
+// processNext claims and runs one job. Returns false when no job was due.
+func (p *Pool) processNext(ctx context.Context, kinds []string) (bool, error) {
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	job, err := p.store.JobClaim(ctx, kinds, p.opts.Lease)
+	if errors.Is(err, models.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	p.run(ctx, job)
+
+	return true, nil
+}
+
+func (p *Pool) run(ctx context.Context, job models.Job) {
+	log := logger.Log.With().Int64("job_id", job.ID).Str("kind", job.Kind).Int("attempt", job.Attempts).Logger()
+
+	// a job whose lease expired after its last attempt was claimed once more than allowed
+	if job.Attempts > job.MaxAttempts {
+		p.fail(ctx, job, errors.New("lease expired on the final attempt"), true)
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, p.opts.Lease)
+	defer cancel()
+
+	start := time.Now()
+	result, err := p.call(runCtx, job)
+	duration := time.Since(start)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			// shutting down, leave the job to be claimed again once its lease expires
+			log.Warn().Err(err).Msg("Job interrupted by shutdown")
+			return
+		}
+
+		var permanent permanentError
+		p.fail(ctx, job, err, errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts)
+		log.Error().Err(err).Dur("duration", duration).Msg("Job failed")
+		return
+	}
+
+	if err := p.store.JobComplete(ctx, job.ID, result); err != nil {
+		log.Error().Err(err).Msg("Failed to mark job as succeeded")
+		return
+	}
+
+	metrics.QueueJobs.Add(job.Kind+"."+models.JOB_STATUS_SUCCEEDED, 1)
+	log.Info().Dur("duration", duration).Msg("Job succeeded")
+}
+
+// call runs the handler, converting a panic into an error.
+func (p *Pool) call(ctx context.Context, job models.Job) (result string, err error) {
+	handler, ok := p.handlers[job.Kind]
+	if !ok {
+		return "", Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	var lastReport time.Time
+	lastPercent := -1
+
+	progress := func(percent int, message string) {
+		if percent == lastPercent || (percent < 100 && time.Since(lastReport) < progressInterval) {
+			return
+		}
+		lastPercent, lastReport = percent, time.Now()
+
+		if err := p.store.JobProgress(ctx, job.ID, percent, message); err != nil {
+			logger.Log.Warn().Err(err).Int64("job_id", job.ID).Msg("Failed to record job progress")
+		}
+	}
+
+	return handler(ctx, job, progress)
+}
+
+// fail records the error and schedules a retry with exponential backoff and jitter, or moves
+// the job to the dead state.
+func (p *Pool) fail(ctx context.Context, job models.Job, jobErr error, dead bool) {
+	var retryAt time.Time
+	status := models.JOB_STATUS_DEAD
+
+	if !dead {
+		retryAt = time.Now().Add(p.backoff(job.Attempts))
+		status = models.JOB_STATUS_QUEUED
+	}
+
+	if err := p.store.JobFail(ctx, job.ID, jobErr.Error(), retryAt); err != nil {
+		logger.Log.Error().Err(err).Int64("job_id", job.ID).Msg("Failed to record job failure")
+		return
+	}
+
+	metrics.QueueJobs.Add(job.Kind+"."+status, 1)
+}
+
+// backoff returns the delay before retrying after the given attempt: BackoffBase doubled for
+// each previous attempt, capped at BackoffMax, plus up to 20% jitter.
+func (p *Pool) backoff(attempt int) time.Duration {
+	delay := p.opts.BackoffBase
+	for i := 1; i < attempt && delay < p.opts.BackoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.opts.BackoffMax)
+
+	return delay + rand.N(delay/5+1)
+}
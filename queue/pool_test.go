@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oalexander6/web-app-template/models"
+)
+
+func TestPoolCompletesJobWithProgress(t *testing.T) {
+	store := NewMemoryStore()
+	pool := NewPool(store, Options{})
+
+	pool.Handle("test.ok", func(ctx context.Context, job models.Job, progress func(int, string)) (string, error) {
+		progress(50, "halfway")
+		return "done:" + job.Payload, nil
+	})
+
+	job, _ := store.JobEnqueue(context.Background(), models.JobEnqueueParams{Kind: "test.ok", Payload: "p", MaxAttempts: 3})
+
+	if processed, err := pool.processNext(context.Background(), []string{"test.ok"}); !processed || err != nil {
+		t.Fatalf("Expected a job to be processed, got %v, %v", processed, err)
+	}
+
+	got, _ := store.JobGetByID(context.Background(), job.ID)
+	if got.Status != models.JOB_STATUS_SUCCEEDED || got.Result != "done:p" || got.Progress != 100 || got.Payload != "" {
+		t.Fatalf("Got an unexpected job: %+v", got)
+	}
+
+	if processed, _ := pool.processNext(context.Background(), []string{"test.ok"}); processed {
+		t.Fatal("Expected no more due jobs")
+	}
+}
+
+func TestPoolRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	store := NewMemoryStore()
+	pool := NewPool(store, Options{BackoffBase: time.Nanosecond, BackoffMax: time.Nanosecond})
+
+	attempts := 0
+	pool.Handle("test.fail", func(ctx context.Context, job models.Job, progress func(int, string)) (string, error) {
+		attempts++
+		return "", errors.New("boom")
+	})
+
+	job, _ := store.JobEnqueue(context.Background(), models.JobEnqueueParams{Kind: "test.fail", MaxAttempts: 3})
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond)
+		pool.processNext(context.Background(), []string{"test.fail"})
+	}
+
+	got, _ := store.JobGetByID(context.Background(), job.ID)
+	if attempts != 3 || got.Status != models.JOB_STATUS_DEAD || got.LastError != "boom" {
+		t.Fatalf("Expected 3 attempts and a dead job, got %d attempts and %+v", attempts, got)
+	}
+}
+
+func TestPoolPermanentErrorSkipsRetries(t *testing.T) {
+	store := NewMemoryStore()
+	pool := NewPool(store, Options{})
+
+	pool.Handle("test.permanent", func(ctx context.Context, job models.Job, progress func(int, string)) (string, error) {
+		return "", Permanent(errors.New("bad payload"))
+	})
+
+	job, _ := store.JobEnqueue(context.Background(), models.JobEnqueueParams{Kind: "test.permanent", MaxAttempts: 5})
+	pool.processNext(context.Background(), []string{"test.permanent"})
+
+	got, _ := store.JobGetByID(context.Background(), job.ID)
+	if got.Status != models.JOB_STATUS_DEAD || got.Attempts != 1 {
+		t.Fatalf("Expected the job to be dead after 1 attempt, got %+v", got)
+	}
+}
+
+func TestMemoryStoreReclaimsExpiredLease(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	job, _ := store.JobEnqueue(ctx, models.JobEnqueueParams{Kind: "test.lease", MaxAttempts: 2})
+
+	if _, err := store.JobClaim(ctx, []string{"test.lease"}, time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := store.JobClaim(ctx, []string{"test.lease"}, time.Hour); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected the leased job not to be claimable, got %v", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	reclaimed, err := store.JobClaim(ctx, []string{"test.lease"}, time.Hour)
+	if err != nil || reclaimed.ID != job.ID || reclaimed.Attempts != 2 {
+		t.Fatalf("Expected the job to be reclaimed on its second attempt, got %+v, %v", reclaimed, err)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	pool := NewPool(NewMemoryStore(), Options{BackoffBase: time.Second, BackoffMax: 10 * time.Second})
+
+	if d := pool.backoff(1); d < time.Second || d > 1200*time.Millisecond {
+		t.Fatalf("Expected about 1s for the first retry, got %s", d)
+	}
+
+	if d := pool.backoff(20); d < 10*time.Second || d > 12*time.Second {
+		t.Fatalf("Expected the delay to be capped near 10s, got %s", d)
+	}
+}
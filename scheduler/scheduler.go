@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/oalexander6/web-app-template/logger"
+	"github.com/oalexander6/web-app-template/metrics"
+)
+
+const (
+	defaultJobTimeout = 5 * time.Minute
+
+	OUTCOME_SUCCESS = "success"
+	OUTCOME_FAILURE = "failure"
+	OUTCOME_SKIPPED = "skipped"
+)
+
+// Job is a periodic task.
+type Job struct {
+	// unique name, also used as the lock key shared by all instances
+	Name string
+	// cron expression or descriptor, see Parse
+	Schedule string
+	// maximum run time, the job's context is cancelled after it, defaults to 5 minutes
+	Timeout time.Duration
+	// random delay of up to Jitter after each tick, spreading load from jobs on the same tick
+	Jitter time.Duration
+	Run    func(ctx context.Context) error
+}
+
+// Coordinator decides which instance runs a tick of a job.
+type Coordinator interface {
+	// Claim returns ok when this instance should run the job's tick. ok is false when another
+	// instance is running the job or has already run this tick. release must be called after
+	// the job finishes when ok is true.
+	Claim(ctx context.Context, job string, tick time.Time) (release func(), ok bool, err error)
+}
+
+type scheduledJob struct {
+	Job
+	schedule Schedule
+}
+
+// Scheduler runs registered jobs on their schedules until its context is cancelled.
+type Scheduler struct {
+	coordinator Coordinator
+	jobs        []scheduledJob
+	wg          sync.WaitGroup
+}
+
+// New creates a scheduler. When coordinator is nil jobs run on every tick without
+// coordinating with other instances.
+func New(coordinator Coordinator) *Scheduler {
+	if coordinator == nil {
+		coordinator = NewLocalCoordinator()
+	}
+
+	return &Scheduler{coordinator: coordinator}
+}
+
+// Register adds a job. Must be called before Start.
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("job name and run function are required")
+	}
+
+	for _, existing := range s.jobs {
+		if existing.Name == job.Name {
+			return fmt.Errorf("job %q is already registered", job.Name)
+		}
+	}
+
+	schedule, err := Parse(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %q: %w", job.Name, err)
+	}
+
+	if job.Timeout <= 0 {
+		job.Timeout = defaultJobTimeout
+	}
+
+	s.jobs = append(s.jobs, scheduledJob{Job: job, schedule: schedule})
+
+	return nil
+}
+
+// Start runs every registered job on its schedule until ctx is cancelled. Running jobs have
+// their context cancelled on shutdown; use Wait to block until they have returned.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job scheduledJob) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+
+		logger.Log.Info().Str("job", job.Name).Str("schedule", job.Schedule).Msg("Scheduled job")
+	}
+}
+
+// Wait blocks until all job loops have stopped after the Start context is cancelled.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job scheduledJob) {
+	for {
+		tick := job.schedule.Next(time.Now())
+		if tick.IsZero() {
+			logger.Log.Error().Str("job", job.Name).Msg("Job schedule never matches, not running it")
+			return
+		}
+
+		delay := time.Until(tick)
+		if job.Jitter > 0 {
+			delay += rand.N(job.Jitter)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.runOnce(ctx, job.Job, tick)
+	}
+}
+
+// runOnce claims the tick and runs the job with its timeout, recording the outcome.
+func (s *Scheduler) runOnce(ctx context.Context, job Job, tick time.Time) {
+	release, ok, err := s.coordinator.Claim(ctx, job.Name, tick)
+	if err != nil {
+		logger.Log.Error().Err(err).Str("job", job.Name).Msg("Failed to claim job")
+		metrics.SchedulerJobRuns.Add(job.Name+"."+OUTCOME_FAILURE, 1)
+		return
+	}
+
+	if !ok {
+		logger.Log.Debug().Str("job", job.Name).Time("tick", tick).Msg("Job claimed by another instance")
+		metrics.SchedulerJobRuns.Add(job.Name+"."+OUTCOME_SKIPPED, 1)
+		return
+	}
+	defer release()
+
+	runCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err = runJob(runCtx, job)
+	duration := time.Since(start)
+
+	metrics.SchedulerJobDurationSeconds.AddFloat(job.Name, duration.Seconds())
+
+	if err != nil {
+		logger.Log.Error().Err(err).Str("job", job.Name).Dur("duration", duration).Msg("Job failed")
+		metrics.SchedulerJobRuns.Add(job.Name+"."+OUTCOME_FAILURE, 1)
+		return
+	}
+
+	logger.Log.Info().Str("job", job.Name).Dur("duration", duration).Msg("Job finished")
+	metrics.SchedulerJobRuns.Add(job.Name+"."+OUTCOME_SUCCESS, 1)
+}
+
+// runJob calls the job, converting a panic into an error so one job can't stop the others.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return job.Run(ctx)
+}
+
+// LocalCoordinator coordinates jobs within a single process. Use it when the store can't
+// provide locks shared between instances.
+type LocalCoordinator struct {
+	mu       sync.Mutex
+	running  map[string]bool
+	lastTick map[string]time.Time
+}
+
+func NewLocalCoordinator() *LocalCoordinator {
+	return &LocalCoordinator{
+		running:  make(map[string]bool),
+		lastTick: make(map[string]time.Time),
+	}
+}
+
+// Claim implements Coordinator.
+func (c *LocalCoordinator) Claim(ctx context.Context, job string, tick time.Time) (func(), bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running[job] || !tick.After(c.lastTick[job]) {
+		return nil, false, nil
+	}
+
+	c.running[job] = true
+	c.lastTick[job] = tick
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.running, job)
+	}, true, nil
+}
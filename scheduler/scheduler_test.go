@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oalexander6/web-app-template/metrics"
+)
+
+func TestRunOnceClaimsEachTickOnce(t *testing.T) {
+	s := New(nil)
+	runs := 0
+
+	job := Job{Name: "test-claim", Timeout: time.Second, Run: func(ctx context.Context) error {
+		runs++
+		return nil
+	}}
+
+	tick := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	s.runOnce(context.Background(), job, tick)
+	s.runOnce(context.Background(), job, tick)
+	s.runOnce(context.Background(), job, tick.Add(time.Minute))
+
+	if runs != 2 {
+		t.Fatalf("Expected 2 runs, got %d", runs)
+	}
+
+	if got := metrics.SchedulerJobRuns.Get("test-claim." + OUTCOME_SKIPPED).String(); got != "1" {
+		t.Fatalf("Expected 1 skipped run, got %s", got)
+	}
+}
+
+func TestRunOnceAppliesTimeoutAndRecoversPanics(t *testing.T) {
+	s := New(nil)
+	tick := time.Now()
+
+	var runErr error
+	s.runOnce(context.Background(), Job{Name: "test-timeout", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		runErr = ctx.Err()
+		return runErr
+	}}, tick)
+
+	if !errors.Is(runErr, context.DeadlineExceeded) {
+		t.Fatalf("Expected the job context to time out, got %v", runErr)
+	}
+
+	s.runOnce(context.Background(), Job{Name: "test-panic", Timeout: time.Second, Run: func(ctx context.Context) error {
+		panic("boom")
+	}}, tick)
+
+	if got := metrics.SchedulerJobRuns.Get("test-panic." + OUTCOME_FAILURE).String(); got != "1" {
+		t.Fatalf("Expected the panic to be recorded as a failure, got %s", got)
+	}
+}
+
+func TestRegisterRejectsInvalidJobs(t *testing.T) {
+	s := New(nil)
+	run := func(ctx context.Context) error { return nil }
+
+	if err := s.Register(Job{Name: "a", Schedule: "@hourly", Run: run}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := s.Register(Job{Name: "a", Schedule: "@hourly", Run: run}); err == nil {
+		t.Fatal("Expected duplicate job name to be rejected")
+	}
+
+	if err := s.Register(Job{Name: "b", Schedule: "not a schedule", Run: run}); err == nil {
+		t.Fatal("Expected invalid schedule to be rejected")
+	}
+}
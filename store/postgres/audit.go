@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// auditLockID is the advisory lock key held while appending an audit event so concurrent
+// writers read and extend the same chain head.
+const auditLockID = 7_331_002
+
+type AuditEvent struct {
+	ID         int64              `db:"id"`
+	OccurredAt pgtype.Timestamptz `db:"occurred_at"`
+	Action     string             `db:"action"`
+	Outcome    string             `db:"outcome"`
+	ActorID    int64              `db:"actor_id"`
+	ActorName  string             `db:"actor_name"`
+	IP         string             `db:"ip"`
+	UserAgent  string             `db:"user_agent"`
+	RequestID  string             `db:"request_id"`
+	TargetType string             `db:"target_type"`
+	TargetID   int64              `db:"target_id"`
+	Details    string             `db:"details"`
+	PrevHash   string             `db:"prev_hash"`
+	Hash       string             `db:"hash"`
+}
+
+// AuditAppend implements models.Store.
+func (s PostgresStore) AuditAppend(ctx context.Context, event models.AuditEvent, hash func(prevHash string, event models.AuditEvent) string) (models.AuditEvent, error) {
+	occurredAt, err := time.Parse(time.RFC3339Nano, event.OccurredAt)
+	if err != nil {
+		return models.AuditEvent{}, fmt.Errorf("%w: invalid occurred_at: %s", models.ErrInvalidInput, err)
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.AuditEvent{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, auditLockID); err != nil {
+		return models.AuditEvent{}, err
+	}
+
+	var prevHash string
+	if err := tx.QueryRow(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1;`).Scan(&prevHash); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return models.AuditEvent{}, err
+	}
+
+	event.PrevHash = prevHash
+	event.Hash = hash(prevHash, event)
+
+	query := `INSERT INTO audit_events
+		(occurred_at, action, outcome, actor_id, actor_name, ip, user_agent, request_id, target_type, target_id, details, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id;`
+
+	if err := tx.QueryRow(ctx, query, occurredAt, event.Action, event.Outcome, event.ActorID, event.ActorName, event.IP, event.UserAgent,
+		event.RequestID, event.TargetType, event.TargetID, event.Details, event.PrevHash, event.Hash).Scan(&event.ID); err != nil {
+		return models.AuditEvent{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.AuditEvent{}, err
+	}
+
+	return event, nil
+}
+
+// AuditList implements models.Store.
+func (s PostgresStore) AuditList(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []any
+
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Action != "" {
+		where("action=$%d", filter.Action)
+	}
+	if filter.Outcome != "" {
+		where("outcome=$%d", filter.Outcome)
+	}
+	if filter.ActorID != 0 {
+		where("actor_id=$%d", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		where("target_type=$%d", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		where("target_id=$%d", filter.TargetID)
+	}
+	for _, bound := range []struct{ condition, value string }{{"occurred_at>=$%d", filter.Since}, {"occurred_at<=$%d", filter.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return []models.AuditEvent{}, fmt.Errorf("%w: invalid timestamp: %s", models.ErrInvalidInput, err)
+		}
+		where(bound.condition, ts)
+	}
+	if filter.AfterID != 0 {
+		where("id>$%d", filter.AfterID)
+	}
+	if filter.BeforeID != 0 {
+		where("id<$%d", filter.BeforeID)
+	}
+
+	query := `SELECT * FROM audit_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	if filter.Descending {
+		query += ` ORDER BY id DESC`
+	} else {
+		query += ` ORDER BY id ASC`
+	}
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := s.DB.Query(ctx, query+";", args...)
+	if err != nil {
+		return []models.AuditEvent{}, err
+	}
+
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByName[AuditEvent])
+	if err != nil {
+		return []models.AuditEvent{}, err
+	}
+
+	results := make([]models.AuditEvent, len(events))
+	for i, event := range events {
+		results[i] = auditEventToModel(event)
+	}
+
+	return results, nil
+}
+
+func auditEventToModel(event AuditEvent) models.AuditEvent {
+	return models.AuditEvent{
+		ID:         event.ID,
+		OccurredAt: event.OccurredAt.Time.UTC().Format(time.RFC3339Nano),
+		Action:     event.Action,
+		Outcome:    event.Outcome,
+		ActorID:    event.ActorID,
+		ActorName:  event.ActorName,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Details:    event.Details,
+		PrevHash:   event.PrevHash,
+		Hash:       event.Hash,
+	}
+}
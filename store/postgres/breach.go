@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// NoteSetCompromised implements models.Store.
+func (s PostgresStore) NoteSetCompromised(ctx context.Context, id int64, count int, at time.Time) error {
+	query := `UPDATE notes SET compromised_at=$1, breach_count=$2 WHERE id=$3;`
+
+	compromisedAt := timestamptz(at)
+	if count == 0 {
+		compromisedAt = timestamptz(time.Time{})
+	}
+
+	result, err := s.DB.Exec(ctx, query, compromisedAt, count, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
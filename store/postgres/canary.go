@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// CanaryGet implements models.Store.
+func (s PostgresStore) CanaryGet(ctx context.Context) (string, error) {
+	var value string
+
+	if err := s.DB.QueryRow(ctx, `SELECT value FROM encryption_canary WHERE id=1;`).Scan(&value); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", models.ErrNotFound
+		}
+		return "", err
+	}
+
+	return value, nil
+}
+
+// CanarySet implements models.Store.
+func (s PostgresStore) CanarySet(ctx context.Context, value string) error {
+	query := `INSERT INTO encryption_canary (id, value, updated_at) VALUES (1, $1, $2)
+		ON CONFLICT (id) DO UPDATE SET value=EXCLUDED.value, updated_at=EXCLUDED.updated_at;`
+
+	_, err := s.DB.Exec(ctx, query, value, time.Now().UTC())
+
+	return err
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -13,35 +14,51 @@ type PostgresStore struct {
 	DB *pgxpool.Pool
 }
 
-var schema = `
-CREATE TABLE IF NOT EXISTS notes (
-	id         BIGSERIAL PRIMARY KEY,
-	name       TEXT NOT NULL,
-	value      TEXT NOT NULL,
-	created_at TIMESTAMPTZ NOT NULL,
-	updated_at TIMESTAMPTZ NOT NULL,
-	deleted    BOOLEAN NOT NULL
-);
-`
-
+// New connects to Postgres and applies any pending migrations.
 func New(opts config.PostgresConfig) *PostgresStore {
+	s, err := Open(opts)
+	if err != nil {
+		logger.Log.Fatal().Msgf("%s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	applied, err := s.MigrateUp(ctx)
+	if err != nil {
+		logger.Log.Fatal().Msgf("Failed to apply migrations: %s", err)
+	}
+
+	for _, m := range applied {
+		logger.Log.Info().Int("version", m.Version).Str("name", m.Name).Msg("Applied migration")
+	}
+
+	return s
+}
+
+// Open connects to Postgres without touching the schema.
+func Open(opts config.PostgresConfig) (*PostgresStore, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
 	conn, err := pgxpool.New(ctx, opts.URI)
 	if err != nil {
-		logger.Log.Fatal().Msgf("Unable to create pgx connection pool: %s", err)
+		return nil, fmt.Errorf("unable to create pgx connection pool: %w", err)
 	}
 
 	if err = conn.Ping(ctx); err != nil {
-		logger.Log.Fatal().Msgf("Failed to ping postgres: %s", err)
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping postgres: %w", err)
 	}
 
-	conn.Exec(context.Background(), schema)
-
 	return &PostgresStore{
 		DB: conn,
-	}
+	}, nil
+}
+
+// Ping implements models.Store.
+func (s PostgresStore) Ping(ctx context.Context) error {
+	return s.DB.Ping(ctx)
 }
 
 func (s PostgresStore) Close() {
@@ -2,8 +2,10 @@ package postgres_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"testing"
 	"time"
 
@@ -78,8 +80,9 @@ func TestNew(t *testing.T) {
 
 func TestCreateNote(t *testing.T) {
 	srv := postgres.New(pgOpts)
+	vaultID := mustCreateVault(t, srv)
 
-	result, err := srv.NoteCreate(context.Background(), models.NoteCreateParams{Name: "Test Note 1", Value: "testval1"})
+	result, err := srv.NoteCreate(context.Background(), models.NoteWrite{VaultID: vaultID, Name: "Test Note 1", Value: "testval1"})
 	if err != nil {
 		t.Fatalf("Unexpected error: %s", err)
 	}
@@ -96,24 +99,25 @@ func TestCreateNote(t *testing.T) {
 		t.Fatal("Name or value did not match input")
 	}
 
-	query := `SELECT id, name, value, created_at, updated_at, deleted FROM notes WHERE id=$1;`
+	query := `SELECT id, name, value, created_at, updated_at, deleted_at FROM notes WHERE id=$1;`
 
 	var savedNote postgres.Note
 
 	if err := srv.DB.QueryRow(context.Background(), query, result.ID).
-		Scan(&savedNote.ID, &savedNote.Name, &savedNote.Value, &savedNote.CreatedAt, &savedNote.UpdatedAt, &savedNote.Deleted); err != nil {
+		Scan(&savedNote.ID, &savedNote.Name, &savedNote.Value, &savedNote.CreatedAt, &savedNote.UpdatedAt, &savedNote.DeletedAt); err != nil {
 		t.Fatalf("Unexpected error: %s", err)
 	}
 
-	if savedNote.ID != result.ID || savedNote.Name != "Test Note 1" || savedNote.Value != "testval1" || savedNote.Deleted.Bool {
+	if savedNote.ID != result.ID || savedNote.Name != "Test Note 1" || savedNote.Value != "testval1" || savedNote.DeletedAt.Valid {
 		t.Fatal("Saved record did not match expected")
 	}
 }
 
 func TestGetNoteByID(t *testing.T) {
 	srv := postgres.New(pgOpts)
+	vaultID := mustCreateVault(t, srv)
 
-	result, err := srv.NoteCreate(context.Background(), models.NoteCreateParams{Name: "Test Note 2", Value: "testval2"})
+	result, err := srv.NoteCreate(context.Background(), models.NoteWrite{VaultID: vaultID, Name: "Test Note 2", Value: "testval2"})
 	if err != nil {
 		t.Fatalf("Unexpected error: %s", err)
 	}
@@ -123,7 +127,850 @@ func TestGetNoteByID(t *testing.T) {
 		t.Fatalf("Unexpected error: %s", err)
 	}
 
-	if note.ID != result.ID || note.Name != "Test Note 2" || note.Value != "testval2" || note.Deleted {
+	if note.ID != result.ID || note.Name != "Test Note 2" || note.Value != "testval2" || note.DeletedAt != "" {
 		t.Fatal("Got an unexpected value")
 	}
 }
+
+func TestMigrationStatus(t *testing.T) {
+	srv := postgres.New(pgOpts)
+
+	statuses, err := srv.MigrationStatus(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Fatalf("Expected migration %d to be applied", status.Version)
+		}
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	srv := postgres.New(pgOpts)
+
+	result, err := srv.UserCreate(context.Background(), "testuser1", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	user, err := srv.UserGetByUsername(context.Background(), "testuser1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if user.ID != result.ID || user.PasswordHash != "hash" {
+		t.Fatal("Got an unexpected value")
+	}
+
+	if _, err := srv.UserCreate(context.Background(), "testuser1", "hash"); !errors.Is(err, models.ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists, got %v", err)
+	}
+}
+
+func TestAuditAppendChainsHashes(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+
+	hash := func(prevHash string, event models.AuditEvent) string {
+		return prevHash + "/" + event.Action
+	}
+
+	first, err := srv.AuditAppend(ctx, models.AuditEvent{OccurredAt: time.Now().UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano), Action: "a", Outcome: models.AUDIT_OUTCOME_SUCCESS}, hash)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	second, err := srv.AuditAppend(ctx, models.AuditEvent{OccurredAt: time.Now().UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano), Action: "b", Outcome: models.AUDIT_OUTCOME_SUCCESS}, hash)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if second.PrevHash != first.Hash || second.Hash != first.Hash+"/b" {
+		t.Fatalf("Expected second event to chain from the first, got %+v", second)
+	}
+
+	events, err := srv.AuditList(ctx, models.AuditFilter{Action: "b"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(events) != 1 || events[0].ID != second.ID || events[0].OccurredAt != second.OccurredAt {
+		t.Fatalf("Got an unexpected value: %+v", events)
+	}
+
+	if _, err := srv.DB.Exec(ctx, `UPDATE audit_events SET action='c' WHERE id=$1;`, first.ID); err == nil {
+		t.Fatal("Expected audit events to be append-only")
+	}
+}
+
+func TestNoteUpdateKeepsVersions(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Versioned", Value: "v1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, value := range []string{"v2", "v3", "v4"} {
+		if _, err := srv.NoteUpdate(ctx, note.ID, models.NoteWrite{Name: "Versioned", Value: value, Retain: 2}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	versions, err := srv.NoteGetVersions(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(versions) != 2 || versions[0].Value != "v3" || versions[1].Value != "v2" {
+		t.Fatalf("Expected the 2 newest versions, got %+v", versions)
+	}
+
+	if _, err := srv.NoteGetVersion(ctx, note.ID, 1); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected pruned version to be ErrNotFound, got %v", err)
+	}
+}
+
+func TestNoteFieldsKeptInVersions(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	fields := map[string]models.NoteField{
+		"username": {Value: "alice"},
+		"totp":     {Value: "ciphertext", Encrypted: true},
+	}
+
+	customFields := []models.CustomField{
+		{Name: "Account ID", Kind: models.CUSTOM_FIELD_TEXT, Value: "42"},
+		{Name: "API secret", Kind: models.CUSTOM_FIELD_HIDDEN, Value: "ciphertext"},
+	}
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Login", Value: "pw", Type: models.NOTE_TYPE_LOGIN, Fields: fields, CustomFields: customFields})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	updated, err := srv.NoteUpdate(ctx, note.ID, models.NoteWrite{Name: "Login", Value: "pw2", Fields: map[string]models.NoteField{"username": {Value: "bob"}}, Retain: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if updated.Type != models.NOTE_TYPE_LOGIN || len(updated.Fields) != 1 || updated.Fields["username"].Value != "bob" {
+		t.Fatalf("Expected the fields to be replaced, got %+v", updated)
+	}
+
+	version, err := srv.NoteGetVersion(ctx, note.ID, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if version.Fields["username"].Value != "alice" || !version.Fields["totp"].Encrypted {
+		t.Fatalf("Expected the replaced fields in the version, got %+v", version.Fields)
+	}
+
+	if len(version.CustomFields) != 2 || version.CustomFields[0].Name != "Account ID" || version.CustomFields[1].Kind != models.CUSTOM_FIELD_HIDDEN {
+		t.Fatalf("Expected the replaced custom fields in order in the version, got %+v", version.CustomFields)
+	}
+
+	if len(updated.CustomFields) != 0 {
+		t.Fatalf("Expected the custom fields to be cleared, got %+v", updated.CustomFields)
+	}
+}
+
+func TestNoteTrashRestoreAndPurge(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Trashed", Value: "val", DataKey: "wrapped"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if key, err := srv.NoteKeyCreate(ctx, note.ID, "other"); err != nil || key != "wrapped" {
+		t.Fatalf("Expected the note to keep the key it was created with, got %q, %v", key, err)
+	}
+
+	if err := srv.NoteDeleteByID(ctx, note.ID, 1); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := srv.NoteGetByID(ctx, note.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected deleted note to be ErrNotFound, got %v", err)
+	}
+
+	if err := srv.NoteRestore(ctx, note.ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := srv.NoteDeleteByID(ctx, note.ID, 1); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	purged, err := srv.NotePurgeDeletedBefore(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !slices.Contains(purged, note.ID) {
+		t.Fatalf("Expected note %d to be purged, got %v", note.ID, purged)
+	}
+
+	if err := srv.NoteRestore(ctx, note.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected purged note to be ErrNotFound, got %v", err)
+	}
+
+	if _, err := srv.NoteKeyGet(ctx, note.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected the purged note's data key to be destroyed, got %v", err)
+	}
+}
+
+func TestNoteExpiryDueAndReview(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	expiry := models.NoteExpiry{ExpiresAt: time.Now().Add(time.Hour), RotateEvery: 30 * 24 * time.Hour}
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Expiring", Value: "val", Expiry: expiry})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	containsNote := func(notes []models.Note) bool {
+		return slices.ContainsFunc(notes, func(n models.Note) bool { return n.ID == note.ID })
+	}
+
+	due, err := srv.NoteGetDueBefore(ctx, time.Now().Add(2*time.Hour), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !containsNote(due) {
+		t.Fatalf("Expected note %d to be due", note.ID)
+	}
+
+	reviewed, err := srv.NoteReview(ctx, note.ID, models.NoteExpiry{RotateEvery: expiry.RotateEvery})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if reviewed.ExpiresAt != "" || reviewed.ReviewedAt == "" || reviewed.RotateEvery != expiry.RotateEvery {
+		t.Fatalf("Expected the expiry to be cleared and the review recorded, got %+v", reviewed)
+	}
+
+	due, err = srv.NoteGetDueBefore(ctx, time.Now().Add(2*time.Hour), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if containsNote(due) {
+		t.Fatalf("Expected reviewed note %d not to be due", note.ID)
+	}
+
+	due, err = srv.NoteGetDueBefore(ctx, time.Now().Add(31*24*time.Hour), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !containsNote(due) {
+		t.Fatalf("Expected note %d to be due for rotation", note.ID)
+	}
+
+	if _, err := srv.NoteReminderGet(ctx, note.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected no reminder before one is saved, got %v", err)
+	}
+
+	reminder := models.NoteReminder{NoteID: note.ID, Status: models.NOTE_EXPIRY_STATUS_ROTATION_DUE, DueAt: "2030-01-02T00:00:00Z", RemindedAt: "2030-01-01T00:00:00Z"}
+	if err := srv.NoteReminderSave(ctx, reminder); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if got, err := srv.NoteReminderGet(ctx, note.ID); err != nil || got != reminder {
+		t.Fatalf("Expected the saved reminder %+v, got %+v, %v", reminder, got, err)
+	}
+}
+
+func TestNoteRotationPolicies(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	if _, err := srv.NoteRotationPolicySet(ctx, models.NoteRotationPolicy{NoteID: 1 << 40, Length: 16, Charset: models.NOTE_CHARSET_HEX,
+		Interval: time.Hour}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected a policy for a missing note to be ErrNotFound, got %v", err)
+	}
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Rotated", Value: "val"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	policy, err := srv.NoteRotationPolicySet(ctx, models.NoteRotationPolicy{NoteID: note.ID, Length: 16, Charset: models.NOTE_CHARSET_HEX,
+		Interval: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if policy.Interval != 24*time.Hour || policy.LastRotatedAt != "" || policy.NextRotationAt == "" {
+		t.Fatalf("Unexpected policy %+v", policy)
+	}
+
+	// the policy interval is the note's rotation interval
+	if got, err := srv.NoteGetByID(ctx, note.ID); err != nil || got.RotateEvery != 24*time.Hour {
+		t.Fatalf("Expected the note to rotate every 24h, got %+v, %v", got, err)
+	}
+
+	isDue := func(at time.Time) bool {
+		due, err := srv.NoteRotationPoliciesDue(ctx, at)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return slices.ContainsFunc(due, func(p models.NoteRotationPolicy) bool { return p.NoteID == note.ID })
+	}
+
+	if isDue(time.Now()) || !isDue(time.Now().Add(25*time.Hour)) {
+		t.Fatalf("Expected note %d to be due for rotation one interval from now", note.ID)
+	}
+
+	if err := srv.NoteRotationRecordDryRun(ctx, note.ID, time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := srv.NoteUpdate(ctx, note.ID, models.NoteWrite{Name: "Rotated", Value: "new", Retain: 10, Rotated: true}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	policy, err = srv.NoteRotationPolicyGet(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if policy.LastRotatedAt == "" || policy.LastDryRunAt == "" {
+		t.Fatalf("Expected the rotation and dry run to be recorded, got %+v", policy)
+	}
+
+	if err := srv.NoteRotationPolicyDelete(ctx, note.ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := srv.NoteRotationPolicyGet(ctx, note.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected deleted policy to be ErrNotFound, got %v", err)
+	}
+}
+
+func TestNoteAnalysisSaveAndGetAll(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Analyzed", Value: "val"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	analysis := models.NoteAnalysis{
+		NoteID:       note.ID,
+		Fingerprint:  "abc",
+		KeyID:        "key",
+		Score:        1,
+		GuessesLog10: 4.5,
+		Warnings:     []string{"Short values are easy to guess"},
+		ValueHash:    "hash",
+		AnalyzedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if err := srv.NoteAnalysisSave(ctx, analysis); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	analysis.Score = 4
+	analysis.Warnings = []string{}
+	if err := srv.NoteAnalysisSave(ctx, analysis); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	analyses, err := srv.NoteAnalysisGetAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	idx := slices.IndexFunc(analyses, func(a models.NoteAnalysis) bool { return a.NoteID == note.ID })
+	if idx < 0 {
+		t.Fatalf("Expected an analysis for note %d", note.ID)
+	}
+
+	if got := analyses[idx]; got.Score != 4 || len(got.Warnings) != 0 || got.ValueHash != "hash" {
+		t.Fatalf("Expected the analysis to be replaced, got %+v", got)
+	}
+
+	if err := srv.NoteAnalysisSave(ctx, models.NoteAnalysis{NoteID: 1 << 40, Warnings: []string{}, ValueHash: "hash"}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected an analysis for a missing note to be ErrNotFound, got %v", err)
+	}
+}
+
+func TestNoteSetCompromisedClearedOnUpdate(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Breached", Value: "val"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := srv.NoteSetCompromised(ctx, note.ID, 42, time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	compromised, err := srv.NoteGetByID(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if compromised.CompromisedAt == "" || compromised.BreachCount != 42 {
+		t.Fatalf("Expected the note to be marked compromised, got %+v", compromised)
+	}
+
+	updated, err := srv.NoteUpdate(ctx, note.ID, models.NoteWrite{Name: "Breached", Value: "new", Retain: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if updated.CompromisedAt != "" || updated.BreachCount != 0 {
+		t.Fatalf("Expected a new value to clear the mark, got %+v", updated)
+	}
+
+	if err := srv.NoteSetCompromised(ctx, 1<<40, 1, time.Now()); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected a missing note to be ErrNotFound, got %v", err)
+	}
+}
+
+func TestFoldersTagsAndFavorites(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	parent, err := srv.FolderCreate(ctx, models.FolderParams{VaultID: vaultID, Name: "Work"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	child, err := srv.FolderCreate(ctx, models.FolderParams{VaultID: vaultID, Name: "Servers", ParentID: parent.ID})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := srv.FolderCreate(ctx, models.FolderParams{VaultID: vaultID, Name: "servers", ParentID: parent.ID}); !errors.Is(err, models.ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists for a duplicate name, got %v", err)
+	}
+
+	if _, err := srv.FolderUpdate(ctx, parent.ID, models.FolderParams{Name: "Work", ParentID: child.ID}); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput when moving a folder into its subfolder, got %v", err)
+	}
+
+	user, err := srv.UserCreate(ctx, "favorites-user", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	other, err := srv.UserCreate(ctx, "favorites-other", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	inChild, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "db", Value: "v", FolderID: child.ID, Tags: []string{"prod", "SSH"}, Favorite: true, CreatedBy: user.ID})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	inParent, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "vpn", Value: "v", FolderID: parent.ID, Tags: []string{"prod"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tagged, err := srv.NoteList(ctx, models.NoteFilter{Tag: "ssh", UserID: user.ID})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(tagged) != 1 || tagged[0].ID != inChild.ID || len(tagged[0].Tags) != 2 || !tagged[0].Favorite {
+		t.Fatalf("Expected only the tagged favorite note, got %+v", tagged)
+	}
+
+	// favorites belong to the user who marked them
+	if favorites, err := srv.NoteList(ctx, models.NoteFilter{Favorite: true, UserID: other.ID}); err != nil || len(favorites) != 0 {
+		t.Fatalf("Expected no favorites for another user, got %+v, %v", favorites, err)
+	}
+
+	if err := srv.NoteSetFavorite(ctx, other.ID, inParent.ID, true); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := srv.NoteSetFavorite(ctx, other.ID, inParent.ID, true); err != nil {
+		t.Fatalf("Expected marking a favorite twice to succeed, got %v", err)
+	}
+
+	if ids, err := srv.NoteFavoriteIDs(ctx, other.ID); err != nil || len(ids) != 1 || ids[0] != inParent.ID {
+		t.Fatalf("Expected the other user's favorite, got %v, %v", ids, err)
+	}
+
+	if err := srv.NoteSetFavorite(ctx, other.ID, inParent.ID, false); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := srv.NoteSetFavorite(ctx, other.ID, -1, true); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a missing note, got %v", err)
+	}
+
+	if err := srv.NoteSetTags(ctx, inParent.ID, []string{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, trashed, err := srv.FolderDelete(ctx, child.ID, true, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(trashed) != 1 || trashed[0] != inChild.ID {
+		t.Fatalf("Expected the child folder's note to be trashed, got %v", trashed)
+	}
+
+	folders, moved, err := srv.FolderDelete(ctx, parent.ID, false, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if folders != 1 || len(moved) != 1 || moved[0] != inParent.ID {
+		t.Fatalf("Expected the parent folder's note to be moved out, got %d folders and %v", folders, moved)
+	}
+
+	root := int64(0)
+	notes, err := srv.NoteList(ctx, models.NoteFilter{FolderID: &root})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	found := false
+	for _, note := range notes {
+		if note.ID == inParent.ID {
+			found = note.FolderID == 0 && len(note.Tags) == 0
+		}
+	}
+
+	if !found {
+		t.Fatalf("Expected the moved note outside any folder without tags, got %+v", notes)
+	}
+}
+
+func TestNoteSearch(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	fields := map[string]models.NoteField{
+		"urls":     {Value: "https://search-example.com/login"},
+		"password": {Value: "zebracorn", Encrypted: true},
+	}
+
+	customFields := []models.CustomField{
+		{Name: "Recovery", Kind: models.CUSTOM_FIELD_HIDDEN, Value: "zebracorn"},
+	}
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Quokka Portal", Value: "zebracorn", Type: models.NOTE_TYPE_LOGIN, Tags: []string{"marsupial"}, Fields: fields, CustomFields: customFields})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, query := range []string{"quok", "marsupial", "search-example", "Quoka Portal"} {
+		matches, err := srv.NoteSearch(ctx, query, nil, []int64{vaultID}, 10)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(matches) == 0 || matches[0].Note.ID != note.ID || matches[0].Score <= 0 {
+			t.Errorf("Expected %q to find the note first, got %+v", query, matches)
+		}
+	}
+
+	matches, err := srv.NoteSearch(ctx, "zebracorn", nil, []int64{vaultID}, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(matches) != 0 {
+		t.Fatalf("Expected secrets not to be searchable, got %+v", matches)
+	}
+}
+
+func TestEncryptedNameIndex(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	index := models.NoteNameIndex{Encrypted: true, Exact: "exact-token", Lookup: []string{"prefix-token", "exact-token"}}
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "ciphertext", Value: "v", NameIndex: index})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !note.NameEncrypted {
+		t.Fatal("Expected the note name to be marked encrypted")
+	}
+
+	if _, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "other ciphertext", Value: "v", NameIndex: index}); !errors.Is(err, models.ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists for a duplicate name token, got %v", err)
+	}
+
+	notes, err := srv.NoteList(ctx, models.NoteFilter{Name: "anything", NameToken: "exact-token"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(notes) != 1 || notes[0].ID != note.ID {
+		t.Fatalf("Expected the exact token to find the note, got %+v", notes)
+	}
+
+	matches, err := srv.NoteSearch(ctx, "ciphertext", []string{"prefix-token"}, []int64{vaultID}, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(matches) != 1 || matches[0].Note.ID != note.ID {
+		t.Fatalf("Expected only the prefix token to match the encrypted name, got %+v", matches)
+	}
+}
+
+func TestNoteNamesUniquePerFolder(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+	vaultID := mustCreateVault(t, srv)
+
+	note, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Unique Name", Value: "v"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err = srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "unique name", Value: "v"})
+
+	var conflict *models.NameConflictError
+	if !errors.As(err, &conflict) || conflict.NoteID != note.ID || !errors.Is(err, models.ErrAlreadyExists) {
+		t.Fatalf("Expected a name conflict with note %d, got %v", note.ID, err)
+	}
+
+	folder, err := srv.FolderCreate(ctx, models.FolderParams{VaultID: vaultID, Name: "Unique Names"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	other, err := srv.NoteCreate(ctx, models.NoteWrite{VaultID: vaultID, Name: "Unique Name", Value: "v", FolderID: folder.ID})
+	if err != nil {
+		t.Fatalf("Expected the name to be free in another folder, got %s", err)
+	}
+
+	if err := srv.NoteSetFolder(ctx, other.ID, 0); !errors.As(err, &conflict) || conflict.NoteID != note.ID {
+		t.Fatalf("Expected moving the note to conflict with note %d, got %v", note.ID, err)
+	}
+
+	if err := srv.NoteDeleteByID(ctx, note.ID, 0); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := srv.NoteSetFolder(ctx, other.ID, 0); err != nil {
+		t.Fatalf("Expected the name of a deleted note to be free, got %s", err)
+	}
+
+	if err := srv.NoteRestore(ctx, note.ID); !errors.As(err, &conflict) || conflict.NoteID != other.ID {
+		t.Fatalf("Expected restoring the note to conflict with note %d, got %v", other.ID, err)
+	}
+}
+
+func TestOrganizationMembershipsAndInvitations(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+
+	owner, err := srv.UserCreate(ctx, "org-owner", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	invitee, err := srv.UserCreate(ctx, "org-invitee", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	organization, err := srv.OrganizationCreate(ctx, "Acme", owner.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	vault, err := srv.VaultCreate(ctx, organization.ID, "Shared")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := srv.VaultCreate(ctx, organization.ID, "shared"); !errors.Is(err, models.ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists, got %v", err)
+	}
+
+	retention := 3
+	updated, err := srv.VaultSetVersionRetention(ctx, vault.ID, &retention)
+	if err != nil || updated.VersionRetention == nil || *updated.VersionRetention != 3 {
+		t.Fatalf("Expected a retention of 3, got %v, %v", updated.VersionRetention, err)
+	}
+
+	if updated, err = srv.VaultSetVersionRetention(ctx, vault.ID, nil); err != nil || updated.VersionRetention != nil {
+		t.Fatalf("Expected the default retention, got %v, %v", updated.VersionRetention, err)
+	}
+
+	now := time.Now().UTC()
+	invitation, err := srv.InvitationCreate(ctx, models.Invitation{
+		OrganizationID: organization.ID,
+		VaultID:        vault.ID,
+		UserID:         invitee.ID,
+		Role:           models.ROLE_EDITOR,
+		InvitedBy:      owner.ID,
+		CreatedAt:      now.Format(time.RFC3339),
+		ExpiresAt:      now.Add(time.Hour).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if invitation.OrganizationName != "Acme" || invitation.Username != "org-invitee" {
+		t.Fatalf("Expected the invitation to include names, got %+v", invitation)
+	}
+
+	member, err := srv.InvitationAccept(ctx, invitation.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if member.UserID != invitee.ID || member.VaultID != vault.ID || member.Role != models.ROLE_EDITOR {
+		t.Fatalf("Expected a vault editor membership, got %+v", member)
+	}
+
+	roles, err := srv.MembershipRoles(ctx, invitee.ID, 0, vault.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !slices.Equal(roles, []string{models.ROLE_EDITOR}) {
+		t.Fatalf("Expected the invitee to edit the vault, got %v", roles)
+	}
+
+	roles, err = srv.MembershipRoles(ctx, invitee.ID, organization.ID, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(roles) != 0 {
+		t.Fatalf("Expected no organization wide role for the invitee, got %v", roles)
+	}
+
+	vaults, err := srv.VaultGetForUser(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(vaults) != 1 || vaults[0].ID != vault.ID || vaults[0].Role != models.ROLE_OWNER {
+		t.Fatalf("Expected the owner to own the vault, got %+v", vaults)
+	}
+
+	members, err := srv.MemberGetAll(ctx, organization.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(members) != 2 || members[0].UserID != owner.ID {
+		t.Fatalf("Expected the owner then the invitee, got %+v", members)
+	}
+
+	if _, err := srv.MemberSetRole(ctx, members[0].ID, models.ROLE_ADMIN); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("Expected the last owner not to be demoted, got %v", err)
+	}
+
+	if err := srv.MemberDelete(ctx, members[0].ID); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("Expected the last owner not to be removed, got %v", err)
+	}
+
+	if err := srv.MemberDelete(ctx, member.ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := srv.InvitationGetByID(ctx, invitation.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected the accepted invitation to be removed, got %v", err)
+	}
+}
+
+func TestPasswordPolicies(t *testing.T) {
+	srv := postgres.New(pgOpts)
+	ctx := context.Background()
+
+	vault, err := srv.VaultGetByID(ctx, mustCreateVault(t, srv))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := srv.PasswordPolicyGet(ctx, vault.OrganizationID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound before a policy is set, got %v", err)
+	}
+
+	saved, err := srv.PasswordPolicySet(ctx, models.PasswordPolicy{
+		OrganizationID:   vault.OrganizationID,
+		MinLength:        16,
+		Require:          []string{"upper", "digit"},
+		BannedSubstrings: []string{"acme"},
+		RejectBreached:   true,
+		UpdatedAt:        time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	got, err := srv.PasswordPolicyGet(ctx, vault.OrganizationID)
+	if err != nil || got.MinLength != 16 || len(got.Require) != 2 || len(got.BannedSubstrings) != 1 || !got.RejectBreached || got.UpdatedAt != saved.UpdatedAt {
+		t.Fatalf("Expected the saved policy, got %+v, %v", got, err)
+	}
+
+	if _, err := srv.PasswordPolicySet(ctx, models.PasswordPolicy{OrganizationID: -1, UpdatedAt: saved.UpdatedAt}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for an unknown organization, got %v", err)
+	}
+
+	if err := srv.PasswordPolicyDelete(ctx, vault.OrganizationID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := srv.PasswordPolicyDelete(ctx, vault.OrganizationID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a removed policy, got %v", err)
+	}
+}
+
+// mustCreateVault creates a vault owned by a new user for the test's notes and folders.
+func mustCreateVault(t *testing.T, srv *postgres.PostgresStore) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	owner, err := srv.UserCreate(ctx, "owner-"+t.Name(), "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	organization, err := srv.OrganizationCreate(ctx, t.Name(), owner.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	vault, err := srv.VaultCreate(ctx, organization.ID, "Test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return vault.ID
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// NoteGetDueBefore implements models.Store.
+func (s PostgresStore) NoteGetDueBefore(ctx context.Context, before time.Time, vaultIDs []int64) ([]models.Note, error) {
+	query := `SELECT * FROM notes WHERE deleted_at IS NULL AND ($2::bigint[] IS NULL OR vault_id = ANY($2)) AND (
+		(expires_at <= $1 AND (reviewed_at IS NULL OR reviewed_at < expires_at))
+		OR (rotate_every_seconds > 0 AND GREATEST(updated_at, reviewed_at) + rotate_every_seconds * INTERVAL '1 second' <= $1)
+	) ORDER BY id;`
+
+	rows, err := s.DB.Query(ctx, query, before.UTC(), vaultIDs)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Note])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	return notesToModel(notes), nil
+}
+
+// NoteReview implements models.Store.
+func (s PostgresStore) NoteReview(ctx context.Context, id int64, expiry models.NoteExpiry) (models.Note, error) {
+	query := `UPDATE notes SET reviewed_at=$1, expires_at=$2, rotate_every_seconds=$3
+		WHERE id=$4 AND deleted_at IS NULL RETURNING *;`
+
+	rows, err := s.DB.Query(ctx, query, time.Now().UTC(), timestamptz(expiry.ExpiresAt), int64(expiry.RotateEvery.Seconds()), id)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	note, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Note])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Note{}, models.ErrNotFound
+		}
+		return models.Note{}, err
+	}
+
+	notes := []Note{note}
+	if err := s.loadTags(ctx, notes); err != nil {
+		return models.Note{}, err
+	}
+
+	return noteToModel(notes[0]), nil
+}
+
+// NoteReminderGet implements models.Store.
+func (s PostgresStore) NoteReminderGet(ctx context.Context, noteID int64) (models.NoteReminder, error) {
+	query := `SELECT status, due_at, reminded_at FROM note_reminders WHERE note_id=$1;`
+
+	var status string
+	var dueAt, remindedAt pgtype.Timestamptz
+
+	if err := s.DB.QueryRow(ctx, query, noteID).Scan(&status, &dueAt, &remindedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.NoteReminder{}, models.ErrNotFound
+		}
+		return models.NoteReminder{}, err
+	}
+
+	return models.NoteReminder{
+		NoteID:     noteID,
+		Status:     status,
+		DueAt:      formatTimestamptz(dueAt),
+		RemindedAt: formatTimestamptz(remindedAt),
+	}, nil
+}
+
+// NoteReminderSave implements models.Store.
+func (s PostgresStore) NoteReminderSave(ctx context.Context, reminder models.NoteReminder) error {
+	query := `INSERT INTO note_reminders (note_id, status, due_at, reminded_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (note_id) DO UPDATE SET status=EXCLUDED.status, due_at=EXCLUDED.due_at, reminded_at=EXCLUDED.reminded_at;`
+
+	dueAt, err := time.Parse(time.RFC3339, reminder.DueAt)
+	if err != nil {
+		return err
+	}
+
+	remindedAt, err := time.Parse(time.RFC3339, reminder.RemindedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(ctx, query, reminder.NoteID, reminder.Status, dueAt.UTC(), remindedAt.UTC())
+
+	return err
+}
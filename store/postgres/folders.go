@@ -0,0 +1,444 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type Folder struct {
+	ID        int64              `db:"id"`
+	VaultID   int64              `db:"vault_id"`
+	Name      string             `db:"name"`
+	ParentID  pgtype.Int8        `db:"parent_id"`
+	NoteCount int                `db:"note_count"`
+	CreatedAt pgtype.Timestamptz `db:"created_at"`
+	UpdatedAt pgtype.Timestamptz `db:"updated_at"`
+}
+
+// folderSelect selects folders with the number of notes directly in them.
+const folderSelect = `SELECT f.id, f.vault_id, f.name, f.parent_id, f.created_at, f.updated_at,
+	(SELECT count(*) FROM notes n WHERE n.folder_id = f.id AND n.deleted_at IS NULL)::int AS note_count
+	FROM folders f`
+
+// folderSubtree is a recursive CTE of the IDs of folder $1 and every folder inside it.
+const folderSubtree = `WITH RECURSIVE subtree AS (
+	SELECT id FROM folders WHERE id=$1
+	UNION ALL
+	SELECT f.id FROM folders f JOIN subtree s ON f.parent_id = s.id
+)`
+
+// FolderGetAll implements models.Store.
+func (s PostgresStore) FolderGetAll(ctx context.Context) ([]models.Folder, error) {
+	rows, err := s.DB.Query(ctx, folderSelect+` ORDER BY lower(f.name), f.id;`)
+	if err != nil {
+		return []models.Folder{}, err
+	}
+
+	folders, err := pgx.CollectRows(rows, pgx.RowToStructByName[Folder])
+	if err != nil {
+		return []models.Folder{}, err
+	}
+
+	results := make([]models.Folder, len(folders))
+	for i := range folders {
+		results[i] = folderToModel(folders[i])
+	}
+
+	return results, nil
+}
+
+// FolderGetByID implements models.Store.
+func (s PostgresStore) FolderGetByID(ctx context.Context, id int64) (models.Folder, error) {
+	rows, err := s.DB.Query(ctx, folderSelect+` WHERE f.id=$1;`, id)
+	if err != nil {
+		return models.Folder{}, err
+	}
+
+	folder, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Folder])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Folder{}, models.ErrNotFound
+		}
+		return models.Folder{}, err
+	}
+
+	return folderToModel(folder), nil
+}
+
+// FolderCreate implements models.Store.
+func (s PostgresStore) FolderCreate(ctx context.Context, params models.FolderParams) (models.Folder, error) {
+	query := `INSERT INTO folders (vault_id, name, parent_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $4) RETURNING id;`
+
+	now := time.Now().UTC()
+
+	var id int64
+	if err := s.DB.QueryRow(ctx, query, params.VaultID, params.Name, nullableID(params.ParentID), now).Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return models.Folder{}, models.ErrAlreadyExists
+		}
+		return models.Folder{}, err
+	}
+
+	return models.Folder{
+		ID:        id,
+		VaultID:   params.VaultID,
+		Name:      params.Name,
+		ParentID:  params.ParentID,
+		CreatedAt: now.Format(time.RFC3339),
+		UpdatedAt: now.Format(time.RFC3339),
+	}, nil
+}
+
+// FolderUpdate implements models.Store. The folder is locked while checking the new parent
+// isn't inside it.
+func (s PostgresStore) FolderUpdate(ctx context.Context, id int64, params models.FolderParams) (models.Folder, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.Folder{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE folders IN SHARE ROW EXCLUSIVE MODE;`); err != nil {
+		return models.Folder{}, err
+	}
+
+	var cycle bool
+	err = tx.QueryRow(ctx, folderSubtree+` SELECT EXISTS (SELECT 1 FROM subtree WHERE id=$2);`, id, params.ParentID).Scan(&cycle)
+	if err != nil {
+		return models.Folder{}, err
+	}
+
+	if cycle {
+		return models.Folder{}, fmt.Errorf("%w: a folder can't be moved into one of its subfolders", models.ErrInvalidInput)
+	}
+
+	result, err := tx.Exec(ctx, `UPDATE folders SET name=$1, parent_id=$2, updated_at=$3 WHERE id=$4;`,
+		params.Name, nullableID(params.ParentID), time.Now().UTC(), id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Folder{}, models.ErrAlreadyExists
+		}
+		return models.Folder{}, err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.Folder{}, models.ErrNotFound
+	}
+
+	rows, err := tx.Query(ctx, folderSelect+` WHERE f.id=$1;`, id)
+	if err != nil {
+		return models.Folder{}, err
+	}
+
+	folder, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Folder])
+	if err != nil {
+		return models.Folder{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Folder{}, err
+	}
+
+	return folderToModel(folder), nil
+}
+
+// FolderDelete implements models.Store. Subfolders are removed by the cascading parent_id
+// foreign key and notes left in them are moved out of any folder by
+// the folder_id foreign key's ON DELETE SET NULL.
+func (s PostgresStore) FolderDelete(ctx context.Context, id int64, trashNotes bool, deletedBy int64) (int, []int64, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var folders int
+	if err := tx.QueryRow(ctx, folderSubtree+` SELECT count(*) FROM subtree;`, id).Scan(&folders); err != nil {
+		return 0, nil, err
+	}
+
+	if folders == 0 {
+		return 0, nil, models.ErrNotFound
+	}
+
+	query := folderSubtree + ` SELECT id FROM notes WHERE folder_id IN (SELECT id FROM subtree) AND deleted_at IS NULL ORDER BY id FOR UPDATE;`
+	if trashNotes {
+		query = folderSubtree + ` UPDATE notes SET deleted_at=$2, deleted_by=$3
+			WHERE folder_id IN (SELECT id FROM subtree) AND deleted_at IS NULL RETURNING id;`
+	}
+
+	args := []any{id}
+	if trashNotes {
+		args = append(args, time.Now().UTC(), deletedBy)
+	}
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	noteIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM folders WHERE id=$1;`, id); err != nil {
+		if isUniqueViolation(err) {
+			return 0, nil, s.subtreeNameConflict(ctx, id)
+		}
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, err
+	}
+
+	return folders, noteIDs, nil
+}
+
+// subtreeNameConflict returns a *models.NameConflictError naming a note outside any folder, or
+// another note in the subtree, that has the name of a note in the subtree of the folder.
+func (s PostgresStore) subtreeNameConflict(ctx context.Context, folderID int64) error {
+	query := folderSubtree + ` SELECT o.id FROM notes n
+		JOIN notes o ON o.id <> n.id AND o.deleted_at IS NULL AND o.vault_id = n.vault_id
+			AND (o.folder_id IS NULL OR o.folder_id IN (SELECT id FROM subtree)) AND ` + sameName + `
+		WHERE n.folder_id IN (SELECT id FROM subtree) AND n.deleted_at IS NULL
+		ORDER BY o.folder_id NULLS FIRST, o.id LIMIT 1;`
+
+	return s.findNameConflict(ctx, query, folderID)
+}
+
+// listedNote is a listed note with whether the listing user marked it as a favorite.
+type listedNote struct {
+	Note
+	Favorite bool `db:"favorite"`
+}
+
+// NoteList implements models.Store.
+func (s PostgresStore) NoteList(ctx context.Context, filter models.NoteFilter) ([]models.Note, error) {
+	query := `SELECT notes.*, EXISTS (SELECT 1 FROM note_favorites f WHERE f.note_id = notes.id AND f.user_id = $8) AS favorite
+		FROM notes WHERE deleted_at IS NULL
+		AND ($6::bigint = 0 OR vault_id = $6)
+		AND ($7::bigint[] IS NULL OR vault_id = ANY($7))
+		AND ($1::bigint IS NULL OR COALESCE(folder_id, 0) = $1)
+		AND ($2 = '' OR EXISTS (SELECT 1 FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			WHERE nt.note_id = notes.id AND lower(t.name) = lower($2)))
+		AND (NOT $3 OR EXISTS (SELECT 1 FROM note_favorites f WHERE f.note_id = notes.id AND f.user_id = $8))
+		AND ($4 = '' OR (NOT name_encrypted AND lower(name) = lower($4)) OR name_token = $5)
+		ORDER BY favorite DESC, id;`
+
+	rows, err := s.DB.Query(ctx, query, filter.FolderID, filter.Tag, filter.Favorite, strings.TrimSpace(filter.Name), filter.NameToken, filter.VaultID, filter.VaultIDs, filter.UserID)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	listed, err := pgx.CollectRows(rows, pgx.RowToStructByName[listedNote])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes := make([]Note, len(listed))
+	for i := range listed {
+		notes[i] = listed[i].Note
+	}
+
+	if err := s.loadTags(ctx, notes); err != nil {
+		return []models.Note{}, err
+	}
+
+	results := notesToModel(notes)
+	for i := range results {
+		results[i].Favorite = listed[i].Favorite
+	}
+
+	return results, nil
+}
+
+// NoteSetFolder implements models.Store.
+func (s PostgresStore) NoteSetFolder(ctx context.Context, noteID int64, folderID int64) error {
+	result, err := s.DB.Exec(ctx, `UPDATE notes SET folder_id=$1 WHERE id=$2 AND deleted_at IS NULL;`, nullableID(folderID), noteID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return s.movedNameConflict(ctx, noteID, pgtype.Int8{Int64: folderID, Valid: true})
+		}
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteSetFavorite implements models.Store.
+func (s PostgresStore) NoteSetFavorite(ctx context.Context, userID int64, noteID int64, favorite bool) error {
+	// both count the note rather than the changed rows, so repeating a change isn't an error
+	query := `WITH note AS (SELECT id FROM notes WHERE id = $2 AND deleted_at IS NULL),
+		changed AS (INSERT INTO note_favorites (user_id, note_id, created_at) SELECT $1, id, $3 FROM note ON CONFLICT DO NOTHING)
+		SELECT count(*) FROM note;`
+	args := []any{userID, noteID, time.Now().UTC()}
+
+	if !favorite {
+		query = `WITH note AS (SELECT id FROM notes WHERE id = $2 AND deleted_at IS NULL),
+			changed AS (DELETE FROM note_favorites WHERE user_id = $1 AND note_id IN (SELECT id FROM note))
+			SELECT count(*) FROM note;`
+		args = args[:2]
+	}
+
+	var found int
+	if err := s.DB.QueryRow(ctx, query, args...).Scan(&found); err != nil {
+		return err
+	}
+
+	if found != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteFavoriteIDs implements models.Store.
+func (s PostgresStore) NoteFavoriteIDs(ctx context.Context, userID int64) ([]int64, error) {
+	rows, err := s.DB.Query(ctx, `SELECT note_id FROM note_favorites WHERE user_id = $1 ORDER BY note_id;`, userID)
+	if err != nil {
+		return []int64{}, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// NoteSetTags implements models.Store.
+func (s PostgresStore) NoteSetTags(ctx context.Context, noteID int64, tags []string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id=$1 AND deleted_at IS NULL);`, noteID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return models.ErrNotFound
+	}
+
+	if err := setNoteTags(ctx, tx, noteID, tags); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// TagGetAll implements models.Store. Tags only on deleted notes are counted as unused.
+func (s PostgresStore) TagGetAll(ctx context.Context, vaultIDs []int64) ([]models.Tag, error) {
+	query := `SELECT t.name, count(n.id) FILTER (WHERE n.deleted_at IS NULL)::int FROM tags t
+		JOIN note_tags nt ON nt.tag_id = t.id
+		JOIN notes n ON n.id = nt.note_id AND n.vault_id = ANY($1)
+		GROUP BY t.id, t.name ORDER BY lower(t.name);`
+
+	if vaultIDs == nil {
+		vaultIDs = []int64{}
+	}
+
+	rows, err := s.DB.Query(ctx, query, vaultIDs)
+	if err != nil {
+		return []models.Tag{}, err
+	}
+
+	tags, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Tag, error) {
+		var tag models.Tag
+		err := row.Scan(&tag.Name, &tag.NoteCount)
+		return tag, err
+	})
+	if err != nil {
+		return []models.Tag{}, err
+	}
+
+	return tags, nil
+}
+
+// setNoteTags replaces the note's tags within the transaction, creating tags that don't exist
+// and removing tags no note uses anymore. The note's search text is rebuilt.
+func setNoteTags(ctx context.Context, tx pgx.Tx, noteID int64, tags []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM note_tags WHERE note_id=$1;`, noteID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		var tagID int64
+		err := tx.QueryRow(ctx, `INSERT INTO tags (name, created_at) VALUES ($1, $2)
+			ON CONFLICT ((lower(name))) DO UPDATE SET name=tags.name RETURNING id;`, tag, time.Now().UTC()).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `INSERT INTO note_tags (note_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`, noteID, tagID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM tags t WHERE NOT EXISTS (SELECT 1 FROM note_tags nt WHERE nt.tag_id = t.id);`); err != nil {
+		return err
+	}
+
+	return updateSearchText(ctx, tx, noteID)
+}
+
+// loadTags sets the tags of each note, sorted by name.
+func (s PostgresStore) loadTags(ctx context.Context, notes []Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(notes))
+	index := make(map[int64]int, len(notes))
+	for i, note := range notes {
+		ids[i] = note.ID
+		index[note.ID] = i
+	}
+
+	rows, err := s.DB.Query(ctx, `SELECT nt.note_id, t.name FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+		WHERE nt.note_id = ANY($1) ORDER BY lower(t.name);`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteID int64
+		var name string
+		if err := rows.Scan(&noteID, &name); err != nil {
+			return err
+		}
+		notes[index[noteID]].Tags = append(notes[index[noteID]].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// nullableID stores an ID of 0 as NULL.
+func nullableID(id int64) pgtype.Int8 {
+	return pgtype.Int8{Int64: id, Valid: id != 0}
+}
+
+func folderToModel(folder Folder) models.Folder {
+	return models.Folder{
+		ID:        folder.ID,
+		VaultID:   folder.VaultID,
+		Name:      folder.Name,
+		ParentID:  folder.ParentID.Int64,
+		NoteCount: folder.NoteCount,
+		CreatedAt: folder.CreatedAt.Time.Format(time.RFC3339),
+		UpdatedAt: folder.UpdatedAt.Time.Format(time.RFC3339),
+	}
+}
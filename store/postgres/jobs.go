@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type Job struct {
+	ID              int64              `db:"id"`
+	Kind            string             `db:"kind"`
+	Payload         string             `db:"payload"`
+	Status          string             `db:"status"`
+	Attempts        int                `db:"attempts"`
+	MaxAttempts     int                `db:"max_attempts"`
+	RunAt           pgtype.Timestamptz `db:"run_at"`
+	LockedUntil     pgtype.Timestamptz `db:"locked_until"`
+	Progress        int                `db:"progress"`
+	ProgressMessage string             `db:"progress_message"`
+	Result          string             `db:"result"`
+	LastError       string             `db:"last_error"`
+	CreatedBy       int64              `db:"created_by"`
+	CreatedAt       pgtype.Timestamptz `db:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `db:"updated_at"`
+	FinishedAt      pgtype.Timestamptz `db:"finished_at"`
+}
+
+// JobEnqueue implements models.Store.
+func (s PostgresStore) JobEnqueue(ctx context.Context, params models.JobEnqueueParams) (models.Job, error) {
+	query := `INSERT INTO jobs (kind, payload, status, max_attempts, run_at, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7) RETURNING *;`
+
+	rows, err := s.DB.Query(ctx, query, params.Kind, params.Payload, models.JOB_STATUS_QUEUED, params.MaxAttempts,
+		params.RunAt.UTC(), params.CreatedBy, time.Now().UTC())
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Job])
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	return jobToModel(job), nil
+}
+
+// JobGetByID implements models.Store.
+func (s PostgresStore) JobGetByID(ctx context.Context, id int64) (models.Job, error) {
+	rows, err := s.DB.Query(ctx, `SELECT * FROM jobs WHERE id=$1;`, id)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Job])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Job{}, models.ErrNotFound
+		}
+		return models.Job{}, err
+	}
+
+	return jobToModel(job), nil
+}
+
+// JobClaim implements models.Store. SKIP LOCKED lets concurrent workers each claim a
+// different job without waiting on each other.
+func (s PostgresStore) JobClaim(ctx context.Context, kinds []string, lease time.Duration) (models.Job, error) {
+	now := time.Now().UTC()
+
+	query := `UPDATE jobs SET status=$1, attempts=attempts+1, locked_until=$2, updated_at=$3
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ANY($4)
+				AND ((status=$5 AND run_at <= $3) OR (status=$1 AND locked_until < $3))
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *;`
+
+	rows, err := s.DB.Query(ctx, query, models.JOB_STATUS_RUNNING, now.Add(lease), now, kinds, models.JOB_STATUS_QUEUED)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Job])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Job{}, models.ErrNotFound
+		}
+		return models.Job{}, err
+	}
+
+	return jobToModel(job), nil
+}
+
+// JobProgress implements models.Store.
+func (s PostgresStore) JobProgress(ctx context.Context, id int64, progress int, message string) error {
+	query := `UPDATE jobs SET progress=$1, progress_message=$2, updated_at=$3 WHERE id=$4 AND status=$5;`
+
+	_, err := s.DB.Exec(ctx, query, progress, message, time.Now().UTC(), id, models.JOB_STATUS_RUNNING)
+
+	return err
+}
+
+// JobComplete implements models.Store. The payload is cleared once the job has finished.
+func (s PostgresStore) JobComplete(ctx context.Context, id int64, result string) error {
+	query := `UPDATE jobs SET status=$1, progress=100, result=$2, payload='', locked_until=NULL, updated_at=$3, finished_at=$3
+		WHERE id=$4;`
+
+	return execOne(ctx, s, query, models.JOB_STATUS_SUCCEEDED, result, time.Now().UTC(), id)
+}
+
+// JobFail implements models.Store. The payload of dead jobs is cleared.
+func (s PostgresStore) JobFail(ctx context.Context, id int64, message string, retryAt time.Time) error {
+	now := time.Now().UTC()
+
+	if retryAt.IsZero() {
+		query := `UPDATE jobs SET status=$1, last_error=$2, payload='', locked_until=NULL, updated_at=$3, finished_at=$3 WHERE id=$4;`
+		return execOne(ctx, s, query, models.JOB_STATUS_DEAD, message, now, id)
+	}
+
+	query := `UPDATE jobs SET status=$1, last_error=$2, run_at=$3, locked_until=NULL, updated_at=$4 WHERE id=$5;`
+
+	return execOne(ctx, s, query, models.JOB_STATUS_QUEUED, message, retryAt.UTC(), now, id)
+}
+
+// JobDeleteFinishedBefore implements models.Store.
+func (s PostgresStore) JobDeleteFinishedBefore(ctx context.Context, before time.Time) (int, error) {
+	query := `DELETE FROM jobs WHERE status IN ($1, $2) AND finished_at < $3;`
+
+	result, err := s.DB.Exec(ctx, query, models.JOB_STATUS_SUCCEEDED, models.JOB_STATUS_DEAD, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.RowsAffected()), nil
+}
+
+// execOne runs a statement expected to change exactly one row, returning ErrNotFound otherwise.
+func execOne(ctx context.Context, s PostgresStore, query string, args ...any) error {
+	result, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+func jobToModel(job Job) models.Job {
+	return models.Job{
+		ID:              job.ID,
+		Kind:            job.Kind,
+		Payload:         job.Payload,
+		Status:          job.Status,
+		Attempts:        job.Attempts,
+		MaxAttempts:     job.MaxAttempts,
+		RunAt:           formatTimestamptz(job.RunAt),
+		Progress:        job.Progress,
+		ProgressMessage: job.ProgressMessage,
+		Result:          job.Result,
+		LastError:       job.LastError,
+		CreatedBy:       job.CreatedBy,
+		CreatedAt:       formatTimestamptz(job.CreatedAt),
+		UpdatedAt:       formatTimestamptz(job.UpdatedAt),
+		FinishedAt:      formatTimestamptz(job.FinishedAt),
+	}
+}
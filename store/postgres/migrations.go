@@ -0,0 +1,693 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// migrationsLockID is the advisory lock key held while migrations run so concurrent
+// instances don't apply the same migration twice.
+const migrationsLockID = 7_331_001
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrations are applied in order. Never edit a released migration, add a new one instead.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create_notes",
+		Up: `
+CREATE TABLE IF NOT EXISTS notes (
+	id         BIGSERIAL PRIMARY KEY,
+	name       TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	deleted    BOOLEAN NOT NULL
+);`,
+		Down: `DROP TABLE IF EXISTS notes;`,
+	},
+	{
+		Version: 2,
+		Name:    "create_users",
+		Up: `
+CREATE TABLE IF NOT EXISTS users (
+	id            BIGSERIAL PRIMARY KEY,
+	username      TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL
+);`,
+		Down: `DROP TABLE IF EXISTS users;`,
+	},
+	{
+		Version: 3,
+		Name:    "create_encryption_canary",
+		Up: `
+CREATE TABLE IF NOT EXISTS encryption_canary (
+	id         INTEGER PRIMARY KEY CHECK (id = 1),
+	value      TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);`,
+		Down: `DROP TABLE IF EXISTS encryption_canary;`,
+	},
+	{
+		Version: 4,
+		Name:    "add_notes_quarantined_at",
+		Up:      `ALTER TABLE notes ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMPTZ;`,
+		Down:    `ALTER TABLE notes DROP COLUMN IF EXISTS quarantined_at;`,
+	},
+	{
+		Version: 5,
+		Name:    "create_audit_events",
+		Up: `
+CREATE TABLE IF NOT EXISTS audit_events (
+	id          BIGSERIAL PRIMARY KEY,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	action      TEXT NOT NULL,
+	outcome     TEXT NOT NULL,
+	actor_id    BIGINT NOT NULL DEFAULT 0,
+	actor_name  TEXT NOT NULL DEFAULT '',
+	ip          TEXT NOT NULL DEFAULT '',
+	user_agent  TEXT NOT NULL DEFAULT '',
+	request_id  TEXT NOT NULL DEFAULT '',
+	target_type TEXT NOT NULL DEFAULT '',
+	target_id   BIGINT NOT NULL DEFAULT 0,
+	details     TEXT NOT NULL DEFAULT '',
+	prev_hash   TEXT NOT NULL,
+	hash        TEXT NOT NULL UNIQUE
+);
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at_idx ON audit_events (occurred_at);
+CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events (target_type, target_id);
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();`,
+		Down: `
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();`,
+	},
+	{
+		Version: 6,
+		Name:    "create_note_versions",
+		Up: `
+CREATE TABLE IF NOT EXISTS note_versions (
+	id          BIGSERIAL PRIMARY KEY,
+	note_id     BIGINT NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+	version     INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	value       TEXT NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	replaced_at TIMESTAMPTZ NOT NULL,
+	replaced_by BIGINT NOT NULL DEFAULT 0,
+	UNIQUE (note_id, version)
+);`,
+		Down: `DROP TABLE IF EXISTS note_versions;`,
+	},
+	{
+		Version: 7,
+		Name:    "replace_notes_deleted_with_deleted_at",
+		Up: `
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS deleted_by BIGINT NOT NULL DEFAULT 0;
+UPDATE notes SET deleted_at = updated_at WHERE deleted;
+ALTER TABLE notes DROP COLUMN IF EXISTS deleted;
+CREATE INDEX IF NOT EXISTS notes_deleted_at_idx ON notes (deleted_at) WHERE deleted_at IS NOT NULL;`,
+		Down: `
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS deleted BOOLEAN NOT NULL DEFAULT false;
+UPDATE notes SET deleted = true WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS notes_deleted_at_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE notes DROP COLUMN IF EXISTS deleted_by;`,
+	},
+	{
+		Version: 8,
+		Name:    "create_scheduler_runs",
+		Up: `
+CREATE TABLE IF NOT EXISTS scheduler_runs (
+	job        TEXT PRIMARY KEY,
+	last_tick  TIMESTAMPTZ NOT NULL,
+	claimed_at TIMESTAMPTZ NOT NULL
+);`,
+		Down: `DROP TABLE IF EXISTS scheduler_runs;`,
+	},
+	{
+		Version: 9,
+		Name:    "create_jobs",
+		Up: `
+CREATE TABLE IF NOT EXISTS jobs (
+	id               BIGSERIAL PRIMARY KEY,
+	kind             TEXT NOT NULL,
+	payload          TEXT NOT NULL DEFAULT '',
+	status           TEXT NOT NULL,
+	attempts         INTEGER NOT NULL DEFAULT 0,
+	max_attempts     INTEGER NOT NULL,
+	run_at           TIMESTAMPTZ NOT NULL,
+	locked_until     TIMESTAMPTZ,
+	progress         INTEGER NOT NULL DEFAULT 0,
+	progress_message TEXT NOT NULL DEFAULT '',
+	result           TEXT NOT NULL DEFAULT '',
+	last_error       TEXT NOT NULL DEFAULT '',
+	created_by       BIGINT NOT NULL DEFAULT 0,
+	created_at       TIMESTAMPTZ NOT NULL,
+	updated_at       TIMESTAMPTZ NOT NULL,
+	finished_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS jobs_claim_idx ON jobs (run_at, id) WHERE status IN ('queued', 'running');`,
+		Down: `DROP TABLE IF EXISTS jobs;`,
+	},
+	{
+		Version: 10,
+		Name:    "add_note_expiry",
+		Up: `
+ALTER TABLE notes
+	ADD COLUMN IF NOT EXISTS expires_at           TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS rotate_every_seconds BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS reviewed_at          TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS notes_expires_at_idx ON notes (expires_at) WHERE expires_at IS NOT NULL AND deleted_at IS NULL;`,
+		Down: `
+DROP INDEX IF EXISTS notes_expires_at_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS expires_at, DROP COLUMN IF EXISTS rotate_every_seconds, DROP COLUMN IF EXISTS reviewed_at;`,
+	},
+	{
+		Version: 11,
+		Name:    "create_note_rotation_policies",
+		Up: `
+CREATE TABLE IF NOT EXISTS note_rotation_policies (
+	note_id          BIGINT PRIMARY KEY REFERENCES notes (id) ON DELETE CASCADE,
+	length           INTEGER NOT NULL,
+	charset          TEXT NOT NULL,
+	interval_seconds BIGINT NOT NULL,
+	next_rotation_at TIMESTAMPTZ NOT NULL,
+	last_rotated_at  TIMESTAMPTZ,
+	created_at       TIMESTAMPTZ NOT NULL,
+	updated_at       TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS note_rotation_policies_next_idx ON note_rotation_policies (next_rotation_at);`,
+		Down: `DROP TABLE IF EXISTS note_rotation_policies;`,
+	},
+	{
+		Version: 12,
+		Name:    "create_note_analyses",
+		Up: `
+CREATE TABLE IF NOT EXISTS note_analyses (
+	note_id         BIGINT PRIMARY KEY REFERENCES notes (id) ON DELETE CASCADE,
+	fingerprint     TEXT NOT NULL,
+	key_id          TEXT NOT NULL,
+	score           INTEGER NOT NULL,
+	guesses_log10   DOUBLE PRECISION NOT NULL,
+	warnings        TEXT[] NOT NULL,
+	note_updated_at TIMESTAMPTZ NOT NULL,
+	analyzed_at     TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS note_analyses_fingerprint_idx ON note_analyses (fingerprint);`,
+		Down: `DROP TABLE IF EXISTS note_analyses;`,
+	},
+	{
+		Version: 13,
+		Name:    "add_note_compromised",
+		Up: `
+ALTER TABLE notes
+	ADD COLUMN IF NOT EXISTS compromised_at TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS breach_count   INTEGER NOT NULL DEFAULT 0;`,
+		Down: `ALTER TABLE notes DROP COLUMN IF EXISTS compromised_at, DROP COLUMN IF EXISTS breach_count;`,
+	},
+	{
+		Version: 14,
+		Name:    "add_note_type",
+		Up:      `ALTER TABLE notes ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'secure_note';`,
+		Down:    `ALTER TABLE notes DROP COLUMN IF EXISTS type;`,
+	},
+	{
+		Version: 15,
+		Name:    "add_note_fields",
+		Up: `
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS fields JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE note_versions ADD COLUMN IF NOT EXISTS fields JSONB NOT NULL DEFAULT '{}';`,
+		Down: `
+ALTER TABLE notes DROP COLUMN IF EXISTS fields;
+ALTER TABLE note_versions DROP COLUMN IF EXISTS fields;`,
+	},
+	{
+		Version: 16,
+		Name:    "add_note_custom_fields",
+		Up: `
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE note_versions ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '[]';`,
+		Down: `
+ALTER TABLE notes DROP COLUMN IF EXISTS custom_fields;
+ALTER TABLE note_versions DROP COLUMN IF EXISTS custom_fields;`,
+	},
+	{
+		Version: 17,
+		Name:    "add_folders_tags_favorites",
+		Up: `
+CREATE TABLE IF NOT EXISTS folders (
+	id         BIGSERIAL PRIMARY KEY,
+	name       TEXT NOT NULL,
+	parent_id  BIGINT REFERENCES folders(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS folders_parent_name_idx ON folders (COALESCE(parent_id, 0), lower(name));
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS folder_id BIGINT REFERENCES folders(id) ON DELETE SET NULL;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS favorite BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS notes_folder_id_idx ON notes (folder_id);
+CREATE TABLE IF NOT EXISTS tags (
+	id         BIGSERIAL PRIMARY KEY,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS tags_name_idx ON tags (lower(name));
+CREATE TABLE IF NOT EXISTS note_tags (
+	note_id BIGINT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	tag_id  BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (note_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS note_tags_tag_id_idx ON note_tags (tag_id);`,
+		Down: `
+DROP TABLE IF EXISTS note_tags;
+DROP TABLE IF EXISTS tags;
+ALTER TABLE notes DROP COLUMN IF EXISTS favorite;
+ALTER TABLE notes DROP COLUMN IF EXISTS folder_id;
+DROP TABLE IF EXISTS folders;`,
+	},
+	{
+		Version: 18,
+		Name:    "add_note_search",
+		Up: `
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_text TEXT NOT NULL DEFAULT '';
+UPDATE notes n SET search_text = concat_ws(' ', n.name,
+	(SELECT string_agg(t.name, ' ') FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE nt.note_id = n.id),
+	(SELECT string_agg(f.value->>'value', ' ') FROM jsonb_each(n.fields) f WHERE NOT COALESCE((f.value->>'encrypted')::boolean, false)),
+	(SELECT string_agg(c->>'value', ' ') FROM jsonb_array_elements(n.custom_fields) c WHERE c->>'kind' IN ('text', 'url')));
+CREATE INDEX IF NOT EXISTS notes_search_fts_idx ON notes USING GIN (to_tsvector('simple', search_text));
+CREATE INDEX IF NOT EXISTS notes_search_trgm_idx ON notes USING GIN (search_text gin_trgm_ops);`,
+		Down: `
+DROP INDEX IF EXISTS notes_search_trgm_idx;
+DROP INDEX IF EXISTS notes_search_fts_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS search_text;`,
+	},
+	{
+		Version: 19,
+		Name:    "add_note_name_index",
+		Up: `
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS name_encrypted BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS name_token TEXT;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS name_index TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE note_versions ADD COLUMN IF NOT EXISTS name_encrypted BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS notes_name_index_idx ON notes USING GIN (name_index);
+CREATE UNIQUE INDEX IF NOT EXISTS notes_name_token_idx ON notes (COALESCE(folder_id, 0), name_token)
+	WHERE deleted_at IS NULL AND name_token IS NOT NULL;`,
+		Down: `
+DROP INDEX IF EXISTS notes_name_token_idx;
+DROP INDEX IF EXISTS notes_name_index_idx;
+ALTER TABLE note_versions DROP COLUMN IF EXISTS name_encrypted;
+ALTER TABLE notes DROP COLUMN IF EXISTS name_index;
+ALTER TABLE notes DROP COLUMN IF EXISTS name_token;
+ALTER TABLE notes DROP COLUMN IF EXISTS name_encrypted;`,
+	},
+	{
+		Version: 20,
+		Name:    "add_note_name_unique",
+		// notes have no owner, so names are unique per folder across the instance; existing
+		// duplicates other than the oldest are renamed "<name> (<id>)"
+		Up: `
+UPDATE notes n SET name = n.name || ' (' || n.id || ')',
+	search_text = n.name || ' (' || n.id || ')' || substr(n.search_text, length(n.name) + 1)
+FROM (SELECT id, row_number() OVER (PARTITION BY COALESCE(folder_id, 0), lower(name) ORDER BY id) AS rn
+	FROM notes WHERE deleted_at IS NULL AND NOT name_encrypted) d
+WHERE n.id = d.id AND d.rn > 1;
+CREATE UNIQUE INDEX IF NOT EXISTS notes_name_unique_idx ON notes (COALESCE(folder_id, 0), lower(name))
+	WHERE deleted_at IS NULL AND NOT name_encrypted;`,
+		Down: `
+DROP INDEX IF EXISTS notes_name_unique_idx;`,
+	},
+	{
+		Version: 21,
+		Name:    "add_organizations",
+		// existing notes and folders move to a "Default" vault of a "Default" organization owned
+		// by every existing user, so everyone keeps the access they had; names become unique per
+		// folder of a vault
+		Up: `
+CREATE TABLE IF NOT EXISTS organizations (
+	id         BIGSERIAL PRIMARY KEY,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS vaults (
+	id              BIGSERIAL PRIMARY KEY,
+	organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	name            TEXT NOT NULL,
+	created_at      TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS vaults_organization_name_idx ON vaults (organization_id, lower(name));
+CREATE TABLE IF NOT EXISTS memberships (
+	id              BIGSERIAL PRIMARY KEY,
+	organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	vault_id        BIGINT REFERENCES vaults(id) ON DELETE CASCADE,
+	user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role            TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'editor', 'viewer')),
+	created_at      TIMESTAMPTZ NOT NULL,
+	updated_at      TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS memberships_scope_idx ON memberships (organization_id, COALESCE(vault_id, 0), user_id);
+CREATE INDEX IF NOT EXISTS memberships_user_id_idx ON memberships (user_id);
+CREATE TABLE IF NOT EXISTS invitations (
+	id              BIGSERIAL PRIMARY KEY,
+	organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	vault_id        BIGINT REFERENCES vaults(id) ON DELETE CASCADE,
+	user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role            TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'editor', 'viewer')),
+	invited_by      BIGINT NOT NULL DEFAULT 0,
+	created_at      TIMESTAMPTZ NOT NULL,
+	expires_at      TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS invitations_scope_idx ON invitations (organization_id, COALESCE(vault_id, 0), user_id);
+INSERT INTO organizations (name, created_at) SELECT 'Default', now()
+	WHERE NOT EXISTS (SELECT 1 FROM organizations)
+	AND (EXISTS (SELECT 1 FROM users) OR EXISTS (SELECT 1 FROM notes) OR EXISTS (SELECT 1 FROM folders));
+INSERT INTO vaults (organization_id, name, created_at) SELECT id, 'Default', now() FROM organizations
+	WHERE NOT EXISTS (SELECT 1 FROM vaults);
+INSERT INTO memberships (organization_id, user_id, role, created_at, updated_at)
+	SELECT o.id, u.id, 'owner', now(), now() FROM organizations o, users u WHERE true ON CONFLICT DO NOTHING;
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS vault_id BIGINT REFERENCES vaults(id);
+UPDATE notes SET vault_id = (SELECT min(id) FROM vaults) WHERE vault_id IS NULL;
+ALTER TABLE notes ALTER COLUMN vault_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS notes_vault_id_idx ON notes (vault_id);
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS vault_id BIGINT REFERENCES vaults(id);
+UPDATE folders SET vault_id = (SELECT min(id) FROM vaults) WHERE vault_id IS NULL;
+ALTER TABLE folders ALTER COLUMN vault_id SET NOT NULL;
+DROP INDEX IF EXISTS folders_parent_name_idx;
+CREATE UNIQUE INDEX folders_parent_name_idx ON folders (vault_id, COALESCE(parent_id, 0), lower(name));
+DROP INDEX IF EXISTS notes_name_token_idx;
+CREATE UNIQUE INDEX notes_name_token_idx ON notes (vault_id, COALESCE(folder_id, 0), name_token)
+	WHERE deleted_at IS NULL AND name_token IS NOT NULL;
+DROP INDEX IF EXISTS notes_name_unique_idx;
+CREATE UNIQUE INDEX notes_name_unique_idx ON notes (vault_id, COALESCE(folder_id, 0), lower(name))
+	WHERE deleted_at IS NULL AND NOT name_encrypted;`,
+		Down: `
+DROP INDEX IF EXISTS notes_name_unique_idx;
+CREATE UNIQUE INDEX notes_name_unique_idx ON notes (COALESCE(folder_id, 0), lower(name))
+	WHERE deleted_at IS NULL AND NOT name_encrypted;
+DROP INDEX IF EXISTS notes_name_token_idx;
+CREATE UNIQUE INDEX notes_name_token_idx ON notes (COALESCE(folder_id, 0), name_token)
+	WHERE deleted_at IS NULL AND name_token IS NOT NULL;
+DROP INDEX IF EXISTS folders_parent_name_idx;
+CREATE UNIQUE INDEX folders_parent_name_idx ON folders (COALESCE(parent_id, 0), lower(name));
+ALTER TABLE folders DROP COLUMN IF EXISTS vault_id;
+DROP INDEX IF EXISTS notes_vault_id_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS vault_id;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS vaults;
+DROP TABLE IF EXISTS organizations;`,
+	},
+	{
+		Version: 22,
+		Name:    "add_vault_version_retention",
+		// NULL keeps the instance wide NOTE_VERSION_RETENTION
+		Up: `
+ALTER TABLE vaults ADD COLUMN IF NOT EXISTS version_retention INTEGER CHECK (version_retention >= 0);`,
+		Down: `
+ALTER TABLE vaults DROP COLUMN IF EXISTS version_retention;`,
+	},
+	{
+		Version: 23,
+		Name:    "trim_name_index_short_prefixes",
+		// name_index holds the prefix tokens from one character up, then the exact token; drop
+		// the one and two character prefixes, which are no longer indexed. The tokens can't be
+		// recomputed without the index key, so this isn't reverted.
+		Up: `
+UPDATE notes SET name_index = name_index[least(cardinality(name_index) - 1, 2) + 1:]
+	WHERE name_encrypted AND cardinality(name_index) > 1;`,
+		Down: `SELECT 1;`,
+	},
+	{
+		Version: 24,
+		Name:    "create_note_keys",
+		// notes written before this keep using the master key until they are updated
+		Up: `
+CREATE TABLE IF NOT EXISTS note_keys (
+	note_id BIGINT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+	key TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`,
+		Down: `
+DROP TABLE IF EXISTS note_keys;`,
+	},
+	{
+		Version: 25,
+		Name:    "create_note_reminders",
+		Up: `
+CREATE TABLE IF NOT EXISTS note_reminders (
+	note_id BIGINT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+	status TEXT NOT NULL,
+	due_at TIMESTAMPTZ NOT NULL,
+	reminded_at TIMESTAMPTZ NOT NULL
+);`,
+		Down: `
+DROP TABLE IF EXISTS note_reminders;`,
+	},
+	{
+		Version: 26,
+		Name:    "rotate_policies_on_note_interval",
+		// the policy interval moves to the note's rotate_every and the schedule is derived from
+		// it, so the two can't disagree
+		Up: `
+UPDATE notes n SET rotate_every_seconds = p.interval_seconds FROM note_rotation_policies p WHERE p.note_id = n.id;
+DROP INDEX IF EXISTS note_rotation_policies_next_idx;
+ALTER TABLE note_rotation_policies DROP COLUMN IF EXISTS interval_seconds, DROP COLUMN IF EXISTS next_rotation_at,
+	ADD COLUMN IF NOT EXISTS last_dry_run_at TIMESTAMPTZ;`,
+		Down: `
+ALTER TABLE note_rotation_policies ADD COLUMN IF NOT EXISTS interval_seconds BIGINT,
+	ADD COLUMN IF NOT EXISTS next_rotation_at TIMESTAMPTZ, DROP COLUMN IF EXISTS last_dry_run_at;
+UPDATE note_rotation_policies p SET interval_seconds = n.rotate_every_seconds,
+	next_rotation_at = GREATEST(n.updated_at, n.reviewed_at, p.updated_at) + n.rotate_every_seconds * INTERVAL '1 second'
+	FROM notes n WHERE n.id = p.note_id;
+ALTER TABLE note_rotation_policies ALTER COLUMN interval_seconds SET NOT NULL, ALTER COLUMN next_rotation_at SET NOT NULL;
+CREATE INDEX IF NOT EXISTS note_rotation_policies_next_idx ON note_rotation_policies (next_rotation_at);`,
+	},
+	{
+		Version: 27,
+		Name:    "key_note_analyses_on_value_hash",
+		// existing analyses get an empty hash and are recomputed by the next report
+		Up: `
+ALTER TABLE note_analyses ADD COLUMN IF NOT EXISTS value_hash TEXT NOT NULL DEFAULT '',
+	DROP COLUMN IF EXISTS note_updated_at;`,
+		Down: `
+ALTER TABLE note_analyses ADD COLUMN IF NOT EXISTS note_updated_at TIMESTAMPTZ NOT NULL DEFAULT 'epoch',
+	DROP COLUMN IF EXISTS value_hash;`,
+	},
+	{
+		Version: 28,
+		Name:    "create_password_policies",
+		// organizations without a row use the instance default from the environment
+		Up: `
+CREATE TABLE IF NOT EXISTS password_policies (
+	organization_id BIGINT PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+	min_length INTEGER NOT NULL DEFAULT 0,
+	require TEXT[] NOT NULL DEFAULT '{}',
+	banned_substrings TEXT[] NOT NULL DEFAULT '{}',
+	reject_breached BOOLEAN NOT NULL DEFAULT false,
+	updated_at TIMESTAMPTZ NOT NULL
+);`,
+		Down: `
+DROP TABLE IF EXISTS password_policies;`,
+	},
+	{
+		Version: 29,
+		Name:    "move_favorites_to_users",
+		// a shared favorite becomes a favorite of every member who can read the note
+		Up: `
+CREATE TABLE IF NOT EXISTS note_favorites (
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	note_id BIGINT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (user_id, note_id)
+);
+CREATE INDEX IF NOT EXISTS note_favorites_note_id_idx ON note_favorites (note_id);
+INSERT INTO note_favorites (user_id, note_id, created_at)
+	SELECT DISTINCT m.user_id, n.id, now() FROM notes n
+	JOIN vaults v ON v.id = n.vault_id
+	JOIN memberships m ON m.organization_id = v.organization_id AND (m.vault_id IS NULL OR m.vault_id = n.vault_id)
+	WHERE n.favorite ON CONFLICT DO NOTHING;
+ALTER TABLE notes DROP COLUMN IF EXISTS favorite;`,
+		Down: `
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS favorite BOOLEAN NOT NULL DEFAULT false;
+UPDATE notes n SET favorite = true WHERE EXISTS (SELECT 1 FROM note_favorites f WHERE f.note_id = n.id);
+DROP TABLE IF EXISTS note_favorites;`,
+	},
+}
+
+var migrationsTableSchema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL
+);
+`
+
+// MigrateUp implements models.Store.
+func (s PostgresStore) MigrateUp(ctx context.Context) ([]models.MigrationStatus, error) {
+	var applied []models.MigrationStatus
+
+	err := s.withMigrationLock(ctx, func(tx pgx.Tx, current map[int]time.Time) error {
+		for _, m := range migrations {
+			if _, ok := current[m.Version]; ok {
+				continue
+			}
+
+			if _, err := tx.Exec(ctx, m.Up); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+
+			appliedAt := time.Now().UTC()
+			if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3);`, m.Version, m.Name, appliedAt); err != nil {
+				return err
+			}
+
+			applied = append(applied, migrationToModel(m, true, appliedAt))
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// MigrateDown implements models.Store.
+func (s PostgresStore) MigrateDown(ctx context.Context, steps int) ([]models.MigrationStatus, error) {
+	var reverted []models.MigrationStatus
+
+	err := s.withMigrationLock(ctx, func(tx pgx.Tx, current map[int]time.Time) error {
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := current[m.Version]; !ok {
+				continue
+			}
+
+			if _, err := tx.Exec(ctx, m.Down); err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+
+			if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version=$1;`, m.Version); err != nil {
+				return err
+			}
+
+			reverted = append(reverted, migrationToModel(m, false, time.Time{}))
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// MigrationStatus implements models.Store.
+func (s PostgresStore) MigrationStatus(ctx context.Context) ([]models.MigrationStatus, error) {
+	if _, err := s.DB.Exec(ctx, migrationsTableSchema); err != nil {
+		return nil, err
+	}
+
+	current, err := appliedMigrations(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		appliedAt, ok := current[m.Version]
+		results[i] = migrationToModel(m, ok, appliedAt)
+	}
+
+	return results, nil
+}
+
+// withMigrationLock runs fn in a transaction holding the migrations advisory lock.
+func (s PostgresStore) withMigrationLock(ctx context.Context, fn func(tx pgx.Tx, current map[int]time.Time) error) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, migrationsLockID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, migrationsTableSchema); err != nil {
+		return err
+	}
+
+	current, err := appliedMigrations(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx, current); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// appliedMigrations returns the applied migration versions and when they were applied.
+func appliedMigrations(ctx context.Context, q querier) (map[int]time.Time, error) {
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	current := make(map[int]time.Time)
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		current[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+func migrationToModel(m migration, applied bool, appliedAt time.Time) models.MigrationStatus {
+	status := models.MigrationStatus{
+		Version: m.Version,
+		Name:    m.Name,
+		Applied: applied,
+	}
+
+	if applied {
+		status.AppliedAt = appliedAt.UTC().Format(time.RFC3339)
+	}
+
+	return status
+}
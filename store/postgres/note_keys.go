@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// NoteKeyGet implements models.Store.
+func (s PostgresStore) NoteKeyGet(ctx context.Context, noteID int64) (string, error) {
+	var key string
+
+	if err := s.DB.QueryRow(ctx, `SELECT key FROM note_keys WHERE note_id=$1;`, noteID).Scan(&key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", models.ErrNotFound
+		}
+		return "", err
+	}
+
+	return key, nil
+}
+
+// NoteKeyCreate implements models.Store.
+func (s PostgresStore) NoteKeyCreate(ctx context.Context, noteID int64, key string) (string, error) {
+	query := `INSERT INTO note_keys (note_id, key, created_at) VALUES ($1, $2, $3) ON CONFLICT (note_id) DO NOTHING;`
+
+	if _, err := s.DB.Exec(ctx, query, noteID, key, time.Now().UTC()); err != nil {
+		if isForeignKeyViolation(err) {
+			return "", models.ErrNotFound
+		}
+		return "", err
+	}
+
+	return s.NoteKeyGet(ctx, noteID)
+}
+
+// NoteKeySet implements models.Store.
+func (s PostgresStore) NoteKeySet(ctx context.Context, noteID int64, key string) error {
+	result, err := s.DB.Exec(ctx, `UPDATE note_keys SET key=$1 WHERE note_id=$2;`, key, noteID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// insertNoteKey saves the data key of a note created in the transaction.
+func insertNoteKey(ctx context.Context, tx pgx.Tx, noteID int64, key string) error {
+	if key == "" {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `INSERT INTO note_keys (note_id, key, created_at) VALUES ($1, $2, $3);`, noteID, key, time.Now().UTC())
+
+	return err
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -11,39 +12,113 @@ import (
 )
 
 type Note struct {
-	ID        int64              `db:"id"`
-	Name      string             `db:"name"`
-	Value     string             `db:"value"`
-	CreatedAt pgtype.Timestamptz `db:"created_at"`
-	UpdatedAt pgtype.Timestamptz `db:"updated_at"`
-	Deleted   pgtype.Bool        `db:"deleted"`
+	ID            int64              `db:"id"`
+	Name          string             `db:"name"`
+	Value         string             `db:"value"`
+	CreatedAt     pgtype.Timestamptz `db:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `db:"updated_at"`
+	QuarantinedAt pgtype.Timestamptz `db:"quarantined_at"`
+	DeletedAt     pgtype.Timestamptz `db:"deleted_at"`
+	DeletedBy     int64              `db:"deleted_by"`
+	ExpiresAt     pgtype.Timestamptz `db:"expires_at"`
+	RotateEvery   int64              `db:"rotate_every_seconds"`
+	ReviewedAt    pgtype.Timestamptz `db:"reviewed_at"`
+	CompromisedAt pgtype.Timestamptz `db:"compromised_at"`
+	BreachCount   int                `db:"breach_count"`
+	Type          string             `db:"type"`
+	// field name to value, fields other than listed ones are encrypted
+	Fields map[string]models.NoteField `db:"fields"`
+	// ordered user defined fields, hidden values are encrypted
+	CustomFields []models.CustomField `db:"custom_fields"`
+	VaultID      int64                `db:"vault_id"`
+	FolderID     pgtype.Int8          `db:"folder_id"`
+	// plaintext searched by NoteSearch, see updateSearchText
+	SearchText string `db:"search_text"`
+	// blind index of an encrypted name, see models.NoteNameIndex
+	NameEncrypted bool        `db:"name_encrypted"`
+	NameToken     pgtype.Text `db:"name_token"`
+	NameIndex     []string    `db:"name_index"`
+	// loaded from note_tags by loadTags
+	Tags []string `db:"-"`
 }
 
-// NoteCreate implements models.Store.
-func (s PostgresStore) NoteCreate(ctx context.Context, noteInput models.NoteCreateParams) (models.Note, error) {
-	query := `INSERT INTO notes (name, value, created_at, updated_at, deleted) VALUES ($1, $2, $3, $4, $5) RETURNING id;`
+// NoteCreate implements models.Store. The note and its tags are inserted in a single transaction.
+func (s PostgresStore) NoteCreate(ctx context.Context, write models.NoteWrite) (models.Note, error) {
+	query := `INSERT INTO notes (name, value, created_at, updated_at, expires_at, rotate_every_seconds, type, fields, custom_fields, folder_id,
+		name_encrypted, name_token, name_index, vault_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id;`
+
+	fields := write.Fields
+	if fields == nil {
+		fields = map[string]models.NoteField{}
+	}
+
+	customFields := write.CustomFields
+	if customFields == nil {
+		customFields = []models.CustomField{}
+	}
 
 	currTime := time.Now().UTC().Format(time.RFC3339)
+	expiresAt := timestamptz(write.Expiry.ExpiresAt)
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback(ctx)
 
 	var insertedID int64
-	if err := s.DB.QueryRow(ctx, query, noteInput.Name, noteInput.Value, currTime, currTime, false).Scan(&insertedID); err != nil {
+	if err := tx.QueryRow(ctx, query, write.Name, write.Value, currTime, currTime, expiresAt, int64(write.Expiry.RotateEvery.Seconds()), write.Type, fields, customFields, nullableID(write.FolderID),
+		write.NameIndex.Encrypted, nameToken(write.NameIndex), nameLookup(write.NameIndex), write.VaultID).Scan(&insertedID); err != nil {
+		if isUniqueViolation(err) {
+			return models.Note{}, s.nameConflict(ctx, 0, write.VaultID, write.FolderID, write.Name, write.NameIndex)
+		}
+		return models.Note{}, err
+	}
+
+	if err := setNoteTags(ctx, tx, insertedID, write.Tags); err != nil {
+		return models.Note{}, err
+	}
+
+	if err := insertNoteKey(ctx, tx, insertedID, write.DataKey); err != nil {
+		return models.Note{}, err
+	}
+
+	favorite := write.Favorite && write.CreatedBy != 0
+	if favorite {
+		if _, err := tx.Exec(ctx, `INSERT INTO note_favorites (user_id, note_id, created_at) VALUES ($1, $2, $3);`, write.CreatedBy, insertedID, currTime); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		return models.Note{}, err
 	}
 
 	return models.Note{
-		ID:        insertedID,
-		Name:      noteInput.Name,
-		Value:     noteInput.Value,
-		CreatedAt: currTime,
-		UpdatedAt: currTime,
+		ID:            insertedID,
+		Name:          write.Name,
+		Value:         write.Value,
+		Type:          write.Type,
+		Fields:        fields,
+		CustomFields:  customFields,
+		VaultID:       write.VaultID,
+		FolderID:      write.FolderID,
+		Favorite:      favorite,
+		Tags:          write.Tags,
+		CreatedAt:     currTime,
+		NameEncrypted: write.NameIndex.Encrypted,
+		UpdatedAt:     currTime,
+		ExpiresAt:     formatTimestamptz(expiresAt),
+		RotateEvery:   write.Expiry.RotateEvery.Truncate(time.Second),
 	}, nil
 }
 
-// NoteDeleteByID implements models.Store.
-func (s PostgresStore) NoteDeleteByID(ctx context.Context, id int64) error {
-	query := `UPDATE notes SET deleted=true WHERE id=$1;`
+// NoteDeleteByID implements models.Store. The note is moved to the trash.
+func (s PostgresStore) NoteDeleteByID(ctx context.Context, id int64, deletedBy int64) error {
+	query := `UPDATE notes SET deleted_at=$1, deleted_by=$2 WHERE id=$3 AND deleted_at IS NULL;`
 
-	result, err := s.DB.Exec(ctx, query, id)
+	result, err := s.DB.Exec(ctx, query, time.Now().UTC(), deletedBy, id)
 	if err != nil {
 		return err
 	}
@@ -57,7 +132,7 @@ func (s PostgresStore) NoteDeleteByID(ctx context.Context, id int64) error {
 
 // NoteGetByID implements models.Store.
 func (s PostgresStore) NoteGetByID(ctx context.Context, id int64) (models.Note, error) {
-	query := `SELECT * FROM notes WHERE id=$1 AND deleted=false;`
+	query := `SELECT * FROM notes WHERE id=$1 AND deleted_at IS NULL;`
 
 	row, err := s.DB.Query(ctx, query, id)
 	if err != nil {
@@ -72,12 +147,93 @@ func (s PostgresStore) NoteGetByID(ctx context.Context, id int64) (models.Note,
 		return models.Note{}, err
 	}
 
-	return noteToModel(note), nil
+	notes := []Note{note}
+	if err := s.loadTags(ctx, notes); err != nil {
+		return models.Note{}, err
+	}
+
+	return noteToModel(notes[0]), nil
+}
+
+// NoteGetVaultID implements models.Store.
+func (s PostgresStore) NoteGetVaultID(ctx context.Context, id int64) (int64, error) {
+	var vaultID int64
+	if err := s.DB.QueryRow(ctx, `SELECT vault_id FROM notes WHERE id=$1;`, id).Scan(&vaultID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, models.ErrNotFound
+		}
+		return 0, err
+	}
+
+	return vaultID, nil
 }
 
 // NoteGetAll implements models.Store.
-func (s PostgresStore) NoteGetAll(ctx context.Context) ([]models.Note, error) {
-	query := `SELECT * FROM notes WHERE deleted=false;`
+func (s PostgresStore) NoteGetAll(ctx context.Context, vaultIDs []int64) ([]models.Note, error) {
+	query := `SELECT * FROM notes WHERE deleted_at IS NULL AND ($1::bigint[] IS NULL OR vault_id = ANY($1));`
+
+	rows, err := s.DB.Query(ctx, query, vaultIDs)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Note])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	if err := s.loadTags(ctx, notes); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notesToModel(notes), nil
+}
+
+// NoteSample implements models.Store.
+func (s PostgresStore) NoteSample(ctx context.Context, limit int) ([]models.Note, error) {
+	query := `SELECT * FROM notes WHERE deleted_at IS NULL ORDER BY random() LIMIT $1;`
+
+	rows, err := s.DB.Query(ctx, query, limit)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Note])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	return notesToModel(notes), nil
+}
+
+// NoteUpdateValue implements models.Store. Updating the value releases the note from quarantine.
+func (s PostgresStore) NoteUpdateValue(ctx context.Context, id int64, value string) error {
+	query := `UPDATE notes SET value=$1, updated_at=$2, quarantined_at=NULL WHERE id=$3;`
+
+	result, err := s.DB.Exec(ctx, query, value, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteQuarantine implements models.Store.
+func (s PostgresStore) NoteQuarantine(ctx context.Context, id int64) error {
+	query := `UPDATE notes SET quarantined_at=$1 WHERE id=$2 AND quarantined_at IS NULL;`
+
+	_, err := s.DB.Exec(ctx, query, time.Now().UTC(), id)
+
+	return err
+}
+
+// NoteGetQuarantined implements models.Store.
+func (s PostgresStore) NoteGetQuarantined(ctx context.Context) ([]models.Note, error) {
+	query := `SELECT * FROM notes WHERE deleted_at IS NULL AND quarantined_at IS NOT NULL ORDER BY quarantined_at;`
 
 	rows, err := s.DB.Query(ctx, query)
 	if err != nil {
@@ -95,13 +251,41 @@ func (s PostgresStore) NoteGetAll(ctx context.Context) ([]models.Note, error) {
 // Converts a DB note struct to a models.Note struct.
 func noteToModel(note Note) models.Note {
 	return models.Note{
-		ID:        note.ID,
-		Name:      note.Name,
-		Value:     note.Value,
-		CreatedAt: note.CreatedAt.Time.Format(time.RFC3339),
-		UpdatedAt: note.UpdatedAt.Time.Format(time.RFC3339),
-		Deleted:   note.Deleted.Bool,
+		ID:            note.ID,
+		Name:          note.Name,
+		Value:         note.Value,
+		CreatedAt:     note.CreatedAt.Time.Format(time.RFC3339),
+		UpdatedAt:     note.UpdatedAt.Time.Format(time.RFC3339),
+		QuarantinedAt: formatTimestamptz(note.QuarantinedAt),
+		DeletedAt:     formatTimestamptz(note.DeletedAt),
+		DeletedBy:     note.DeletedBy,
+		ExpiresAt:     formatTimestamptz(note.ExpiresAt),
+		RotateEvery:   time.Duration(note.RotateEvery) * time.Second,
+		ReviewedAt:    formatTimestamptz(note.ReviewedAt),
+		CompromisedAt: formatTimestamptz(note.CompromisedAt),
+		BreachCount:   note.BreachCount,
+		Type:          note.Type,
+		Fields:        note.Fields,
+		CustomFields:  note.CustomFields,
+		VaultID:       note.VaultID,
+		FolderID:      note.FolderID.Int64,
+		Tags:          note.Tags,
+		NameEncrypted: note.NameEncrypted,
+	}
+}
+
+// formatTimestamptz formats a nullable timestamp, returning an empty string for NULL.
+func formatTimestamptz(ts pgtype.Timestamptz) string {
+	if !ts.Valid {
+		return ""
 	}
+
+	return ts.Time.Format(time.RFC3339)
+}
+
+// timestamptz converts an optional time to a nullable timestamp, NULL for the zero time.
+func timestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t.UTC(), Valid: !t.IsZero()}
 }
 
 // // Converts a list of DB note structs to a list of models.Note structs.
@@ -114,3 +298,298 @@ func notesToModel(notes []Note) []models.Note {
 
 	return results
 }
+
+// NoteReencryptAll implements models.Store. Every note, including deleted notes, every note
+// version, note data key, pending job payloads and the encryption canary are locked and rewritten
+// in a single transaction.
+func (s PostgresStore) NoteReencryptAll(ctx context.Context, reencrypt func(value string) (string, error)) (int, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT id, value FROM notes ORDER BY id FOR UPDATE;`)
+	if err != nil {
+		return 0, err
+	}
+
+	values := make(map[int64]string)
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		values[id] = value
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		newValue, err := reencrypt(values[id])
+		if err != nil {
+			return 0, fmt.Errorf("note %d: %w", id, err)
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE notes SET value=$1 WHERE id=$2;`, newValue, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := reencryptColumn(ctx, tx, "note name", `SELECT id, name FROM notes WHERE name_encrypted ORDER BY id FOR UPDATE;`,
+		`UPDATE notes SET name=$1 WHERE id=$2;`, reencrypt); err != nil {
+		return 0, err
+	}
+
+	if err := reencryptFields(ctx, tx, "note", "notes", reencrypt); err != nil {
+		return 0, err
+	}
+
+	if err := reencryptCustomFields(ctx, tx, "note", "notes", reencrypt); err != nil {
+		return 0, err
+	}
+
+	if err := reencryptVersions(ctx, tx, reencrypt); err != nil {
+		return 0, err
+	}
+
+	if err := reencryptColumn(ctx, tx, "note key", `SELECT note_id, key FROM note_keys ORDER BY note_id FOR UPDATE;`,
+		`UPDATE note_keys SET key=$1 WHERE note_id=$2;`, reencrypt); err != nil {
+		return 0, err
+	}
+
+	// queued jobs can carry encrypted payloads, finished jobs have theirs cleared
+	if err := reencryptColumn(ctx, tx, "job payload", `SELECT id, payload FROM jobs WHERE payload <> '' ORDER BY id FOR UPDATE;`,
+		`UPDATE jobs SET payload=$1 WHERE id=$2;`, reencrypt); err != nil {
+		return 0, err
+	}
+
+	var canary string
+	err = tx.QueryRow(ctx, `SELECT value FROM encryption_canary WHERE id=1 FOR UPDATE;`).Scan(&canary)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+
+	if err == nil {
+		newCanary, err := reencrypt(canary)
+		if err != nil {
+			return 0, fmt.Errorf("encryption canary: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE encryption_canary SET value=$1, updated_at=$2 WHERE id=1;`, newCanary, time.Now().UTC()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// NoteEncryptNames implements models.Store. Every plaintext note and version name is sealed in a
+// single transaction and the search text of each changed note is rebuilt without its name.
+func (s PostgresStore) NoteEncryptNames(ctx context.Context, seal func(name string) (string, models.NoteNameIndex, error)) (int, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	notes, err := plaintextNames(ctx, tx, `SELECT id, name FROM notes WHERE NOT name_encrypted ORDER BY id FOR UPDATE;`)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, note := range notes {
+		name, index, err := seal(note.Name)
+		if err != nil {
+			return 0, fmt.Errorf("note %d: %w", note.ID, err)
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE notes SET name=$1, name_encrypted=$2, name_token=$3, name_index=$4 WHERE id=$5;`,
+			name, index.Encrypted, nameToken(index), nameLookup(index), note.ID)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return 0, fmt.Errorf("note %d: %w", note.ID, models.ErrAlreadyExists)
+			}
+			return 0, err
+		}
+
+		if err := updateSearchText(ctx, tx, note.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	versions, err := plaintextNames(ctx, tx, `SELECT id, name FROM note_versions WHERE NOT name_encrypted ORDER BY id FOR UPDATE;`)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, version := range versions {
+		name, index, err := seal(version.Name)
+		if err != nil {
+			return 0, fmt.Errorf("note version %d: %w", version.ID, err)
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE note_versions SET name=$1, name_encrypted=$2 WHERE id=$3;`, name, index.Encrypted, version.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(notes), nil
+}
+
+// NoteEncryptFields implements models.Store. Every note and version with fields is passed to
+// seal in a single transaction and the search text of each changed note is rebuilt without the
+// newly encrypted fields.
+func (s PostgresStore) NoteEncryptFields(ctx context.Context, seal func(noteID int64, noteType string, fields map[string]models.NoteField) (bool, error)) (int, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	notes, err := sealFields(ctx, tx, "note", `SELECT id, id AS note_id, type, fields FROM notes WHERE fields <> '{}' ORDER BY id FOR UPDATE;`,
+		`UPDATE notes SET fields=$1 WHERE id=$2;`, seal)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range notes {
+		if err := updateSearchText(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := sealFields(ctx, tx, "note version", `SELECT v.id, v.note_id, n.type, v.fields FROM note_versions v JOIN notes n ON n.id = v.note_id
+		WHERE v.fields <> '{}' ORDER BY v.id FOR UPDATE OF v;`, `UPDATE note_versions SET fields=$1 WHERE id=$2;`, seal); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(notes), nil
+}
+
+type fieldsRow struct {
+	ID     int64                       `db:"id"`
+	NoteID int64                       `db:"note_id"`
+	Type   string                      `db:"type"`
+	Fields map[string]models.NoteField `db:"fields"`
+}
+
+// sealFields passes the fields of every row selected by the query to seal, writes back the rows
+// it changed with update and returns their IDs.
+func sealFields(ctx context.Context, tx pgx.Tx, label string, query string, update string, seal func(noteID int64, noteType string, fields map[string]models.NoteField) (bool, error)) ([]int64, error) {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[fieldsRow])
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []int64
+	for _, row := range results {
+		ok, err := seal(row.NoteID, row.Type, row.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s %d: %w", label, row.ID, err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if _, err := tx.Exec(ctx, update, row.Fields, row.ID); err != nil {
+			return nil, err
+		}
+		changed = append(changed, row.ID)
+	}
+
+	return changed, nil
+}
+
+type namedRow struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+// plaintextNames returns the (id, name) rows selected by the query.
+func plaintextNames(ctx context.Context, tx pgx.Tx, query string) ([]namedRow, error) {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[namedRow])
+}
+
+// nameToken stores the exact token of a plaintext name as NULL so only encrypted names are
+// covered by the unique index on it.
+func nameToken(index models.NoteNameIndex) pgtype.Text {
+	return pgtype.Text{String: index.Exact, Valid: index.Encrypted}
+}
+
+// nameLookup stores the lookup tokens of a plaintext name as an empty array.
+func nameLookup(index models.NoteNameIndex) []string {
+	if index.Lookup == nil {
+		return []string{}
+	}
+	return index.Lookup
+}
+
+// sameName matches a note o whose name equals that of note n: by exact token when the name of n
+// is encrypted, case-insensitively otherwise. Plaintext and encrypted names never match, like in
+// the unique indexes on them.
+const sameName = `CASE WHEN n.name_encrypted THEN o.name_token = n.name_token
+	ELSE NOT o.name_encrypted AND lower(o.name) = lower(n.name) END`
+
+// nameConflict returns a *models.NameConflictError naming the note other than noteID in the
+// folder of the vault that has the name, or models.ErrAlreadyExists if it was deleted since the
+// unique violation.
+func (s PostgresStore) nameConflict(ctx context.Context, noteID int64, vaultID int64, folderID int64, name string, index models.NoteNameIndex) error {
+	query := `SELECT o.id FROM (SELECT $1::bigint AS id, $2::bigint AS vault_id, $3::bigint AS folder_id, $4::text AS name, $5::boolean AS name_encrypted, $6::text AS name_token) n
+		JOIN notes o ON o.id <> n.id AND o.deleted_at IS NULL AND o.vault_id = n.vault_id AND COALESCE(o.folder_id, 0) = n.folder_id AND ` + sameName + `
+		ORDER BY o.id LIMIT 1;`
+
+	return s.findNameConflict(ctx, query, noteID, vaultID, folderID, name, index.Encrypted, nameToken(index))
+}
+
+// movedNameConflict returns a *models.NameConflictError naming the note that has the name of the
+// stored note in the folder of its vault, its own folder when folderID is NULL.
+func (s PostgresStore) movedNameConflict(ctx context.Context, noteID int64, folderID pgtype.Int8) error {
+	query := `SELECT o.id FROM notes n
+		JOIN notes o ON o.id <> n.id AND o.deleted_at IS NULL AND o.vault_id = n.vault_id
+			AND COALESCE(o.folder_id, 0) = COALESCE($2, n.folder_id, 0) AND ` + sameName + `
+		WHERE n.id = $1 ORDER BY o.id LIMIT 1;`
+
+	return s.findNameConflict(ctx, query, noteID, folderID)
+}
+
+// findNameConflict runs a query selecting the ID of a conflicting note.
+func (s PostgresStore) findNameConflict(ctx context.Context, query string, args ...any) error {
+	var conflictID int64
+	if err := s.DB.QueryRow(ctx, query, args...).Scan(&conflictID); err != nil {
+		return models.ErrAlreadyExists
+	}
+
+	return &models.NameConflictError{NoteID: conflictID}
+}
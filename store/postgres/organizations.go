@@ -0,0 +1,499 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type Organization struct {
+	ID        int64              `db:"id"`
+	Name      string             `db:"name"`
+	Role      string             `db:"role"`
+	CreatedAt pgtype.Timestamptz `db:"created_at"`
+}
+
+type Vault struct {
+	ID               int64              `db:"id"`
+	OrganizationID   int64              `db:"organization_id"`
+	Name             string             `db:"name"`
+	VersionRetention pgtype.Int4        `db:"version_retention"`
+	Role             string             `db:"role"`
+	CreatedAt        pgtype.Timestamptz `db:"created_at"`
+}
+
+type Member struct {
+	ID             int64              `db:"id"`
+	OrganizationID int64              `db:"organization_id"`
+	VaultID        int64              `db:"vault_id"`
+	UserID         int64              `db:"user_id"`
+	Username       string             `db:"username"`
+	Role           string             `db:"role"`
+	CreatedAt      pgtype.Timestamptz `db:"created_at"`
+	UpdatedAt      pgtype.Timestamptz `db:"updated_at"`
+}
+
+type Invitation struct {
+	ID               int64              `db:"id"`
+	OrganizationID   int64              `db:"organization_id"`
+	OrganizationName string             `db:"organization_name"`
+	VaultID          int64              `db:"vault_id"`
+	UserID           int64              `db:"user_id"`
+	Username         string             `db:"username"`
+	Role             string             `db:"role"`
+	InvitedBy        int64              `db:"invited_by"`
+	CreatedAt        pgtype.Timestamptz `db:"created_at"`
+	ExpiresAt        pgtype.Timestamptz `db:"expires_at"`
+}
+
+// roleOrder ranks roles for array_position, least privileged first.
+const roleOrder = `ARRAY['viewer', 'editor', 'admin', 'owner']`
+
+// memberSelect selects memberships with the username of the member.
+const memberSelect = `SELECT m.id, m.organization_id, COALESCE(m.vault_id, 0) AS vault_id, m.user_id, u.username, m.role, m.created_at, m.updated_at
+	FROM memberships m JOIN users u ON u.id = m.user_id`
+
+// invitationSelect selects invitations with the names of the organization and invited user.
+const invitationSelect = `SELECT i.id, i.organization_id, o.name AS organization_name, COALESCE(i.vault_id, 0) AS vault_id, i.user_id, u.username,
+	i.role, i.invited_by, i.created_at, i.expires_at
+	FROM invitations i JOIN organizations o ON o.id = i.organization_id JOIN users u ON u.id = i.user_id`
+
+// OrganizationCreate implements models.Store. The organization and its owner's membership are
+// inserted in a single transaction.
+func (s PostgresStore) OrganizationCreate(ctx context.Context, name string, ownerID int64) (models.Organization, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.Organization{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+
+	var id int64
+	if err := tx.QueryRow(ctx, `INSERT INTO organizations (name, created_at) VALUES ($1, $2) RETURNING id;`, name, now).Scan(&id); err != nil {
+		return models.Organization{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO memberships (organization_id, user_id, role, created_at, updated_at) VALUES ($1, $2, $3, $4, $4);`,
+		id, ownerID, models.ROLE_OWNER, now); err != nil {
+		return models.Organization{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Organization{}, err
+	}
+
+	return models.Organization{ID: id, Name: name, Role: models.ROLE_OWNER, CreatedAt: now.Format(time.RFC3339)}, nil
+}
+
+// OrganizationGetForUser implements models.Store.
+func (s PostgresStore) OrganizationGetForUser(ctx context.Context, userID int64) ([]models.Organization, error) {
+	query := `SELECT o.id, o.name, o.created_at, COALESCE(max(m.role) FILTER (WHERE m.vault_id IS NULL), '') AS role
+		FROM organizations o JOIN memberships m ON m.organization_id = o.id
+		WHERE m.user_id = $1 GROUP BY o.id ORDER BY lower(o.name), o.id;`
+
+	rows, err := s.DB.Query(ctx, query, userID)
+	if err != nil {
+		return []models.Organization{}, err
+	}
+
+	organizations, err := pgx.CollectRows(rows, pgx.RowToStructByName[Organization])
+	if err != nil {
+		return []models.Organization{}, err
+	}
+
+	results := make([]models.Organization, len(organizations))
+	for i, organization := range organizations {
+		results[i] = models.Organization{
+			ID:        organization.ID,
+			Name:      organization.Name,
+			Role:      organization.Role,
+			CreatedAt: organization.CreatedAt.Time.Format(time.RFC3339),
+		}
+	}
+
+	return results, nil
+}
+
+// VaultCreate implements models.Store.
+func (s PostgresStore) VaultCreate(ctx context.Context, organizationID int64, name string) (models.Vault, error) {
+	now := time.Now().UTC()
+
+	var id int64
+	err := s.DB.QueryRow(ctx, `INSERT INTO vaults (organization_id, name, created_at) VALUES ($1, $2, $3) RETURNING id;`, organizationID, name, now).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Vault{}, models.ErrAlreadyExists
+		}
+		return models.Vault{}, err
+	}
+
+	return models.Vault{ID: id, OrganizationID: organizationID, Name: name, CreatedAt: now.Format(time.RFC3339)}, nil
+}
+
+// VaultGetByID implements models.Store.
+func (s PostgresStore) VaultGetByID(ctx context.Context, id int64) (models.Vault, error) {
+	vaults, err := s.vaultQuery(ctx, `SELECT id, organization_id, name, version_retention, '' AS role, created_at FROM vaults WHERE id=$1;`, id)
+	if err != nil {
+		return models.Vault{}, err
+	}
+
+	if len(vaults) == 0 {
+		return models.Vault{}, models.ErrNotFound
+	}
+
+	return vaults[0], nil
+}
+
+// VaultSetVersionRetention implements models.Store.
+func (s PostgresStore) VaultSetVersionRetention(ctx context.Context, id int64, retention *int) (models.Vault, error) {
+	result, err := s.DB.Exec(ctx, `UPDATE vaults SET version_retention=$1 WHERE id=$2;`, retention, id)
+	if err != nil {
+		return models.Vault{}, err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.Vault{}, models.ErrNotFound
+	}
+
+	return s.VaultGetByID(ctx, id)
+}
+
+// VaultGetAll implements models.Store.
+func (s PostgresStore) VaultGetAll(ctx context.Context) ([]models.Vault, error) {
+	return s.vaultQuery(ctx, `SELECT id, organization_id, name, version_retention, '' AS role, created_at FROM vaults ORDER BY lower(name), id;`)
+}
+
+// VaultGetForUser implements models.Store. Organization wide memberships grant a role in every
+// vault of the organization.
+func (s PostgresStore) VaultGetForUser(ctx context.Context, userID int64) ([]models.Vault, error) {
+	query := `SELECT v.id, v.organization_id, v.name, v.version_retention, v.created_at,
+			(array_agg(m.role ORDER BY array_position(` + roleOrder + `, m.role) DESC))[1] AS role
+		FROM vaults v JOIN memberships m ON m.organization_id = v.organization_id AND (m.vault_id IS NULL OR m.vault_id = v.id)
+		WHERE m.user_id = $1 GROUP BY v.id ORDER BY lower(v.name), v.id;`
+
+	return s.vaultQuery(ctx, query, userID)
+}
+
+func (s PostgresStore) vaultQuery(ctx context.Context, query string, args ...any) ([]models.Vault, error) {
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return []models.Vault{}, err
+	}
+
+	vaults, err := pgx.CollectRows(rows, pgx.RowToStructByName[Vault])
+	if err != nil {
+		return []models.Vault{}, err
+	}
+
+	results := make([]models.Vault, len(vaults))
+	for i, vault := range vaults {
+		results[i] = models.Vault{
+			ID:             vault.ID,
+			OrganizationID: vault.OrganizationID,
+			Name:           vault.Name,
+			Role:           vault.Role,
+			CreatedAt:      vault.CreatedAt.Time.Format(time.RFC3339),
+		}
+		if vault.VersionRetention.Valid {
+			retention := int(vault.VersionRetention.Int32)
+			results[i].VersionRetention = &retention
+		}
+	}
+
+	return results, nil
+}
+
+// MembershipRoles implements models.Store.
+func (s PostgresStore) MembershipRoles(ctx context.Context, userID int64, organizationID int64, vaultID int64) ([]string, error) {
+	query := `SELECT role FROM memberships WHERE user_id = $1 AND CASE WHEN $3::bigint = 0
+		THEN organization_id = $2 AND vault_id IS NULL
+		ELSE organization_id = (SELECT organization_id FROM vaults WHERE id = $3) AND (vault_id IS NULL OR vault_id = $3) END;`
+
+	rows, err := s.DB.Query(ctx, query, userID, organizationID, vaultID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// MemberGetAll implements models.Store. Organization wide members come before vault members.
+func (s PostgresStore) MemberGetAll(ctx context.Context, organizationID int64) ([]models.Member, error) {
+	return s.memberQuery(ctx, s.DB, memberSelect+` WHERE m.organization_id=$1 ORDER BY m.vault_id NULLS FIRST, lower(u.username), m.id;`, organizationID)
+}
+
+// MemberGetByID implements models.Store.
+func (s PostgresStore) MemberGetByID(ctx context.Context, id int64) (models.Member, error) {
+	return s.memberGetOne(ctx, s.DB, id)
+}
+
+// MemberSetRole implements models.Store.
+func (s PostgresStore) MemberSetRole(ctx context.Context, id int64, role string) (models.Member, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.Member{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if role != models.ROLE_OWNER {
+		if err := keepOwner(ctx, tx, id); err != nil {
+			return models.Member{}, err
+		}
+	}
+
+	result, err := tx.Exec(ctx, `UPDATE memberships SET role=$1, updated_at=$2 WHERE id=$3;`, role, time.Now().UTC(), id)
+	if err != nil {
+		return models.Member{}, err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.Member{}, models.ErrNotFound
+	}
+
+	member, err := s.memberGetOne(ctx, tx, id)
+	if err != nil {
+		return models.Member{}, err
+	}
+
+	return member, tx.Commit(ctx)
+}
+
+// MemberDelete implements models.Store.
+func (s PostgresStore) MemberDelete(ctx context.Context, id int64) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := keepOwner(ctx, tx, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM memberships WHERE id=$1;`, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+// keepOwner returns an ErrInvalidInput error if the membership is the only owner of its
+// organization. The organization's owners are locked until the transaction ends, so concurrent
+// changes can't remove every owner.
+func keepOwner(ctx context.Context, tx pgx.Tx, id int64) error {
+	query := `SELECT id FROM memberships WHERE organization_id = (SELECT organization_id FROM memberships WHERE id=$1)
+		AND vault_id IS NULL AND role=$2 ORDER BY id FOR UPDATE;`
+
+	rows, err := tx.Query(ctx, query, id, models.ROLE_OWNER)
+	if err != nil {
+		return err
+	}
+
+	owners, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return err
+	}
+
+	if len(owners) == 1 && owners[0] == id {
+		return fmt.Errorf("%w: an organization must keep at least one owner", models.ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// InvitationCreate implements models.Store.
+func (s PostgresStore) InvitationCreate(ctx context.Context, invitation models.Invitation) (models.Invitation, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM invitations WHERE organization_id=$1 AND COALESCE(vault_id, 0)=$2 AND user_id=$3 AND expires_at <= now();`,
+		invitation.OrganizationID, invitation.VaultID, invitation.UserID)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	query := `INSERT INTO invitations (organization_id, vault_id, user_id, role, invited_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+
+	var id int64
+	err = tx.QueryRow(ctx, query, invitation.OrganizationID, nullableID(invitation.VaultID), invitation.UserID, invitation.Role,
+		invitation.InvitedBy, invitation.CreatedAt, invitation.ExpiresAt).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Invitation{}, models.ErrAlreadyExists
+		}
+		return models.Invitation{}, err
+	}
+
+	created, err := s.invitationGetOne(ctx, tx, id)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+// InvitationGetByID implements models.Store. Expired invitations are returned too.
+func (s PostgresStore) InvitationGetByID(ctx context.Context, id int64) (models.Invitation, error) {
+	return s.invitationGetOne(ctx, s.DB, id)
+}
+
+// InvitationGetForOrganization implements models.Store.
+func (s PostgresStore) InvitationGetForOrganization(ctx context.Context, organizationID int64) ([]models.Invitation, error) {
+	return s.invitationQuery(ctx, s.DB, invitationSelect+` WHERE i.organization_id=$1 AND i.expires_at > now() ORDER BY i.id;`, organizationID)
+}
+
+// InvitationGetForUser implements models.Store.
+func (s PostgresStore) InvitationGetForUser(ctx context.Context, userID int64) ([]models.Invitation, error) {
+	return s.invitationQuery(ctx, s.DB, invitationSelect+` WHERE i.user_id=$1 AND i.expires_at > now() ORDER BY i.id;`, userID)
+}
+
+// InvitationAccept implements models.Store. The membership is saved and the invitation removed in
+// a single transaction.
+func (s PostgresStore) InvitationAccept(ctx context.Context, id int64) (models.Member, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.Member{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var organizationID, userID int64
+	var vaultID pgtype.Int8
+	var role string
+	err = tx.QueryRow(ctx, `DELETE FROM invitations WHERE id=$1 AND expires_at > now() RETURNING organization_id, vault_id, user_id, role;`, id).
+		Scan(&organizationID, &vaultID, &userID, &role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Member{}, models.ErrNotFound
+		}
+		return models.Member{}, err
+	}
+
+	query := `INSERT INTO memberships (organization_id, vault_id, user_id, role, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (organization_id, COALESCE(vault_id, 0), user_id) DO UPDATE SET role=EXCLUDED.role, updated_at=EXCLUDED.updated_at
+		RETURNING id;`
+
+	var memberID int64
+	if err := tx.QueryRow(ctx, query, organizationID, vaultID, userID, role, time.Now().UTC()).Scan(&memberID); err != nil {
+		return models.Member{}, err
+	}
+
+	member, err := s.memberGetOne(ctx, tx, memberID)
+	if err != nil {
+		return models.Member{}, err
+	}
+
+	return member, tx.Commit(ctx)
+}
+
+// InvitationDelete implements models.Store.
+func (s PostgresStore) InvitationDelete(ctx context.Context, id int64) error {
+	result, err := s.DB.Exec(ctx, `DELETE FROM invitations WHERE id=$1;`, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s PostgresStore) memberGetOne(ctx context.Context, db querier, id int64) (models.Member, error) {
+	members, err := s.memberQuery(ctx, db, memberSelect+` WHERE m.id=$1;`, id)
+	if err != nil {
+		return models.Member{}, err
+	}
+
+	if len(members) == 0 {
+		return models.Member{}, models.ErrNotFound
+	}
+
+	return members[0], nil
+}
+
+func (s PostgresStore) memberQuery(ctx context.Context, db querier, query string, args ...any) ([]models.Member, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return []models.Member{}, err
+	}
+
+	members, err := pgx.CollectRows(rows, pgx.RowToStructByName[Member])
+	if err != nil {
+		return []models.Member{}, err
+	}
+
+	results := make([]models.Member, len(members))
+	for i, member := range members {
+		results[i] = models.Member{
+			ID:             member.ID,
+			OrganizationID: member.OrganizationID,
+			VaultID:        member.VaultID,
+			UserID:         member.UserID,
+			Username:       member.Username,
+			Role:           member.Role,
+			CreatedAt:      member.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:      member.UpdatedAt.Time.Format(time.RFC3339),
+		}
+	}
+
+	return results, nil
+}
+
+func (s PostgresStore) invitationGetOne(ctx context.Context, db querier, id int64) (models.Invitation, error) {
+	invitations, err := s.invitationQuery(ctx, db, invitationSelect+` WHERE i.id=$1;`, id)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	if len(invitations) == 0 {
+		return models.Invitation{}, models.ErrNotFound
+	}
+
+	return invitations[0], nil
+}
+
+func (s PostgresStore) invitationQuery(ctx context.Context, db querier, query string, args ...any) ([]models.Invitation, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return []models.Invitation{}, err
+	}
+
+	invitations, err := pgx.CollectRows(rows, pgx.RowToStructByName[Invitation])
+	if err != nil {
+		return []models.Invitation{}, err
+	}
+
+	results := make([]models.Invitation, len(invitations))
+	for i, invitation := range invitations {
+		results[i] = models.Invitation{
+			ID:               invitation.ID,
+			OrganizationID:   invitation.OrganizationID,
+			OrganizationName: invitation.OrganizationName,
+			VaultID:          invitation.VaultID,
+			UserID:           invitation.UserID,
+			Username:         invitation.Username,
+			Role:             invitation.Role,
+			InvitedBy:        invitation.InvitedBy,
+			CreatedAt:        invitation.CreatedAt.Time.Format(time.RFC3339),
+			ExpiresAt:        invitation.ExpiresAt.Time.Format(time.RFC3339),
+		}
+	}
+
+	return results, nil
+}
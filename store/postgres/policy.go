@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type PasswordPolicy struct {
+	OrganizationID   int64              `db:"organization_id"`
+	MinLength        int                `db:"min_length"`
+	Require          []string           `db:"require"`
+	BannedSubstrings []string           `db:"banned_substrings"`
+	RejectBreached   bool               `db:"reject_breached"`
+	UpdatedAt        pgtype.Timestamptz `db:"updated_at"`
+}
+
+// PasswordPolicyGet implements models.Store.
+func (s PostgresStore) PasswordPolicyGet(ctx context.Context, organizationID int64) (models.PasswordPolicy, error) {
+	rows, err := s.DB.Query(ctx, `SELECT * FROM password_policies WHERE organization_id=$1;`, organizationID)
+	if err != nil {
+		return models.PasswordPolicy{}, err
+	}
+
+	return collectPasswordPolicy(rows)
+}
+
+// PasswordPolicySet implements models.Store.
+func (s PostgresStore) PasswordPolicySet(ctx context.Context, p models.PasswordPolicy) (models.PasswordPolicy, error) {
+	query := `INSERT INTO password_policies (organization_id, min_length, require, banned_substrings, reject_breached, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (organization_id) DO UPDATE SET min_length=EXCLUDED.min_length, require=EXCLUDED.require,
+			banned_substrings=EXCLUDED.banned_substrings, reject_breached=EXCLUDED.reject_breached, updated_at=EXCLUDED.updated_at
+		RETURNING *;`
+
+	updatedAt, _ := time.Parse(time.RFC3339, p.UpdatedAt)
+
+	rows, err := s.DB.Query(ctx, query, p.OrganizationID, p.MinLength, p.Require, p.BannedSubstrings, p.RejectBreached, timestamptz(updatedAt))
+	if err != nil {
+		return models.PasswordPolicy{}, err
+	}
+
+	saved, err := collectPasswordPolicy(rows)
+	if isForeignKeyViolation(err) {
+		return models.PasswordPolicy{}, models.ErrNotFound
+	}
+
+	return saved, err
+}
+
+// PasswordPolicyDelete implements models.Store.
+func (s PostgresStore) PasswordPolicyDelete(ctx context.Context, organizationID int64) error {
+	result, err := s.DB.Exec(ctx, `DELETE FROM password_policies WHERE organization_id=$1;`, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+func collectPasswordPolicy(rows pgx.Rows) (models.PasswordPolicy, error) {
+	p, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[PasswordPolicy])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PasswordPolicy{}, models.ErrNotFound
+		}
+		return models.PasswordPolicy{}, err
+	}
+
+	return models.PasswordPolicy{
+		OrganizationID:   p.OrganizationID,
+		MinLength:        p.MinLength,
+		Require:          p.Require,
+		BannedSubstrings: p.BannedSubstrings,
+		RejectBreached:   p.RejectBreached,
+		UpdatedAt:        formatTimestamptz(p.UpdatedAt),
+	}, nil
+}
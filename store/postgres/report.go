@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type NoteAnalysis struct {
+	NoteID       int64              `db:"note_id"`
+	Fingerprint  string             `db:"fingerprint"`
+	KeyID        string             `db:"key_id"`
+	Score        int                `db:"score"`
+	GuessesLog10 float64            `db:"guesses_log10"`
+	Warnings     []string           `db:"warnings"`
+	ValueHash    string             `db:"value_hash"`
+	AnalyzedAt   pgtype.Timestamptz `db:"analyzed_at"`
+}
+
+// NoteAnalysisSave implements models.Store.
+func (s PostgresStore) NoteAnalysisSave(ctx context.Context, analysis models.NoteAnalysis) error {
+	query := `INSERT INTO note_analyses (note_id, fingerprint, key_id, score, guesses_log10, warnings, value_hash, analyzed_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8 WHERE EXISTS (SELECT 1 FROM notes WHERE id=$1)
+		ON CONFLICT (note_id) DO UPDATE SET fingerprint=EXCLUDED.fingerprint, key_id=EXCLUDED.key_id,
+			score=EXCLUDED.score, guesses_log10=EXCLUDED.guesses_log10, warnings=EXCLUDED.warnings,
+			value_hash=EXCLUDED.value_hash, analyzed_at=EXCLUDED.analyzed_at;`
+
+	analyzedAt, _ := time.Parse(time.RFC3339, analysis.AnalyzedAt)
+
+	result, err := s.DB.Exec(ctx, query, analysis.NoteID, analysis.Fingerprint, analysis.KeyID, analysis.Score,
+		analysis.GuessesLog10, analysis.Warnings, analysis.ValueHash, timestamptz(analyzedAt))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteAnalysisGetAll implements models.Store.
+func (s PostgresStore) NoteAnalysisGetAll(ctx context.Context, vaultIDs []int64) ([]models.NoteAnalysis, error) {
+	query := `SELECT a.* FROM note_analyses a JOIN notes n ON n.id = a.note_id
+		WHERE n.deleted_at IS NULL AND ($1::bigint[] IS NULL OR n.vault_id = ANY($1));`
+
+	rows, err := s.DB.Query(ctx, query, vaultIDs)
+	if err != nil {
+		return []models.NoteAnalysis{}, err
+	}
+
+	analyses, err := pgx.CollectRows(rows, pgx.RowToStructByName[NoteAnalysis])
+	if err != nil {
+		return []models.NoteAnalysis{}, err
+	}
+
+	results := make([]models.NoteAnalysis, len(analyses))
+	for i, analysis := range analyses {
+		results[i] = models.NoteAnalysis{
+			NoteID:       analysis.NoteID,
+			Fingerprint:  analysis.Fingerprint,
+			KeyID:        analysis.KeyID,
+			Score:        analysis.Score,
+			GuessesLog10: analysis.GuessesLog10,
+			Warnings:     analysis.Warnings,
+			ValueHash:    analysis.ValueHash,
+			AnalyzedAt:   formatTimestamptz(analysis.AnalyzedAt),
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type NoteRotationPolicy struct {
+	NoteID          int64              `db:"note_id"`
+	Length          int                `db:"length"`
+	Charset         string             `db:"charset"`
+	IntervalSeconds int64              `db:"interval_seconds"`
+	NextRotationAt  pgtype.Timestamptz `db:"next_rotation_at"`
+	LastRotatedAt   pgtype.Timestamptz `db:"last_rotated_at"`
+	LastDryRunAt    pgtype.Timestamptz `db:"last_dry_run_at"`
+	CreatedAt       pgtype.Timestamptz `db:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `db:"updated_at"`
+}
+
+// rotationPolicyColumns selects a policy with the interval of its note and the next rotation,
+// which falls due an interval after the later of the note's last update, its last review and
+// the last change to the policy. Notes without an interval have no next rotation.
+const rotationPolicyColumns = `SELECT p.note_id, p.length, p.charset, n.rotate_every_seconds AS interval_seconds,
+	CASE WHEN n.rotate_every_seconds > 0
+		THEN GREATEST(n.updated_at, n.reviewed_at, p.updated_at) + n.rotate_every_seconds * INTERVAL '1 second' END AS next_rotation_at,
+	p.last_rotated_at, p.last_dry_run_at, p.created_at, p.updated_at
+	FROM note_rotation_policies p JOIN notes n ON n.id = p.note_id`
+
+// NoteRotationPolicySet implements models.Store.
+func (s PostgresStore) NoteRotationPolicySet(ctx context.Context, policy models.NoteRotationPolicy) (models.NoteRotationPolicy, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.NoteRotationPolicy{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `UPDATE notes SET rotate_every_seconds=$1 WHERE id=$2 AND deleted_at IS NULL;`,
+		int64(policy.Interval.Seconds()), policy.NoteID)
+	if err != nil {
+		return models.NoteRotationPolicy{}, err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.NoteRotationPolicy{}, models.ErrNotFound
+	}
+
+	query := `INSERT INTO note_rotation_policies (note_id, length, charset, created_at, updated_at) VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (note_id) DO UPDATE SET length=EXCLUDED.length, charset=EXCLUDED.charset, updated_at=EXCLUDED.updated_at;`
+
+	if _, err := tx.Exec(ctx, query, policy.NoteID, policy.Length, policy.Charset, time.Now().UTC()); err != nil {
+		return models.NoteRotationPolicy{}, err
+	}
+
+	rows, err := tx.Query(ctx, rotationPolicyColumns+` WHERE p.note_id=$1;`, policy.NoteID)
+	if err != nil {
+		return models.NoteRotationPolicy{}, err
+	}
+
+	saved, err := collectRotationPolicy(rows)
+	if err != nil {
+		return models.NoteRotationPolicy{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.NoteRotationPolicy{}, err
+	}
+
+	return saved, nil
+}
+
+// NoteRotationPolicyGet implements models.Store.
+func (s PostgresStore) NoteRotationPolicyGet(ctx context.Context, noteID int64) (models.NoteRotationPolicy, error) {
+	rows, err := s.DB.Query(ctx, rotationPolicyColumns+` WHERE p.note_id=$1 AND n.deleted_at IS NULL;`, noteID)
+	if err != nil {
+		return models.NoteRotationPolicy{}, err
+	}
+
+	return collectRotationPolicy(rows)
+}
+
+// NoteRotationPolicyDelete implements models.Store.
+func (s PostgresStore) NoteRotationPolicyDelete(ctx context.Context, noteID int64) error {
+	result, err := s.DB.Exec(ctx, `DELETE FROM note_rotation_policies WHERE note_id=$1;`, noteID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteRotationPoliciesDue implements models.Store.
+func (s PostgresStore) NoteRotationPoliciesDue(ctx context.Context, before time.Time) ([]models.NoteRotationPolicy, error) {
+	query := `SELECT * FROM (` + rotationPolicyColumns + ` WHERE n.deleted_at IS NULL) due
+		WHERE next_rotation_at <= $1 ORDER BY next_rotation_at;`
+
+	rows, err := s.DB.Query(ctx, query, before.UTC())
+	if err != nil {
+		return []models.NoteRotationPolicy{}, err
+	}
+
+	policies, err := pgx.CollectRows(rows, pgx.RowToStructByName[NoteRotationPolicy])
+	if err != nil {
+		return []models.NoteRotationPolicy{}, err
+	}
+
+	results := make([]models.NoteRotationPolicy, len(policies))
+	for i := range policies {
+		results[i] = rotationPolicyToModel(policies[i])
+	}
+
+	return results, nil
+}
+
+// NoteRotationRecordDryRun implements models.Store.
+func (s PostgresStore) NoteRotationRecordDryRun(ctx context.Context, noteID int64, at time.Time) error {
+	result, err := s.DB.Exec(ctx, `UPDATE note_rotation_policies SET last_dry_run_at=$1 WHERE note_id=$2;`, at.UTC(), noteID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+func collectRotationPolicy(rows pgx.Rows) (models.NoteRotationPolicy, error) {
+	policy, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[NoteRotationPolicy])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.NoteRotationPolicy{}, models.ErrNotFound
+		}
+		return models.NoteRotationPolicy{}, err
+	}
+
+	return rotationPolicyToModel(policy), nil
+}
+
+func rotationPolicyToModel(policy NoteRotationPolicy) models.NoteRotationPolicy {
+	return models.NoteRotationPolicy{
+		NoteID:         policy.NoteID,
+		Length:         policy.Length,
+		Charset:        policy.Charset,
+		Interval:       time.Duration(policy.IntervalSeconds) * time.Second,
+		NextRotationAt: formatTimestamptz(policy.NextRotationAt),
+		LastRotatedAt:  formatTimestamptz(policy.LastRotatedAt),
+		LastDryRunAt:   formatTimestamptz(policy.LastDryRunAt),
+		CreatedAt:      formatTimestamptz(policy.CreatedAt),
+		UpdatedAt:      formatTimestamptz(policy.UpdatedAt),
+	}
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// schedulerLockNamespace is the first key of the advisory locks held while a scheduled job
+// runs, the second key is derived from the job name.
+const schedulerLockNamespace = 7_331_003
+
+// Claim implements scheduler.Coordinator. A session advisory lock held on a dedicated
+// connection keeps other instances from running the job concurrently, and scheduler_runs
+// records the last claimed tick so an instance whose clock lags can't run a tick again after
+// the lock is released.
+func (s PostgresStore) Claim(ctx context.Context, job string, tick time.Time) (func(), bool, error) {
+	conn, err := s.DB.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1, hashtext($2));`, schedulerLockNamespace, job).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, false, err
+	}
+
+	if !locked {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	release := func() {
+		// the job context may already be cancelled, unlock regardless so the connection is
+		// returned to the pool without the lock
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1, hashtext($2));`, schedulerLockNamespace, job); err != nil {
+			conn.Conn().Close(unlockCtx)
+		}
+		conn.Release()
+	}
+
+	query := `INSERT INTO scheduler_runs (job, last_tick, claimed_at) VALUES ($1, $2, $3)
+		ON CONFLICT (job) DO UPDATE SET last_tick=EXCLUDED.last_tick, claimed_at=EXCLUDED.claimed_at
+		WHERE scheduler_runs.last_tick < EXCLUDED.last_tick;`
+
+	result, err := conn.Exec(ctx, query, job, tick.UTC(), time.Now().UTC())
+	if err != nil {
+		release()
+		return nil, false, err
+	}
+
+	if result.RowsAffected() == 0 {
+		release()
+		return nil, false, nil
+	}
+
+	return release, true, nil
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type noteSearchRow struct {
+	Note
+	Rank float64 `db:"rank"`
+}
+
+// NoteSearch implements models.Store. Notes match when every word of the query is a prefix of
+// a word in their search text, when the query is similar enough to part of it to allow typos or
+// when their encrypted name starts with the query. Matches in the name rank higher.
+func (s PostgresStore) NoteSearch(ctx context.Context, query string, nameTokens []string, vaultIDs []int64, limit int) ([]models.NoteMatch, error) {
+	sql := `WITH q AS (SELECT to_tsquery('simple', $2) AS query)
+		SELECT n.*, (ts_rank(setweight(to_tsvector('simple', CASE WHEN n.name_encrypted THEN '' ELSE n.name END), 'A')
+			|| to_tsvector('simple', n.search_text), q.query)
+			+ word_similarity($1, n.search_text)
+			+ CASE WHEN n.name_index && $4::text[] THEN 1 ELSE 0 END)::float8 AS rank
+		FROM notes n, q
+		WHERE n.deleted_at IS NULL AND n.vault_id = ANY($5)
+			AND (to_tsvector('simple', n.search_text) @@ q.query OR $1 <% n.search_text OR n.name_index && $4::text[])
+		ORDER BY rank DESC, n.id
+		LIMIT $3;`
+
+	if nameTokens == nil {
+		nameTokens = []string{}
+	}
+
+	if vaultIDs == nil {
+		vaultIDs = []int64{}
+	}
+
+	rows, err := s.DB.Query(ctx, sql, query, prefixTSQuery(query), limit, nameTokens, vaultIDs)
+	if err != nil {
+		return []models.NoteMatch{}, err
+	}
+
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[noteSearchRow])
+	if err != nil {
+		return []models.NoteMatch{}, err
+	}
+
+	notes := make([]Note, len(results))
+	for i := range results {
+		notes[i] = results[i].Note
+	}
+
+	if err := s.loadTags(ctx, notes); err != nil {
+		return []models.NoteMatch{}, err
+	}
+
+	matches := make([]models.NoteMatch, len(results))
+	for i := range results {
+		matches[i] = models.NoteMatch{Note: noteToModel(notes[i]), Score: results[i].Rank}
+	}
+
+	return matches, nil
+}
+
+// prefixTSQuery builds a tsquery matching every word of the query as a prefix. Only letters and
+// digits are kept so the query can't contain tsquery operators.
+func prefixTSQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i := range words {
+		words[i] += ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
+
+// updateSearchText rebuilds the plaintext search_text of a note from its name, tags, plaintext
+// fields and text and url custom fields. Values, encrypted names, encrypted fields and hidden
+// custom fields are never included.
+func updateSearchText(ctx context.Context, tx pgx.Tx, noteID int64) error {
+	_, err := tx.Exec(ctx, `UPDATE notes n SET search_text = concat_ws(' ', CASE WHEN n.name_encrypted THEN NULL ELSE n.name END,
+		(SELECT string_agg(t.name, ' ') FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE nt.note_id = n.id),
+		(SELECT string_agg(f.value->>'value', ' ') FROM jsonb_each(n.fields) f WHERE NOT COALESCE((f.value->>'encrypted')::boolean, false)),
+		(SELECT string_agg(c->>'value', ' ') FROM jsonb_array_elements(n.custom_fields) c WHERE c->>'kind' IN ('text', 'url')))
+		WHERE n.id=$1;`, noteID)
+
+	return err
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+// NoteGetDeleted implements models.Store.
+func (s PostgresStore) NoteGetDeleted(ctx context.Context, vaultIDs []int64) ([]models.Note, error) {
+	query := `SELECT * FROM notes WHERE deleted_at IS NOT NULL AND ($1::bigint[] IS NULL OR vault_id = ANY($1))
+		ORDER BY deleted_at DESC;`
+
+	rows, err := s.DB.Query(ctx, query, vaultIDs)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes, err := pgx.CollectRows(rows, pgx.RowToStructByName[Note])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	return notesToModel(notes), nil
+}
+
+// NoteRestore implements models.Store.
+func (s PostgresStore) NoteRestore(ctx context.Context, id int64) error {
+	query := `UPDATE notes SET deleted_at=NULL, deleted_by=0, updated_at=$1 WHERE id=$2 AND deleted_at IS NOT NULL;`
+
+	result, err := s.DB.Exec(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return s.movedNameConflict(ctx, id, pgtype.Int8{})
+		}
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NotePurge implements models.Store. Only notes in the trash can be purged. The note's data key
+// is destroyed and its versions are removed with it.
+func (s PostgresStore) NotePurge(ctx context.Context, id int64) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM notes WHERE id=$1 AND deleted_at IS NOT NULL;`, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	if err := deleteNoteKeys(ctx, tx, []int64{id}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// NotePurgeDeletedBefore implements models.Store.
+func (s PostgresStore) NotePurgeDeletedBefore(ctx context.Context, before time.Time) ([]int64, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return []int64{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `DELETE FROM notes WHERE deleted_at IS NOT NULL AND deleted_at < $1 RETURNING id;`, before.UTC())
+	if err != nil {
+		return []int64{}, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return []int64{}, err
+	}
+
+	if err := deleteNoteKeys(ctx, tx, ids); err != nil {
+		return []int64{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return []int64{}, err
+	}
+
+	return ids, nil
+}
+
+// deleteNoteKeys destroys the data keys of purged notes, leaving any remaining copy of their
+// values unreadable.
+func deleteNoteKeys(ctx context.Context, tx pgx.Tx, noteIDs []int64) error {
+	_, err := tx.Exec(ctx, `DELETE FROM note_keys WHERE note_id = ANY($1);`, noteIDs)
+	return err
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type User struct {
+	ID           int64              `db:"id"`
+	Username     string             `db:"username"`
+	PasswordHash string             `db:"password_hash"`
+	CreatedAt    pgtype.Timestamptz `db:"created_at"`
+	UpdatedAt    pgtype.Timestamptz `db:"updated_at"`
+}
+
+// UserCreate implements models.Store.
+func (s PostgresStore) UserCreate(ctx context.Context, username string, passwordHash string) (models.User, error) {
+	query := `INSERT INTO users (username, password_hash, created_at, updated_at) VALUES ($1, $2, $3, $4) RETURNING id;`
+
+	currTime := time.Now().UTC().Format(time.RFC3339)
+
+	var insertedID int64
+	if err := s.DB.QueryRow(ctx, query, username, passwordHash, currTime, currTime).Scan(&insertedID); err != nil {
+		if isUniqueViolation(err) {
+			return models.User{}, models.ErrAlreadyExists
+		}
+		return models.User{}, err
+	}
+
+	return models.User{
+		ID:           insertedID,
+		Username:     username,
+		PasswordHash: passwordHash,
+		CreatedAt:    currTime,
+		UpdatedAt:    currTime,
+	}, nil
+}
+
+// UserGetByID implements models.Store.
+func (s PostgresStore) UserGetByID(ctx context.Context, id int64) (models.User, error) {
+	return s.userGetOne(ctx, `SELECT * FROM users WHERE id=$1;`, id)
+}
+
+// UserGetByUsername implements models.Store.
+func (s PostgresStore) UserGetByUsername(ctx context.Context, username string) (models.User, error) {
+	return s.userGetOne(ctx, `SELECT * FROM users WHERE username=$1;`, username)
+}
+
+func (s PostgresStore) userGetOne(ctx context.Context, query string, args ...any) (models.User, error) {
+	row, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := pgx.CollectOneRow(row, pgx.RowToStructByName[User])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, models.ErrNotFound
+		}
+		return models.User{}, err
+	}
+
+	return userToModel(user), nil
+}
+
+// Converts a DB user struct to a models.User struct.
+func userToModel(user User) models.User {
+	return models.User{
+		ID:           user.ID,
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		CreatedAt:    user.CreatedAt.Time.Format(time.RFC3339),
+		UpdatedAt:    user.UpdatedAt.Time.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,333 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/oalexander6/web-app-template/models"
+)
+
+type NoteVersion struct {
+	ID           int64                       `db:"id"`
+	NoteID       int64                       `db:"note_id"`
+	Version      int                         `db:"version"`
+	Name         string                      `db:"name"`
+	Value        string                      `db:"value"`
+	CreatedAt    pgtype.Timestamptz          `db:"created_at"`
+	ReplacedAt   pgtype.Timestamptz          `db:"replaced_at"`
+	ReplacedBy   int64                       `db:"replaced_by"`
+	Fields       map[string]models.NoteField `db:"fields"`
+	CustomFields []models.CustomField        `db:"custom_fields"`
+	// the name is encrypted
+	NameEncrypted bool `db:"name_encrypted"`
+}
+
+// NoteUpdate implements models.Store. The note row is locked so concurrent updates each
+// capture the value they replaced.
+func (s PostgresStore) NoteUpdate(ctx context.Context, id int64, write models.NoteWrite) (models.Note, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var name, value string
+	var nameEncrypted bool
+	var vaultID int64
+	var folderID pgtype.Int8
+	var currentFields map[string]models.NoteField
+	var currentCustomFields []models.CustomField
+	var updatedAt time.Time
+	err = tx.QueryRow(ctx, `SELECT name, name_encrypted, vault_id, folder_id, value, fields, custom_fields, updated_at FROM notes WHERE id=$1 AND deleted_at IS NULL FOR UPDATE;`, id).
+		Scan(&name, &nameEncrypted, &vaultID, &folderID, &value, &currentFields, &currentCustomFields, &updatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Note{}, models.ErrNotFound
+		}
+		return models.Note{}, err
+	}
+
+	now := time.Now().UTC()
+
+	if write.Retain > 0 {
+		query := `INSERT INTO note_versions (note_id, version, name, value, fields, custom_fields, created_at, replaced_at, replaced_by, name_encrypted)
+			SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9 FROM note_versions WHERE note_id=$1;`
+
+		if _, err := tx.Exec(ctx, query, id, name, value, currentFields, currentCustomFields, updatedAt, now, write.ReplacedBy, nameEncrypted); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	prune := `DELETE FROM note_versions WHERE note_id=$1
+		AND version <= (SELECT MAX(version) FROM note_versions WHERE note_id=$1) - $2;`
+
+	if _, err := tx.Exec(ctx, prune, id, write.Retain); err != nil {
+		return models.Note{}, err
+	}
+
+	fields := write.Fields
+	if fields == nil {
+		fields = map[string]models.NoteField{}
+	}
+
+	customFields := write.CustomFields
+	if customFields == nil {
+		customFields = []models.CustomField{}
+	}
+
+	// a kept name leaves the name and its index columns as they are
+	rows, err := tx.Query(ctx, `UPDATE notes SET value=$2, fields=$3, custom_fields=$4, updated_at=$5, quarantined_at=NULL, compromised_at=NULL, breach_count=0,
+		name=CASE WHEN $10 THEN name ELSE $1 END,
+		name_encrypted=CASE WHEN $10 THEN name_encrypted ELSE $7 END,
+		name_token=CASE WHEN $10 THEN name_token ELSE $8 END,
+		name_index=CASE WHEN $10 THEN name_index ELSE $9 END
+		WHERE id=$6 RETURNING *;`,
+		write.Name, write.Value, fields, customFields, now, id, write.NameIndex.Encrypted, nameToken(write.NameIndex), nameLookup(write.NameIndex), write.KeepName)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	note, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Note])
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Note{}, s.nameConflict(ctx, id, vaultID, folderID.Int64, write.Name, write.NameIndex)
+		}
+		return models.Note{}, err
+	}
+
+	if err := updateSearchText(ctx, tx, id); err != nil {
+		return models.Note{}, err
+	}
+
+	if write.Rotated {
+		if _, err := tx.Exec(ctx, `UPDATE note_rotation_policies SET last_rotated_at=$1 WHERE note_id=$2;`, now, id); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Note{}, err
+	}
+
+	notes := []Note{note}
+	if err := s.loadTags(ctx, notes); err != nil {
+		return models.Note{}, err
+	}
+
+	return noteToModel(notes[0]), nil
+}
+
+// NoteGetVersions implements models.Store.
+func (s PostgresStore) NoteGetVersions(ctx context.Context, noteID int64) ([]models.NoteVersion, error) {
+	query := `SELECT * FROM note_versions WHERE note_id=$1 ORDER BY version DESC;`
+
+	rows, err := s.DB.Query(ctx, query, noteID)
+	if err != nil {
+		return []models.NoteVersion{}, err
+	}
+
+	versions, err := pgx.CollectRows(rows, pgx.RowToStructByName[NoteVersion])
+	if err != nil {
+		return []models.NoteVersion{}, err
+	}
+
+	results := make([]models.NoteVersion, len(versions))
+	for i := range versions {
+		results[i] = noteVersionToModel(versions[i])
+	}
+
+	return results, nil
+}
+
+// NoteGetVersion implements models.Store. Versions of deleted notes are not returned.
+func (s PostgresStore) NoteGetVersion(ctx context.Context, noteID int64, version int) (models.NoteVersion, error) {
+	query := `SELECT v.* FROM note_versions v JOIN notes n ON n.id = v.note_id
+		WHERE v.note_id=$1 AND v.version=$2 AND n.deleted_at IS NULL;`
+
+	rows, err := s.DB.Query(ctx, query, noteID, version)
+	if err != nil {
+		return models.NoteVersion{}, err
+	}
+
+	noteVersion, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[NoteVersion])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.NoteVersion{}, models.ErrNotFound
+		}
+		return models.NoteVersion{}, err
+	}
+
+	return noteVersionToModel(noteVersion), nil
+}
+
+// reencryptVersions rewrites the value and encrypted fields of every note version within the
+// key rotation transaction.
+func reencryptVersions(ctx context.Context, tx pgx.Tx, reencrypt func(value string) (string, error)) error {
+	if err := reencryptColumn(ctx, tx, "note version", `SELECT id, value FROM note_versions ORDER BY id FOR UPDATE;`,
+		`UPDATE note_versions SET value=$1 WHERE id=$2;`, reencrypt); err != nil {
+		return err
+	}
+
+	if err := reencryptColumn(ctx, tx, "note version name", `SELECT id, name FROM note_versions WHERE name_encrypted ORDER BY id FOR UPDATE;`,
+		`UPDATE note_versions SET name=$1 WHERE id=$2;`, reencrypt); err != nil {
+		return err
+	}
+
+	if err := reencryptFields(ctx, tx, "note version", "note_versions", reencrypt); err != nil {
+		return err
+	}
+
+	return reencryptCustomFields(ctx, tx, "note version", "note_versions", reencrypt)
+}
+
+// reencryptColumn rewrites each value returned by selectQuery as (id, value) using updateQuery
+// with (value, id).
+func reencryptColumn(ctx context.Context, tx pgx.Tx, label string, selectQuery string, updateQuery string, reencrypt func(value string) (string, error)) error {
+	rows, err := tx.Query(ctx, selectQuery)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[int64]string)
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		values[id] = value
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		newValue, err := reencrypt(values[id])
+		if err != nil {
+			return fmt.Errorf("%s %d: %w", label, id, err)
+		}
+
+		if _, err := tx.Exec(ctx, updateQuery, newValue, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reencryptFields rewrites the encrypted fields of every row of the table that has any.
+func reencryptFields(ctx context.Context, tx pgx.Tx, label string, table string, reencrypt func(value string) (string, error)) error {
+	return reencryptJSONColumn(ctx, tx, label, table, "fields", `'{}'`, func(fields map[string]models.NoteField) (bool, error) {
+		changed := false
+
+		for name, field := range fields {
+			if !field.Encrypted {
+				continue
+			}
+
+			newValue, err := reencrypt(field.Value)
+			if err != nil {
+				return false, fmt.Errorf("field %s: %w", name, err)
+			}
+
+			fields[name] = models.NoteField{Value: newValue, Encrypted: true}
+			changed = true
+		}
+
+		return changed, nil
+	})
+}
+
+// reencryptCustomFields rewrites the hidden custom fields of every row of the table that has
+// any.
+func reencryptCustomFields(ctx context.Context, tx pgx.Tx, label string, table string, reencrypt func(value string) (string, error)) error {
+	return reencryptJSONColumn(ctx, tx, label, table, "custom_fields", `'[]'`, func(fields []models.CustomField) (bool, error) {
+		changed := false
+
+		for i, field := range fields {
+			if field.Kind != models.CUSTOM_FIELD_HIDDEN {
+				continue
+			}
+
+			newValue, err := reencrypt(field.Value)
+			if err != nil {
+				return false, fmt.Errorf("custom field %q: %w", field.Name, err)
+			}
+
+			fields[i].Value = newValue
+			changed = true
+		}
+
+		return changed, nil
+	})
+}
+
+// reencryptJSONColumn decodes the JSON column of every row of the table where it isn't empty,
+// lets rewrite change it in place and writes back the rows it changed.
+func reencryptJSONColumn[T any](ctx context.Context, tx pgx.Tx, label string, table string, column string, empty string, rewrite func(value T) (bool, error)) error {
+	rows, err := tx.Query(ctx, fmt.Sprintf(`SELECT id, %s FROM %s WHERE %s <> %s ORDER BY id FOR UPDATE;`, column, table, column, empty))
+	if err != nil {
+		return err
+	}
+
+	values := make(map[int64]T)
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+		var value T
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		values[id] = value
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		changed, err := rewrite(values[id])
+		if err != nil {
+			return fmt.Errorf("%s %d: %w", label, id, err)
+		}
+
+		if !changed {
+			continue
+		}
+
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET %s=$1 WHERE id=$2;`, table, column), values[id], id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func noteVersionToModel(version NoteVersion) models.NoteVersion {
+	return models.NoteVersion{
+		NoteID:        version.NoteID,
+		Version:       version.Version,
+		Name:          version.Name,
+		Value:         version.Value,
+		Fields:        version.Fields,
+		CustomFields:  version.CustomFields,
+		CreatedAt:     version.CreatedAt.Time.Format(time.RFC3339),
+		ReplacedAt:    version.ReplacedAt.Time.Format(time.RFC3339),
+		ReplacedBy:    version.ReplacedBy,
+		NameEncrypted: version.NameEncrypted,
+	}
+}
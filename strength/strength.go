@@ -0,0 +1,747 @@
+package strength
+
+import (
+	_ "embed"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	PATTERN_DICTIONARY = "dictionary"
+	PATTERN_SEQUENCE   = "sequence"
+	PATTERN_REPEAT     = "repeat"
+	PATTERN_KEYBOARD   = "keyboard"
+	PATTERN_YEAR       = "year"
+	PATTERN_BRUTEFORCE = "bruteforce"
+
+	// scores of 3 or more resist an offline attack on a slow hash
+	SCORE_STRONG = 3
+
+	// longer values are scored on their prefix plus brute force for the rest, which keeps the
+	// search cheap and only ever underestimates
+	maxAnalyzedLength = 100
+	// guesses per character that isn't part of any pattern
+	bruteforceCardinality = 10
+	// guesses below which a pattern is treated as no easier than a few random characters
+	minGuessesSingleChar = 10
+	minGuessesMultiChar  = 50
+	// years closer than this to the current one are all considered equally likely
+	minYearSpace = 20
+	// starting keys and average neighbours on a qwerty keyboard
+	keyboardStartingPositions = 94
+	keyboardAverageDegree     = 4.6
+)
+
+// common_passwords.txt is the ranked common password list from zxcvbn-go, licensed MIT:
+// https://github.com/ccojocar/zxcvbn-go
+//
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords maps each lowercase common password to its rank, starting at 1.
+var commonPasswords = sync.OnceValues(func() (map[string]int, int) {
+	lines := strings.Split(strings.TrimSpace(commonPasswordList), "\n")
+	ranks := make(map[string]int, len(lines))
+	longest := 0
+
+	for i, line := range lines {
+		if _, ok := ranks[line]; !ok {
+			ranks[line] = i + 1
+		}
+		longest = max(longest, len([]rune(line)))
+	}
+
+	return ranks, longest
+})
+
+// l33t maps common substitutions back to the letter they replace. 1 is also tried as l.
+var l33t = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '{': 'c', '3': 'e', '6': 'g', '9': 'g', '1': 'i', '!': 'i',
+	'|': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z', '%': 'x',
+}
+
+// keyboardRows are the unshifted and shifted rows of a qwerty keyboard.
+var keyboardRows = [][2]string{
+	{"`1234567890-=", "~!@#$%^&*()_+"},
+	{"qwertyuiop[]\\", "QWERTYUIOP{}|"},
+	{"asdfghjkl;'", "ASDFGHJKL:\""},
+	{"zxcvbnm,./", "ZXCVBNM<>?"},
+}
+
+var warnings = map[string]string{
+	PATTERN_SEQUENCE: "Sequences like abc or 6543 are easy to guess",
+	PATTERN_REPEAT:   "Repeats like aaa or abcabc are easy to guess",
+	PATTERN_KEYBOARD: "Straight rows of keys like qwerty are easy to guess",
+	PATTERN_YEAR:     "Years are easy to guess",
+}
+
+// Match is a part of the value an attacker would guess as a unit.
+type Match struct {
+	Pattern string
+	Token   string
+	// rune offsets of the token, end exclusive
+	Start, End int
+	Guesses    float64
+	// rank in the common password list, for dictionary matches
+	Rank     int
+	Reversed bool
+	L33t     bool
+}
+
+// Result is the estimated strength of a value.
+type Result struct {
+	// 0 (too guessable) to 4 (very unguessable)
+	Score int
+	// base 10 logarithm of the estimated number of guesses needed
+	GuessesLog10 float64
+	Warnings     []string
+	// the cheapest combination of patterns found
+	Sequence []Match
+}
+
+// Estimate scores a value the way zxcvbn does: it finds the dictionary words, sequences,
+// repeats, keyboard rows and years in the value and estimates how many guesses the cheapest
+// combination of them, with brute force for everything else, would take.
+func Estimate(value string) Result {
+	runes := []rune(value)
+	extra := 0
+
+	if len(runes) > maxAnalyzedLength {
+		extra = len(runes) - maxAnalyzedLength
+		runes = runes[:maxAnalyzedLength]
+	}
+
+	sequence, guessesLog10 := cheapestSequence(runes, findMatches(runes))
+	guessesLog10 += float64(extra) * math.Log10(bruteforceCardinality)
+
+	result := Result{
+		Score:        scoreFromGuesses(guessesLog10),
+		GuessesLog10: math.Round(guessesLog10*10) / 10,
+		Warnings:     []string{},
+		Sequence:     sequence,
+	}
+
+	if result.Score < SCORE_STRONG {
+		result.Warnings = feedback(runes, sequence)
+	}
+
+	return result
+}
+
+// scoreFromGuesses maps guesses to the zxcvbn score thresholds of 10^3, 10^6, 10^8 and 10^10.
+func scoreFromGuesses(guessesLog10 float64) int {
+	switch {
+	case guessesLog10 < 3:
+		return 0
+	case guessesLog10 < 6:
+		return 1
+	case guessesLog10 < 8:
+		return 2
+	case guessesLog10 < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func findMatches(runes []rune) []Match {
+	var matches []Match
+
+	matches = append(matches, dictionaryMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	return matches
+}
+
+// cheapestSequence returns the non-overlapping matches covering the value that minimise
+// l! * product(guesses), where l is the number of matches, filling gaps with brute force.
+// Returns the sequence and the base 10 logarithm of its guesses.
+func cheapestSequence(runes []rune, matches []Match) ([]Match, float64) {
+	n := len(runes)
+	if n == 0 {
+		return []Match{}, 0
+	}
+
+	// every gap is a candidate brute force match, tokens are filled in once a sequence is chosen
+	candidates := make([]Match, 0, len(matches)+n*(n+1)/2)
+	for _, match := range matches {
+		match.Guesses = max(match.Guesses, minGuesses(match))
+		candidates = append(candidates, match)
+	}
+
+	for start := 0; start < n; start++ {
+		for end := start + 1; end <= n; end++ {
+			candidates = append(candidates, Match{
+				Pattern: PATTERN_BRUTEFORCE,
+				Start:   start,
+				End:     end,
+				Guesses: math.Pow(bruteforceCardinality, float64(end-start)),
+			})
+		}
+	}
+
+	byEnd := make([][]int, n+1)
+	for i, match := range candidates {
+		byEnd[match.End] = append(byEnd[match.End], i)
+	}
+
+	type step struct {
+		cost  float64
+		match int
+		set   bool
+	}
+
+	// best[end][count] is the cheapest way to cover runes[:end] with count matches
+	best := make([][]step, n+1)
+	for i := range best {
+		best[i] = make([]step, n+1)
+	}
+	best[0][0] = step{set: true}
+
+	for end := 1; end <= n; end++ {
+		for _, i := range byEnd[end] {
+			match := candidates[i]
+			cost := math.Log10(match.Guesses)
+
+			for count := 1; count <= match.Start+1; count++ {
+				prev := best[match.Start][count-1]
+				if !prev.set {
+					continue
+				}
+
+				current := &best[end][count]
+				if !current.set || prev.cost+cost < current.cost {
+					*current = step{cost: prev.cost + cost, match: i, set: true}
+				}
+			}
+		}
+	}
+
+	bestCount, bestCost := 0, math.Inf(1)
+	for count := 1; count <= n; count++ {
+		if !best[n][count].set {
+			continue
+		}
+
+		total := best[n][count].cost + logFactorial(count)
+		if total < bestCost {
+			bestCount, bestCost = count, total
+		}
+	}
+
+	sequence := make([]Match, bestCount)
+	for end, count := n, bestCount; count > 0; count-- {
+		match := candidates[best[end][count].match]
+		match.Token = string(runes[match.Start:match.End])
+		sequence[count-1] = match
+		end = match.Start
+	}
+
+	return sequence, bestCost
+}
+
+// minGuesses keeps a short pattern inside a longer value from counting as cheaper than a few
+// random characters.
+func minGuesses(match Match) float64 {
+	if match.End-match.Start == 1 {
+		return minGuessesSingleChar
+	}
+
+	return minGuessesMultiChar
+}
+
+func dictionaryMatches(runes []rune) []Match {
+	ranks, longest := commonPasswords()
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	variants := l33tVariants(lower)
+
+	var matches []Match
+
+	for start := range lower {
+		for end := start + 1; end <= len(lower) && end-start <= longest; end++ {
+			token := lower[start:end]
+			original := runes[start:end]
+
+			if rank, ok := ranks[string(token)]; ok {
+				matches = append(matches, dictionaryMatch(original, start, end, rank, false, nil))
+			}
+
+			if rank, ok := ranks[string(reverse(token))]; ok && end-start > 1 {
+				matches = append(matches, dictionaryMatch(original, start, end, rank, true, nil))
+			}
+
+			for _, variant := range variants {
+				if !variant.substituted(start, end) {
+					continue
+				}
+
+				if rank, ok := ranks[string(variant.word[start:end])]; ok {
+					matches = append(matches, dictionaryMatch(original, start, end, rank, false, variant.replaced(token)))
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+func dictionaryMatch(token []rune, start, end, rank int, reversed bool, replaced map[rune]rune) Match {
+	guesses := float64(rank) * uppercaseVariations(token) * l33tVariations(token, replaced)
+	if reversed {
+		guesses *= 2
+	}
+
+	return Match{
+		Pattern:  PATTERN_DICTIONARY,
+		Token:    string(token),
+		Start:    start,
+		End:      end,
+		Guesses:  guesses,
+		Rank:     rank,
+		Reversed: reversed,
+		L33t:     replaced != nil,
+	}
+}
+
+// l33tVariant is a value with its l33t substitutions undone.
+type l33tVariant struct {
+	word []rune
+	// number of substitutions in word[:i], for checking a token has any
+	subs []int
+	one  rune
+}
+
+func (v l33tVariant) substituted(start, end int) bool {
+	return v.subs[end]-v.subs[start] > 0
+}
+
+// replaced returns the substituted characters of the token mapped to the letter they replaced.
+func (v l33tVariant) replaced(token []rune) map[rune]rune {
+	replaced := make(map[rune]rune)
+	for _, r := range token {
+		if letter, ok := l33t[r]; ok {
+			if r == '1' {
+				letter = v.one
+			}
+			replaced[r] = letter
+		}
+	}
+
+	return replaced
+}
+
+// l33tVariants returns the value with its substitutions undone, once reading 1 as i and once as
+// l when it contains a 1. Returns nothing when the value has no substitutions.
+func l33tVariants(value []rune) []l33tVariant {
+	var variants []l33tVariant
+
+	for _, one := range []rune{'i', 'l'} {
+		variant := l33tVariant{word: make([]rune, len(value)), subs: make([]int, len(value)+1), one: one}
+
+		for i, r := range value {
+			variant.word[i] = r
+			variant.subs[i+1] = variant.subs[i]
+
+			if letter, ok := l33t[r]; ok {
+				if r == '1' {
+					letter = one
+				}
+				variant.word[i] = letter
+				variant.subs[i+1]++
+			}
+		}
+
+		if variant.subs[len(value)] == 0 {
+			return nil
+		}
+
+		variants = append(variants, variant)
+
+		if !strings.ContainsRune(string(value), '1') {
+			break
+		}
+	}
+
+	return variants
+}
+
+// uppercaseVariations returns how many ways the token's letters could have been capitalised
+// given how many of them are upper case. Capitalising the first, last or every letter is common
+// enough to only double the guesses.
+func uppercaseVariations(token []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(token[0]) || unicode.IsUpper(token[len(token)-1]))) {
+		return 2
+	}
+
+	return variations(upper, lower)
+}
+
+// l33tVariations returns how many ways the substitutions could have been applied, counting each
+// substituted character against the occurrences of the letter it replaced.
+func l33tVariations(token []rune, replaced map[rune]rune) float64 {
+	if replaced == nil {
+		return 1
+	}
+
+	total := 1.0
+	for sub, letter := range replaced {
+		subbed, unsubbed := 0, 0
+		for _, r := range token {
+			switch unicode.ToLower(r) {
+			case sub:
+				subbed++
+			case letter:
+				unsubbed++
+			}
+		}
+
+		if unsubbed == 0 {
+			total *= 2
+		} else {
+			total *= variations(subbed, unsubbed)
+		}
+	}
+
+	return total
+}
+
+// variations returns the sum of (a+b choose i) for i from 1 to min(a, b).
+func variations(a, b int) float64 {
+	total := 0.0
+	for i := 1; i <= min(a, b); i++ {
+		total += binomial(a+b, i)
+	}
+
+	return max(total, 1)
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+
+	return result
+}
+
+// sequenceMatches finds runs of 3 or more letters or digits that step up or down by one, such as
+// abc or 9876.
+func sequenceMatches(runes []rune) []Match {
+	var matches []Match
+
+	for start := 0; start < len(runes)-2; {
+		delta := runes[start+1] - runes[start]
+		end := start + 1
+
+		if (delta == 1 || delta == -1) && sameClass(runes[start], runes[start+1]) {
+			for end+1 <= len(runes)-1 && runes[end+1]-runes[end] == delta && sameClass(runes[end], runes[end+1]) {
+				end++
+			}
+		}
+
+		if end-start+1 >= 3 {
+			matches = append(matches, sequenceMatch(runes, start, end+1, delta < 0))
+			start = end
+			continue
+		}
+
+		start++
+	}
+
+	return matches
+}
+
+func sequenceMatch(runes []rune, start, end int, descending bool) Match {
+	first := runes[start]
+	base := 26.0
+
+	switch {
+	case strings.ContainsRune("aAzZ019", first):
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	}
+
+	if descending {
+		base *= 2
+	}
+
+	return Match{
+		Pattern: PATTERN_SEQUENCE,
+		Token:   string(runes[start:end]),
+		Start:   start,
+		End:     end,
+		Guesses: base * float64(end-start),
+	}
+}
+
+func sameClass(a, b rune) bool {
+	return (unicode.IsLower(a) && unicode.IsLower(b)) ||
+		(unicode.IsUpper(a) && unicode.IsUpper(b)) ||
+		(unicode.IsDigit(a) && unicode.IsDigit(b))
+}
+
+// repeatMatches finds a block repeated back to back, at least 3 times for a single character
+// and at least twice otherwise. The guesses are those of the block times the repetitions.
+func repeatMatches(runes []rune) []Match {
+	var matches []Match
+	blocks := make(map[string]float64)
+
+	for start := range runes {
+		// the smallest block that repeats from here covers any larger one
+		for size := 1; start+2*size <= len(runes); size++ {
+			block := string(runes[start : start+size])
+			count := 1
+
+			for next := start + size; next+size <= len(runes) && string(runes[next:next+size]) == block; next += size {
+				count++
+			}
+
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+
+			guesses, ok := blocks[block]
+			if !ok {
+				guesses = blockGuesses(runes[start : start+size])
+				blocks[block] = guesses
+			}
+
+			end := start + size*count
+			matches = append(matches, Match{
+				Pattern: PATTERN_REPEAT,
+				Token:   string(runes[start:end]),
+				Start:   start,
+				End:     end,
+				Guesses: guesses * float64(count),
+			})
+
+			break
+		}
+	}
+
+	return matches
+}
+
+func blockGuesses(block []rune) float64 {
+	if len(block) == 1 {
+		return cardinality(block[0])
+	}
+
+	_, guessesLog10 := cheapestSequence(block, findMatches(block))
+
+	return math.Pow(10, guessesLog10)
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLetter(r):
+		return 26
+	default:
+		return 33
+	}
+}
+
+// keyboardMatches finds runs of 3 or more keys next to each other in the same keyboard row,
+// such as qwerty or ;lkj, in either direction.
+func keyboardMatches(runes []rune) []Match {
+	var matches []Match
+
+	for start := 0; start < len(runes)-2; {
+		row, col, ok := keyPosition(runes[start])
+		end := start + 1
+		direction := 0
+
+		for ok && end < len(runes) {
+			nextRow, nextCol, nextOK := keyPosition(runes[end])
+			step := nextCol - col
+
+			if !nextOK || nextRow != row || (step != 1 && step != -1) || (direction != 0 && step != direction) {
+				break
+			}
+
+			direction, col = step, nextCol
+			end++
+		}
+
+		if end-start >= 3 {
+			matches = append(matches, keyboardMatch(runes, start, end))
+			start = end - 1
+			continue
+		}
+
+		start++
+	}
+
+	return matches
+}
+
+func keyboardMatch(runes []rune, start, end int) Match {
+	shifted, unshifted := 0, 0
+	for _, r := range runes[start:end] {
+		if _, _, isShifted := keyShift(r); isShifted {
+			shifted++
+		} else {
+			unshifted++
+		}
+	}
+
+	guesses := keyboardStartingPositions * keyboardAverageDegree * float64(end-start-1)
+	if shifted > 0 {
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			guesses *= variations(shifted, unshifted)
+		}
+	}
+
+	return Match{
+		Pattern: PATTERN_KEYBOARD,
+		Token:   string(runes[start:end]),
+		Start:   start,
+		End:     end,
+		Guesses: guesses,
+	}
+}
+
+func keyPosition(r rune) (row, col int, ok bool) {
+	row, col, _ = keyShift(r)
+	return row, col, col >= 0
+}
+
+// keyShift returns the row and column of the key and whether shift is needed to type it. The
+// column is -1 if the character isn't on the keyboard.
+func keyShift(r rune) (row, col int, shifted bool) {
+	for i, keys := range keyboardRows {
+		if col := strings.IndexRune(keys[0], r); col >= 0 {
+			return i, col, false
+		}
+		if col := strings.IndexRune(keys[1], r); col >= 0 {
+			return i, col, true
+		}
+	}
+
+	return 0, -1, false
+}
+
+// yearMatches finds years from 1900 to 2099, guessed by their distance from the current year.
+func yearMatches(runes []rune) []Match {
+	var matches []Match
+	current := time.Now().Year()
+
+	for start := 0; start+4 <= len(runes); start++ {
+		year := 0
+		for _, r := range runes[start : start+4] {
+			if r < '0' || r > '9' {
+				year = -1
+				break
+			}
+			year = year*10 + int(r-'0')
+		}
+
+		if year < 1900 || year > 2099 {
+			continue
+		}
+
+		distance := year - current
+		if distance < 0 {
+			distance = -distance
+		}
+
+		matches = append(matches, Match{
+			Pattern: PATTERN_YEAR,
+			Token:   string(runes[start : start+4]),
+			Start:   start,
+			End:     start + 4,
+			Guesses: float64(max(distance, minYearSpace)),
+		})
+	}
+
+	return matches
+}
+
+// feedback explains the patterns that make a value weak, most significant first.
+func feedback(runes []rune, sequence []Match) []string {
+	result := []string{}
+	seen := make(map[string]bool)
+	add := func(warning string) {
+		if !seen[warning] {
+			seen[warning] = true
+			result = append(result, warning)
+		}
+	}
+
+	if len(sequence) == 1 && sequence[0].Pattern == PATTERN_DICTIONARY {
+		match := sequence[0]
+		if match.Reversed || match.L33t || uppercaseVariations([]rune(match.Token)) > 1 {
+			add("This is similar to a commonly used password")
+		} else if match.Rank <= 100 {
+			add("This is a top-100 common password")
+		} else {
+			add("This is a very common password")
+		}
+	}
+
+	// longest patterns first
+	ordered := append([]Match{}, sequence...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].End-ordered[i].Start > ordered[j].End-ordered[j].Start
+	})
+
+	for _, match := range ordered {
+		switch match.Pattern {
+		case PATTERN_DICTIONARY:
+			add("Common passwords and words are easy to guess, even with substitutions like @ for a")
+		case PATTERN_BRUTEFORCE:
+		default:
+			add(warnings[match.Pattern])
+		}
+	}
+
+	if len(runes) < 8 {
+		add("Short values are easy to guess, use at least 12 characters")
+	}
+
+	return result
+}
+
+func reverse(runes []rune) []rune {
+	reversed := make([]rune, len(runes))
+	for i, r := range runes {
+		reversed[len(runes)-1-i] = r
+	}
+
+	return reversed
+}
+
+func logFactorial(n int) float64 {
+	lgamma, _ := math.Lgamma(float64(n + 1))
+	return lgamma / math.Ln10
+}
@@ -0,0 +1,90 @@
+package strength
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateScores(t *testing.T) {
+	tests := []struct {
+		value    string
+		maxScore int
+		minScore int
+	}{
+		{"password", 0, 0},
+		{"P@ssw0rd", 1, 0},
+		{"drowssap", 1, 0},
+		{"qwertyuiop", 1, 0},
+		{"abcdefgh", 1, 0},
+		{"aaaaaaaaaaaa", 1, 0},
+		{"monkey1990", 2, 0},
+		{"xK9#mQ2$vL7!pR4w", 4, 4},
+		{"correct-horse-battery-staple-jump", 4, 3},
+	}
+
+	for _, tc := range tests {
+		result := Estimate(tc.value)
+		if result.Score < tc.minScore || result.Score > tc.maxScore {
+			t.Errorf("%q: expected a score from %d to %d, got %d (%+v)", tc.value, tc.minScore, tc.maxScore, result.Score, result.Sequence)
+		}
+	}
+}
+
+func TestEstimateSequenceCoversValue(t *testing.T) {
+	value := "Tr0ub4dor&3dfghjk2019"
+	result := Estimate(value)
+
+	var b strings.Builder
+	end := 0
+	for _, match := range result.Sequence {
+		if match.Start != end {
+			t.Fatalf("Expected matches to be contiguous, got a gap at %d", end)
+		}
+		b.WriteString(match.Token)
+		end = match.End
+	}
+
+	if b.String() != value {
+		t.Fatalf("Expected the sequence to cover %q, got %q", value, b.String())
+	}
+
+	patterns := make(map[string]bool)
+	for _, match := range result.Sequence {
+		patterns[match.Pattern] = true
+	}
+
+	for _, pattern := range []string{PATTERN_KEYBOARD, PATTERN_YEAR} {
+		if !patterns[pattern] {
+			t.Errorf("Expected a %s match in %+v", pattern, result.Sequence)
+		}
+	}
+}
+
+func TestEstimateWarnings(t *testing.T) {
+	if warnings := Estimate("password").Warnings; len(warnings) == 0 || warnings[0] != "This is a top-100 common password" {
+		t.Fatalf("Expected a common password warning, got %v", warnings)
+	}
+
+	if warnings := Estimate("P@ssword").Warnings; len(warnings) == 0 || warnings[0] != "This is similar to a commonly used password" {
+		t.Fatalf("Expected a similar password warning, got %v", warnings)
+	}
+
+	if warnings := Estimate("xK9#mQ2$vL7!pR4w").Warnings; len(warnings) != 0 {
+		t.Fatalf("Expected no warnings for a strong value, got %v", warnings)
+	}
+}
+
+func TestEstimateLongValue(t *testing.T) {
+	short := Estimate(strings.Repeat("a", maxAnalyzedLength))
+	long := Estimate(strings.Repeat("a", maxAnalyzedLength+10))
+
+	if long.GuessesLog10 <= short.GuessesLog10 {
+		t.Fatalf("Expected characters past the analyzed prefix to add guesses, got %.1f and %.1f", short.GuessesLog10, long.GuessesLog10)
+	}
+}
+
+func TestEstimateEmpty(t *testing.T) {
+	if result := Estimate(""); result.Score != 0 || result.GuessesLog10 != 0 {
+		t.Fatalf("Expected an empty value to score 0, got %+v", result)
+	}
+}